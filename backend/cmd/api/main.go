@@ -65,7 +65,7 @@ func main() {
 	rateLimiter := middleware.NewRateLimiter(redisClient)
 	lastSeenMiddleware := middleware.NewLastSeenMiddleware(db, redisClient)
 
-	workerService := services.NewWorkerService(db)
+	workerService := services.NewWorkerService(db, redisClient)
 	workerService.Start(context.Background())
 
 	app := fiber.New(fiber.Config{
@@ -138,6 +138,8 @@ func main() {
 	messages.Get("/:id", messageHandler.GetMessage)
 	messages.Patch("/:id", messageHandler.EditMessage)
 	messages.Delete("/:id", messageHandler.DeleteMessage)
+	messages.Get("/:id/code-blocks", messageHandler.GetMessageCodeBlocks)
+	messages.Post("/:id/code-blocks/:index/promote", messageHandler.PromoteCodeBlock)
 
 	api.Get("/media/*", auth.Protected(), messageHandler.GetMediaFile)
 
@@ -149,32 +151,122 @@ func main() {
 	chats.Get("/:id", chatHandler.GetChat)
 	chats.Get("/:id/messages", chatHandler.GetChatMessages)
 	chats.Post("/:id/read", chatHandler.MarkAsRead)
+	chats.Patch("/:id/settings", chatHandler.UpdateChatSettings)
+	chats.Patch("/:id/restrictions", chatHandler.UpdateChatRestrictions)
 	chats.Post("/:id/members", chatHandler.AddMember)
 	chats.Delete("/:id/members/:userId", chatHandler.RemoveMember)
-
-	channelHandler := handlers.NewChannelHandler(db)
+	chats.Post("/:id/members/:userId/restrict", chatHandler.RestrictMember)
+	chats.Delete("/:id/members/:userId/restrict", chatHandler.UnrestrictMember)
+	chats.Get("/:id/bans", chatHandler.ListSanctions)
+	chats.Post("/:id/bans", chatHandler.BanMember)
+	chats.Delete("/:id/bans/:userId", chatHandler.UnbanMember)
+
+	topicHandler := handlers.NewTopicHandler(db, redisClient)
+	chats.Patch("/:id/forum", topicHandler.SetForumMode)
+	chats.Get("/:id/topics", topicHandler.ListTopics)
+	chats.Post("/:id/topics", topicHandler.CreateTopic)
+	chats.Patch("/:id/topics/:topicId", topicHandler.UpdateTopic)
+	chats.Delete("/:id/topics/:topicId", topicHandler.DeleteTopic)
+	chats.Post("/:id/topics/:topicId/read", topicHandler.MarkTopicRead)
+
+	exportHandler := handlers.NewExportHandler(db)
+	chats.Post("/:id/export", exportHandler.CreateExport)
+	chats.Get("/:id/exports/:exportId", exportHandler.GetExport)
+	chats.Get("/:id/exports/:exportId/download", exportHandler.DownloadExport)
+
+	folderHandler := handlers.NewFolderHandler(db, redisClient)
+	folders := api.Group("/folders", auth.Protected(), lastSeenMiddleware.UpdateLastSeen())
+	folders.Get("/", folderHandler.ListFolders)
+	folders.Post("/", folderHandler.CreateFolder)
+	folders.Patch("/:id", folderHandler.UpdateFolder)
+	folders.Delete("/:id", folderHandler.DeleteFolder)
+
+	channelHandler := handlers.NewChannelHandler(db, redisClient, wsHandler)
 	channels := api.Group("/channels", auth.Protected(), lastSeenMiddleware.UpdateLastSeen())
 	channels.Post("/", channelHandler.CreateChannel)
+	channels.Get("/feed", channelHandler.GetFeed)
+	channels.Get("/search", channelHandler.SearchChannels)
+	channels.Get("/mine", channelHandler.ListMyChannels)
+	channels.Get("/resolve/:handle", channelHandler.ResolveHandle)
+	channels.Get("/join/:token", channelHandler.PreviewInvite)
+	channels.Post("/join/:token", channelHandler.JoinByInvite)
 	channels.Get("/:id", channelHandler.GetChannel)
+	channels.Patch("/:id", channelHandler.UpdateChannel)
 	channels.Post("/:id/subscribe", channelHandler.Subscribe)
 	channels.Delete("/:id/subscribe", channelHandler.Unsubscribe)
-
-	wikiHandler := handlers.NewWikiHandler(db)
+	channels.Get("/:id/stats", channelHandler.GetStats)
+	channels.Get("/:id/admins", channelHandler.ListAdmins)
+	channels.Put("/:id/admins/:userId", channelHandler.SetAdmin)
+	channels.Delete("/:id/admins/:userId", channelHandler.RemoveAdmin)
+	channels.Get("/:id/invites", channelHandler.ListInviteLinks)
+	channels.Post("/:id/invites", channelHandler.CreateInviteLink)
+	channels.Delete("/:id/invites/:inviteId", channelHandler.RevokeInviteLink)
+	channels.Get("/:id/join-requests", channelHandler.ListJoinRequests)
+	channels.Post("/:id/join-requests/:requestId/approve", channelHandler.ApproveJoinRequest)
+	channels.Post("/:id/join-requests/:requestId/reject", channelHandler.RejectJoinRequest)
+	channels.Put("/:id/discussion", channelHandler.LinkDiscussion)
+	channels.Delete("/:id/discussion", channelHandler.UnlinkDiscussion)
+	channels.Get("/:id/subscribers", channelHandler.ListSubscribers)
+	channels.Delete("/:id/subscribers/:userId", channelHandler.RemoveSubscriber)
+	channels.Get("/:id/posts", channelHandler.ListPosts)
+	channels.Post("/:id/posts/views", channelHandler.RecordPostViews)
+	channels.Post("/:id/posts", channelHandler.CreatePost)
+	channels.Post("/:id/posts/upload", rateLimiter.UploadRateLimit(), channelHandler.UploadPostMedia)
+	channels.Patch("/:id/posts/:postId", channelHandler.UpdatePost)
+	channels.Get("/:id/posts/:postId/comments", channelHandler.ListComments)
+	channels.Post("/:id/posts/:postId/comments", channelHandler.CreateComment)
+	channels.Delete("/:id/posts/:postId", channelHandler.DeletePost)
+
+	wikiHandler := handlers.NewWikiHandler(db, redisClient, wsHandler)
 	wiki := api.Group("/wiki", auth.Protected(), lastSeenMiddleware.UpdateLastSeen())
 	wiki.Post("/", wikiHandler.CreateWikiPage)
+	wiki.Get("/search", wikiHandler.SearchAllWikis)
+	wiki.Get("/:channelId/search", wikiHandler.SearchWiki)
+	wiki.Get("/:channelId/permissions", wikiHandler.GetWikiPermissions)
+	wiki.Get("/:channelId/watch", wikiHandler.GetWikiWatches)
+	wiki.Post("/:channelId/watch", wikiHandler.WatchWiki)
+	wiki.Delete("/:channelId/watch", wikiHandler.UnwatchWiki)
+	wiki.Get("/:channelId/templates", wikiHandler.ListWikiTemplates)
+	wiki.Post("/:channelId/templates", wikiHandler.CreateWikiTemplate)
+	wiki.Get("/:channelId/export", wikiHandler.ExportWiki)
+	wiki.Post("/:channelId/import", wikiHandler.ImportWiki)
+	wiki.Post("/:channelId/reorder", wikiHandler.ReorderWikiPages)
+	wiki.Get("/:channelId/tree", wikiHandler.GetWikiTree)
 	wiki.Get("/:channelId/:slug", wikiHandler.GetWikiPage)
 	wiki.Patch("/:channelId/:slug", wikiHandler.UpdateWikiPage)
 	wiki.Get("/:channelId/:slug/revisions", wikiHandler.GetWikiRevisions)
+	wiki.Get("/:channelId/:slug/diff", wikiHandler.GetWikiDiff)
+	wiki.Post("/:channelId/:slug/revert/:revision", wikiHandler.RevertWikiPage)
+	wiki.Get("/:channelId/:slug/backlinks", wikiHandler.GetWikiBacklinks)
+	wiki.Post("/:channelId/:slug/rename", wikiHandler.RenameWikiPage)
+	wiki.Post("/:channelId/:slug/move", wikiHandler.MoveWikiPage)
+	wiki.Post("/:channelId/:slug/lock", wikiHandler.LockWikiPage)
+	wiki.Delete("/:channelId/:slug/lock", wikiHandler.UnlockWikiPage)
+	wiki.Post("/:channelId/:slug/watch", wikiHandler.WatchWikiPage)
+	wiki.Delete("/:channelId/:slug/watch", wikiHandler.UnwatchWikiPage)
+	wiki.Get("/:channelId/:slug/attachments", wikiHandler.ListWikiAttachments)
+	wiki.Post("/:channelId/:slug/attachments", wikiHandler.UploadWikiAttachment)
+	wiki.Delete("/:channelId/:slug/attachments/:attachmentId", wikiHandler.DeleteWikiAttachment)
+	wiki.Patch("/:channelId/templates/:templateId", wikiHandler.UpdateWikiTemplate)
+	wiki.Delete("/:channelId/templates/:templateId", wikiHandler.DeleteWikiTemplate)
 	wiki.Delete("/:channelId/:slug", wikiHandler.DeleteWikiPage)
 	wiki.Get("/:channelId", wikiHandler.ListWikiPages)
-	wiki.Get("/:channelId/tree", wikiHandler.GetWikiTree)
 
-	codeHandler := handlers.NewCodeHandler(db)
+	codeHandler := handlers.NewCodeHandler(db, redisClient)
 	code := api.Group("/code", auth.Protected(), lastSeenMiddleware.UpdateLastSeen())
 	code.Post("/", codeHandler.CreateCodeSnippet)
 	code.Get("/:id", codeHandler.GetCodeSnippet)
 	code.Patch("/:id", codeHandler.UpdateCodeSnippet)
 	code.Delete("/:id", codeHandler.DeleteCodeSnippet)
+	code.Get("/:id/versions", codeHandler.ListCodeSnippetVersions)
+	code.Get("/:id/diff", codeHandler.GetCodeSnippetDiff)
+	code.Post("/:id/format", codeHandler.FormatCodeSnippet)
+	code.Get("/:id/comments", codeHandler.ListCodeComments)
+	code.Post("/:id/comments", codeHandler.CreateCodeComment)
+	code.Patch("/:id/comments/:commentId", codeHandler.UpdateCodeComment)
+	code.Delete("/:id/comments/:commentId", codeHandler.DeleteCodeComment)
+	code.Post("/:id/comments/:commentId/resolve", codeHandler.ResolveCodeComment)
+	code.Post("/:id/comments/:commentId/unresolve", codeHandler.UnresolveCodeComment)
 	code.Get("/chat/:chatId", codeHandler.ListCodeSnippetsByChat)
 	code.Get("/message/:messageId", codeHandler.GetCodeSnippetByMessage)
 
@@ -188,7 +280,7 @@ func main() {
 	tempRoles.Get("/user/:userId", tempRoleHandler.ListUserRoles)
 	tempRoles.Get("/check/:userId/:targetId", tempRoleHandler.CheckUserPermission)
 
-	rssHandler := handlers.NewRSSHandler(db)
+	rssHandler := handlers.NewRSSHandler(db, redisClient)
 	rss := api.Group("/rss", auth.Protected(), lastSeenMiddleware.UpdateLastSeen())
 	rss.Post("/", rssHandler.AddRSSFeed)
 	rss.Get("/:id", rssHandler.GetRSSFeed)
@@ -3,6 +3,7 @@ module github.com/messenger/backend
 go 1.21
 
 require (
+    github.com/alecthomas/chroma/v2 v2.14.0
     github.com/gofiber/fiber/v3 v3.0.0-beta.3
     github.com/gofiber/websocket/v2 v2.2.1
     github.com/golang-jwt/jwt/v5 v5.2.0
@@ -21,6 +22,7 @@ require (
     github.com/andybalholm/brotli v1.1.0 // indirect
     github.com/cespare/xxhash/v2 v2.2.0 // indirect
     github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+    github.com/dlclark/regexp2 v1.11.0 // indirect
     github.com/fasthttp/websocket v1.5.3 // indirect
     github.com/gofiber/fiber/v2 v2.46.0 // indirect
     github.com/gofiber/utils/v2 v2.0.0-beta.4 // indirect
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+// ListAdmins returns the owner and admins of a channel with their rights.
+func (h *ChannelHandler) ListAdmins(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if !h.channelService.CanManage(channel, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only channel admins can list admins",
+		})
+	}
+
+	h.db.Preload("Owner").First(channel, channel.ID)
+
+	admins, err := h.channelService.ListAdmins(c.Context(), channel)
+	if err != nil {
+		log.Printf("Error listing channel admins: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list admins",
+		})
+	}
+
+	return c.JSON(admins)
+}
+
+// SetAdmin promotes a subscriber to admin or changes an admin's rights.
+// Owner only.
+func (h *ChannelHandler) SetAdmin(c fiber.Ctx) error {
+	uid, targetID, channel := h.parseAdminTarget(c)
+	if channel == nil {
+		return nil
+	}
+
+	if channel.OwnerID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the channel owner can manage admins",
+		})
+	}
+
+	if targetID == channel.OwnerID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The owner already has every right",
+		})
+	}
+
+	var req models.SetChannelAdminRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	admin, err := h.channelService.SetAdmin(c.Context(), channel.ID, targetID, uid, req.Rights)
+	if err != nil {
+		if errors.Is(err, services.ErrNotSubscribed) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Only subscribers can become admins",
+			})
+		}
+		log.Printf("Error setting channel admin: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to set admin",
+		})
+	}
+
+	return c.JSON(admin.ToResponse())
+}
+
+// RemoveAdmin demotes an admin. The owner may demote anyone; admins may step
+// down themselves.
+func (h *ChannelHandler) RemoveAdmin(c fiber.Ctx) error {
+	uid, targetID, channel := h.parseAdminTarget(c)
+	if channel == nil {
+		return nil
+	}
+
+	if channel.OwnerID != uid && targetID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the channel owner can manage admins",
+		})
+	}
+
+	removed, err := h.channelService.RemoveAdmin(c.Context(), channel.ID, targetID)
+	if err != nil {
+		log.Printf("Error removing channel admin: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove admin",
+		})
+	}
+	if !removed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Admin not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Admin removed successfully",
+	})
+}
+
+// ListSubscribers pages through a channel's subscribers. Requires
+// CanManageSubscribers.
+func (h *ChannelHandler) ListSubscribers(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	rights, _ := h.channelService.AdminRights(channel, uid)
+	if !rights.CanManageSubscribers {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot manage subscribers of this channel",
+		})
+	}
+
+	limit := 50
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 200 {
+		limit = v
+	}
+	offset := 0
+	if v, err := strconv.Atoi(c.Query("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	subscribers, total, err := h.channelService.ListSubscribers(c.Context(), channel.ID, limit, offset)
+	if err != nil {
+		log.Printf("Error listing channel subscribers: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list subscribers",
+		})
+	}
+
+	response := make([]fiber.Map, len(subscribers))
+	for i, sub := range subscribers {
+		entry := fiber.Map{
+			"user_id":       sub.UserID,
+			"subscribed_at": sub.SubscribedAt,
+		}
+		if sub.User != nil {
+			entry["user"] = sub.User.ToResponse()
+		}
+		response[i] = entry
+	}
+
+	return c.JSON(fiber.Map{
+		"subscribers": response,
+		"count":       len(response),
+		"total":       total,
+	})
+}
+
+// RemoveSubscriber removes a user from the channel. Requires
+// CanManageSubscribers; only the owner can remove another admin.
+func (h *ChannelHandler) RemoveSubscriber(c fiber.Ctx) error {
+	uid, targetID, channel := h.parseAdminTarget(c)
+	if channel == nil {
+		return nil
+	}
+
+	rights, _ := h.channelService.AdminRights(channel, uid)
+	if !rights.CanManageSubscribers {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot manage subscribers of this channel",
+		})
+	}
+
+	if targetID == channel.OwnerID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The owner cannot be removed",
+		})
+	}
+	if _, targetIsAdmin := h.channelService.AdminRights(channel, targetID); targetIsAdmin && channel.OwnerID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the channel owner can remove an admin",
+		})
+	}
+
+	removed, err := h.channelService.Unsubscribe(c.Context(), channel.ID, targetID)
+	if err != nil {
+		log.Printf("Error removing channel subscriber: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove subscriber",
+		})
+	}
+	if !removed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Subscriber not found",
+		})
+	}
+
+	h.wsHandler.NotifyChannelSubscription(targetID.String(), channel.ID.String(), false)
+
+	return c.JSON(fiber.Map{
+		"message": "Subscriber removed successfully",
+	})
+}
+
+// parseAdminTarget reads the caller, the channel and the :userId route
+// parameter. On failure it writes the error response and returns a nil
+// channel.
+func (h *ChannelHandler) parseAdminTarget(c fiber.Ctx) (uuid.UUID, uuid.UUID, *models.Channel) {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+		return uuid.Nil, uuid.Nil, nil
+	}
+
+	targetID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+		return uuid.Nil, uuid.Nil, nil
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return uuid.Nil, uuid.Nil, nil
+	}
+
+	return uid, targetID, channel
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+// LinkDiscussion makes a group chat the channel's discussion group. The
+// caller must own the channel and be an admin of the group.
+func (h *ChannelHandler) LinkDiscussion(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if channel.OwnerID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the channel owner can link a discussion group",
+		})
+	}
+
+	var req models.LinkDiscussionRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	chatID, err := uuid.Parse(req.ChatID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid chat ID",
+		})
+	}
+
+	var member models.ChatMember
+	if err := h.db.Where("chat_id = ? AND user_id = ?", chatID, uid).First(&member).Error; err != nil ||
+		member.Role != models.MemberRoleAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You must be an admin of the discussion group",
+		})
+	}
+
+	if err := h.channelService.LinkDiscussion(c.Context(), channel, chatID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrDiscussionNotGroup):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Only group chats can be discussion groups",
+			})
+		case errors.Is(err, services.ErrDiscussionInUse):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "This group is already linked to another channel",
+			})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Chat not found",
+			})
+		}
+		log.Printf("Error linking discussion group: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to link discussion group",
+		})
+	}
+
+	h.db.Preload("Owner").First(channel, channel.ID)
+
+	return c.JSON(channel.ToResponse())
+}
+
+func (h *ChannelHandler) UnlinkDiscussion(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if channel.OwnerID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the channel owner can unlink the discussion group",
+		})
+	}
+
+	if err := h.channelService.UnlinkDiscussion(c.Context(), channel); err != nil {
+		log.Printf("Error unlinking discussion group: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to unlink discussion group",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Discussion group unlinked",
+	})
+}
+
+// ListComments pages through the comments on a post, oldest first.
+func (h *ChannelHandler) ListComments(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if !h.channelService.CanRead(channel, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+	}
+
+	post := h.loadPost(c, channel.ID)
+	if post == nil {
+		return nil
+	}
+
+	root, err := h.channelService.DiscussionRoot(c.Context(), post)
+	if err != nil {
+		return commentsError(c, err)
+	}
+
+	limit := 50
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+	offset := 0
+	if v, err := strconv.Atoi(c.Query("offset")); err == nil && v >= 0 {
+		offset = v
+	}
+
+	comments, total, err := h.channelService.ListComments(c.Context(), root.ID, limit, offset)
+	if err != nil {
+		log.Printf("Error listing post comments: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list comments",
+		})
+	}
+
+	responses := make([]models.MessageResponse, len(comments))
+	for i, msg := range comments {
+		responses[i] = msg.ToResponse()
+	}
+
+	return c.JSON(fiber.Map{
+		"chat_id":  root.ChatID,
+		"root_id":  root.ID,
+		"comments": responses,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+		"has_more": int64(offset+len(comments)) < total,
+	})
+}
+
+// CreateComment posts a comment on a channel post. The comment is an ordinary
+// message in the post's discussion thread, so the group's bans, read-only
+// restrictions and slow mode apply. Commenters join the group on first use.
+func (h *ChannelHandler) CreateComment(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if !h.channelService.IsSubscribed(channel.ID, uid) && !h.channelService.CanManage(channel, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only subscribers can comment",
+		})
+	}
+
+	post := h.loadPost(c, channel.ID)
+	if post == nil {
+		return nil
+	}
+
+	root, err := h.channelService.DiscussionRoot(c.Context(), post)
+	if err != nil {
+		return commentsError(c, err)
+	}
+
+	var req models.CreateCommentRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Content == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Content is required",
+		})
+	}
+
+	replyToID := root.ID
+	if req.ReplyToID != nil {
+		rid, err := uuid.Parse(*req.ReplyToID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid reply ID",
+			})
+		}
+		threadRoot, err := h.chatService.ResolveThreadRoot(c.Context(), root.ChatID, &rid)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		if threadRoot == nil || *threadRoot != root.ID {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": services.ErrReplyNotInThread.Error(),
+			})
+		}
+		replyToID = rid
+	}
+
+	member, err := h.chatService.JoinDiscussion(c.Context(), root.ChatID, uid)
+	if err != nil {
+		return postingRestrictedResponse(c, err)
+	}
+
+	if err := h.chatService.CheckPostingAllowed(c.Context(), member, models.MessageTypeText, req.Content); err != nil {
+		return postingRestrictedResponse(c, err)
+	}
+
+	comment := models.Message{
+		SenderID:     &uid,
+		ChatID:       root.ChatID,
+		Content:      req.Content,
+		MessageType:  models.MessageTypeText,
+		ReplyToID:    &replyToID,
+		TopicID:      root.TopicID,
+		ThreadRootID: &root.ID,
+	}
+
+	if err := h.db.Create(&comment).Error; err != nil {
+		h.chatService.ReleaseSlowMode(c.Context(), member)
+		log.Printf("Error creating comment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to post comment",
+		})
+	}
+
+	if err := h.db.Preload("Sender").First(&comment, comment.ID).Error; err != nil {
+		log.Printf("Error loading comment with sender: %v", err)
+	}
+
+	h.publishChatMessage(c, &comment)
+
+	event, err := json.Marshal(map[string]interface{}{
+		"type":          "channel_post_comments",
+		"channel_id":    channel.ID,
+		"post_id":       post.ID,
+		"comment_count": h.channelService.CountComments(c.Context(), post),
+	})
+	if err == nil {
+		h.wsHandler.BroadcastToChannel(channel.ID.String(), event)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(comment.ToResponse())
+}
+
+// publishChatMessage delivers a new message to the chat's members the same
+// way the messages endpoint does.
+func (h *ChannelHandler) publishChatMessage(c fiber.Ctx, message *models.Message) {
+	messageJSON, err := json.Marshal(message.ToResponse())
+	if err == nil {
+		h.redis.Publish(c.Context(), "chat:"+message.ChatID.String(), messageJSON)
+	}
+
+	h.chatService.HandleNewMessage(c.Context(), message)
+}
+
+func commentsError(c fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrCommentsNotAvailable) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Comments are not available for this post",
+		})
+	}
+	log.Printf("Error loading discussion thread: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Database error",
+	})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// SearchChannels looks up public channels by name, handle or description.
+// Private channels are never returned.
+func (h *ChannelHandler) SearchChannels(c fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	if len([]rune(q)) < 2 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Search query must be at least 2 characters",
+		})
+	}
+
+	limit := 20
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > 50 {
+		limit = 50
+	}
+	offset := 0
+	if v, err := strconv.Atoi(c.Query("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	channels, total, err := h.channelService.SearchChannels(c.Context(), q, limit, offset)
+	if err != nil {
+		log.Printf("Error searching channels: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to search channels",
+		})
+	}
+
+	response := make([]models.ChannelResponse, len(channels))
+	for i := range channels {
+		response[i] = channels[i].ToResponse()
+	}
+
+	return c.JSON(fiber.Map{
+		"channels": response,
+		"count":    len(response),
+		"total":    total,
+	})
+}
+
+// ResolveHandle finds a channel by its @handle. Private channels answer 404
+// to anyone who is not already a subscriber.
+func (h *ChannelHandler) ResolveHandle(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	handle, err := models.NormalizeChannelHandle(c.Params("handle"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Channel not found",
+		})
+	}
+
+	channel, err := h.channelService.ResolveHandle(c.Context(), handle, uid)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Channel not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.JSON(channel.ToResponse())
+}
+
+// ListMyChannels returns the channels the caller owns or subscribes to.
+func (h *ChannelHandler) ListMyChannels(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channels, err := h.channelService.ListMyChannels(c.Context(), uid)
+	if err != nil {
+		log.Printf("Error listing user channels: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list channels",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"channels": channels,
+		"count":    len(channels),
+	})
+}
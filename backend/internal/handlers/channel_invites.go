@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+// CreateInviteLink issues an invite link. Requires CanManageSubscribers.
+func (h *ChannelHandler) CreateInviteLink(c fiber.Ctx) error {
+	uid, channel := h.requireSubscriberManager(c)
+	if channel == nil {
+		return nil
+	}
+
+	var req models.CreateInviteLinkRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.UsageLimit != nil && *req.UsageLimit < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "usage_limit must be positive",
+		})
+	}
+	if req.ExpiresInHours != nil && (*req.ExpiresInHours < 1 || *req.ExpiresInHours > 8760) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "expires_in_hours must be between 1 and 8760",
+		})
+	}
+
+	link, err := h.channelService.CreateInviteLink(c.Context(), channel.ID, uid, req)
+	if err != nil {
+		log.Printf("Error creating invite link: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create invite link",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(link)
+}
+
+func (h *ChannelHandler) ListInviteLinks(c fiber.Ctx) error {
+	_, channel := h.requireSubscriberManager(c)
+	if channel == nil {
+		return nil
+	}
+
+	links, err := h.channelService.ListInviteLinks(c.Context(), channel.ID)
+	if err != nil {
+		log.Printf("Error listing invite links: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list invite links",
+		})
+	}
+
+	return c.JSON(links)
+}
+
+func (h *ChannelHandler) RevokeInviteLink(c fiber.Ctx) error {
+	_, channel := h.requireSubscriberManager(c)
+	if channel == nil {
+		return nil
+	}
+
+	linkID, err := uuid.Parse(c.Params("inviteId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid invite ID",
+		})
+	}
+
+	revoked, err := h.channelService.RevokeInviteLink(c.Context(), channel.ID, linkID)
+	if err != nil {
+		log.Printf("Error revoking invite link: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke invite link",
+		})
+	}
+	if !revoked {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Invite link not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Invite link revoked",
+	})
+}
+
+// PreviewInvite shows what channel a link leads to before joining.
+func (h *ChannelHandler) PreviewInvite(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	link, channel, err := h.channelService.FindInviteLink(c.Context(), c.Params("token"))
+	if err != nil {
+		return inviteError(c, err)
+	}
+
+	return c.JSON(models.InvitePreviewResponse{
+		ChannelID:        channel.ID,
+		Name:             channel.Name,
+		Description:      channel.Description,
+		AvatarURL:        channel.AvatarURL,
+		SubscriberCount:  channel.SubscriberCount,
+		IsPublic:         channel.IsPublic,
+		RequiresApproval: link.RequiresApproval,
+		IsSubscribed:     h.channelService.IsSubscribed(channel.ID, uid),
+	})
+}
+
+// JoinByInvite subscribes through an invite link. Links that require
+// approval file a join request instead and answer 202.
+func (h *ChannelHandler) JoinByInvite(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	link, channel, err := h.channelService.FindInviteLink(c.Context(), c.Params("token"))
+	if err != nil {
+		return inviteError(c, err)
+	}
+
+	var req models.JoinChannelRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	request, err := h.channelService.JoinByInvite(c.Context(), link, uid, req.Message)
+	if err != nil {
+		return inviteError(c, err)
+	}
+
+	if request != nil {
+		event, err := json.Marshal(map[string]interface{}{
+			"type":       "channel_join_request",
+			"channel_id": channel.ID,
+			"request_id": request.ID,
+			"user_id":    uid,
+			"status":     request.Status,
+		})
+		if err == nil {
+			h.wsHandler.BroadcastToUser(channel.OwnerID.String(), event)
+		}
+		return c.Status(fiber.StatusAccepted).JSON(request)
+	}
+
+	h.wsHandler.NotifyChannelSubscription(uid.String(), channel.ID.String(), true)
+
+	h.db.Preload("Owner").First(channel, channel.ID)
+
+	return c.JSON(channel.ToResponse())
+}
+
+// ListJoinRequests returns a channel's join requests, pending ones by
+// default. Requires CanManageSubscribers.
+func (h *ChannelHandler) ListJoinRequests(c fiber.Ctx) error {
+	_, channel := h.requireSubscriberManager(c)
+	if channel == nil {
+		return nil
+	}
+
+	status := models.JoinRequestStatus(c.Query("status", string(models.JoinRequestPending)))
+	switch status {
+	case models.JoinRequestPending, models.JoinRequestApproved, models.JoinRequestRejected:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "status must be pending, approved or rejected",
+		})
+	}
+
+	requests, err := h.channelService.ListJoinRequests(c.Context(), channel.ID, status)
+	if err != nil {
+		log.Printf("Error listing join requests: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list join requests",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"requests": requests,
+		"count":    len(requests),
+	})
+}
+
+func (h *ChannelHandler) ApproveJoinRequest(c fiber.Ctx) error {
+	return h.reviewJoinRequest(c, true)
+}
+
+func (h *ChannelHandler) RejectJoinRequest(c fiber.Ctx) error {
+	return h.reviewJoinRequest(c, false)
+}
+
+func (h *ChannelHandler) reviewJoinRequest(c fiber.Ctx, approve bool) error {
+	uid, channel := h.requireSubscriberManager(c)
+	if channel == nil {
+		return nil
+	}
+
+	requestID, err := uuid.Parse(c.Params("requestId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request ID",
+		})
+	}
+
+	request, err := h.channelService.ReviewJoinRequest(c.Context(), channel.ID, requestID, uid, approve)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrJoinRequestNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Join request not found",
+			})
+		case errors.Is(err, services.ErrJoinRequestProcessed):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Join request was already reviewed",
+			})
+		case errors.Is(err, services.ErrInviteInvalid):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Invite link was revoked or has no uses left",
+			})
+		}
+		log.Printf("Error reviewing join request: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to review join request",
+		})
+	}
+
+	if approve {
+		h.wsHandler.NotifyChannelSubscription(request.UserID.String(), channel.ID.String(), true)
+	}
+
+	event, err := json.Marshal(map[string]interface{}{
+		"type":       "channel_join_request",
+		"channel_id": channel.ID,
+		"request_id": request.ID,
+		"status":     request.Status,
+	})
+	if err == nil {
+		h.wsHandler.BroadcastToUser(request.UserID.String(), event)
+	}
+
+	return c.JSON(request)
+}
+
+// requireSubscriberManager loads the channel and checks the caller may manage
+// its subscribers. On failure it writes the error response and returns a nil
+// channel.
+func (h *ChannelHandler) requireSubscriberManager(c fiber.Ctx) (uuid.UUID, *models.Channel) {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+		return uuid.Nil, nil
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return uuid.Nil, nil
+	}
+
+	rights, _ := h.channelService.AdminRights(channel, uid)
+	if !rights.CanManageSubscribers {
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot manage subscribers of this channel",
+		})
+		return uuid.Nil, nil
+	}
+
+	return uid, channel
+}
+
+func inviteError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInviteInvalid):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Invite link is invalid or has expired",
+		})
+	case errors.Is(err, services.ErrAlreadySubscribed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Already subscribed",
+		})
+	case errors.Is(err, services.ErrJoinRequestPending):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Your join request is already pending",
+		})
+	}
+	log.Printf("Error joining channel by invite: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to join channel",
+	})
+}
@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/pkg/media"
+	"gorm.io/gorm"
+)
+
+func (h *ChannelHandler) CreatePost(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if !h.channelService.CanPublish(channel, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot publish to this channel",
+		})
+	}
+
+	var req models.CreateChannelPostRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Content == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Content is required",
+		})
+	}
+
+	postType := models.MessageTypeText
+	if req.PostType != "" {
+		postType = req.PostType
+	}
+	if postType != models.MessageTypeText && postType != models.MessageTypeCode {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Use the upload endpoint for media posts",
+		})
+	}
+
+	if err := models.ValidateEntities(req.Content, req.Entities); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	post := models.ChannelPost{
+		ChannelID: channel.ID,
+		AuthorID:  uid,
+		Content:   req.Content,
+		PostType:  postType,
+		Entities:  req.Entities,
+		Signature: h.channelService.PostSignature(channel, uid),
+	}
+	if postType == models.MessageTypeCode {
+		language := models.CodeLanguageOther
+		if req.CodeLanguage != nil {
+			language = *req.CodeLanguage
+		}
+		post.CodeLanguage = &language
+	}
+
+	if err := h.db.Create(&post).Error; err != nil {
+		log.Printf("Error creating channel post: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to publish post",
+		})
+	}
+
+	return h.publishPost(c, channel, &post)
+}
+
+// UploadPostMedia publishes a media post from a multipart upload, with an
+// optional caption in the "content" field.
+func (h *ChannelHandler) UploadPostMedia(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if !h.channelService.CanPublish(channel, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot publish to this channel",
+		})
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No file uploaded",
+		})
+	}
+
+	allowedTypes := mergeMaps(media.AllowedImageTypes, media.AllowedVideoTypes, media.AllowedAudioTypes)
+	if err := h.mediaUploader.ValidateFile(file, allowedTypes); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var entities []models.MessageEntity
+	caption := c.FormValue("content")
+	if raw := c.FormValue("entities"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &entities); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid entities",
+			})
+		}
+	}
+	if err := models.ValidateEntities(caption, entities); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	openedFile, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to open file",
+		})
+	}
+	defer openedFile.Close()
+
+	result, err := h.mediaUploader.SaveFile(openedFile, file, uid)
+	if err != nil {
+		log.Printf("Error saving file: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save file",
+		})
+	}
+
+	post := models.ChannelPost{
+		ChannelID: channel.ID,
+		AuthorID:  uid,
+		Content:   caption,
+		PostType:  determineMessageType(result.MimeType),
+		MediaURL:  &result.FilePath,
+		MediaSize: &result.FileSize,
+		Entities:  entities,
+		Signature: h.channelService.PostSignature(channel, uid),
+	}
+
+	if err := h.db.Create(&post).Error; err != nil {
+		h.mediaUploader.DeleteFile(result.FilePath)
+		if result.Thumbnail != nil {
+			h.mediaUploader.DeleteFile(*result.Thumbnail)
+		}
+		log.Printf("Error creating channel post: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to publish post",
+		})
+	}
+
+	return h.publishPost(c, channel, &post)
+}
+
+func (h *ChannelHandler) ListPosts(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if !h.channelService.CanRead(channel, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+	}
+
+	page, err := h.channelService.ListChannelPosts(c.Context(), channel.ID, c.Query("cursor"), postPageLimit(c))
+	if err != nil {
+		return postPageError(c, err)
+	}
+
+	return postPageResponse(c, page)
+}
+
+// GetFeed merges the posts of every channel the user is subscribed to.
+func (h *ChannelHandler) GetFeed(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	page, err := h.channelService.ListFeed(c.Context(), uid, c.Query("cursor"), postPageLimit(c))
+	if err != nil {
+		return postPageError(c, err)
+	}
+
+	return postPageResponse(c, page)
+}
+
+func (h *ChannelHandler) UpdatePost(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	post := h.loadPost(c, channel.ID)
+	if post == nil {
+		return nil
+	}
+
+	if !h.channelService.CanEditPost(channel, post, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot edit this post",
+		})
+	}
+
+	var req models.UpdateChannelPostRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Content != nil {
+		post.Content = *req.Content
+	}
+	if req.Entities != nil {
+		post.Entities = *req.Entities
+	}
+
+	if post.Content == "" && post.MediaURL == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Content is required",
+		})
+	}
+	if err := models.ValidateEntities(post.Content, post.Entities); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	post.IsEdited = true
+	if err := h.db.Save(post).Error; err != nil {
+		log.Printf("Error updating channel post: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update post",
+		})
+	}
+
+	if post.DiscussionMessageID != nil {
+		if err := h.db.Model(&models.Message{}).
+			Where("id = ?", *post.DiscussionMessageID).
+			Updates(map[string]interface{}{"content": post.Content, "is_edited": true}).Error; err != nil {
+			log.Printf("Error syncing discussion thread root: %v", err)
+		}
+	}
+
+	h.db.Preload("Author").First(post, post.ID)
+	post.CommentCount = h.channelService.CountComments(c.Context(), post)
+	h.broadcastPostEvent(channel.ID, "channel_post_edited", post.ToResponse())
+
+	return c.JSON(post.ToResponse())
+}
+
+func (h *ChannelHandler) DeletePost(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	post := h.loadPost(c, channel.ID)
+	if post == nil {
+		return nil
+	}
+
+	if !h.channelService.CanDeletePost(channel, post, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot delete this post",
+		})
+	}
+
+	if err := h.db.Model(post).Update("is_deleted", true).Error; err != nil {
+		log.Printf("Error deleting channel post: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete post",
+		})
+	}
+
+	if post.DiscussionMessageID != nil {
+		if err := h.db.Model(&models.Message{}).
+			Where("id = ?", *post.DiscussionMessageID).
+			Update("is_deleted", true).Error; err != nil {
+			log.Printf("Error deleting discussion thread root: %v", err)
+		}
+	}
+
+	h.broadcastPostEvent(channel.ID, "channel_post_deleted", fiber.Map{
+		"id": post.ID,
+	})
+
+	return c.JSON(fiber.Map{
+		"message": "Post deleted successfully",
+	})
+}
+
+// publishPost delivers a freshly stored post to subscribers and opens its
+// comment thread in the discussion group, if the channel has one.
+func (h *ChannelHandler) publishPost(c fiber.Ctx, channel *models.Channel, post *models.ChannelPost) error {
+	root, err := h.channelService.CreateDiscussionThread(c.Context(), channel, post)
+	if err != nil {
+		log.Printf("Error creating discussion thread: %v", err)
+	} else if root != nil {
+		h.publishChatMessage(c, root)
+	}
+
+	if err := h.db.Preload("Author").First(post, post.ID).Error; err != nil {
+		log.Printf("Error loading channel post with author: %v", err)
+	}
+
+	h.broadcastPostEvent(post.ChannelID, "channel_post", post.ToResponse())
+
+	return c.Status(fiber.StatusCreated).JSON(post.ToResponse())
+}
+
+func (h *ChannelHandler) broadcastPostEvent(channelID uuid.UUID, eventType string, payload interface{}) {
+	event, err := json.Marshal(map[string]interface{}{
+		"type":       eventType,
+		"channel_id": channelID,
+		"post":       payload,
+	})
+	if err != nil {
+		return
+	}
+
+	h.wsHandler.BroadcastToChannel(channelID.String(), event)
+}
+
+// loadChannel fetches the channel named by the :id route parameter. On
+// failure it writes the error response and returns nil.
+func (h *ChannelHandler) loadChannel(c fiber.Ctx) *models.Channel {
+	cid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+		return nil
+	}
+
+	var channel models.Channel
+	if err := h.db.First(&channel, cid).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Channel not found",
+			})
+			return nil
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return nil
+	}
+
+	return &channel
+}
+
+func (h *ChannelHandler) loadPost(c fiber.Ctx, channelID uuid.UUID) *models.ChannelPost {
+	pid, err := uuid.Parse(c.Params("postId"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post ID",
+		})
+		return nil
+	}
+
+	var post models.ChannelPost
+	if err := h.db.Where("id = ? AND channel_id = ? AND is_deleted = ?", pid, channelID, false).
+		First(&post).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Post not found",
+			})
+			return nil
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return nil
+	}
+
+	return &post
+}
+
+func postPageLimit(c fiber.Ctx) int {
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+	return limit
+}
+
+func postPageError(c fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid cursor",
+		})
+	}
+	log.Printf("Error listing channel posts: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Database error",
+	})
+}
+
+func postPageResponse(c fiber.Ctx, page *services.PostPage) error {
+	responses := make([]models.ChannelPostResponse, len(page.Posts))
+	for i := range page.Posts {
+		responses[i] = page.Posts[i].ToResponse()
+	}
+
+	resp := fiber.Map{
+		"posts":    responses,
+		"has_more": page.NextCursor != "",
+	}
+	if page.NextCursor != "" {
+		resp["next_cursor"] = page.NextCursor
+	}
+
+	return c.JSON(resp)
+}
+
+// RecordPostViews counts the listed posts as seen by the caller. Clients send
+// the posts that scrolled into view; repeats are ignored.
+func (h *ChannelHandler) RecordPostViews(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if !h.channelService.CanRead(channel, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+	}
+
+	var req models.RecordPostViewsRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if len(req.PostIDs) == 0 || len(req.PostIDs) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "post_ids must contain 1-100 posts",
+		})
+	}
+
+	postIDs := make([]uuid.UUID, 0, len(req.PostIDs))
+	for _, id := range req.PostIDs {
+		pid, err := uuid.Parse(id)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid post ID",
+			})
+		}
+		postIDs = append(postIDs, pid)
+	}
+
+	if err := h.channelService.RecordPostViews(c.Context(), channel.ID, uid, postIDs); err != nil {
+		log.Printf("Error recording post views: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record views",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
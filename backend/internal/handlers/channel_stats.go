@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+const maxStatsRangeDays = 366
+
+// GetStats reports subscriber growth and post reach over a date range.
+// from and to are YYYY-MM-DD and default to the last 30 days.
+func (h *ChannelHandler) GetStats(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if !h.channelService.CanManage(channel, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only channel managers can view statistics",
+		})
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to := today
+	if v := c.Query("to"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid to date, expected YYYY-MM-DD",
+			})
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -29)
+	if v := c.Query("from"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid from date, expected YYYY-MM-DD",
+			})
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "from must not be after to",
+		})
+	}
+	if to.Sub(from) > maxStatsRangeDays*24*time.Hour {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Date range is limited to one year",
+		})
+	}
+
+	stats, err := h.channelService.GetChannelStats(c.Context(), channel, from, to)
+	if err != nil {
+		log.Printf("Error computing channel stats: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load statistics",
+		})
+	}
+
+	return c.JSON(stats)
+}
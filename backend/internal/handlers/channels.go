@@ -1,20 +1,36 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/pkg/media"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 type ChannelHandler struct {
-	db *gorm.DB
+	db             *gorm.DB
+	redis          *redis.Client
+	wsHandler      *WebSocketHandler
+	channelService *services.ChannelService
+	chatService    *services.ChatService
+	mediaUploader  *media.MediaUploader
 }
 
-func NewChannelHandler(db *gorm.DB) *ChannelHandler {
-	return &ChannelHandler{db: db}
+func NewChannelHandler(db *gorm.DB, redisClient *redis.Client, wsHandler *WebSocketHandler) *ChannelHandler {
+	return &ChannelHandler{
+		db:             db,
+		redis:          redisClient,
+		wsHandler:      wsHandler,
+		channelService: services.NewChannelService(db, redisClient),
+		chatService:    services.NewChatService(db, redisClient),
+		mediaUploader:  media.NewMediaUploader(),
+	}
 }
 
 func (h *ChannelHandler) CreateChannel(c fiber.Ctx) error {
@@ -39,25 +55,36 @@ func (h *ChannelHandler) CreateChannel(c fiber.Ctx) error {
 		isPublic = *req.IsPublic
 	}
 
+	var handle *string
+	if req.Handle != nil && *req.Handle != "" {
+		normalized, ok := h.checkHandle(c, *req.Handle, uuid.Nil)
+		if !ok {
+			return nil
+		}
+		handle = &normalized
+	}
+
 	channel := models.Channel{
 		Name:        req.Name,
+		Handle:      handle,
 		OwnerID:     uid,
 		Description: req.Description,
 		IsPublic:    isPublic,
 	}
 
-	if err := h.db.Create(&channel).Error; err != nil {
+	if err := h.channelService.CreateChannel(c.Context(), &channel); err != nil {
+		if errors.Is(err, services.ErrHandleTaken) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		log.Printf("Error creating channel: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create channel",
 		})
 	}
 
-	subscriber := models.ChannelSubscriber{
-		ChannelID: channel.ID,
-		UserID:    uid,
-	}
-	h.db.Create(&subscriber)
+	h.wsHandler.NotifyChannelSubscription(uid.String(), channel.ID.String(), true)
 
 	if err := h.db.Preload("Owner").First(&channel, channel.ID).Error; err != nil {
 		log.Printf("Error loading channel with owner: %v", err)
@@ -66,9 +93,19 @@ func (h *ChannelHandler) CreateChannel(c fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(channel.ToResponse())
 }
 
+// GetChannel returns a channel. Private channels look like they do not exist
+// to anyone who cannot read them.
 func (h *ChannelHandler) GetChannel(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
 	channelID := c.Params("id")
 
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
 	cid, err := uuid.Parse(channelID)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -88,9 +125,128 @@ func (h *ChannelHandler) GetChannel(c fiber.Ctx) error {
 		})
 	}
 
+	if !h.channelService.CanRead(&channel, uid) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Channel not found",
+		})
+	}
+
+	return c.JSON(channel.ToResponse())
+}
+
+// UpdateChannel changes the name, description, handle, visibility or
+// signature setting of a channel. An empty handle removes it. Owner only.
+func (h *ChannelHandler) UpdateChannel(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	channel := h.loadChannel(c)
+	if channel == nil {
+		return nil
+	}
+
+	if channel.OwnerID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the channel owner can edit the channel",
+		})
+	}
+
+	var req models.UpdateChannelRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if *req.Name == "" || len(*req.Name) > 255 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Name must be 1-255 characters",
+			})
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.SignPosts != nil {
+		updates["sign_posts"] = *req.SignPosts
+	}
+	if req.IsPublic != nil {
+		updates["is_public"] = *req.IsPublic
+	}
+	if req.WikiDigest != nil {
+		updates["wiki_digest"] = *req.WikiDigest
+	}
+	if req.Handle != nil {
+		if *req.Handle == "" {
+			updates["handle"] = nil
+		} else {
+			normalized, ok := h.checkHandle(c, *req.Handle, channel.ID)
+			if !ok {
+				return nil
+			}
+			updates["handle"] = normalized
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := h.channelService.UpdateChannel(c.Context(), channel, updates); err != nil {
+			if errors.Is(err, services.ErrHandleTaken) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			log.Printf("Error updating channel: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update channel",
+			})
+		}
+	}
+
+	if err := h.db.Preload("Owner").First(channel, channel.ID).Error; err != nil {
+		log.Printf("Error loading channel with owner: %v", err)
+	}
+
 	return c.JSON(channel.ToResponse())
 }
 
+// checkHandle normalizes a requested handle and makes sure no other channel
+// holds it. On failure it writes the error response and returns ok == false.
+func (h *ChannelHandler) checkHandle(c fiber.Ctx, handle string, channelID uuid.UUID) (string, bool) {
+	normalized, err := models.NormalizeChannelHandle(handle)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+		return "", false
+	}
+
+	available, err := h.channelService.HandleAvailable(normalized, channelID)
+	if err != nil {
+		log.Printf("Error checking channel handle: %v", err)
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return "", false
+	}
+	if !available {
+		c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": services.ErrHandleTaken.Error(),
+		})
+		return "", false
+	}
+
+	return normalized, true
+}
+
 func (h *ChannelHandler) Subscribe(c fiber.Ctx) error {
 	userID := c.Locals("userID").(string)
 	channelID := c.Params("id")
@@ -121,24 +277,28 @@ func (h *ChannelHandler) Subscribe(c fiber.Ctx) error {
 		})
 	}
 
-	var existingSubscriber models.ChannelSubscriber
-	if err := h.db.Where("channel_id = ? AND user_id = ?", cid, uid).First(&existingSubscriber).Error; err == nil {
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-			"error": "Already subscribed",
+	// Private channels are joined through invite links; to everyone else
+	// they do not exist.
+	if !channel.IsPublic && !h.channelService.CanManage(&channel, uid) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Channel not found",
 		})
 	}
 
-	subscriber := models.ChannelSubscriber{
-		ChannelID: cid,
-		UserID:    uid,
-	}
-
-	if err := h.db.Create(&subscriber).Error; err != nil {
+	if err := h.channelService.Subscribe(c.Context(), cid, uid); err != nil {
+		if errors.Is(err, services.ErrAlreadySubscribed) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Already subscribed",
+			})
+		}
+		log.Printf("Error subscribing to channel: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to subscribe",
 		})
 	}
 
+	h.wsHandler.NotifyChannelSubscription(uid.String(), cid.String(), true)
+
 	return c.JSON(fiber.Map{
 		"message": "Subscribed successfully",
 	})
@@ -162,12 +322,18 @@ func (h *ChannelHandler) Unsubscribe(c fiber.Ctx) error {
 		})
 	}
 
-	if err := h.db.Where("channel_id = ? AND user_id = ?", cid, uid).Delete(&models.ChannelSubscriber{}).Error; err != nil {
+	removed, err := h.channelService.Unsubscribe(c.Context(), cid, uid)
+	if err != nil {
+		log.Printf("Error unsubscribing from channel: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to unsubscribe",
 		})
 	}
 
+	if removed {
+		h.wsHandler.NotifyChannelSubscription(uid.String(), cid.String(), false)
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Unsubscribed successfully",
 	})
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+    "encoding/json"
+    "errors"
     "log"
     "strconv"
+    "time"
 
     "github.com/gofiber/fiber/v3"
     "github.com/google/uuid"
@@ -179,7 +182,15 @@ func (h *ChatHandler) GetUserChats(c fiber.Ctx) error {
         })
     }
 
-    chats, err := h.chatService.GetUserChatsWithLastMessage(c.Context(), uid)
+    if folderID := c.Query("folder"); folderID != "" {
+        return h.getFolderChats(c, uid, folderID)
+    }
+
+    opts := services.ChatListOptions{
+        Archived: c.Query("archived", "false") == "true",
+    }
+
+    chats, err := h.chatService.GetUserChatsWithLastMessage(c.Context(), uid, opts)
     if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
             "error": "Failed to get chats",
@@ -192,6 +203,46 @@ func (h *ChatHandler) GetUserChats(c fiber.Ctx) error {
     })
 }
 
+func (h *ChatHandler) getFolderChats(c fiber.Ctx, uid uuid.UUID, folderID string) error {
+    fid, err := uuid.Parse(folderID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid folder ID",
+        })
+    }
+
+    var folder models.ChatFolder
+    if err := h.db.Where("id = ? AND user_id = ?", fid, uid).First(&folder).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+                "error": "Folder not found",
+            })
+        }
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Database error",
+        })
+    }
+
+    chats, err := h.chatService.GetUserChatsInFolder(c.Context(), uid, &folder)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Failed to get chats",
+        })
+    }
+
+    var unreadCount int64
+    for _, chat := range chats {
+        unreadCount += chat.UnreadCount
+    }
+
+    return c.JSON(fiber.Map{
+        "chats":        chats,
+        "count":        len(chats),
+        "folder_id":    folder.ID,
+        "unread_count": unreadCount,
+    })
+}
+
 func (h *ChatHandler) GetChat(c fiber.Ctx) error {
     userID := c.Locals("userID").(string)
     chatID := c.Params("id")
@@ -286,8 +337,30 @@ func (h *ChatHandler) GetChatMessages(c fiber.Ctx) error {
         }
     }
 
+    var topicID *uuid.UUID
+    if t := c.Query("topic"); t != "" {
+        tid, err := uuid.Parse(t)
+        if err != nil {
+            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+                "error": "Invalid topic ID",
+            })
+        }
+        var topic models.ChatTopic
+        if err := h.db.Where("id = ? AND chat_id = ?", tid, cid).First(&topic).Error; err != nil {
+            return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+                "error": "Topic not found",
+            })
+        }
+        topicID = &tid
+    }
+
+    filter := h.db.Where("chat_id = ? AND is_deleted = ?", cid, false)
+    if topicID != nil {
+        filter = filter.Where("topic_id = ?", *topicID)
+    }
+
     var messages []models.Message
-    query := h.db.Where("chat_id = ? AND is_deleted = ?", cid, false).
+    query := h.db.Where(filter).
         Preload("Sender").
         Order("created_at DESC").
         Limit(limit).
@@ -300,9 +373,13 @@ func (h *ChatHandler) GetChatMessages(c fiber.Ctx) error {
     }
 
     var total int64
-    h.db.Model(&models.Message{}).Where("chat_id = ? AND is_deleted = ?", cid, false).Count(&total)
+    h.db.Model(&models.Message{}).Where(filter).Count(&total)
 
-    go h.chatService.UpdateLastRead(c.Context(), cid, uid)
+    if topicID != nil {
+        go h.chatService.MarkTopicRead(c.Context(), *topicID, uid)
+    } else {
+        go h.chatService.UpdateLastRead(c.Context(), cid, uid)
+    }
 
     responses := make([]models.MessageResponse, len(messages))
     for i, msg := range messages {
@@ -376,6 +453,19 @@ func (h *ChatHandler) AddMember(c fiber.Ctx) error {
         })
     }
 
+    ban, err := h.chatService.ActiveSanction(c.Context(), cid, newUserID, models.SanctionTypeBan)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Database error",
+        })
+    }
+    if ban != nil {
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+            "error":      "User is banned from this chat",
+            "expires_at": ban.ExpiresAt,
+        })
+    }
+
     role := models.MemberRoleMember
     if req.Role != "" {
         role = req.Role
@@ -452,7 +542,22 @@ func (h *ChatHandler) RemoveMember(c fiber.Ctx) error {
         })
     }
 
-    if err := h.db.Where("chat_id = ? AND user_id = ?", cid, tuid).Delete(&models.ChatMember{}).Error; err != nil {
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Where("chat_id = ? AND user_id = ?", cid, tuid).Delete(&models.ChatMember{}).Error; err != nil {
+            return err
+        }
+        if uid == tuid {
+            return nil
+        }
+        return tx.Create(&models.ChatSanction{
+            ChatID:     cid,
+            UserID:     tuid,
+            Type:       models.SanctionTypeKick,
+            IssuedByID: uid,
+            IsActive:   false,
+        }).Error
+    })
+    if err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
             "error": "Failed to remove member",
         })
@@ -503,3 +608,400 @@ func (h *ChatHandler) MarkAsRead(c fiber.Ctx) error {
         "unread_count": unreadCount,
     })
 }
+
+func (h *ChatHandler) UpdateChatSettings(c fiber.Ctx) error {
+    userID := c.Locals("userID").(string)
+    chatID := c.Params("id")
+
+    uid, err := uuid.Parse(userID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+
+    cid, err := uuid.Parse(chatID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid chat ID",
+        })
+    }
+
+    var req models.UpdateChatSettingsRequest
+    if err := c.Bind().JSON(&req); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    member, err := h.chatService.UpdateChatSettings(c.Context(), cid, uid, req)
+    if err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+                "error": "Access denied",
+            })
+        }
+        if errors.Is(err, services.ErrPinnedChatsLimit) {
+            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+                "error": err.Error(),
+            })
+        }
+        log.Printf("Error updating chat settings: %v", err)
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Failed to update chat settings",
+        })
+    }
+
+    return c.JSON(member.ToSettingsResponse())
+}
+
+func (h *ChatHandler) UpdateChatRestrictions(c fiber.Ctx) error {
+    userID := c.Locals("userID").(string)
+    chatID := c.Params("id")
+
+    uid, err := uuid.Parse(userID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+
+    cid, err := uuid.Parse(chatID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid chat ID",
+        })
+    }
+
+    var chat models.Chat
+    if err := h.db.First(&chat, cid).Error; err != nil {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+            "error": "Chat not found",
+        })
+    }
+
+    if chat.Type == models.ChatTypeDM {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Cannot restrict posting in DM chat",
+        })
+    }
+
+    var chatMember models.ChatMember
+    if err := h.db.Where("chat_id = ? AND user_id = ?", cid, uid).First(&chatMember).Error; err != nil {
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+            "error": "Access denied",
+        })
+    }
+
+    if chatMember.Role != models.MemberRoleAdmin {
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+            "error": "Only admins can change chat restrictions",
+        })
+    }
+
+    var req models.UpdateChatRestrictionsRequest
+    if err := c.Bind().JSON(&req); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    if req.SlowModeSeconds != nil {
+        if *req.SlowModeSeconds < 0 || *req.SlowModeSeconds > 3600 {
+            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+                "error": "slow_mode_seconds must be between 0 and 3600",
+            })
+        }
+        chat.Restrictions.SlowModeSeconds = *req.SlowModeSeconds
+    }
+    if req.AdminsOnly != nil {
+        chat.Restrictions.AdminsOnly = *req.AdminsOnly
+    }
+    if req.NoMedia != nil {
+        chat.Restrictions.NoMedia = *req.NoMedia
+    }
+    if req.NoLinks != nil {
+        chat.Restrictions.NoLinks = *req.NoLinks
+    }
+
+    if err := h.db.Model(&chat).Select("slow_mode_seconds", "admins_only", "no_media", "no_links").
+        Updates(&chat).Error; err != nil {
+        log.Printf("Error updating chat restrictions: %v", err)
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Failed to update chat restrictions",
+        })
+    }
+
+    restrictionsMsg, err := json.Marshal(map[string]interface{}{
+        "type":         "chat_restrictions",
+        "chat_id":      chat.ID,
+        "restrictions": chat.Restrictions,
+    })
+    if err == nil {
+        h.redis.Publish(c.Context(), "chat:"+chat.ID.String(), restrictionsMsg)
+    }
+
+    return c.JSON(chat.ToResponse())
+}
+
+func (h *ChatHandler) BanMember(c fiber.Ctx) error {
+    return h.createSanction(c, models.SanctionTypeBan)
+}
+
+func (h *ChatHandler) UnbanMember(c fiber.Ctx) error {
+    return h.liftSanction(c, models.SanctionTypeBan)
+}
+
+func (h *ChatHandler) RestrictMember(c fiber.Ctx) error {
+    return h.createSanction(c, models.SanctionTypeReadOnly)
+}
+
+func (h *ChatHandler) UnrestrictMember(c fiber.Ctx) error {
+    return h.liftSanction(c, models.SanctionTypeReadOnly)
+}
+
+func (h *ChatHandler) ListSanctions(c fiber.Ctx) error {
+    userID := c.Locals("userID").(string)
+    chatID := c.Params("id")
+
+    uid, err := uuid.Parse(userID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+
+    cid, err := uuid.Parse(chatID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid chat ID",
+        })
+    }
+
+    if chat := h.requireGroupAdmin(c, cid, uid); chat == nil {
+        return nil
+    }
+
+    query := h.db.Model(&models.ChatSanction{}).Where("chat_id = ?", cid)
+
+    if sanctionType := c.Query("type"); sanctionType != "" {
+        query = query.Where("type = ?", sanctionType)
+    }
+
+    if c.Query("include_inactive", "false") != "true" {
+        query = query.Where("is_active = ?", true).
+            Where("expires_at IS NULL OR expires_at > ?", time.Now())
+    }
+
+    var sanctions []models.ChatSanction
+    if err := query.Preload("User").Preload("IssuedBy").Order("created_at DESC").Find(&sanctions).Error; err != nil {
+        log.Printf("Error listing chat sanctions: %v", err)
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Failed to list bans",
+        })
+    }
+
+    return c.JSON(sanctions)
+}
+
+func (h *ChatHandler) createSanction(c fiber.Ctx, sanctionType models.SanctionType) error {
+    userID := c.Locals("userID").(string)
+    chatID := c.Params("id")
+
+    uid, err := uuid.Parse(userID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+
+    cid, err := uuid.Parse(chatID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid chat ID",
+        })
+    }
+
+    var req models.CreateSanctionRequest
+    if err := c.Bind().JSON(&req); err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    // The restrict route names the member in the path.
+    if target := c.Params("userId"); target != "" {
+        req.UserID = target
+    }
+
+    tuid, err := uuid.Parse(req.UserID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid target user ID",
+        })
+    }
+
+    if req.DurationHours < 0 || req.DurationHours > 8760 {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "duration_hours must be between 0 and 8760",
+        })
+    }
+
+    if sanctionType == models.SanctionTypeReadOnly && req.DurationHours == 0 {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "duration_hours is required for restrictions",
+        })
+    }
+
+    chat := h.requireGroupAdmin(c, cid, uid)
+    if chat == nil {
+        return nil
+    }
+
+    if tuid == uid || (chat.OwnerID != nil && *chat.OwnerID == tuid) {
+        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+            "error": "This member cannot be banned or restricted",
+        })
+    }
+
+    var targetMember models.ChatMember
+    isMember := h.db.Where("chat_id = ? AND user_id = ?", cid, tuid).First(&targetMember).Error == nil
+
+    if sanctionType == models.SanctionTypeReadOnly && !isMember {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+            "error": "User is not a member of this chat",
+        })
+    }
+
+    sanction := models.ChatSanction{
+        ChatID:     cid,
+        UserID:     tuid,
+        Type:       sanctionType,
+        Reason:     req.Reason,
+        IssuedByID: uid,
+        IsActive:   true,
+    }
+    if req.DurationHours > 0 {
+        expiresAt := time.Now().Add(time.Duration(req.DurationHours) * time.Hour)
+        sanction.ExpiresAt = &expiresAt
+    }
+
+    err = h.db.Transaction(func(tx *gorm.DB) error {
+        // A new sanction replaces any earlier one of the same type.
+        if err := tx.Model(&models.ChatSanction{}).
+            Where("chat_id = ? AND user_id = ? AND type = ? AND is_active = ?", cid, tuid, sanctionType, true).
+            Updates(map[string]interface{}{"is_active": false, "lifted_at": time.Now()}).Error; err != nil {
+            return err
+        }
+        if sanctionType == models.SanctionTypeBan && isMember {
+            if err := tx.Where("chat_id = ? AND user_id = ?", cid, tuid).Delete(&models.ChatMember{}).Error; err != nil {
+                return err
+            }
+        }
+        return tx.Create(&sanction).Error
+    })
+    if err != nil {
+        log.Printf("Error creating chat sanction: %v", err)
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Failed to apply sanction",
+        })
+    }
+
+    h.chatService.InvalidateUserChatsCache(c.Context(), tuid)
+
+    eventJSON, err := json.Marshal(map[string]interface{}{
+        "type":       "chat_sanction",
+        "chat_id":    cid,
+        "sanction":   sanction.Type,
+        "expires_at": sanction.ExpiresAt,
+        "reason":     sanction.Reason,
+    })
+    if err == nil {
+        h.redis.Publish(c.Context(), "user:"+tuid.String(), eventJSON)
+    }
+
+    if err := h.db.Preload("User").Preload("IssuedBy").First(&sanction, sanction.ID).Error; err != nil {
+        log.Printf("Error loading chat sanction: %v", err)
+    }
+
+    return c.Status(fiber.StatusCreated).JSON(sanction)
+}
+
+func (h *ChatHandler) liftSanction(c fiber.Ctx, sanctionType models.SanctionType) error {
+    userID := c.Locals("userID").(string)
+    chatID := c.Params("id")
+    targetUserID := c.Params("userId")
+
+    uid, err := uuid.Parse(userID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+
+    cid, err := uuid.Parse(chatID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid chat ID",
+        })
+    }
+
+    tuid, err := uuid.Parse(targetUserID)
+    if err != nil {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Invalid target user ID",
+        })
+    }
+
+    if chat := h.requireGroupAdmin(c, cid, uid); chat == nil {
+        return nil
+    }
+
+    lifted, err := h.chatService.LiftSanctions(c.Context(), cid, tuid, sanctionType)
+    if err != nil {
+        log.Printf("Error lifting chat sanction: %v", err)
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Failed to lift sanction",
+        })
+    }
+
+    if lifted == 0 {
+        return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+            "error": "No active sanction found",
+        })
+    }
+
+    return c.JSON(fiber.Map{
+        "message": "Sanction lifted successfully",
+    })
+}
+
+// requireGroupAdmin loads a group chat and checks that uid administers it.
+// On failure it writes the error response and returns nil.
+func (h *ChatHandler) requireGroupAdmin(c fiber.Ctx, cid, uid uuid.UUID) *models.Chat {
+    var chat models.Chat
+    if err := h.db.First(&chat, cid).Error; err != nil {
+        c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+            "error": "Chat not found",
+        })
+        return nil
+    }
+
+    if chat.Type == models.ChatTypeDM {
+        c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Not available in DM chats",
+        })
+        return nil
+    }
+
+    var chatMember models.ChatMember
+    if err := h.db.Where("chat_id = ? AND user_id = ?", cid, uid).First(&chatMember).Error; err != nil ||
+        chatMember.Role != models.MemberRoleAdmin {
+        c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+            "error": "Only admins can moderate members",
+        })
+        return nil
+    }
+
+    return &chat
+}
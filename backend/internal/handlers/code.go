@@ -1,20 +1,32 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/pkg/highlight"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 type CodeHandler struct {
-	db *gorm.DB
+	db          *gorm.DB
+	redis       *redis.Client
+	codeService *services.CodeService
+	chatService *services.ChatService
 }
 
-func NewCodeHandler(db *gorm.DB) *CodeHandler {
-	return &CodeHandler{db: db}
+func NewCodeHandler(db *gorm.DB, redisClient *redis.Client) *CodeHandler {
+	return &CodeHandler{
+		db:          db,
+		redis:       redisClient,
+		codeService: services.NewCodeService(db),
+		chatService: services.NewChatService(db, redisClient),
+	}
 }
 
 func (h *CodeHandler) CreateCodeSnippet(c fiber.Ctx) error {
@@ -75,8 +87,11 @@ func (h *CodeHandler) CreateCodeSnippet(c fiber.Ctx) error {
 		FileName:    req.FileName,
 		CreatedByID: uid,
 	}
+	if codeSnippet.Language == "" || codeSnippet.Language == models.CodeLanguageOther {
+		detectSnippetLanguage(&codeSnippet)
+	}
 
-	if err := h.db.Create(&codeSnippet).Error; err != nil {
+	if err := services.CreateCodeSnippet(h.db, &codeSnippet); err != nil {
 		log.Printf("Error creating code snippet: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create code snippet",
@@ -112,7 +127,75 @@ func (h *CodeHandler) GetCodeSnippet(c fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(codeSnippet.ToResponse())
+	resp := codeSnippet.ToResponse()
+	switch render := c.Query("render"); render {
+	case "":
+	case "html", "tokens":
+		if !renderCodeSnippet(c, &resp, render == "html") {
+			return nil
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "render must be html or tokens",
+		})
+	}
+
+	return c.JSON(resp)
+}
+
+// renderCodeSnippet highlights the snippet's code by its language, marking
+// the lines in the highlight query parameter. It fills in the HTML, or the
+// token lines when asHTML is false. On invalid parameters it writes the
+// error response and returns false.
+func renderCodeSnippet(c fiber.Ctx, resp *models.CodeSnippetResponse, asHTML bool) bool {
+	ranges, err := highlight.ParseLineRanges(c.Query("highlight"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid highlight: " + err.Error(),
+		})
+		return false
+	}
+
+	lines := highlight.Lines(highlight.Tokenize(string(resp.Language), resp.Code))
+	highlight.MarkLines(lines, ranges)
+
+	if !asHTML {
+		resp.Lines = lines
+		return true
+	}
+
+	theme, ok := highlight.LookupTheme(c.Query("theme"))
+	if !ok {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Unknown theme",
+			"themes": highlight.ThemeNames(),
+		})
+		return false
+	}
+
+	resp.Theme = theme.Name
+	resp.HTML = highlight.RenderHTML(lines, highlight.HTMLOptions{
+		Theme:       theme,
+		LineNumbers: fiber.Query[bool](c, "line_numbers", true),
+	})
+	return true
+}
+
+// detectSnippetLanguage infers the snippet's language from its file name
+// and code. Code that looks like no known language is marked other.
+func detectSnippetLanguage(snippet *models.CodeSnippet) {
+	fileName := ""
+	if snippet.FileName != nil {
+		fileName = *snippet.FileName
+	}
+
+	detection := highlight.Detect(fileName, snippet.Code)
+	snippet.Language = models.CodeLanguageOther
+	if detection.Language != "" {
+		snippet.Language = models.CodeLanguage(detection.Language)
+	}
+	snippet.LanguageDetected = true
+	snippet.LanguageConfidence = detection.Confidence
 }
 
 func (h *CodeHandler) UpdateCodeSnippet(c fiber.Ctx) error {
@@ -138,7 +221,8 @@ func (h *CodeHandler) UpdateCodeSnippet(c fiber.Ctx) error {
 		})
 	}
 
-	if codeSnippet.CreatedByID != uuid.MustParse(userID) {
+	uid := uuid.MustParse(userID)
+	if codeSnippet.CreatedByID != uid {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": "Only the creator can edit this code snippet",
 		})
@@ -151,8 +235,12 @@ func (h *CodeHandler) UpdateCodeSnippet(c fiber.Ctx) error {
 		})
 	}
 
+	previous := codeSnippet
+
 	if req.Language != nil {
 		codeSnippet.Language = *req.Language
+		codeSnippet.LanguageDetected = false
+		codeSnippet.LanguageConfidence = 0
 	}
 	if req.Code != nil {
 		codeSnippet.Code = *req.Code
@@ -160,13 +248,35 @@ func (h *CodeHandler) UpdateCodeSnippet(c fiber.Ctx) error {
 	if req.FileName != nil {
 		codeSnippet.FileName = req.FileName
 	}
+	// A language the user chose sticks, a detected one follows the code.
+	// Sending an empty language asks for detection again.
+	if codeSnippet.Language == "" || (codeSnippet.LanguageDetected && (req.Code != nil || req.FileName != nil)) {
+		detectSnippetLanguage(&codeSnippet)
+	}
+
+	summary := ""
+	if req.ChangeSummary != nil {
+		summary = *req.ChangeSummary
+	}
 
-	if err := h.db.Save(&codeSnippet).Error; err != nil {
+	var moved []models.CodeComment
+	if services.SameCodeVersion(&previous, &codeSnippet) {
+		err = h.codeService.SaveLanguageDetection(c.Context(), &codeSnippet, previous.Version)
+	} else {
+		moved, err = h.codeService.SaveVersion(c.Context(), &previous, &codeSnippet, uid, summary)
+	}
+	if errors.Is(err, services.ErrStaleCodeSnippet) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Code snippet was changed by someone else, reload it and try again",
+		})
+	}
+	if err != nil {
 		log.Printf("Error updating code snippet: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update code snippet",
 		})
 	}
+	h.publishMovedCodeComments(c, &codeSnippet, moved)
 
 	if err := h.db.Preload("CreatedBy").First(&codeSnippet, codeSnippet.ID).Error; err != nil {
 		log.Printf("Error loading code snippet with creator: %v", err)
@@ -204,7 +314,16 @@ func (h *CodeHandler) DeleteCodeSnippet(c fiber.Ctx) error {
 		})
 	}
 
-	if err := h.db.Delete(&codeSnippet).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("snippet_id = ?", codeSnippet.ID).Delete(&models.CodeSnippetVersion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("snippet_id = ?", codeSnippet.ID).Delete(&models.CodeComment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&codeSnippet).Error
+	})
+	if err != nil {
 		log.Printf("Error deleting code snippet: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete code snippet",
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+const maxCodeCommentLength = 10000
+
+// ListCodeComments lists the review threads on a snippet, ordered by line.
+// ?resolved=true or ?resolved=false keeps only threads in that state.
+func (h *CodeHandler) ListCodeComments(c fiber.Ctx) error {
+	_, codeSnippet, ok := h.loadMemberSnippet(c)
+	if !ok {
+		return nil
+	}
+
+	var resolved *bool
+	if value := c.Query("resolved"); value != "" {
+		state := value == "true"
+		resolved = &state
+	}
+
+	threads, err := h.codeService.ListThreads(c.Context(), codeSnippet.ID, resolved)
+	if err != nil {
+		log.Printf("Error fetching code comments: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.JSON(threads)
+}
+
+// CreateCodeComment starts a review thread on a range of lines of the
+// snippet's latest version, or replies to a thread with parent_id.
+func (h *CodeHandler) CreateCodeComment(c fiber.Ctx) error {
+	uid, codeSnippet, ok := h.loadMemberSnippet(c)
+	if !ok {
+		return nil
+	}
+
+	var req models.CreateCodeCommentRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Comment body is required",
+		})
+	}
+	if len(body) > maxCodeCommentLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Comment body is too long",
+		})
+	}
+
+	var parentID *uuid.UUID
+	if req.ParentID != nil {
+		pid, err := uuid.Parse(*req.ParentID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid parent ID",
+			})
+		}
+		parentID = &pid
+	}
+
+	if err := h.chatService.CheckReadOnly(c.Context(), codeSnippet.ChatID, uid); err != nil {
+		return postingRestrictedResponse(c, err)
+	}
+
+	comment, err := h.codeService.CreateComment(c.Context(), codeSnippet, uid, body, parentID, req.StartLine, req.EndLine)
+	if errors.Is(err, services.ErrCodeCommentParentMissing) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Parent comment not found",
+		})
+	}
+	if errors.Is(err, services.ErrCodeCommentLines) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid line range",
+		})
+	}
+	if err != nil {
+		log.Printf("Error creating code comment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create comment",
+		})
+	}
+
+	if err := h.db.Preload("Author").First(comment, comment.ID).Error; err != nil {
+		log.Printf("Error loading code comment with author: %v", err)
+	}
+
+	resp := comment.ToResponse()
+	h.publishCodeCommentEvent(c, codeSnippet, "code_comment_created", fiber.Map{"comment": resp})
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+// UpdateCodeComment edits the body of a comment. Authors only.
+func (h *CodeHandler) UpdateCodeComment(c fiber.Ctx) error {
+	uid, codeSnippet, ok := h.loadMemberSnippet(c)
+	if !ok {
+		return nil
+	}
+
+	comment := h.loadCodeComment(c, codeSnippet)
+	if comment == nil {
+		return nil
+	}
+	if comment.AuthorID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the author can edit this comment",
+		})
+	}
+
+	var req models.UpdateCodeCommentRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Comment body is required",
+		})
+	}
+	if len(body) > maxCodeCommentLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Comment body is too long",
+		})
+	}
+
+	if err := h.codeService.UpdateComment(c.Context(), comment, body); err != nil {
+		log.Printf("Error updating code comment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update comment",
+		})
+	}
+
+	if err := h.db.Preload("Author").First(comment, comment.ID).Error; err != nil {
+		log.Printf("Error loading code comment with author: %v", err)
+	}
+
+	resp := comment.ToResponse()
+	h.publishCodeCommentEvent(c, codeSnippet, "code_comment_updated", fiber.Map{"comment": resp})
+
+	return c.JSON(resp)
+}
+
+// DeleteCodeComment removes a comment, and its replies if it starts a
+// thread. Authors only.
+func (h *CodeHandler) DeleteCodeComment(c fiber.Ctx) error {
+	uid, codeSnippet, ok := h.loadMemberSnippet(c)
+	if !ok {
+		return nil
+	}
+
+	comment := h.loadCodeComment(c, codeSnippet)
+	if comment == nil {
+		return nil
+	}
+	if comment.AuthorID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the author can delete this comment",
+		})
+	}
+
+	if err := h.codeService.DeleteComment(c.Context(), comment); err != nil {
+		log.Printf("Error deleting code comment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete comment",
+		})
+	}
+
+	h.publishCodeCommentEvent(c, codeSnippet, "code_comment_deleted", fiber.Map{
+		"comment_id": comment.ID,
+		"parent_id":  comment.ParentID,
+	})
+
+	return c.JSON(fiber.Map{
+		"message": "Comment deleted successfully",
+	})
+}
+
+// ResolveCodeComment marks a thread resolved. Any chat member may resolve.
+func (h *CodeHandler) ResolveCodeComment(c fiber.Ctx) error {
+	return h.setCodeCommentResolved(c, true)
+}
+
+// UnresolveCodeComment reopens a resolved thread.
+func (h *CodeHandler) UnresolveCodeComment(c fiber.Ctx) error {
+	return h.setCodeCommentResolved(c, false)
+}
+
+func (h *CodeHandler) setCodeCommentResolved(c fiber.Ctx, resolved bool) error {
+	uid, codeSnippet, ok := h.loadMemberSnippet(c)
+	if !ok {
+		return nil
+	}
+
+	comment := h.loadCodeComment(c, codeSnippet)
+	if comment == nil {
+		return nil
+	}
+	changed, err := h.codeService.SetResolved(c.Context(), comment, uid, resolved)
+	if errors.Is(err, services.ErrCodeCommentNotThread) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only threads can be resolved",
+		})
+	}
+	if err != nil {
+		log.Printf("Error updating code comment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update comment",
+		})
+	}
+
+	if changed {
+		if err := h.db.Preload("Author").First(comment, comment.ID).Error; err != nil {
+			log.Printf("Error loading code comment with author: %v", err)
+		}
+
+		eventType := "code_comment_unresolved"
+		if resolved {
+			eventType = "code_comment_resolved"
+		}
+		h.publishCodeCommentEvent(c, codeSnippet, eventType, fiber.Map{"comment": comment.ToResponse()})
+	}
+
+	return c.JSON(comment.ToResponse())
+}
+
+// loadMemberSnippet resolves the caller and the snippet named by :id and
+// checks that the caller is a member of the snippet's chat. On failure it
+// writes the error response and returns false.
+func (h *CodeHandler) loadMemberSnippet(c fiber.Ctx) (uuid.UUID, *models.CodeSnippet, bool) {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+		return uuid.Nil, nil, false
+	}
+
+	codeSnippet := h.loadCodeSnippet(c)
+	if codeSnippet == nil {
+		return uuid.Nil, nil, false
+	}
+
+	var count int64
+	if err := h.db.Model(&models.ChatMember{}).Where("chat_id = ? AND user_id = ?", codeSnippet.ChatID, uid).Count(&count).Error; err != nil || count == 0 {
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You are not a member of this chat",
+		})
+		return uuid.Nil, nil, false
+	}
+	return uid, codeSnippet, true
+}
+
+// loadCodeComment fetches the snippet's comment named by :commentId. On
+// failure it writes the error response and returns nil.
+func (h *CodeHandler) loadCodeComment(c fiber.Ctx, codeSnippet *models.CodeSnippet) *models.CodeComment {
+	cid, err := uuid.Parse(c.Params("commentId"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid comment ID",
+		})
+		return nil
+	}
+
+	var comment models.CodeComment
+	if err := h.db.Where("id = ? AND snippet_id = ?", cid, codeSnippet.ID).First(&comment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Comment not found",
+			})
+			return nil
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return nil
+	}
+	return &comment
+}
+
+// publishCodeCommentEvent sends a comment event to the snippet's chat.
+func (h *CodeHandler) publishCodeCommentEvent(c fiber.Ctx, codeSnippet *models.CodeSnippet, eventType string, fields fiber.Map) {
+	payload := map[string]interface{}{
+		"type":       eventType,
+		"chat_id":    codeSnippet.ChatID,
+		"snippet_id": codeSnippet.ID,
+	}
+	for k, v := range fields {
+		payload[k] = v
+	}
+
+	event, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	h.redis.Publish(c.Context(), "chat:"+codeSnippet.ChatID.String(), event)
+}
+
+// publishMovedCodeComments tells the snippet's chat which threads a new
+// version moved or made outdated.
+func (h *CodeHandler) publishMovedCodeComments(c fiber.Ctx, codeSnippet *models.CodeSnippet, moved []models.CodeComment) {
+	if len(moved) == 0 {
+		return
+	}
+
+	comments := make([]models.CodeCommentResponse, len(moved))
+	for i := range moved {
+		comments[i] = moved[i].ToResponse()
+	}
+	h.publishCodeCommentEvent(c, codeSnippet, "code_comments_moved", fiber.Map{
+		"version":  codeSnippet.Version,
+		"comments": comments,
+	})
+}
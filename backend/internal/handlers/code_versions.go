@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/pkg/codefmt"
+	"github.com/messenger/backend/pkg/diff"
+	"gorm.io/gorm"
+)
+
+// ListCodeSnippetVersions lists the versions of a snippet, newest first.
+func (h *CodeHandler) ListCodeSnippetVersions(c fiber.Ctx) error {
+	_, codeSnippet, ok := h.loadMemberSnippet(c)
+	if !ok {
+		return nil
+	}
+
+	var versions []models.CodeSnippetVersion
+	if err := h.db.Preload("CreatedBy").Where("snippet_id = ?", codeSnippet.ID).
+		Order("version_number DESC").Find(&versions).Error; err != nil {
+		log.Printf("Error fetching code snippet versions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// Snippets created before versioning have no rows until their first
+	// change; their current state is version 1.
+	if len(versions) == 0 {
+		versions = append(versions, services.CurrentCodeVersion(codeSnippet))
+	}
+
+	return c.JSON(versions)
+}
+
+// GetCodeSnippetDiff compares two versions of a snippet. By default it
+// compares the latest version with the one before it.
+func (h *CodeHandler) GetCodeSnippetDiff(c fiber.Ctx) error {
+	_, codeSnippet, ok := h.loadMemberSnippet(c)
+	if !ok {
+		return nil
+	}
+
+	to := fiber.Query[int](c, "to", codeSnippet.Version)
+	from := fiber.Query[int](c, "from", 0)
+	if from == 0 {
+		from = to - 1
+		if from < 1 {
+			from = 1
+		}
+	}
+
+	if from < 1 || to < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid version number",
+		})
+	}
+
+	context := fiber.Query[int](c, "context", defaultDiffContext)
+	if context < 0 || context > maxDiffContext {
+		context = defaultDiffContext
+	}
+
+	var versions []models.CodeSnippetVersion
+	if err := h.db.Where("snippet_id = ? AND version_number IN ?", codeSnippet.ID, []int{from, to}).
+		Find(&versions).Error; err != nil {
+		log.Printf("Error fetching code snippet versions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if len(versions) == 0 && codeSnippet.Version == 1 {
+		versions = append(versions, services.CurrentCodeVersion(codeSnippet))
+	}
+
+	var fromVersion, toVersion *models.CodeSnippetVersion
+	for i := range versions {
+		if versions[i].VersionNumber == from {
+			fromVersion = &versions[i]
+		}
+		if versions[i].VersionNumber == to {
+			toVersion = &versions[i]
+		}
+	}
+	if fromVersion == nil || toVersion == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Version not found",
+		})
+	}
+
+	name := codeSnippet.ID.String()
+	if codeSnippet.FileName != nil && *codeSnippet.FileName != "" {
+		name = path.Base(*codeSnippet.FileName)
+	}
+
+	result := diff.Lines(fromVersion.Code, toVersion.Code, context)
+	unified := result.Unified(
+		fmt.Sprintf("a/%s@%d", name, from),
+		fmt.Sprintf("b/%s@%d", name, to),
+	)
+
+	if c.Query("format") == "unified" {
+		c.Set(fiber.HeaderContentType, "text/x-diff; charset=utf-8")
+		return c.SendString(unified)
+	}
+
+	return c.JSON(models.CodeSnippetDiffResponse{
+		SnippetID: codeSnippet.ID,
+		From:      fromVersion.ToRef(),
+		To:        toVersion.ToRef(),
+		Additions: result.Additions,
+		Deletions: result.Deletions,
+		Hunks:     result.Hunks,
+		Unified:   unified,
+	})
+}
+
+// FormatCodeSnippet pretty-prints the snippet's code in its language and
+// saves the result as a new version. Code that is already formatted is
+// returned unchanged, without a new version.
+func (h *CodeHandler) FormatCodeSnippet(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	codeSnippet := h.loadCodeSnippet(c)
+	if codeSnippet == nil {
+		return nil
+	}
+
+	if codeSnippet.CreatedByID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the creator can edit this code snippet",
+		})
+	}
+
+	formatted, err := codefmt.Format(string(codeSnippet.Language), codeSnippet.Code)
+	if errors.Is(err, codefmt.ErrUnsupported) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Formatting is not supported for %s", codeSnippet.Language),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   "Code could not be formatted",
+			"details": err.Error(),
+		})
+	}
+
+	if formatted != codeSnippet.Code {
+		previous := *codeSnippet
+		codeSnippet.Code = formatted
+		moved, err := h.codeService.SaveVersion(c.Context(), &previous, codeSnippet, uid, "Formatted")
+		if errors.Is(err, services.ErrStaleCodeSnippet) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Code snippet was changed by someone else, reload it and try again",
+			})
+		}
+		if err != nil {
+			log.Printf("Error formatting code snippet: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to format code snippet",
+			})
+		}
+		h.publishMovedCodeComments(c, codeSnippet, moved)
+	}
+
+	if err := h.db.Preload("CreatedBy").First(codeSnippet, codeSnippet.ID).Error; err != nil {
+		log.Printf("Error loading code snippet with creator: %v", err)
+	}
+
+	return c.JSON(codeSnippet.ToResponse())
+}
+
+// loadCodeSnippet fetches the snippet named by the :id route parameter. On
+// failure it writes the error response and returns nil.
+func (h *CodeHandler) loadCodeSnippet(c fiber.Ctx) *models.CodeSnippet {
+	sid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid code snippet ID",
+		})
+		return nil
+	}
+
+	var codeSnippet models.CodeSnippet
+	if err := h.db.First(&codeSnippet, sid).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Code snippet not found",
+			})
+			return nil
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return nil
+	}
+	return &codeSnippet
+}
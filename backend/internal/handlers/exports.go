@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+type ExportHandler struct {
+	db            *gorm.DB
+	exportService *services.ExportService
+}
+
+func NewExportHandler(db *gorm.DB) *ExportHandler {
+	return &ExportHandler{
+		db:            db,
+		exportService: services.NewExportService(db),
+	}
+}
+
+// CreateExport queues a background export of the whole chat. In groups only
+// admins may export; in DMs either participant may.
+func (h *ExportHandler) CreateExport(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	cid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid chat ID",
+		})
+	}
+
+	var chat models.Chat
+	if err := h.db.First(&chat, cid).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Chat not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	var chatMember models.ChatMember
+	if err := h.db.Where("chat_id = ? AND user_id = ?", cid, uid).First(&chatMember).Error; err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not a member of this chat",
+		})
+	}
+
+	if chat.Type == models.ChatTypeGroup && chatMember.Role != models.MemberRoleAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only admins can export group chats",
+		})
+	}
+
+	var req models.CreateChatExportRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	// An export whose job died with a restart must not block this one.
+	if _, err := h.exportService.FailStaleExports(); err != nil {
+		log.Printf("Error failing stale chat exports: %v", err)
+	}
+
+	var inProgress models.ChatExport
+	err = h.db.Where("chat_id = ? AND requested_by_id = ? AND status IN ?", cid, uid,
+		[]models.ExportStatus{models.ExportStatusPending, models.ExportStatusRunning}).
+		First(&inProgress).Error
+	if err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  "An export of this chat is already in progress",
+			"export": inProgress.ToResponse(),
+		})
+	}
+
+	export := models.ChatExport{
+		ChatID:        cid,
+		RequestedByID: uid,
+		IncludeMedia:  req.IncludeMedia == nil || *req.IncludeMedia,
+		Status:        models.ExportStatusPending,
+	}
+
+	if err := h.db.Create(&export).Error; err != nil {
+		log.Printf("Error creating chat export: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start export",
+		})
+	}
+
+	go h.exportService.Run(export.ID)
+
+	return c.Status(fiber.StatusAccepted).JSON(export.ToResponse())
+}
+
+// GetExport reports the progress of an export started by the current user.
+func (h *ExportHandler) GetExport(c fiber.Ctx) error {
+	export := h.loadExport(c)
+	if export == nil {
+		return nil
+	}
+
+	return c.JSON(export.ToResponse())
+}
+
+func (h *ExportHandler) DownloadExport(c fiber.Ctx) error {
+	export := h.loadExport(c)
+	if export == nil {
+		return nil
+	}
+
+	switch export.Status {
+	case models.ExportStatusPending, models.ExportStatusRunning:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Export is not ready yet",
+		})
+	case models.ExportStatusFailed:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "Export failed",
+		})
+	}
+
+	if export.Status == models.ExportStatusExpired || export.FilePath == nil ||
+		export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt) {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error": "Export has expired",
+		})
+	}
+
+	filename := "chat-" + export.ChatID.String() + "-" + export.CreatedAt.Format("20060102") + ".zip"
+	return c.Download(*export.FilePath, filename)
+}
+
+// loadExport fetches the export addressed by the route for its requester.
+// On failure it writes the error response and returns nil.
+func (h *ExportHandler) loadExport(c fiber.Ctx) *models.ChatExport {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+		return nil
+	}
+
+	cid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid chat ID",
+		})
+		return nil
+	}
+
+	eid, err := uuid.Parse(c.Params("exportId"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid export ID",
+		})
+		return nil
+	}
+
+	var export models.ChatExport
+	if err := h.db.Where("id = ? AND chat_id = ? AND requested_by_id = ?", eid, cid, uid).
+		First(&export).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Export not found",
+			})
+			return nil
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return nil
+	}
+
+	return &export
+}
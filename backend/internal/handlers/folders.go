@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+type FolderHandler struct {
+	db          *gorm.DB
+	redis       *redis.Client
+	chatService *services.ChatService
+}
+
+func NewFolderHandler(db *gorm.DB, redisClient *redis.Client) *FolderHandler {
+	return &FolderHandler{
+		db:          db,
+		redis:       redisClient,
+		chatService: services.NewChatService(db, redisClient),
+	}
+}
+
+func (h *FolderHandler) ListFolders(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	folders, err := h.loadFolders(uid)
+	if err != nil {
+		log.Printf("Error listing chat folders: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list folders",
+		})
+	}
+
+	unread, err := h.chatService.GetFolderUnreadCounts(c.Context(), uid, folders)
+	if err != nil {
+		log.Printf("Error counting folder unread totals: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list folders",
+		})
+	}
+
+	responses := make([]models.ChatFolderResponse, len(folders))
+	for i, folder := range folders {
+		responses[i] = models.ChatFolderResponse{
+			ChatFolder:  folder,
+			UnreadCount: unread[folder.ID].UnreadCount,
+			UnreadChats: unread[folder.ID].UnreadChats,
+		}
+	}
+
+	return c.JSON(responses)
+}
+
+func (h *FolderHandler) CreateFolder(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req models.CreateChatFolderRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Folder name is required",
+		})
+	}
+
+	var count int64
+	h.db.Model(&models.ChatFolder{}).Where("user_id = ?", uid).Count(&count)
+	if count >= models.MaxChatFolders {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Folder limit reached",
+		})
+	}
+
+	folder := models.ChatFolder{
+		UserID:          uid,
+		Name:            req.Name,
+		Emoji:           req.Emoji,
+		Position:        int(count),
+		IncludeChatIDs:  req.IncludeChatIDs,
+		ExcludeChatIDs:  req.ExcludeChatIDs,
+		ChatTypes:       req.ChatTypes,
+		ContactsOnly:    req.ContactsOnly,
+		UnreadOnly:      req.UnreadOnly,
+		ExcludeMuted:    req.ExcludeMuted,
+		IncludeArchived: req.IncludeArchived,
+	}
+
+	if !folder.HasRules() && len(folder.IncludeChatIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Folder must include chats or define at least one rule",
+		})
+	}
+
+	if err := h.db.Create(&folder).Error; err != nil {
+		log.Printf("Error creating chat folder: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create folder",
+		})
+	}
+
+	h.publishFoldersUpdated(c, uid)
+
+	return c.Status(fiber.StatusCreated).JSON(folder)
+}
+
+func (h *FolderHandler) UpdateFolder(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	fid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid folder ID",
+		})
+	}
+
+	var folder models.ChatFolder
+	if err := h.db.Where("id = ? AND user_id = ?", fid, uid).First(&folder).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Folder not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	var req models.UpdateChatFolderRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Name != nil {
+		folder.Name = *req.Name
+	}
+	if req.Emoji != nil {
+		folder.Emoji = req.Emoji
+	}
+	if req.Position != nil {
+		folder.Position = *req.Position
+	}
+	if req.IncludeChatIDs != nil {
+		folder.IncludeChatIDs = *req.IncludeChatIDs
+	}
+	if req.ExcludeChatIDs != nil {
+		folder.ExcludeChatIDs = *req.ExcludeChatIDs
+	}
+	if req.ChatTypes != nil {
+		folder.ChatTypes = *req.ChatTypes
+	}
+	if req.ContactsOnly != nil {
+		folder.ContactsOnly = *req.ContactsOnly
+	}
+	if req.UnreadOnly != nil {
+		folder.UnreadOnly = *req.UnreadOnly
+	}
+	if req.ExcludeMuted != nil {
+		folder.ExcludeMuted = *req.ExcludeMuted
+	}
+	if req.IncludeArchived != nil {
+		folder.IncludeArchived = *req.IncludeArchived
+	}
+
+	if !folder.HasRules() && len(folder.IncludeChatIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Folder must include chats or define at least one rule",
+		})
+	}
+
+	if err := h.db.Save(&folder).Error; err != nil {
+		log.Printf("Error updating chat folder: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update folder",
+		})
+	}
+
+	h.publishFoldersUpdated(c, uid)
+
+	return c.JSON(folder)
+}
+
+func (h *FolderHandler) DeleteFolder(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	fid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid folder ID",
+		})
+	}
+
+	result := h.db.Where("id = ? AND user_id = ?", fid, uid).Delete(&models.ChatFolder{})
+	if result.Error != nil {
+		log.Printf("Error deleting chat folder: %v", result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete folder",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Folder not found",
+		})
+	}
+
+	h.publishFoldersUpdated(c, uid)
+
+	return c.JSON(fiber.Map{
+		"message": "Folder deleted successfully",
+	})
+}
+
+func (h *FolderHandler) loadFolders(userID uuid.UUID) ([]models.ChatFolder, error) {
+	var folders []models.ChatFolder
+	err := h.db.Where("user_id = ?", userID).Order("position ASC, created_at ASC").Find(&folders).Error
+	return folders, err
+}
+
+// publishFoldersUpdated pushes the current folder list to every device of the user.
+func (h *FolderHandler) publishFoldersUpdated(c fiber.Ctx, userID uuid.UUID) {
+	folders, err := h.loadFolders(userID)
+	if err != nil {
+		log.Printf("Error loading chat folders for sync: %v", err)
+		return
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"type":    "folders_updated",
+		"folders": folders,
+	})
+	if err != nil {
+		return
+	}
+
+	h.redis.Publish(c.Context(), "user:"+userID.String(), payload)
+}
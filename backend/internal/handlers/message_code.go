@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+// GetMessageCodeBlocks lists the fenced code blocks of a text message with
+// their detected languages, and the snippets of those already promoted.
+func (h *MessageHandler) GetMessageCodeBlocks(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	message := h.loadCodeBlockMessage(c)
+	if message == nil {
+		return nil
+	}
+
+	var count int64
+	if err := h.db.Model(&models.ChatMember{}).Where("chat_id = ? AND user_id = ?", message.ChatID, uid).Count(&count).Error; err != nil || count == 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+	}
+
+	blocks, err := h.messageCodeBlocks(message)
+	if err != nil {
+		log.Printf("Error loading promoted code blocks: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if blocks == nil {
+		blocks = []models.CodeBlock{}
+	}
+
+	return c.JSON(blocks)
+}
+
+// PromoteCodeBlock posts one of the sender's code blocks as a code message
+// replying to the original, with a snippet in the detected language unless
+// the request names one.
+func (h *MessageHandler) PromoteCodeBlock(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	index, err := strconv.Atoi(c.Params("index"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid code block index",
+		})
+	}
+
+	var req models.PromoteCodeBlockRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	if req.Language != nil && !req.Language.IsValid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid language",
+		})
+	}
+
+	original := h.loadCodeBlockMessage(c)
+	if original == nil {
+		return nil
+	}
+	if original.SenderID == nil || *original.SenderID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the sender can promote code blocks",
+		})
+	}
+
+	blocks, err := h.messageCodeBlocks(original)
+	if err != nil {
+		log.Printf("Error loading promoted code blocks: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if index < 0 || index >= len(blocks) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Code block not found",
+		})
+	}
+	block := blocks[index]
+	if block.SnippetID != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":      "Code block already promoted",
+			"snippet_id": block.SnippetID,
+		})
+	}
+
+	var chatMember models.ChatMember
+	if err := h.db.Where("chat_id = ? AND user_id = ?", original.ChatID, uid).First(&chatMember).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "You are not a member of this chat",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// The promoted message goes to the original's topic, which may have been
+	// closed or deleted since, or the chat may have left forum mode.
+	var topic *string
+	if original.TopicID != nil {
+		value := original.TopicID.String()
+		topic = &value
+	}
+	topicID, err := h.chatService.ResolveMessageTopic(c.Context(), &chatMember, topic)
+	if err != nil {
+		return postingRestrictedResponse(c, err)
+	}
+
+	if err := h.chatService.CheckPostingAllowed(c.Context(), &chatMember, models.MessageTypeCode, block.Code); err != nil {
+		return postingRestrictedResponse(c, err)
+	}
+
+	threadRootID, err := h.chatService.ResolveThreadRoot(c.Context(), original.ChatID, &original.ID)
+	if err != nil {
+		h.chatService.ReleaseSlowMode(c.Context(), &chatMember)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	message := models.Message{
+		SenderID:     &uid,
+		ChatID:       original.ChatID,
+		Content:      block.Code,
+		MessageType:  models.MessageTypeCode,
+		ReplyToID:    &original.ID,
+		TopicID:      topicID,
+		ThreadRootID: threadRootID,
+	}
+	snippet := models.CodeSnippet{
+		ChatID:         original.ChatID,
+		Language:       block.Language,
+		Code:           block.Code,
+		FileName:       req.FileName,
+		CreatedByID:    uid,
+		PromotedFromID: &original.ID,
+		PromotedBlock:  &block.Index,
+		PromotedHash:   services.CodeBlockHash(block.Code),
+	}
+	// A language named after the fence counts as chosen, like one in the
+	// request.
+	if req.Language != nil {
+		snippet.Language = *req.Language
+	} else if block.Confidence < 1 {
+		snippet.LanguageDetected = true
+		snippet.LanguageConfidence = block.Confidence
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		snippet.MessageID = message.ID
+		return services.CreateCodeSnippet(tx, &snippet)
+	})
+	if err != nil {
+		h.chatService.ReleaseSlowMode(c.Context(), &chatMember)
+		log.Printf("Error promoting code block: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to promote code block",
+		})
+	}
+
+	if err := h.db.Preload("Sender").First(&message, message.ID).Error; err != nil {
+		log.Printf("Error loading message with sender: %v", err)
+	}
+
+	messageJSON, err := json.Marshal(message.ToResponse())
+	if err == nil {
+		h.redis.Publish(c.Context(), "chat:"+original.ChatID.String(), messageJSON)
+	}
+
+	h.chatService.HandleNewMessage(c.Context(), &message)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":      message.ToResponse(),
+		"code_snippet": snippet.ToResponse(),
+	})
+}
+
+// loadCodeBlockMessage fetches the text message named by the :id route
+// parameter. On failure it writes the error response and returns nil.
+func (h *MessageHandler) loadCodeBlockMessage(c fiber.Ctx) *models.Message {
+	mid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid message ID",
+		})
+		return nil
+	}
+
+	var message models.Message
+	if err := h.db.First(&message, mid).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Message not found",
+			})
+			return nil
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return nil
+	}
+
+	if message.IsDeleted || message.MessageType != models.MessageTypeText {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only text messages have code blocks",
+		})
+		return nil
+	}
+	return &message
+}
+
+// messageCodeBlocks finds the fenced code blocks of a text message and
+// marks those already promoted with their snippet.
+func (h *MessageHandler) messageCodeBlocks(message *models.Message) ([]models.CodeBlock, error) {
+	blocks := services.CodeBlocks(message.Content)
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+
+	var snippets []models.CodeSnippet
+	if err := h.db.Select("id", "promoted_block", "promoted_hash").Where("promoted_from_id = ?", message.ID).Find(&snippets).Error; err != nil {
+		return nil, err
+	}
+	services.MarkPromotedBlocks(blocks, snippets)
+	return blocks, nil
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
     "encoding/json"
+    "errors"
     "log"
     "strconv"
     "strings"
@@ -9,6 +10,7 @@ import (
     "github.com/gofiber/fiber/v3"
     "github.com/google/uuid"
     "github.com/messenger/backend/internal/models"
+    "github.com/messenger/backend/internal/services"
     "github.com/messenger/backend/pkg/media"
     "github.com/redis/go-redis/v9"
     "gorm.io/gorm"
@@ -18,6 +20,7 @@ type MessageHandler struct {
     db             *gorm.DB
     redis          *redis.Client
     mediaUploader  *media.MediaUploader
+    chatService    *services.ChatService
 }
 
 func NewMessageHandler(db *gorm.DB, redisClient *redis.Client) *MessageHandler {
@@ -25,6 +28,7 @@ func NewMessageHandler(db *gorm.DB, redisClient *redis.Client) *MessageHandler {
         db:            db,
         redis:         redisClient,
         mediaUploader: media.NewMediaUploader(),
+        chatService:   services.NewChatService(db, redisClient),
     }
 }
 
@@ -69,6 +73,15 @@ func (h *MessageHandler) SendMessage(c fiber.Ctx) error {
         messageType = req.MessageType
     }
 
+    topicID, err := h.chatService.ResolveMessageTopic(c.Context(), &chatMember, req.TopicID)
+    if err != nil {
+        return postingRestrictedResponse(c, err)
+    }
+
+    if err := h.chatService.CheckPostingAllowed(c.Context(), &chatMember, messageType, req.Content); err != nil {
+        return postingRestrictedResponse(c, err)
+    }
+
     var replyToID *uuid.UUID
     if req.ReplyToID != nil {
         rid, err := uuid.Parse(*req.ReplyToID)
@@ -77,15 +90,26 @@ func (h *MessageHandler) SendMessage(c fiber.Ctx) error {
         }
     }
 
+    threadRootID, err := h.chatService.ResolveThreadRoot(c.Context(), chatID, replyToID)
+    if err != nil {
+        h.chatService.ReleaseSlowMode(c.Context(), &chatMember)
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Database error",
+        })
+    }
+
     message := models.Message{
-        SenderID:    &uid,
-        ChatID:      chatID,
-        Content:     req.Content,
-        MessageType: messageType,
-        ReplyToID:   replyToID,
+        SenderID:     &uid,
+        ChatID:       chatID,
+        Content:      req.Content,
+        MessageType:  messageType,
+        ReplyToID:    replyToID,
+        TopicID:      topicID,
+        ThreadRootID: threadRootID,
     }
 
     if err := h.db.Create(&message).Error; err != nil {
+        h.chatService.ReleaseSlowMode(c.Context(), &chatMember)
         log.Printf("Error creating message: %v", err)
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
             "error": "Failed to send message",
@@ -102,7 +126,14 @@ func (h *MessageHandler) SendMessage(c fiber.Ctx) error {
         h.redis.Publish(c.Context(), channelName, messageJSON)
     }
 
-    return c.Status(fiber.StatusCreated).JSON(message.ToResponse())
+    h.chatService.HandleNewMessage(c.Context(), &message)
+
+    resp := message.ToResponse()
+    if message.MessageType == models.MessageTypeText {
+        resp.CodeBlocks = services.CodeBlocks(message.Content)
+    }
+
+    return c.Status(fiber.StatusCreated).JSON(resp)
 }
 
 func (h *MessageHandler) SendMediaMessage(c fiber.Ctx) error {
@@ -156,8 +187,20 @@ func (h *MessageHandler) SendMediaMessage(c fiber.Ctx) error {
         })
     }
 
+    topicValue := c.FormValue("topic_id")
+    topicID, err := h.chatService.ResolveMessageTopic(c.Context(), &chatMember, &topicValue)
+    if err != nil {
+        return postingRestrictedResponse(c, err)
+    }
+
+    declaredType := determineMessageType(file.Header.Get("Content-Type"))
+    if err := h.chatService.CheckPostingAllowed(c.Context(), &chatMember, declaredType, c.FormValue("content")); err != nil {
+        return postingRestrictedResponse(c, err)
+    }
+
     openedFile, err := file.Open()
     if err != nil {
+        h.chatService.ReleaseSlowMode(c.Context(), &chatMember)
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
             "error": "Failed to open file",
         })
@@ -166,6 +209,7 @@ func (h *MessageHandler) SendMediaMessage(c fiber.Ctx) error {
 
     result, err := h.mediaUploader.SaveFile(openedFile, file, uid)
     if err != nil {
+        h.chatService.ReleaseSlowMode(c.Context(), &chatMember)
         log.Printf("Error saving file: %v", err)
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
             "error": "Failed to save file",
@@ -185,9 +229,11 @@ func (h *MessageHandler) SendMediaMessage(c fiber.Ctx) error {
         MessageType: messageType,
         MediaURL:    &result.FilePath,
         MediaSize:   &result.FileSize,
+        TopicID:     topicID,
     }
 
     if err := h.db.Create(&message).Error; err != nil {
+        h.chatService.ReleaseSlowMode(c.Context(), &chatMember)
         h.mediaUploader.DeleteFile(result.FilePath)
         if result.Thumbnail != nil {
             h.mediaUploader.DeleteFile(*result.Thumbnail)
@@ -221,6 +267,8 @@ func (h *MessageHandler) SendMediaMessage(c fiber.Ctx) error {
         h.redis.Publish(c.Context(), channelName, messageJSON)
     }
 
+    h.chatService.HandleNewMessage(c.Context(), &message)
+
     return c.Status(fiber.StatusCreated).JSON(fiber.Map{
         "message": message.ToResponse(),
         "media": fiber.Map{
@@ -384,6 +432,12 @@ func (h *MessageHandler) EditMessage(c fiber.Ctx) error {
         })
     }
 
+    if message.MessageType == models.MessageTypeText {
+        if err := services.RematchPromotedBlocks(h.db, message.ID, req.Content); err != nil {
+            log.Printf("Error rematching promoted code blocks: %v", err)
+        }
+    }
+
     h.db.Preload("Sender").First(&message, message.ID)
 
     messageJSON, err := json.Marshal(message.ToResponse())
@@ -392,7 +446,14 @@ func (h *MessageHandler) EditMessage(c fiber.Ctx) error {
         h.redis.Publish(c.Context(), channelName, messageJSON)
     }
 
-    return c.JSON(message.ToResponse())
+    resp := message.ToResponse()
+    if message.MessageType == models.MessageTypeText {
+        if resp.CodeBlocks, err = h.messageCodeBlocks(&message); err != nil {
+            log.Printf("Error loading promoted code blocks: %v", err)
+        }
+    }
+
+    return c.JSON(resp)
 }
 
 func determineMessageType(mimeType string) models.MessageType {
@@ -408,6 +469,34 @@ func determineMessageType(mimeType string) models.MessageType {
     return models.MessageTypeFile
 }
 
+// postingRestrictedResponse maps a ChatService.CheckPostingAllowed error to a response.
+func postingRestrictedResponse(c fiber.Ctx, err error) error {
+    if errors.Is(err, services.ErrTopicNotFound) {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": "Topic not found",
+        })
+    }
+
+    var restricted *services.PostingRestrictedError
+    if !errors.As(err, &restricted) {
+        log.Printf("Error checking chat restrictions: %v", err)
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Failed to check chat restrictions",
+        })
+    }
+
+    if restricted.RetryAfter > 0 {
+        return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+            "error":       restricted.Reason,
+            "retry_after": int(restricted.RetryAfter.Seconds()),
+        })
+    }
+
+    return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+        "error": restricted.Reason,
+    })
+}
+
 func mergeMaps(maps ...map[string]bool) map[string]bool {
     result := make(map[string]bool)
     for _, m := range maps {
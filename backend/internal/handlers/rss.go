@@ -12,18 +12,22 @@ import (
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 type RSSHandler struct {
-	db        *gorm.DB
-	client    *http.Client
+	db             *gorm.DB
+	client         *http.Client
+	channelService *services.ChannelService
 }
 
-func NewRSSHandler(db *gorm.DB) *RSSHandler {
+func NewRSSHandler(db *gorm.DB, redisClient *redis.Client) *RSSHandler {
 	return &RSSHandler{
-		db:     db,
-		client: &http.Client{Timeout: 30 * time.Second},
+		db:             db,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		channelService: services.NewChannelService(db, redisClient),
 	}
 }
 
@@ -88,6 +92,12 @@ func (h *RSSHandler) AddRSSFeed(c fiber.Ctx) error {
 		})
 	}
 
+	if !h.channelService.CanManageRSS(&channel, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot manage this channel's RSS feed",
+		})
+	}
+
 	var existingFeed models.RSSFeed
 	if err := h.db.Where("channel_id = ?", channelID).First(&existingFeed).Error; err == nil {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
@@ -176,8 +186,16 @@ func (h *RSSHandler) AddRSSFeed(c fiber.Ctx) error {
 }
 
 func (h *RSSHandler) GetRSSFeed(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
 	id := c.Params("id")
 
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
 	sid, err := uuid.Parse(id)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -197,6 +215,12 @@ func (h *RSSHandler) GetRSSFeed(c fiber.Ctx) error {
 		})
 	}
 
+	if !h.canReadFeed(&rssFeed, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+	}
+
 	return c.JSON(rssFeed.ToResponse())
 }
 
@@ -230,9 +254,9 @@ func (h *RSSHandler) UpdateRSSFeed(c fiber.Ctx) error {
 		})
 	}
 
-	if rssFeed.AddedByID != uid {
+	if !h.canManageFeed(&rssFeed, uid) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Only the creator can update this RSS feed",
+			"error": "You cannot manage this channel's RSS feed",
 		})
 	}
 
@@ -310,9 +334,9 @@ func (h *RSSHandler) DeleteRSSFeed(c fiber.Ctx) error {
 		})
 	}
 
-	if rssFeed.AddedByID != uid {
+	if !h.canManageFeed(&rssFeed, uid) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Only the creator can delete this RSS feed",
+			"error": "You cannot manage this channel's RSS feed",
 		})
 	}
 
@@ -358,6 +382,7 @@ func (h *RSSHandler) ListRSSFeeds(c fiber.Ctx) error {
 }
 
 func (h *RSSHandler) GetRSSFeedItems(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
 	id := c.Params("id")
 	limit := c.QueryInt("limit", 20)
 	offset := c.QueryInt("offset", 0)
@@ -366,6 +391,13 @@ func (h *RSSHandler) GetRSSFeedItems(c fiber.Ctx) error {
 		limit = 100
 	}
 
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
 	sid, err := uuid.Parse(id)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -373,6 +405,24 @@ func (h *RSSHandler) GetRSSFeedItems(c fiber.Ctx) error {
 		})
 	}
 
+	var rssFeed models.RSSFeed
+	if err := h.db.First(&rssFeed, sid).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "RSS feed not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	if !h.canReadFeed(&rssFeed, uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+	}
+
 	var items []models.RSSItem
 	if err := h.db.Where("feed_id = ?", sid).
 		Order("published_at DESC").
@@ -423,9 +473,9 @@ func (h *RSSHandler) RefreshRSSFeed(c fiber.Ctx) error {
 		})
 	}
 
-	if rssFeed.AddedByID != uid {
+	if !h.canManageFeed(&rssFeed, uid) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Only the creator can refresh this RSS feed",
+			"error": "You cannot manage this channel's RSS feed",
 		})
 	}
 
@@ -514,6 +564,26 @@ func (h *RSSHandler) RefreshRSSFeed(c fiber.Ctx) error {
 	})
 }
 
+// canReadFeed reports whether the user may see the feed; feeds of private
+// channels are visible to subscribers only.
+func (h *RSSHandler) canReadFeed(feed *models.RSSFeed, userID uuid.UUID) bool {
+	var channel models.Channel
+	if err := h.db.First(&channel, feed.ChannelID).Error; err != nil {
+		return false
+	}
+	return h.channelService.CanRead(&channel, userID)
+}
+
+// canManageFeed reports whether the user is the owner of the feed's channel
+// or an admin allowed to manage its RSS.
+func (h *RSSHandler) canManageFeed(feed *models.RSSFeed, userID uuid.UUID) bool {
+	var channel models.Channel
+	if err := h.db.First(&channel, feed.ChannelID).Error; err != nil {
+		return false
+	}
+	return h.channelService.CanManageRSS(&channel, userID)
+}
+
 func (h *RSSHandler) fetchRSSFeed(url string) (*RSSFeed, error) {
 	resp, err := h.client.Get(url)
 	if err != nil {
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+type TopicHandler struct {
+	db          *gorm.DB
+	redis       *redis.Client
+	chatService *services.ChatService
+}
+
+func NewTopicHandler(db *gorm.DB, redisClient *redis.Client) *TopicHandler {
+	return &TopicHandler{
+		db:          db,
+		redis:       redisClient,
+		chatService: services.NewChatService(db, redisClient),
+	}
+}
+
+// SetForumMode switches a group chat in or out of topics mode. Admins only.
+func (h *TopicHandler) SetForumMode(c fiber.Ctx) error {
+	uid, cid, ok := h.parseIDs(c)
+	if !ok {
+		return nil
+	}
+
+	member := h.loadMember(c, cid, uid)
+	if member == nil {
+		return nil
+	}
+	if member.Role != models.MemberRoleAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only admins can change forum mode",
+		})
+	}
+
+	var chat models.Chat
+	if err := h.db.First(&chat, cid).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Chat not found",
+		})
+	}
+	if chat.Type != models.ChatTypeGroup {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Topics are only available in group chats",
+		})
+	}
+
+	var req models.UpdateForumModeRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.chatService.SetForumMode(c.Context(), cid, uid, req.Enabled); err != nil {
+		log.Printf("Error changing forum mode: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to change forum mode",
+		})
+	}
+
+	h.db.Preload("Members.User").First(&chat, cid)
+
+	event, err := json.Marshal(map[string]interface{}{
+		"type":     "forum_mode",
+		"chat_id":  cid,
+		"is_forum": chat.IsForum,
+	})
+	if err == nil {
+		h.redis.Publish(c.Context(), "chat:"+cid.String(), event)
+	}
+
+	return c.JSON(chat.ToResponse())
+}
+
+func (h *TopicHandler) ListTopics(c fiber.Ctx) error {
+	uid, cid, ok := h.parseIDs(c)
+	if !ok {
+		return nil
+	}
+
+	if h.loadMember(c, cid, uid) == nil {
+		return nil
+	}
+
+	topics, err := h.chatService.GetTopicsWithUnread(c.Context(), cid, uid)
+	if err != nil {
+		log.Printf("Error listing chat topics: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list topics",
+		})
+	}
+
+	return c.JSON(topics)
+}
+
+func (h *TopicHandler) CreateTopic(c fiber.Ctx) error {
+	uid, cid, ok := h.parseIDs(c)
+	if !ok {
+		return nil
+	}
+
+	member := h.loadMember(c, cid, uid)
+	if member == nil {
+		return nil
+	}
+
+	var chat models.Chat
+	if err := h.db.First(&chat, cid).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Chat not found",
+		})
+	}
+	if !chat.IsForum {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Chat is not in forum mode",
+		})
+	}
+	if chat.Restrictions.AdminsOnly && member.Role != models.MemberRoleAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only admins can create topics in this chat",
+		})
+	}
+
+	var req models.CreateChatTopicRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Name == "" || len(req.Name) > 128 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Topic name must be 1-128 characters",
+		})
+	}
+
+	topic := models.ChatTopic{
+		ChatID:      cid,
+		Name:        req.Name,
+		IconEmoji:   req.IconEmoji,
+		CreatedByID: &uid,
+	}
+
+	if err := h.db.Create(&topic).Error; err != nil {
+		log.Printf("Error creating chat topic: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create topic",
+		})
+	}
+
+	h.publishTopicEvent(c, "topic_created", &topic)
+
+	return c.Status(fiber.StatusCreated).JSON(topic)
+}
+
+// UpdateTopic renames, re-icons, closes or reopens a topic. Admins may edit
+// any topic; other members only the ones they created.
+func (h *TopicHandler) UpdateTopic(c fiber.Ctx) error {
+	uid, cid, ok := h.parseIDs(c)
+	if !ok {
+		return nil
+	}
+
+	member := h.loadMember(c, cid, uid)
+	if member == nil {
+		return nil
+	}
+
+	topic := h.loadTopic(c, cid)
+	if topic == nil {
+		return nil
+	}
+
+	isCreator := topic.CreatedByID != nil && *topic.CreatedByID == uid
+	if member.Role != models.MemberRoleAdmin && (!isCreator || topic.IsGeneral) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only admins or the topic creator can edit this topic",
+		})
+	}
+
+	var req models.UpdateChatTopicRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if *req.Name == "" || len(*req.Name) > 128 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Topic name must be 1-128 characters",
+			})
+		}
+		updates["name"] = *req.Name
+	}
+	if req.IconEmoji != nil {
+		updates["icon_emoji"] = req.IconEmoji
+	}
+	if req.IsClosed != nil {
+		updates["is_closed"] = *req.IsClosed
+	}
+
+	if len(updates) > 0 {
+		if err := h.db.Model(topic).Updates(updates).Error; err != nil {
+			log.Printf("Error updating chat topic: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update topic",
+			})
+		}
+		h.db.First(topic, topic.ID)
+		h.publishTopicEvent(c, "topic_updated", topic)
+	}
+
+	return c.JSON(topic)
+}
+
+// DeleteTopic removes a topic together with its messages. The general topic
+// cannot be deleted.
+func (h *TopicHandler) DeleteTopic(c fiber.Ctx) error {
+	uid, cid, ok := h.parseIDs(c)
+	if !ok {
+		return nil
+	}
+
+	member := h.loadMember(c, cid, uid)
+	if member == nil {
+		return nil
+	}
+	if member.Role != models.MemberRoleAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only admins can delete topics",
+		})
+	}
+
+	topic := h.loadTopic(c, cid)
+	if topic == nil {
+		return nil
+	}
+	if topic.IsGeneral {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The general topic cannot be deleted",
+		})
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Message{}).
+			Where("topic_id = ?", topic.ID).
+			Update("is_deleted", true).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("topic_id = ?", topic.ID).Delete(&models.ChatTopicRead{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(topic).Error
+	})
+	if err != nil {
+		log.Printf("Error deleting chat topic: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete topic",
+		})
+	}
+
+	h.publishTopicEvent(c, "topic_deleted", topic)
+
+	return c.JSON(fiber.Map{
+		"message": "Topic deleted successfully",
+	})
+}
+
+func (h *TopicHandler) MarkTopicRead(c fiber.Ctx) error {
+	uid, cid, ok := h.parseIDs(c)
+	if !ok {
+		return nil
+	}
+
+	if h.loadMember(c, cid, uid) == nil {
+		return nil
+	}
+
+	topic := h.loadTopic(c, cid)
+	if topic == nil {
+		return nil
+	}
+
+	if err := h.chatService.MarkTopicRead(c.Context(), topic.ID, uid); err != nil {
+		log.Printf("Error marking topic as read: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to mark topic as read",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Topic marked as read",
+	})
+}
+
+// parseIDs reads the caller and the chat from the request. On failure it
+// writes the error response and returns ok == false.
+func (h *TopicHandler) parseIDs(c fiber.Ctx) (uuid.UUID, uuid.UUID, bool) {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	cid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid chat ID",
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return uid, cid, true
+}
+
+func (h *TopicHandler) loadMember(c fiber.Ctx, cid, uid uuid.UUID) *models.ChatMember {
+	var member models.ChatMember
+	if err := h.db.Where("chat_id = ? AND user_id = ?", cid, uid).First(&member).Error; err != nil {
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+		return nil
+	}
+	return &member
+}
+
+func (h *TopicHandler) loadTopic(c fiber.Ctx, cid uuid.UUID) *models.ChatTopic {
+	tid, err := uuid.Parse(c.Params("topicId"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid topic ID",
+		})
+		return nil
+	}
+
+	var topic models.ChatTopic
+	if err := h.db.Where("id = ? AND chat_id = ?", tid, cid).First(&topic).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Topic not found",
+			})
+			return nil
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return nil
+	}
+	return &topic
+}
+
+func (h *TopicHandler) publishTopicEvent(c fiber.Ctx, eventType string, topic *models.ChatTopic) {
+	event, err := json.Marshal(map[string]interface{}{
+		"type":    eventType,
+		"chat_id": topic.ChatID,
+		"topic":   topic,
+	})
+	if err != nil {
+		return
+	}
+
+	h.redis.Publish(c.Context(), "chat:"+topic.ChatID.String(), event)
+}
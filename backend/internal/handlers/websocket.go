@@ -3,6 +3,7 @@ package handlers
 import (
     "context"
     "encoding/json"
+    "errors"
     "log"
     "sync"
     "time"
@@ -10,6 +11,7 @@ import (
     "github.com/gofiber/websocket/v2"
     "github.com/google/uuid"
     "github.com/messenger/backend/internal/models"
+    "github.com/messenger/backend/internal/services"
     "github.com/messenger/backend/pkg/auth"
     "github.com/redis/go-redis/v9"
     "gorm.io/gorm"
@@ -18,6 +20,7 @@ import (
 type WebSocketHandler struct {
     db          *gorm.DB
     redis       *redis.Client
+    chatService *services.ChatService
     clients     sync.Map
     typingMu    sync.RWMutex
     typingUsers map[string]map[string]time.Time
@@ -33,6 +36,7 @@ type WSClient struct {
 type WSMessage struct {
     Type      string      `json:"type"`
     ChatID    string      `json:"chat_id,omitempty"`
+    TopicID   string      `json:"topic_id,omitempty"`
     Content   string      `json:"content,omitempty"`
     Data      interface{} `json:"data,omitempty"`
     Timestamp int64       `json:"timestamp,omitempty"`
@@ -74,6 +78,7 @@ func NewWebSocketHandler(db *gorm.DB, redisClient *redis.Client) *WebSocketHandl
     return &WebSocketHandler{
         db:          db,
         redis:       redisClient,
+        chatService: services.NewChatService(db, redisClient),
         typingUsers: make(map[string]map[string]time.Time),
     }
 }
@@ -215,14 +220,27 @@ func (h *WebSocketHandler) handleSendMessage(client *WSClient, msg *WSMessage) {
         return
     }
 
+    topicID, err := h.chatService.ResolveMessageTopic(context.Background(), &chatMember, &msg.TopicID)
+    if err != nil {
+        h.sendPostingRestricted(client, msg.ChatID, err)
+        return
+    }
+
+    if err := h.chatService.CheckPostingAllowed(context.Background(), &chatMember, models.MessageTypeText, msg.Content); err != nil {
+        h.sendPostingRestricted(client, msg.ChatID, err)
+        return
+    }
+
     message := models.Message{
         SenderID:    &uid,
         ChatID:      chatID,
         Content:     msg.Content,
         MessageType: models.MessageTypeText,
+        TopicID:     topicID,
     }
 
     if err := h.db.Create(&message).Error; err != nil {
+        h.chatService.ReleaseSlowMode(context.Background(), &chatMember)
         log.Printf("Error creating message: %v", err)
         return
     }
@@ -240,9 +258,38 @@ func (h *WebSocketHandler) handleSendMessage(client *WSClient, msg *WSMessage) {
     channelName := "chat:" + chatID.String()
     h.redis.Publish(context.Background(), channelName, messageJSON)
 
+    h.chatService.HandleNewMessage(context.Background(), &message)
+
     h.clearTypingIndicator(chatID.String(), client.UserID)
 }
 
+func (h *WebSocketHandler) sendPostingRestricted(client *WSClient, chatID string, err error) {
+    event := map[string]interface{}{
+        "type":    "error",
+        "chat_id": chatID,
+    }
+
+    var restricted *services.PostingRestrictedError
+    if errors.Is(err, services.ErrTopicNotFound) {
+        event["error"] = "Topic not found"
+    } else if errors.As(err, &restricted) {
+        event["error"] = restricted.Reason
+        if restricted.RetryAfter > 0 {
+            event["retry_after"] = int(restricted.RetryAfter.Seconds())
+        }
+    } else {
+        log.Printf("Error checking chat restrictions: %v", err)
+        event["error"] = "Failed to send message"
+    }
+
+    errorMsg, _ := json.Marshal(event)
+
+    select {
+    case client.Send <- errorMsg:
+    default:
+    }
+}
+
 func (h *WebSocketHandler) handleTypingIndicator(client *WSClient, msg *WSMessage) {
     chatID := msg.ChatID
     if chatID == "" {
@@ -438,6 +485,22 @@ func (h *WebSocketHandler) subscribeToUserChats(client *WSClient) {
         }
     }
 
+    var channelSubscriptions []models.ChannelSubscriber
+    if err := h.db.Where("user_id = ?", uid).Find(&channelSubscriptions).Error; err != nil {
+        log.Printf("Error finding user channel subscriptions: %v", err)
+    }
+
+    feeds := make([]string, len(channelSubscriptions))
+    for i, sub := range channelSubscriptions {
+        feeds[i] = "channel:" + sub.ChannelID.String()
+    }
+
+    if len(feeds) > 0 {
+        if err := pubsub.Subscribe(ctx, feeds...); err != nil {
+            log.Printf("Error subscribing to channel feeds: %v", err)
+        }
+    }
+
     personalChannel := "user:" + client.UserID
     if err := pubsub.Subscribe(ctx, personalChannel); err != nil {
         log.Printf("Error subscribing to personal channel: %v", err)
@@ -445,6 +508,10 @@ func (h *WebSocketHandler) subscribeToUserChats(client *WSClient) {
 
     ch := pubsub.Channel()
     for message := range ch {
+        if message.Channel == personalChannel {
+            h.applyChannelSubscriptionChange(ctx, pubsub, message.Payload)
+        }
+
         select {
         case client.Send <- []byte(message.Payload):
         default:
@@ -531,6 +598,53 @@ func (h *WebSocketHandler) BroadcastToUser(userID string, message []byte) {
     h.redis.Publish(context.Background(), channelName, message)
 }
 
+// BroadcastToChannel delivers an event to every connected subscriber of a channel.
+func (h *WebSocketHandler) BroadcastToChannel(channelID string, message []byte) {
+    h.redis.Publish(context.Background(), "channel:"+channelID, message)
+}
+
+// NotifyChannelSubscription tells the user's connections to start or stop
+// following a channel's feed.
+func (h *WebSocketHandler) NotifyChannelSubscription(userID, channelID string, subscribed bool) {
+    eventType := "channel_unsubscribed"
+    if subscribed {
+        eventType = "channel_subscribed"
+    }
+
+    event, err := json.Marshal(map[string]interface{}{
+        "type":       eventType,
+        "channel_id": channelID,
+    })
+    if err != nil {
+        return
+    }
+
+    h.BroadcastToUser(userID, event)
+}
+
+// applyChannelSubscriptionChange keeps a connection's feed subscriptions in
+// step with channel_subscribed / channel_unsubscribed events on its personal channel.
+func (h *WebSocketHandler) applyChannelSubscriptionChange(ctx context.Context, pubsub *redis.PubSub, payload string) {
+    var event struct {
+        Type      string `json:"type"`
+        ChannelID string `json:"channel_id"`
+    }
+    if err := json.Unmarshal([]byte(payload), &event); err != nil || event.ChannelID == "" {
+        return
+    }
+
+    switch event.Type {
+    case "channel_subscribed":
+        if err := pubsub.Subscribe(ctx, "channel:"+event.ChannelID); err != nil {
+            log.Printf("Error subscribing to channel feed: %v", err)
+        }
+    case "channel_unsubscribed":
+        if err := pubsub.Unsubscribe(ctx, "channel:"+event.ChannelID); err != nil {
+            log.Printf("Error unsubscribing from channel feed: %v", err)
+        }
+    }
+}
+
 func (h *WebSocketHandler) handleCallWebRTCMessage(client *WSClient, msg *WSMessage) {
     callID, ok := msg.Data.(map[string]interface{})["call_id"].(string)
     if !ok || callID == "" {
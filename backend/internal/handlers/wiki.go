@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"log"
 	"regexp"
 	"strings"
@@ -8,15 +10,31 @@ import (
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/pkg/media"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type WikiHandler struct {
-	db *gorm.DB
+	db             *gorm.DB
+	redis          *redis.Client
+	channelService *services.ChannelService
+	searchService  *services.WikiSearchService
+	wsHandler      *WebSocketHandler
+	mediaUploader  *media.MediaUploader
 }
 
-func NewWikiHandler(db *gorm.DB) *WikiHandler {
-	return &WikiHandler{db: db}
+func NewWikiHandler(db *gorm.DB, redisClient *redis.Client, wsHandler *WebSocketHandler) *WikiHandler {
+	return &WikiHandler{
+		db:             db,
+		redis:          redisClient,
+		channelService: services.NewChannelService(db, redisClient),
+		searchService:  services.NewWikiSearchService(db),
+		wsHandler:      wsHandler,
+		mediaUploader:  media.NewMediaUploader(),
+	}
 }
 
 func slugify(text string) string {
@@ -54,19 +72,24 @@ func (h *WikiHandler) CreateWikiPage(c fiber.Ctx) error {
 		})
 	}
 
-	if req.Slug == "" {
-		req.Slug = slugify(req.Title)
+	perms, ok := h.requireWikiReader(c, channelID, userID)
+	if !ok {
+		return nil
+	}
+	if !perms.Create {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot create pages in this channel's wiki",
+		})
 	}
 
-	var channel models.Channel
-	if err := h.db.First(&channel, channelID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Channel not found",
-			})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Database error",
+	if req.TemplateID != nil && !h.applyWikiTemplate(c, channelID, uid, &req) {
+		return nil
+	}
+	if req.Slug == "" {
+		req.Slug = services.UnreservedWikiSlug(slugify(req.Title))
+	} else if services.WikiSlugReserved(req.Slug) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "This slug is reserved",
 		})
 	}
 
@@ -77,10 +100,23 @@ func (h *WikiHandler) CreateWikiPage(c fiber.Ctx) error {
 		})
 	}
 
-	isPublished := true
+	// Pages of users who may not publish start out as drafts.
+	isPublished := perms.Publish
 	if req.IsPublished != nil {
 		isPublished = *req.IsPublished
 	}
+	if isPublished && !perms.Publish {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot publish pages in this channel's wiki",
+		})
+	}
+
+	isProtected := req.IsProtected != nil && *req.IsProtected
+	if isProtected && !perms.Admin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only wiki admins can protect pages",
+		})
+	}
 
 	order := 0
 	if req.Order != nil {
@@ -106,11 +142,17 @@ func (h *WikiHandler) CreateWikiPage(c fiber.Ctx) error {
 		CreatedByID: uid,
 		ParentID:    parentID,
 		IsPublished: isPublished,
+		IsProtected: isProtected,
 		Order:       order,
 	}
 
 	err = h.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(&wikiPage).Error; err != nil {
+		if parentID != nil {
+			if _, err := services.CheckWikiParent(tx, &wikiPage, *parentID); err != nil {
+				return err
+			}
+		}
+		if err := insertWikiPage(tx, &wikiPage); err != nil {
 			return err
 		}
 		revision := models.WikiRevision{
@@ -121,9 +163,15 @@ func (h *WikiHandler) CreateWikiPage(c fiber.Ctx) error {
 			ChangeSummary:  "Initial creation",
 			RevisionNumber: 1,
 		}
-		return tx.Create(&revision).Error
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		return services.SyncWikiLinks(tx, &wikiPage)
 	})
 
+	if errors.Is(err, services.ErrWikiParentNotFound) {
+		return wikiMoveError(c, err, "create wiki page")
+	}
 	if err != nil {
 		log.Printf("Error creating wiki page: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -135,10 +183,13 @@ func (h *WikiHandler) CreateWikiPage(c fiber.Ctx) error {
 		log.Printf("Error loading wiki page with relations: %v", err)
 	}
 
+	h.announceWikiChange(c.Context(), &wikiPage, models.WikiChangeCreated, uid)
+
 	return c.Status(fiber.StatusCreated).JSON(wikiPage.ToResponse())
 }
 
 func (h *WikiHandler) GetWikiPage(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
 	channelID := c.Params("channelId")
 	slug := c.Params("slug")
 
@@ -149,9 +200,23 @@ func (h *WikiHandler) GetWikiPage(c fiber.Ctx) error {
 		})
 	}
 
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+
 	var wikiPage models.WikiPage
 	if err := h.db.Preload("CreatedBy").Preload("Children").Where("channel_id = ? AND slug = ?", cid, slug).First(&wikiPage).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			target, err := h.channelService.WikiRedirectTarget(c.Context(), cid, slug)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Database error",
+				})
+			}
+			if target != nil && perms.CanViewPage(target) {
+				return redirectWikiPage(c, target)
+			}
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Wiki page not found",
 			})
@@ -161,7 +226,38 @@ func (h *WikiHandler) GetWikiPage(c fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(wikiPage.ToResponse())
+	if !perms.CanViewPage(&wikiPage) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Wiki page not found",
+		})
+	}
+	if !perms.Edit {
+		wikiPage.Children = publishedWikiPages(wikiPage.Children)
+	}
+
+	revision, err := latestWikiRevision(h.db, wikiPage.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	resp := wikiPage.ToResponse()
+	resp.Revision = revision
+	resp.Lock = h.wikiLock(c.Context(), wikiPage.ID)
+	h.recordWikiViewer(c.Context(), wikiPage.ID, userID)
+	c.Set(fiber.HeaderETag, wikiETag(revision))
+
+	if c.Query("format") == "html" {
+		if err := h.renderWikiHTML(&wikiPage, &resp); err != nil {
+			log.Printf("Error rendering wiki page: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to render wiki page",
+			})
+		}
+	}
+
+	return c.JSON(resp)
 }
 
 func (h *WikiHandler) UpdateWikiPage(c fiber.Ctx) error {
@@ -169,6 +265,13 @@ func (h *WikiHandler) UpdateWikiPage(c fiber.Ctx) error {
 	channelID := c.Params("channelId")
 	slug := c.Params("slug")
 
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
 	cid, err := uuid.Parse(channelID)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -188,12 +291,15 @@ func (h *WikiHandler) UpdateWikiPage(c fiber.Ctx) error {
 		})
 	}
 
-	// Permission check (simplified, in real app check if admin of channel)
-	if wikiPage.CreatedByID != uuid.MustParse(userID) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Only the creator can edit this page",
+	perms := h.wikiPermissions(c.Context(), cid, uid)
+	if !perms.CanViewPage(&wikiPage) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Wiki page not found",
 		})
 	}
+	if !perms.CanEditPage(&wikiPage) {
+		return denyWikiEdit(c, perms)
+	}
 
 	var req models.UpdateWikiPageRequest
 	if err := c.Bind().JSON(&req); err != nil {
@@ -202,18 +308,25 @@ func (h *WikiHandler) UpdateWikiPage(c fiber.Ctx) error {
 		})
 	}
 
-	if req.Title != nil {
-		wikiPage.Title = *req.Title
-	}
-	if req.Content != nil {
-		wikiPage.Content = *req.Content
+	if req.IsPublished != nil && *req.IsPublished != wikiPage.IsPublished && !perms.Publish {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot publish or unpublish pages in this channel's wiki",
+		})
 	}
-	if req.IsPublished != nil {
-		wikiPage.IsPublished = *req.IsPublished
+	if req.IsProtected != nil && *req.IsProtected != wikiPage.IsProtected && !perms.Admin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only wiki admins can protect pages",
+		})
 	}
-	if req.Order != nil {
-		wikiPage.Order = *req.Order
+
+	baseRevision, checkBase, ok := wikiBaseRevision(c, req.BaseRevision)
+	if !ok {
+		return c.Status(fiber.StatusPreconditionRequired).JSON(fiber.Map{
+			"error": "base_revision or an If-Match header with the page's ETag is required",
+		})
 	}
+
+	var parentID *uuid.UUID
 	if req.ParentID != nil {
 		pid, err := uuid.Parse(*req.ParentID)
 		if err != nil {
@@ -221,33 +334,68 @@ func (h *WikiHandler) UpdateWikiPage(c fiber.Ctx) error {
 				"error": "Invalid parent ID",
 			})
 		}
-		wikiPage.ParentID = &pid
+		parentID = &pid
 	}
 
+	var latestRevision int
 	err = h.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Save(&wikiPage).Error; err != nil {
+		// Lock the page so concurrent saves are checked one after another.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&wikiPage, wikiPage.ID).Error; err != nil {
 			return err
 		}
 
-		var lastRevision models.WikiRevision
-		tx.Where("page_id = ?", wikiPage.ID).Order("revision_number DESC").First(&lastRevision)
+		latest, err := latestWikiRevision(tx, wikiPage.ID)
+		if err != nil {
+			return err
+		}
+		latestRevision = latest
+		if checkBase && baseRevision != latest {
+			return errStaleWikiRevision
+		}
+
+		if req.Title != nil {
+			wikiPage.Title = *req.Title
+		}
+		if req.Content != nil {
+			wikiPage.Content = *req.Content
+		}
+		if req.IsPublished != nil {
+			wikiPage.IsPublished = *req.IsPublished
+		}
+		if req.IsProtected != nil {
+			wikiPage.IsProtected = *req.IsProtected
+		}
+		if req.Order != nil {
+			wikiPage.Order = *req.Order
+		}
+		if parentID != nil {
+			if _, err := services.CheckWikiParent(tx, &wikiPage, *parentID); err != nil {
+				return err
+			}
+			wikiPage.ParentID = parentID
+		}
+
+		if err := tx.Save(&wikiPage).Error; err != nil {
+			return err
+		}
 
 		summary := "Updated page"
 		if req.ChangeSummary != nil {
 			summary = *req.ChangeSummary
 		}
-
-		revision := models.WikiRevision{
-			PageID:         wikiPage.ID,
-			Title:          wikiPage.Title,
-			Content:        wikiPage.Content,
-			CreatedByID:    uuid.MustParse(userID),
-			ChangeSummary:  summary,
-			RevisionNumber: lastRevision.RevisionNumber + 1,
+		if err := services.AppendWikiRevision(tx, &wikiPage, uid, summary); err != nil {
+			return err
 		}
-		return tx.Create(&revision).Error
+		latestRevision++
+		return services.SyncWikiLinks(tx, &wikiPage)
 	})
 
+	if errors.Is(err, errStaleWikiRevision) {
+		return h.wikiConflict(c, &wikiPage, &req, baseRevision, latestRevision)
+	}
+	if errors.Is(err, services.ErrWikiParentNotFound) || errors.Is(err, services.ErrWikiParentCycle) {
+		return wikiMoveError(c, err, "update wiki page")
+	}
 	if err != nil {
 		log.Printf("Error updating wiki page: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -259,10 +407,16 @@ func (h *WikiHandler) UpdateWikiPage(c fiber.Ctx) error {
 		log.Printf("Error loading wiki page with relations: %v", err)
 	}
 
-	return c.JSON(wikiPage.ToResponse())
+	h.announceWikiChange(c.Context(), &wikiPage, models.WikiChangeUpdated, uid)
+
+	resp := wikiPage.ToResponse()
+	resp.Revision = latestRevision
+	c.Set(fiber.HeaderETag, wikiETag(latestRevision))
+	return c.JSON(resp)
 }
 
 func (h *WikiHandler) GetWikiRevisions(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
 	channelID := c.Params("channelId")
 	slug := c.Params("slug")
 
@@ -273,6 +427,11 @@ func (h *WikiHandler) GetWikiRevisions(c fiber.Ctx) error {
 		})
 	}
 
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+
 	var wikiPage models.WikiPage
 	if err := h.db.Where("channel_id = ? AND slug = ?", cid, slug).First(&wikiPage).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -285,6 +444,12 @@ func (h *WikiHandler) GetWikiRevisions(c fiber.Ctx) error {
 		})
 	}
 
+	if !perms.CanViewPage(&wikiPage) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Wiki page not found",
+		})
+	}
+
 	var revisions []models.WikiRevision
 	if err := h.db.Preload("CreatedBy").Where("page_id = ?", wikiPage.ID).Order("revision_number DESC").Find(&revisions).Error; err != nil {
 		log.Printf("Error fetching wiki revisions: %v", err)
@@ -301,6 +466,13 @@ func (h *WikiHandler) DeleteWikiPage(c fiber.Ctx) error {
 	channelID := c.Params("channelId")
 	slug := c.Params("slug")
 
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
 	cid, err := uuid.Parse(channelID)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -320,25 +492,61 @@ func (h *WikiHandler) DeleteWikiPage(c fiber.Ctx) error {
 		})
 	}
 
-	if wikiPage.CreatedByID != uuid.MustParse(userID) {
+	perms := h.wikiPermissions(c.Context(), cid, uid)
+	if !perms.CanViewPage(&wikiPage) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Wiki page not found",
+		})
+	}
+	if !perms.CanDeletePage(&wikiPage) {
+		if perms.Delete {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "This page is protected and can only be deleted by wiki admins",
+			})
+		}
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Only the creator can delete this page",
+			"error": "You cannot delete pages in this channel's wiki",
 		})
 	}
 
-	if err := h.db.Delete(&wikiPage).Error; err != nil {
+	var attachments []models.WikiAttachment
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("page_id = ?", wikiPage.ID).Find(&attachments).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("page_id = ?", wikiPage.ID).Delete(&models.WikiAttachment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("from_page_id = ?", wikiPage.ID).Delete(&models.WikiLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("page_id = ?", wikiPage.ID).Delete(&models.WikiRedirect{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&wikiPage).Error
+	})
+	if err != nil {
 		log.Printf("Error deleting wiki page: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete wiki page",
 		})
 	}
 
+	h.redis.Del(c.Context(), wikiLockPrefix+wikiPage.ID.String(), wikiViewPrefix+wikiPage.ID.String())
+	h.deleteWikiAttachmentFiles(attachments)
+
+	h.announceWikiChange(c.Context(), &wikiPage, models.WikiChangeDeleted, uid)
+	if err := h.db.Where("page_id = ?", wikiPage.ID).Delete(&models.WikiWatch{}).Error; err != nil {
+		log.Printf("Error deleting wiki watches: %v", err)
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Wiki page deleted successfully",
 	})
 }
 
 func (h *WikiHandler) ListWikiPages(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
 	channelID := c.Params("channelId")
 
 	cid, err := uuid.Parse(channelID)
@@ -348,7 +556,12 @@ func (h *WikiHandler) ListWikiPages(c fiber.Ctx) error {
 		})
 	}
 
-	includeUnpublished := c.Query("include_unpublished", "false") == "true"
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+
+	includeUnpublished := c.Query("include_unpublished", "false") == "true" && perms.Edit
 
 	query := h.db.Model(&models.WikiPage{}).Where("channel_id = ?", cid)
 
@@ -373,6 +586,7 @@ func (h *WikiHandler) ListWikiPages(c fiber.Ctx) error {
 }
 
 func (h *WikiHandler) GetWikiTree(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
 	channelID := c.Params("channelId")
 
 	cid, err := uuid.Parse(channelID)
@@ -382,6 +596,10 @@ func (h *WikiHandler) GetWikiTree(c fiber.Ctx) error {
 		})
 	}
 
+	if _, ok := h.requireWikiReader(c, cid, userID); !ok {
+		return nil
+	}
+
 	var wikiPages []models.WikiPage
 	if err := h.db.Where("channel_id = ? AND is_published = ?", cid, true).
 		Order("order ASC, created_at ASC").
@@ -397,6 +615,119 @@ func (h *WikiHandler) GetWikiTree(c fiber.Ctx) error {
 	return c.JSON(tree)
 }
 
+// GetWikiPermissions returns what the user may do in the channel's wiki, so
+// clients can hide actions they would be refused.
+func (h *WikiHandler) GetWikiPermissions(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+	return c.JSON(perms)
+}
+
+// requireWikiReader resolves the user's permissions in the channel's wiki
+// and checks they may view it; private channels keep it to subscribers. On
+// failure it writes the error response and returns false.
+func (h *WikiHandler) requireWikiReader(c fiber.Ctx, channelID uuid.UUID, userID string) (models.WikiPermissions, bool) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+		return models.WikiPermissions{}, false
+	}
+
+	var channel models.Channel
+	if err := h.db.First(&channel, channelID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Channel not found",
+			})
+			return models.WikiPermissions{}, false
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return models.WikiPermissions{}, false
+	}
+
+	perms, err := h.channelService.WikiPermissions(c.Context(), &channel, uid)
+	if err != nil {
+		log.Printf("Error resolving wiki permissions: %v", err)
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return perms, false
+	}
+
+	if !perms.View {
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+		return perms, false
+	}
+	return perms, true
+}
+
+// wikiPermissions resolves what the user may do in the channel's wiki. An
+// unknown channel grants nothing.
+func (h *WikiHandler) wikiPermissions(ctx context.Context, channelID, userID uuid.UUID) models.WikiPermissions {
+	var channel models.Channel
+	if err := h.db.First(&channel, channelID).Error; err != nil {
+		return models.WikiPermissions{}
+	}
+	perms, err := h.channelService.WikiPermissions(ctx, &channel, userID)
+	if err != nil {
+		log.Printf("Error resolving wiki permissions: %v", err)
+	}
+	return perms
+}
+
+// denyWikiEdit answers a change the user's permissions do not allow.
+func denyWikiEdit(c fiber.Ctx, perms models.WikiPermissions) error {
+	if perms.Edit {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "This page is protected and can only be changed by wiki admins",
+		})
+	}
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": "You cannot edit this channel's wiki",
+	})
+}
+
+// insertWikiPage creates the page. GORM leaves false out of the INSERT for
+// columns with a default, so drafts are unpublished explicitly afterwards.
+func insertWikiPage(tx *gorm.DB, page *models.WikiPage) error {
+	published := page.IsPublished
+	if err := tx.Create(page).Error; err != nil {
+		return err
+	}
+	if published {
+		return nil
+	}
+	page.IsPublished = false
+	return tx.Model(page).Update("is_published", false).Error
+}
+
+func publishedWikiPages(pages []models.WikiPage) []models.WikiPage {
+	var published []models.WikiPage
+	for _, page := range pages {
+		if page.IsPublished {
+			published = append(published, page)
+		}
+	}
+	return published
+}
+
 func buildWikiTree(pages []models.WikiPage, parentID *uuid.UUID) []models.WikiPageResponse {
 	var result []models.WikiPageResponse
 
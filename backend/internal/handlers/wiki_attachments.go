@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/pkg/markdown"
+	"github.com/messenger/backend/pkg/media"
+	"gorm.io/gorm"
+)
+
+// wikiDocumentTypes are the files besides images, video and audio that can
+// be attached to wiki pages.
+var wikiDocumentTypes = map[string]bool{
+	"application/pdf": true,
+	"application/zip": true,
+	"text/plain":      true,
+	"text/csv":        true,
+	"text/markdown":   true,
+}
+
+func (h *WikiHandler) ListWikiAttachments(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+
+	wikiPage := h.loadWikiPage(c, cid, c.Params("slug"), perms)
+	if wikiPage == nil {
+		return nil
+	}
+
+	var attachments []models.WikiAttachment
+	if err := h.db.Where("page_id = ?", wikiPage.ID).Order("file_name ASC").Find(&attachments).Error; err != nil {
+		log.Printf("Error listing wiki attachments: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list attachments",
+		})
+	}
+
+	resp := make([]models.WikiAttachmentResponse, len(attachments))
+	for i := range attachments {
+		resp[i] = attachments[i].ToResponse()
+	}
+	return c.JSON(resp)
+}
+
+// UploadWikiAttachment attaches a file from a multipart upload to the page.
+// File names are unique per page, as Markdown refers to attachments by name.
+func (h *WikiHandler) UploadWikiAttachment(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+	uid, _ := uuid.Parse(userID)
+
+	wikiPage := h.loadWikiPage(c, cid, c.Params("slug"), perms)
+	if wikiPage == nil {
+		return nil
+	}
+	if !perms.CanEditPage(wikiPage) {
+		return denyWikiEdit(c, perms)
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No file uploaded",
+		})
+	}
+
+	name := strings.TrimSpace(filepath.Base(file.Filename))
+	if name == "" || name == "." || len(name) > 255 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid file name",
+		})
+	}
+
+	var count int64
+	if err := h.db.Model(&models.WikiAttachment{}).
+		Where("page_id = ? AND file_name = ?", wikiPage.ID, name).
+		Count(&count).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "The page already has an attachment with this name",
+		})
+	}
+
+	allowedTypes := mergeMaps(media.AllowedImageTypes, media.AllowedVideoTypes, media.AllowedAudioTypes, wikiDocumentTypes)
+	if err := h.mediaUploader.ValidateFile(file, allowedTypes); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	openedFile, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to open file",
+		})
+	}
+	defer openedFile.Close()
+
+	result, err := h.mediaUploader.SaveFile(openedFile, file, uid)
+	if err != nil {
+		log.Printf("Error saving file: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save file",
+		})
+	}
+
+	attachment := models.WikiAttachment{
+		PageID:       wikiPage.ID,
+		FileName:     name,
+		FilePath:     result.FilePath,
+		FileSize:     result.FileSize,
+		MimeType:     result.MimeType,
+		UploadedByID: uid,
+	}
+	if err := h.db.Create(&attachment).Error; err != nil {
+		h.mediaUploader.DeleteFile(result.FilePath)
+		if result.Thumbnail != nil {
+			h.mediaUploader.DeleteFile(*result.Thumbnail)
+		}
+		log.Printf("Error creating wiki attachment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to attach file",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(attachment.ToResponse())
+}
+
+// DeleteWikiAttachment removes an attachment no revision of the page uses.
+// Attachments that are referenced stay until the page is deleted, so that
+// old revisions can still be viewed and restored.
+func (h *WikiHandler) DeleteWikiAttachment(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	attachmentID, err := uuid.Parse(c.Params("attachmentId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid attachment ID",
+		})
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+
+	wikiPage := h.loadWikiPage(c, cid, c.Params("slug"), perms)
+	if wikiPage == nil {
+		return nil
+	}
+	if !perms.CanEditPage(wikiPage) {
+		return denyWikiEdit(c, perms)
+	}
+
+	var attachment models.WikiAttachment
+	if err := h.db.Where("id = ? AND page_id = ?", attachmentID, wikiPage.ID).First(&attachment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Attachment not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	var contents []string
+	if err := h.db.Model(&models.WikiRevision{}).Where("page_id = ?", wikiPage.ID).Pluck("content", &contents).Error; err != nil {
+		log.Printf("Error loading wiki revisions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	for _, content := range append(contents, wikiPage.Content) {
+		for _, ref := range markdown.AttachmentRefs(content) {
+			if ref == attachment.FileName {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "The attachment is used by a revision of this page",
+				})
+			}
+		}
+	}
+
+	if err := h.db.Delete(&attachment).Error; err != nil {
+		log.Printf("Error deleting wiki attachment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete attachment",
+		})
+	}
+	h.deleteWikiAttachmentFiles([]models.WikiAttachment{attachment})
+
+	return c.JSON(fiber.Map{
+		"message": "Attachment deleted successfully",
+	})
+}
+
+// wikiAttachmentURLs maps the names of the page's attachments to their URLs
+// for rendering.
+func (h *WikiHandler) wikiAttachmentURLs(pageID uuid.UUID) (map[string]string, error) {
+	var attachments []models.WikiAttachment
+	if err := h.db.Where("page_id = ?", pageID).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+
+	urls := make(map[string]string, len(attachments))
+	for _, attachment := range attachments {
+		urls[attachment.FileName] = attachment.FilePath
+	}
+	return urls, nil
+}
+
+func (h *WikiHandler) deleteWikiAttachmentFiles(attachments []models.WikiAttachment) {
+	for _, attachment := range attachments {
+		if err := h.mediaUploader.DeleteFile(attachment.FilePath); err != nil {
+			log.Printf("Error removing wiki attachment %s: %v", attachment.FilePath, err)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+// ExportWiki downloads the channel's wiki as a bundle of Markdown files.
+// Drafts are included only for wiki editors.
+func (h *WikiHandler) ExportWiki(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+
+	format := c.Query("format", services.WikiBundleZip)
+	contentType, extension := "application/zip", ".zip"
+	switch format {
+	case services.WikiBundleZip:
+	case services.WikiBundleTar:
+		contentType, extension = "application/gzip", ".tar.gz"
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be zip or tar",
+		})
+	}
+
+	query := h.db.Where("channel_id = ?", cid)
+	if !perms.Edit {
+		query = query.Where("is_published = ?", true)
+	}
+
+	var pages []models.WikiPage
+	if err := query.Order("created_at ASC").Find(&pages).Error; err != nil {
+		log.Printf("Error listing wiki pages: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export wiki",
+		})
+	}
+
+	var revisions map[uuid.UUID][]models.WikiRevision
+	if c.Query("revisions") == "true" && len(pages) > 0 {
+		pageIDs := make([]uuid.UUID, len(pages))
+		for i := range pages {
+			pageIDs[i] = pages[i].ID
+		}
+
+		var all []models.WikiRevision
+		if err := h.db.Where("page_id IN ?", pageIDs).Order("revision_number ASC").Find(&all).Error; err != nil {
+			log.Printf("Error fetching wiki revisions: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to export wiki",
+			})
+		}
+
+		revisions = make(map[uuid.UUID][]models.WikiRevision, len(pages))
+		for _, rev := range all {
+			revisions[rev.PageID] = append(revisions[rev.PageID], rev)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := services.WriteWikiBundle(&buf, format, pages, revisions); err != nil {
+		log.Printf("Error writing wiki bundle: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export wiki",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="wiki-%s%s"`, cid, extension))
+	return c.Send(buf.Bytes())
+}
+
+// ImportWiki creates and updates pages from uploaded Markdown: a bundle
+// made by ExportWiki, any ZIP or tar of .md files, or several .md files
+// named with their relative paths. Pages are matched by slug and the whole
+// import is applied in one transaction. As it can overwrite any page,
+// including protected ones, it is limited to wiki admins.
+func (h *WikiHandler) ImportWiki(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	if !h.wikiPermissions(c.Context(), cid, uid).Admin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only wiki admins can import pages",
+		})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No file uploaded",
+		})
+	}
+
+	var files []services.WikiBundleFile
+	var total int64
+	for _, header := range form.File["file"] {
+		total += header.Size
+		if total > services.MaxWikiBundleSize {
+			return wikiBundleError(c, services.ErrWikiBundleTooLarge)
+		}
+
+		f, err := header.Open()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to open file",
+			})
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to read file",
+			})
+		}
+
+		extracted, err := services.ExtractWikiBundle(header.Filename, data)
+		if err != nil {
+			return wikiBundleError(c, err)
+		}
+		files = append(files, extracted...)
+	}
+
+	bundle, err := services.ReadWikiBundle(files)
+	if err != nil {
+		return wikiBundleError(c, err)
+	}
+
+	var result models.WikiImportResponse
+	var pages []models.WikiPage
+	var actions []models.WikiChangeAction
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		pages, actions, err = importWikiPages(tx, cid, uid, bundle, &result)
+		if err != nil {
+			return err
+		}
+		result.Pages = make([]models.WikiPageSummary, len(pages))
+		for i := range pages {
+			result.Pages[i] = pages[i].ToSummary()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error importing wiki: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to import wiki",
+		})
+	}
+
+	for i := range pages {
+		if actions[i] != "" {
+			h.announceWikiChange(c.Context(), &pages[i], actions[i], uid)
+		}
+	}
+
+	return c.JSON(result)
+}
+
+// importWikiPages applies the bundle: new slugs become pages, known ones are
+// updated, and every page whose title or content changed gets a revision.
+// New pages also get the history the bundle carries for them; pages that
+// already exist keep their own. Parents are set once all pages exist. For
+// each page it returns whether it was created or updated, or "" if the
+// import left it unchanged.
+func importWikiPages(tx *gorm.DB, channelID, userID uuid.UUID, bundle []services.WikiBundlePage, result *models.WikiImportResponse) ([]models.WikiPage, []models.WikiChangeAction, error) {
+	slugs := make([]string, len(bundle))
+	var authorIDs []uuid.UUID
+	for i := range bundle {
+		slugs[i] = bundle[i].Slug
+		for _, rev := range bundle[i].Revisions {
+			authorIDs = append(authorIDs, rev.AuthorID)
+		}
+	}
+
+	// Authors from another server may not exist here; their revisions are
+	// credited to the importer.
+	knownAuthors := make(map[uuid.UUID]bool)
+	if len(authorIDs) > 0 {
+		var known []uuid.UUID
+		if err := tx.Model(&models.User{}).Where("id IN ?", authorIDs).Pluck("id", &known).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, id := range known {
+			knownAuthors[id] = true
+		}
+	}
+
+	var existing []models.WikiPage
+	if err := tx.Where("channel_id = ? AND slug IN ?", channelID, slugs).Find(&existing).Error; err != nil {
+		return nil, nil, err
+	}
+	bySlug := make(map[string]*models.WikiPage, len(existing))
+	for i := range existing {
+		bySlug[existing[i].Slug] = &existing[i]
+	}
+
+	pages := make([]models.WikiPage, len(bundle))
+	changed := make([]bool, len(bundle))
+	for i, entry := range bundle {
+		page, found := bySlug[entry.Slug]
+		if !found {
+			pages[i] = models.WikiPage{
+				ChannelID:   channelID,
+				Slug:        entry.Slug,
+				Title:       entry.Title,
+				Content:     entry.Content,
+				CreatedByID: userID,
+				IsPublished: entry.IsPublished,
+				Order:       entry.Order,
+			}
+			if err := insertWikiPage(tx, &pages[i]); err != nil {
+				return nil, nil, err
+			}
+			if err := restoreWikiRevisions(tx, &pages[i], entry.Revisions, userID, knownAuthors); err != nil {
+				return nil, nil, err
+			}
+			if err := services.AppendWikiRevision(tx, &pages[i], userID, "Imported"); err != nil {
+				return nil, nil, err
+			}
+			if err := services.SyncWikiLinks(tx, &pages[i]); err != nil {
+				return nil, nil, err
+			}
+			result.Created++
+			continue
+		}
+
+		textChanged := page.Title != entry.Title || page.Content != entry.Content
+		changed[i] = textChanged || page.Order != entry.Order || page.IsPublished != entry.IsPublished
+		page.Title = entry.Title
+		page.Content = entry.Content
+		page.Order = entry.Order
+		page.IsPublished = entry.IsPublished
+		if changed[i] {
+			if err := tx.Save(page).Error; err != nil {
+				return nil, nil, err
+			}
+		}
+		if textChanged {
+			if err := services.AppendWikiRevision(tx, page, userID, "Imported"); err != nil {
+				return nil, nil, err
+			}
+			if err := services.SyncWikiLinks(tx, page); err != nil {
+				return nil, nil, err
+			}
+		}
+		pages[i] = *page
+	}
+
+	ids := make(map[string]uuid.UUID, len(pages))
+	for i := range pages {
+		ids[pages[i].Slug] = pages[i].ID
+	}
+	for i, entry := range bundle {
+		var parentID *uuid.UUID
+		if entry.ParentSlug != "" {
+			id := ids[entry.ParentSlug]
+			parentID = &id
+		}
+		if uuidPtrEqual(pages[i].ParentID, parentID) {
+			continue
+		}
+		if err := tx.Model(&pages[i]).Update("parent_id", parentID).Error; err != nil {
+			return nil, nil, err
+		}
+		pages[i].ParentID = parentID
+		changed[i] = true
+	}
+
+	actions := make([]models.WikiChangeAction, len(bundle))
+	for i, entry := range bundle {
+		if _, found := bySlug[entry.Slug]; !found {
+			actions[i] = models.WikiChangeCreated
+			continue
+		}
+		if changed[i] {
+			actions[i] = models.WikiChangeUpdated
+			result.Updated++
+		} else {
+			result.Unchanged++
+		}
+	}
+	return pages, actions, nil
+}
+
+// restoreWikiRevisions saves a new page's history from the bundle, numbered
+// from 1 in the bundle's order.
+func restoreWikiRevisions(tx *gorm.DB, page *models.WikiPage, history []services.WikiBundleRevision, userID uuid.UUID, knownAuthors map[uuid.UUID]bool) error {
+	for i, rev := range history {
+		revision := models.WikiRevision{
+			PageID:         page.ID,
+			Title:          rev.Title,
+			Content:        rev.Content,
+			CreatedByID:    userID,
+			ChangeSummary:  rev.Summary,
+			RevisionNumber: i + 1,
+			CreatedAt:      rev.CreatedAt,
+		}
+		if knownAuthors[rev.AuthorID] {
+			revision.CreatedByID = rev.AuthorID
+		}
+		if revision.Title == "" {
+			revision.Title = page.Title
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uuidPtrEqual(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func wikiBundleError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrWikiBundleTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidWikiBundle), errors.Is(err, services.ErrDuplicateWikiSlug):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	default:
+		log.Printf("Error reading wiki bundle: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read wiki bundle",
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/pkg/diff"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultDiffContext = 3
+	maxDiffContext     = 20
+)
+
+var errWikiRevertNoop = errors.New("wiki page already matches the revision")
+
+// GetWikiDiff compares two revisions of a page. By default it compares the
+// latest revision with the one before it.
+func (h *WikiHandler) GetWikiDiff(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+
+	wikiPage := h.loadWikiPage(c, cid, c.Params("slug"), perms)
+	if wikiPage == nil {
+		return nil
+	}
+
+	to := fiber.Query[int](c, "to", 0)
+	if to == 0 {
+		var latest models.WikiRevision
+		if err := h.db.Where("page_id = ?", wikiPage.ID).Order("revision_number DESC").First(&latest).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Revision not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		to = latest.RevisionNumber
+	}
+
+	from := fiber.Query[int](c, "from", 0)
+	if from == 0 {
+		from = to - 1
+		if from < 1 {
+			from = 1
+		}
+	}
+
+	if from < 1 || to < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid revision number",
+		})
+	}
+
+	context := fiber.Query[int](c, "context", defaultDiffContext)
+	if context < 0 || context > maxDiffContext {
+		context = defaultDiffContext
+	}
+
+	var revisions []models.WikiRevision
+	if err := h.db.Where("page_id = ? AND revision_number IN ?", wikiPage.ID, []int{from, to}).
+		Find(&revisions).Error; err != nil {
+		log.Printf("Error fetching wiki revisions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	var fromRev, toRev *models.WikiRevision
+	for i := range revisions {
+		if revisions[i].RevisionNumber == from {
+			fromRev = &revisions[i]
+		}
+		if revisions[i].RevisionNumber == to {
+			toRev = &revisions[i]
+		}
+	}
+	if fromRev == nil || toRev == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Revision not found",
+		})
+	}
+
+	result := diff.Lines(fromRev.Content, toRev.Content, context)
+	unified := result.Unified(
+		fmt.Sprintf("a/%s@%d", wikiPage.Slug, from),
+		fmt.Sprintf("b/%s@%d", wikiPage.Slug, to),
+	)
+
+	if c.Query("format") == "unified" {
+		c.Set(fiber.HeaderContentType, "text/x-diff; charset=utf-8")
+		return c.SendString(unified)
+	}
+
+	return c.JSON(models.WikiDiffResponse{
+		PageID:       wikiPage.ID,
+		Slug:         wikiPage.Slug,
+		From:         fromRev.ToRef(),
+		To:           toRev.ToRef(),
+		TitleChanged: fromRev.Title != toRev.Title,
+		Additions:    result.Additions,
+		Deletions:    result.Deletions,
+		Hunks:        result.Hunks,
+		Unified:      unified,
+	})
+}
+
+// RevertWikiPage restores the title and content of an earlier revision. The
+// history is kept: the restored text becomes a new revision.
+func (h *WikiHandler) RevertWikiPage(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	number, err := strconv.Atoi(c.Params("revision"))
+	if err != nil || number < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid revision number",
+		})
+	}
+
+	var req models.RevertWikiPageRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	perms := h.wikiPermissions(c.Context(), cid, uid)
+	wikiPage := h.loadWikiPage(c, cid, c.Params("slug"), perms)
+	if wikiPage == nil {
+		return nil
+	}
+
+	if !perms.CanEditPage(wikiPage) {
+		return denyWikiEdit(c, perms)
+	}
+
+	baseRevision, checkBase, ok := wikiBaseRevision(c, req.BaseRevision)
+	if !ok {
+		return c.Status(fiber.StatusPreconditionRequired).JSON(fiber.Map{
+			"error": "base_revision or an If-Match header with the page's ETag is required",
+		})
+	}
+
+	var revision models.WikiRevision
+	if err := h.db.Where("page_id = ? AND revision_number = ?", wikiPage.ID, number).First(&revision).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Revision not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	summary := fmt.Sprintf("Reverted to revision %d", number)
+	if req.ChangeSummary != nil && *req.ChangeSummary != "" {
+		summary = *req.ChangeSummary
+	}
+
+	var latestRevision int
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the page so a revert is checked against saves in flight.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(wikiPage, wikiPage.ID).Error; err != nil {
+			return err
+		}
+
+		latest, err := latestWikiRevision(tx, wikiPage.ID)
+		if err != nil {
+			return err
+		}
+		latestRevision = latest
+		if checkBase && baseRevision != latest {
+			return errStaleWikiRevision
+		}
+		if revision.Title == wikiPage.Title && revision.Content == wikiPage.Content {
+			return errWikiRevertNoop
+		}
+
+		wikiPage.Title = revision.Title
+		wikiPage.Content = revision.Content
+		if err := tx.Save(wikiPage).Error; err != nil {
+			return err
+		}
+		if err := services.AppendWikiRevision(tx, wikiPage, uid, summary); err != nil {
+			return err
+		}
+		return services.SyncWikiLinks(tx, wikiPage)
+	})
+	if errors.Is(err, errStaleWikiRevision) {
+		return h.wikiConflict(c, wikiPage, &models.UpdateWikiPageRequest{Content: &revision.Content}, baseRevision, latestRevision)
+	}
+	if errors.Is(err, errWikiRevertNoop) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Page already matches this revision",
+		})
+	}
+	if err != nil {
+		log.Printf("Error reverting wiki page: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revert wiki page",
+		})
+	}
+
+	if err := h.db.Preload("CreatedBy").Preload("Children").First(wikiPage, wikiPage.ID).Error; err != nil {
+		log.Printf("Error loading wiki page with relations: %v", err)
+	}
+
+	h.announceWikiChange(c.Context(), wikiPage, models.WikiChangeUpdated, uid)
+
+	return c.JSON(wikiPage.ToResponse())
+}
+
+// loadWikiPage fetches a page by channel and slug. Pages the caller may not
+// view, such as drafts for non-editors, are reported as not found. On
+// failure it writes the error response and returns nil.
+func (h *WikiHandler) loadWikiPage(c fiber.Ctx, channelID uuid.UUID, slug string, perms models.WikiPermissions) *models.WikiPage {
+	var wikiPage models.WikiPage
+	if err := h.db.Where("channel_id = ? AND slug = ?", channelID, slug).First(&wikiPage).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Wiki page not found",
+			})
+			return nil
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return nil
+	}
+
+	if !perms.CanViewPage(&wikiPage) {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Wiki page not found",
+		})
+		return nil
+	}
+	return &wikiPage
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/pkg/markdown"
+	"gorm.io/gorm"
+)
+
+var wikiSlugPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,255}$`)
+
+// GetWikiBacklinks lists the pages that link to a slug. The slug need not
+// exist: for a missing page these are the pages with broken links to it.
+func (h *WikiHandler) GetWikiBacklinks(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	slug := c.Params("slug")
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+
+	pages, err := services.LinkingWikiPages(h.db, cid, slug)
+	if err != nil {
+		log.Printf("Error fetching wiki backlinks: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch backlinks",
+		})
+	}
+
+	backlinks := make([]models.WikiPageSummary, 0, len(pages))
+	for i := range pages {
+		if perms.CanViewPage(&pages[i]) {
+			backlinks = append(backlinks, pages[i].ToSummary())
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"slug":      slug,
+		"backlinks": backlinks,
+		"count":     len(backlinks),
+	})
+}
+
+// RenameWikiPage changes a page's slug. With rewrite_links the links of
+// other pages are pointed at the new slug, each as a new revision;
+// otherwise the response lists the pages that still use the old one. The
+// old slug redirects to the page either way.
+func (h *WikiHandler) RenameWikiPage(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	var req models.RenameWikiPageRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	perms := h.wikiPermissions(c.Context(), cid, uid)
+	wikiPage := h.loadWikiPage(c, cid, c.Params("slug"), perms)
+	if wikiPage == nil {
+		return nil
+	}
+
+	if !perms.CanEditPage(wikiPage) {
+		return denyWikiEdit(c, perms)
+	}
+
+	oldSlug := wikiPage.Slug
+	newSlug := strings.TrimSpace(req.Slug)
+	if newSlug == oldSlug {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Page already has this slug",
+		})
+	}
+	if !h.wikiSlugAvailable(c, cid, newSlug) {
+		return nil
+	}
+
+	var rewritten, skipped []models.WikiPageSummary
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		rewritten, skipped, err = h.changeWikiSlug(tx, wikiPage, newSlug, uid, perms, req.RewriteLinks)
+		if err != nil {
+			return err
+		}
+		return services.AppendWikiRevision(tx, wikiPage, uid, fmt.Sprintf("Renamed from %s to %s", oldSlug, newSlug))
+	})
+	if err != nil {
+		log.Printf("Error renaming wiki page: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to rename wiki page",
+		})
+	}
+
+	remaining, err := services.LinkingWikiPages(h.db, cid, oldSlug)
+	if err != nil {
+		log.Printf("Error fetching wiki backlinks: %v", err)
+	}
+	inbound := make([]models.WikiPageSummary, len(remaining))
+	for i := range remaining {
+		inbound[i] = remaining[i].ToSummary()
+	}
+
+	if err := h.db.Preload("CreatedBy").Preload("Children").First(wikiPage, wikiPage.ID).Error; err != nil {
+		log.Printf("Error loading wiki page with relations: %v", err)
+	}
+
+	h.announceWikiChange(c.Context(), wikiPage, models.WikiChangeMoved, uid)
+
+	return c.JSON(models.RenameWikiPageResponse{
+		Page:         wikiPage.ToResponse(),
+		InboundLinks: inbound,
+		Rewritten:    rewritten,
+		Skipped:      skipped,
+	})
+}
+
+// renderWikiHTML fills in the page's HTML, resolving wiki links within the
+// channel and reporting the ones that lead nowhere.
+func (h *WikiHandler) renderWikiHTML(page *models.WikiPage, resp *models.WikiPageResponse) error {
+	targets := markdown.WikiLinks(page.Content)
+
+	existing := make(map[string]bool, len(targets))
+	if len(targets) > 0 {
+		var slugs []string
+		if err := h.db.Model(&models.WikiPage{}).
+			Where("channel_id = ? AND slug IN ?", page.ChannelID, targets).
+			Pluck("slug", &slugs).Error; err != nil {
+			return err
+		}
+		for _, slug := range slugs {
+			existing[slug] = true
+		}
+	}
+
+	// Links to a page's old slug go straight to where it is now.
+	moved := make(map[string]string)
+	var missing []string
+	for _, target := range targets {
+		if !existing[target] {
+			missing = append(missing, target)
+		}
+	}
+	if len(missing) > 0 {
+		var redirects []struct {
+			OldSlug string
+			NewSlug string
+		}
+		if err := h.db.Table("wiki_redirects").
+			Select("wiki_redirects.slug AS old_slug, wiki_pages.slug AS new_slug").
+			Joins("JOIN wiki_pages ON wiki_pages.id = wiki_redirects.page_id").
+			Where("wiki_redirects.channel_id = ? AND wiki_redirects.slug IN ?", page.ChannelID, missing).
+			Scan(&redirects).Error; err != nil {
+			return err
+		}
+		for _, r := range redirects {
+			moved[r.OldSlug] = r.NewSlug
+		}
+	}
+
+	attachments, err := h.wikiAttachmentURLs(page.ID)
+	if err != nil {
+		return err
+	}
+
+	resp.HTML = markdown.ToHTML(page.Content, markdown.Options{
+		ResolveWikiLink: func(slug string) (string, bool) {
+			if to, ok := moved[slug]; ok {
+				return fmt.Sprintf("/wiki/%s/%s", page.ChannelID, url.PathEscape(to)), true
+			}
+			return fmt.Sprintf("/wiki/%s/%s", page.ChannelID, url.PathEscape(slug)), existing[slug]
+		},
+		ResolveAttachment: func(name string) (string, bool) {
+			href, ok := attachments[name]
+			return href, ok
+		},
+	})
+	for _, target := range targets {
+		if _, ok := moved[target]; !ok && !existing[target] {
+			resp.BrokenLinks = append(resp.BrokenLinks, target)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/pkg/diff"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	wikiLockTTL    = 5 * time.Minute
+	wikiViewerTTL  = 10 * time.Minute
+	wikiLockPrefix = "wiki:lock:"
+	wikiViewPrefix = "wiki:viewers:"
+)
+
+var errStaleWikiRevision = errors.New("wiki page changed since the base revision")
+
+// latestWikiRevision returns the page's newest revision number, or 0 if it
+// has none.
+func latestWikiRevision(db *gorm.DB, pageID uuid.UUID) (int, error) {
+	var latest int
+	err := db.Model(&models.WikiRevision{}).
+		Where("page_id = ?", pageID).
+		Select("COALESCE(MAX(revision_number), 0)").
+		Scan(&latest).Error
+	return latest, err
+}
+
+func wikiETag(revision int) string {
+	return strconv.Quote(strconv.Itoa(revision))
+}
+
+// wikiBaseRevision reads the revision a write was based on from the
+// base_revision of its body or If-Match. check is false for "If-Match: *",
+// which overwrites unconditionally; ok is false when neither was given.
+func wikiBaseRevision(c fiber.Ctx, bodyBase *int) (base int, check bool, ok bool) {
+	if bodyBase != nil {
+		return *bodyBase, true, true
+	}
+
+	ifMatch := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if ifMatch == "" {
+		return 0, false, false
+	}
+	if ifMatch == "*" {
+		return 0, false, true
+	}
+
+	tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	revision, err := strconv.Atoi(tag)
+	if err != nil {
+		// An ETag we never issued cannot match.
+		return -1, true, true
+	}
+	return revision, true, true
+}
+
+// wikiConflict answers a save based on an outdated revision with the
+// current page and, if the save changed the content, a three-way merge of
+// the edit with what was saved in the meantime.
+func (h *WikiHandler) wikiConflict(c fiber.Ctx, page *models.WikiPage, req *models.UpdateWikiPageRequest, base, current int) error {
+	if err := h.db.Preload("CreatedBy").First(page, page.ID).Error; err != nil {
+		log.Printf("Error loading wiki page with relations: %v", err)
+	}
+
+	resp := models.WikiConflictResponse{
+		Error:           "The page was changed since your base revision",
+		BaseRevision:    base,
+		CurrentRevision: current,
+		Current:         page.ToResponse(),
+	}
+	resp.Current.Revision = current
+
+	if req.Content != nil {
+		var baseRev models.WikiRevision
+		if err := h.db.Where("page_id = ? AND revision_number = ?", page.ID, base).First(&baseRev).Error; err == nil {
+			resp.Merge = diff.Merge3(baseRev.Content, *req.Content, page.Content)
+		}
+	}
+
+	c.Set(fiber.HeaderETag, wikiETag(current))
+	return c.Status(fiber.StatusConflict).JSON(resp)
+}
+
+// LockWikiPage takes or renews the caller's soft edit lock on a page. Locks
+// are advisory: they do not block saves, but recent viewers of the page are
+// told who is editing it.
+func (h *WikiHandler) LockWikiPage(c fiber.Ctx) error {
+	uid, page, _ := h.requireWikiEditor(c)
+	if page == nil {
+		return nil
+	}
+
+	ctx := c.Context()
+	key := wikiLockPrefix + page.ID.String()
+	acquired, err := h.redis.SetNX(ctx, key, uid.String(), wikiLockTTL).Result()
+	if err != nil {
+		log.Printf("Error taking wiki lock: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to lock wiki page",
+		})
+	}
+
+	if !acquired {
+		lock := h.wikiLock(ctx, page.ID)
+		if lock != nil && lock.UserID != uid {
+			return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+				"error": "Page is being edited by another user",
+				"lock":  lock,
+			})
+		}
+		h.redis.Set(ctx, key, uid.String(), wikiLockTTL)
+	}
+
+	lock := &models.WikiEditLock{UserID: uid, ExpiresAt: time.Now().Add(wikiLockTTL)}
+	if acquired {
+		h.announceWikiLock(ctx, page, lock, true)
+	}
+	return c.JSON(lock)
+}
+
+// UnlockWikiPage releases the caller's edit lock.
+func (h *WikiHandler) UnlockWikiPage(c fiber.Ctx) error {
+	uid, page, _ := h.requireWikiEditor(c)
+	if page == nil {
+		return nil
+	}
+
+	ctx := c.Context()
+	lock := h.wikiLock(ctx, page.ID)
+	if lock == nil {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	if lock.UserID != uid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "The lock is held by another user",
+		})
+	}
+
+	if err := h.redis.Del(ctx, wikiLockPrefix+page.ID.String()).Err(); err != nil {
+		log.Printf("Error releasing wiki lock: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to unlock wiki page",
+		})
+	}
+
+	h.announceWikiLock(ctx, page, lock, false)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// requireWikiEditor loads the page named in the route and checks the caller
+// may edit it, which protected pages limit to wiki admins. It returns the
+// caller's wiki permissions as well. On failure it writes the error response
+// and returns a nil page.
+func (h *WikiHandler) requireWikiEditor(c fiber.Ctx) (uuid.UUID, *models.WikiPage, models.WikiPermissions) {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+		return uuid.Nil, nil, models.WikiPermissions{}
+	}
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+		return uuid.Nil, nil, models.WikiPermissions{}
+	}
+
+	perms := h.wikiPermissions(c.Context(), cid, uid)
+	page := h.loadWikiPage(c, cid, c.Params("slug"), perms)
+	if page == nil {
+		return uuid.Nil, nil, models.WikiPermissions{}
+	}
+
+	if !perms.CanEditPage(page) {
+		denyWikiEdit(c, perms)
+		return uuid.Nil, nil, models.WikiPermissions{}
+	}
+	return uid, page, perms
+}
+
+// wikiLock returns the page's current edit lock, if any.
+func (h *WikiHandler) wikiLock(ctx context.Context, pageID uuid.UUID) *models.WikiEditLock {
+	key := wikiLockPrefix + pageID.String()
+	holder, err := h.redis.Get(ctx, key).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Error reading wiki lock: %v", err)
+		}
+		return nil
+	}
+
+	holderID, err := uuid.Parse(holder)
+	if err != nil {
+		return nil
+	}
+	ttl, err := h.redis.PTTL(ctx, key).Result()
+	if err != nil || ttl < 0 {
+		return nil
+	}
+	return &models.WikiEditLock{UserID: holderID, ExpiresAt: time.Now().Add(ttl)}
+}
+
+// recordWikiViewer remembers that the user has the page open, so lock
+// changes can be announced to them.
+func (h *WikiHandler) recordWikiViewer(ctx context.Context, pageID uuid.UUID, userID string) {
+	key := wikiViewPrefix + pageID.String()
+	now := time.Now()
+	pipe := h.redis.Pipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.Unix()), Member: userID})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("%d", now.Add(-wikiViewerTTL).Unix()))
+	pipe.Expire(ctx, key, wikiViewerTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Error recording wiki viewer: %v", err)
+	}
+}
+
+func (h *WikiHandler) announceWikiLock(ctx context.Context, page *models.WikiPage, lock *models.WikiEditLock, locked bool) {
+	since := time.Now().Add(-wikiViewerTTL).Unix()
+	viewers, err := h.redis.ZRangeByScore(ctx, wikiViewPrefix+page.ID.String(), &redis.ZRangeBy{
+		Min: fmt.Sprintf("%d", since),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		log.Printf("Error listing wiki viewers: %v", err)
+		return
+	}
+
+	event, err := json.Marshal(map[string]interface{}{
+		"type":       "wiki_lock",
+		"channel_id": page.ChannelID,
+		"page_id":    page.ID,
+		"slug":       page.Slug,
+		"user_id":    lock.UserID,
+		"locked":     locked,
+		"expires_at": lock.ExpiresAt,
+	})
+	if err != nil {
+		return
+	}
+
+	for _, viewer := range viewers {
+		if viewer != lock.UserID.String() {
+			h.wsHandler.BroadcastToUser(viewer, event)
+		}
+	}
+}
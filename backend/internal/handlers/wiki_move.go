@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MoveWikiPage moves a page to another parent, slug or position in one
+// step. Changing the parent or slug is recorded as a revision, and the old
+// slug keeps redirecting to the page.
+func (h *WikiHandler) MoveWikiPage(c fiber.Ctx) error {
+	uid, wikiPage, perms := h.requireWikiEditor(c)
+	if wikiPage == nil {
+		return nil
+	}
+
+	var req models.MoveWikiPageRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.ParentID == nil && req.Slug == nil && req.Order == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "parent_id, slug or order is required",
+		})
+	}
+
+	parentID, ok := parseWikiParent(c, req.ParentID)
+	if !ok {
+		return nil
+	}
+
+	oldSlug := wikiPage.Slug
+	newSlug := ""
+	if req.Slug != nil && strings.TrimSpace(*req.Slug) != oldSlug {
+		newSlug = strings.TrimSpace(*req.Slug)
+		if !h.wikiSlugAvailable(c, wikiPage.ChannelID, newSlug) {
+			return nil
+		}
+	}
+
+	rewritten := make([]models.WikiPageSummary, 0)
+	skipped := make([]models.WikiPageSummary, 0)
+	moved := false
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(wikiPage, wikiPage.ID).Error; err != nil {
+			return err
+		}
+
+		var moves []string
+		if req.ParentID != nil && !uuidPtrEqual(wikiPage.ParentID, parentID) {
+			if parentID == nil {
+				moves = append(moves, "Moved to top level")
+			} else {
+				parent, err := services.CheckWikiParent(tx, wikiPage, *parentID)
+				if err != nil {
+					return err
+				}
+				moves = append(moves, fmt.Sprintf("Moved under %s", parent.Slug))
+			}
+			if err := tx.Model(wikiPage).Update("parent_id", parentID).Error; err != nil {
+				return err
+			}
+		}
+
+		if req.Order != nil && *req.Order != wikiPage.Order {
+			if err := tx.Model(wikiPage).Update("order", *req.Order).Error; err != nil {
+				return err
+			}
+		}
+
+		if newSlug != "" {
+			var err error
+			rewritten, skipped, err = h.changeWikiSlug(tx, wikiPage, newSlug, uid, perms, req.RewriteLinks)
+			if err != nil {
+				return err
+			}
+			moves = append(moves, fmt.Sprintf("Renamed from %s to %s", oldSlug, newSlug))
+		}
+
+		if len(moves) == 0 {
+			return nil
+		}
+		moved = true
+		summary := strings.Join(moves, "; ")
+		if req.ChangeSummary != nil {
+			summary = *req.ChangeSummary
+		}
+		return services.AppendWikiRevision(tx, wikiPage, uid, summary)
+	})
+	if err != nil {
+		return wikiMoveError(c, err, "move wiki page")
+	}
+
+	inbound := make([]models.WikiPageSummary, 0)
+	if newSlug != "" {
+		remaining, err := services.LinkingWikiPages(h.db, wikiPage.ChannelID, oldSlug)
+		if err != nil {
+			log.Printf("Error fetching wiki backlinks: %v", err)
+		}
+		for i := range remaining {
+			inbound = append(inbound, remaining[i].ToSummary())
+		}
+	}
+
+	if err := h.db.Preload("CreatedBy").Preload("Children").First(wikiPage, wikiPage.ID).Error; err != nil {
+		log.Printf("Error loading wiki page with relations: %v", err)
+	}
+
+	if moved {
+		h.announceWikiChange(c.Context(), wikiPage, models.WikiChangeMoved, uid)
+	}
+
+	return c.JSON(models.RenameWikiPageResponse{
+		Page:         wikiPage.ToResponse(),
+		InboundLinks: inbound,
+		Rewritten:    rewritten,
+		Skipped:      skipped,
+	})
+}
+
+// ReorderWikiPages sets the order of a parent's children in one request.
+func (h *WikiHandler) ReorderWikiPages(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	if !h.wikiPermissions(c.Context(), cid, uid).Edit {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot edit this channel's wiki",
+		})
+	}
+
+	var req models.ReorderWikiPagesRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if len(req.PageIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "page_ids is required",
+		})
+	}
+
+	parentID, ok := parseWikiParent(c, req.ParentID)
+	if !ok {
+		return nil
+	}
+
+	pageIDs := make([]uuid.UUID, len(req.PageIDs))
+	listed := make(map[uuid.UUID]bool, len(req.PageIDs))
+	for i, raw := range req.PageIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil || listed[id] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "page_ids must be distinct page IDs",
+			})
+		}
+		pageIDs[i] = id
+		listed[id] = true
+	}
+
+	ordered, err := h.channelService.ReorderWikiPages(c.Context(), cid, parentID, pageIDs)
+	if err != nil {
+		return wikiMoveError(c, err, "reorder wiki pages")
+	}
+
+	pages := make([]models.WikiPageSummary, len(ordered))
+	for i := range ordered {
+		pages[i] = ordered[i].ToSummary()
+	}
+
+	return c.JSON(fiber.Map{
+		"parent_id": parentID,
+		"pages":     pages,
+	})
+}
+
+// parseWikiParent reads an optional parent_id, where an empty string means
+// the top level. On failure it writes the error response and returns false.
+func parseWikiParent(c fiber.Ctx, raw *string) (*uuid.UUID, bool) {
+	if raw == nil || *raw == "" {
+		return nil, true
+	}
+	pid, err := uuid.Parse(*raw)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid parent ID",
+		})
+		return nil, false
+	}
+	return &pid, true
+}
+
+// wikiSlugAvailable checks that slug is well-formed and unused in the
+// channel. On failure it writes the error response and returns false.
+func (h *WikiHandler) wikiSlugAvailable(c fiber.Ctx, channelID uuid.UUID, slug string) bool {
+	if !wikiSlugPattern.MatchString(slug) {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Slug may only contain letters, digits, '-' and '_'",
+		})
+		return false
+	}
+	if services.WikiSlugReserved(slug) {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "This slug is reserved",
+		})
+		return false
+	}
+
+	var taken int64
+	if err := h.db.Model(&models.WikiPage{}).
+		Where("channel_id = ? AND slug = ?", channelID, slug).
+		Count(&taken).Error; err != nil {
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return false
+	}
+	if taken > 0 {
+		c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Wiki page with this slug already exists",
+		})
+		return false
+	}
+	return true
+}
+
+// changeWikiSlug renames the page and leaves a redirect from the old slug.
+// With rewriteLinks, [[old]] links in other pages the user may edit are
+// rewritten as well; it returns the pages changed and those skipped.
+func (h *WikiHandler) changeWikiSlug(tx *gorm.DB, page *models.WikiPage, newSlug string, userID uuid.UUID, perms models.WikiPermissions, rewriteLinks bool) (rewritten, skipped []models.WikiPageSummary, err error) {
+	oldSlug := page.Slug
+	if err := services.RenameWikiPage(tx, page, newSlug); err != nil {
+		return nil, nil, err
+	}
+
+	rewritten = make([]models.WikiPageSummary, 0)
+	skipped = make([]models.WikiPageSummary, 0)
+	if !rewriteLinks {
+		return rewritten, skipped, nil
+	}
+
+	changed, protected, err := services.RewriteWikiLinks(tx, page, oldSlug, userID, perms)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range changed {
+		rewritten = append(rewritten, changed[i].ToSummary())
+	}
+	for i := range protected {
+		skipped = append(skipped, protected[i].ToSummary())
+	}
+	return rewritten, skipped, nil
+}
+
+// redirectWikiPage answers a request for an old slug with a permanent
+// redirect to the page's current location.
+func redirectWikiPage(c fiber.Ctx, page *models.WikiPage) error {
+	location := path.Dir(c.Path()) + "/" + url.PathEscape(page.Slug)
+	if query := string(c.Request().URI().QueryString()); query != "" {
+		location += "?" + query
+	}
+
+	c.Set(fiber.HeaderLocation, location)
+	return c.Status(fiber.StatusMovedPermanently).JSON(fiber.Map{
+		"error":    "Wiki page has moved",
+		"slug":     page.Slug,
+		"location": location,
+	})
+}
+
+func wikiMoveError(c fiber.Ctx, err error, action string) error {
+	switch {
+	case errors.Is(err, services.ErrWikiParentNotFound):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Parent page not found",
+		})
+	case errors.Is(err, services.ErrWikiParentCycle), errors.Is(err, services.ErrWikiNotSibling):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	default:
+		log.Printf("Error rearranging wiki pages: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to " + action,
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+// SearchWiki searches the titles and content of one channel's wiki. Drafts
+// are found only by wiki editors.
+func (h *WikiHandler) SearchWiki(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	opts, ok := wikiSearchOptions(c)
+	if !ok {
+		return nil
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+
+	opts.ChannelIDs = []uuid.UUID{cid}
+	if perms.Edit {
+		opts.DraftChannelIDs = opts.ChannelIDs
+	}
+	return h.searchWiki(c, opts)
+}
+
+// SearchAllWikis searches the wikis of every channel the user owns, runs or
+// is subscribed to, or holds a temporary wiki role in.
+func (h *WikiHandler) SearchAllWikis(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	opts, ok := wikiSearchOptions(c)
+	if !ok {
+		return nil
+	}
+
+	opts.ChannelIDs, opts.DraftChannelIDs, err = h.channelService.WikiChannels(c.Context(), uid)
+	if err != nil {
+		log.Printf("Error listing wiki channels: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return h.searchWiki(c, opts)
+}
+
+func (h *WikiHandler) searchWiki(c fiber.Ctx, opts services.WikiSearchOptions) error {
+	hits, total, err := h.searchService.Search(c.Context(), opts)
+	if err != nil {
+		log.Printf("Error searching wiki: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to search wiki",
+		})
+	}
+
+	terms := services.WikiSearchTerms(opts.Query)
+	results := make([]models.WikiSearchResult, len(hits))
+	for i, hit := range hits {
+		page := &hit.Page
+		results[i] = models.WikiSearchResult{
+			PageID:         page.ID,
+			ChannelID:      page.ChannelID,
+			Slug:           page.Slug,
+			Title:          page.Title,
+			TitleHighlight: services.HighlightWikiText(page.Title, terms),
+			Snippet:        services.WikiSnippet(page.Content, terms),
+			IsPublished:    page.IsPublished,
+			Rank:           hit.Rank,
+			UpdatedAt:      page.UpdatedAt,
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"query":   opts.Query,
+		"results": results,
+		"count":   len(results),
+		"total":   total,
+	})
+}
+
+// wikiSearchOptions reads q, published, limit and offset. On failure it
+// writes the error response and returns false.
+func wikiSearchOptions(c fiber.Ctx) (services.WikiSearchOptions, bool) {
+	opts := services.WikiSearchOptions{
+		Query:  strings.TrimSpace(c.Query("q")),
+		Limit:  20,
+		Offset: 0,
+	}
+
+	if len([]rune(opts.Query)) < 2 {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Search query must be at least 2 characters",
+		})
+		return opts, false
+	}
+
+	switch c.Query("published") {
+	case "":
+	case "true", "false":
+		published := c.Query("published") == "true"
+		opts.Published = &published
+	default:
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "published must be true or false",
+		})
+		return opts, false
+	}
+
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		opts.Limit = v
+	}
+	if opts.Limit > 50 {
+		opts.Limit = 50
+	}
+	if v, err := strconv.Atoi(c.Query("offset")); err == nil && v > 0 {
+		opts.Offset = v
+	}
+	return opts, true
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+// ListWikiTemplates lists the channel's page templates to those who may
+// create or edit pages.
+func (h *WikiHandler) ListWikiTemplates(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+	if !perms.Create && !perms.Edit {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot create pages in this channel's wiki",
+		})
+	}
+
+	templates := make([]models.WikiTemplate, 0)
+	if err := h.db.Where("channel_id = ?", cid).Order("name ASC").Find(&templates).Error; err != nil {
+		log.Printf("Error listing wiki templates: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list wiki templates",
+		})
+	}
+
+	return c.JSON(templates)
+}
+
+func (h *WikiHandler) CreateWikiTemplate(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, ok := h.requireWikiTemplateEditor(c, userID)
+	if !ok {
+		return nil
+	}
+	uid, _ := uuid.Parse(userID)
+
+	var req models.CreateWikiTemplateRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name must be 1-100 characters",
+		})
+	}
+	if req.Content == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Content is required",
+		})
+	}
+	if !h.wikiTemplateNameAvailable(c, cid, req.Name, uuid.Nil) {
+		return nil
+	}
+
+	tmpl := models.WikiTemplate{
+		ChannelID:   cid,
+		Name:        req.Name,
+		Description: req.Description,
+		Title:       req.Title,
+		Content:     req.Content,
+		CreatedByID: uid,
+	}
+	if err := h.db.Create(&tmpl).Error; err != nil {
+		log.Printf("Error creating wiki template: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create wiki template",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(tmpl)
+}
+
+func (h *WikiHandler) UpdateWikiTemplate(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, ok := h.requireWikiTemplateEditor(c, userID)
+	if !ok {
+		return nil
+	}
+
+	tmpl := h.loadWikiTemplate(c, cid)
+	if tmpl == nil {
+		return nil
+	}
+
+	var req models.UpdateWikiTemplateRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 100 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Name must be 1-100 characters",
+			})
+		}
+		if name != tmpl.Name && !h.wikiTemplateNameAvailable(c, cid, name, tmpl.ID) {
+			return nil
+		}
+		tmpl.Name = name
+	}
+	if req.Description != nil {
+		tmpl.Description = *req.Description
+	}
+	if req.Title != nil {
+		tmpl.Title = *req.Title
+	}
+	if req.Content != nil {
+		if *req.Content == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Content is required",
+			})
+		}
+		tmpl.Content = *req.Content
+	}
+
+	if err := h.db.Save(tmpl).Error; err != nil {
+		log.Printf("Error updating wiki template: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update wiki template",
+		})
+	}
+
+	return c.JSON(tmpl)
+}
+
+// DeleteWikiTemplate removes a template. Pages created from it are not
+// affected.
+func (h *WikiHandler) DeleteWikiTemplate(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, ok := h.requireWikiTemplateEditor(c, userID)
+	if !ok {
+		return nil
+	}
+
+	tmpl := h.loadWikiTemplate(c, cid)
+	if tmpl == nil {
+		return nil
+	}
+
+	if err := h.db.Delete(tmpl).Error; err != nil {
+		log.Printf("Error deleting wiki template: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete wiki template",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Wiki template deleted successfully",
+	})
+}
+
+// requireWikiTemplateEditor checks that the user may edit the wiki named by
+// the :channelId route parameter, which is what managing its templates
+// takes. On failure it writes the error response and returns false.
+func (h *WikiHandler) requireWikiTemplateEditor(c fiber.Ctx, userID string) (uuid.UUID, bool) {
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+		return uuid.Nil, false
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return uuid.Nil, false
+	}
+	if !perms.Edit {
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You cannot edit this channel's wiki",
+		})
+		return uuid.Nil, false
+	}
+	return cid, true
+}
+
+// loadWikiTemplate fetches the template named by the :templateId route
+// parameter. On failure it writes the error response and returns nil.
+func (h *WikiHandler) loadWikiTemplate(c fiber.Ctx, channelID uuid.UUID) *models.WikiTemplate {
+	templateID, err := uuid.Parse(c.Params("templateId"))
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+		return nil
+	}
+	return h.findWikiTemplate(c, channelID, templateID)
+}
+
+func (h *WikiHandler) findWikiTemplate(c fiber.Ctx, channelID, templateID uuid.UUID) *models.WikiTemplate {
+	var tmpl models.WikiTemplate
+	if err := h.db.Where("id = ? AND channel_id = ?", templateID, channelID).First(&tmpl).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Wiki template not found",
+			})
+			return nil
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return nil
+	}
+	return &tmpl
+}
+
+// wikiTemplateNameAvailable reports whether no other template of the
+// channel has the name. Otherwise it writes the error response.
+func (h *WikiHandler) wikiTemplateNameAvailable(c fiber.Ctx, channelID uuid.UUID, name string, exceptID uuid.UUID) bool {
+	var count int64
+	if err := h.db.Model(&models.WikiTemplate{}).
+		Where("channel_id = ? AND name = ? AND id <> ?", channelID, name, exceptID).
+		Count(&count).Error; err != nil {
+		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+		return false
+	}
+	if count > 0 {
+		c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A template with this name already exists",
+		})
+		return false
+	}
+	return true
+}
+
+// applyWikiTemplate fills the title and content a new page leaves empty from
+// the template the request names, expanding its placeholders. On failure
+// it writes the error response and returns false.
+func (h *WikiHandler) applyWikiTemplate(c fiber.Ctx, channelID, userID uuid.UUID, req *models.CreateWikiPageRequest) bool {
+	templateID, err := uuid.Parse(*req.TemplateID)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+		return false
+	}
+
+	tmpl := h.findWikiTemplate(c, channelID, templateID)
+	if tmpl == nil {
+		return false
+	}
+
+	author := userID.String()
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err == nil && user.Username != nil {
+		author = *user.Username
+	}
+
+	vars := services.WikiTemplateVars(time.Now(), author, req.Title)
+	if req.Title == "" {
+		delete(vars, "title")
+		req.Title = services.ExpandWikiTemplate(tmpl.Title, vars)
+		vars["title"] = req.Title
+	}
+	if req.Content == "" {
+		req.Content = services.ExpandWikiTemplate(tmpl.Content, vars)
+	}
+	return true
+}
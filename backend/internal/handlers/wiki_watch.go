@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// GetWikiWatches returns whether the user watches the channel's wiki and
+// which of its pages they watch.
+func (h *WikiHandler) GetWikiWatches(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+	uid, _ := uuid.Parse(userID)
+
+	var watches []models.WikiWatch
+	if err := h.db.Where("channel_id = ? AND user_id = ?", cid, uid).Find(&watches).Error; err != nil {
+		log.Printf("Error listing wiki watches: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	resp := models.WikiWatchesResponse{Pages: make([]models.WikiPageSummary, 0)}
+	var pageIDs []uuid.UUID
+	for _, watch := range watches {
+		if watch.PageID == nil {
+			resp.Wiki = true
+		} else {
+			pageIDs = append(pageIDs, *watch.PageID)
+		}
+	}
+
+	if len(pageIDs) > 0 {
+		var pages []models.WikiPage
+		if err := h.db.Where("id IN ?", pageIDs).Order("title ASC").Find(&pages).Error; err != nil {
+			log.Printf("Error loading watched wiki pages: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		for i := range pages {
+			if perms.CanViewPage(&pages[i]) {
+				resp.Pages = append(resp.Pages, pages[i].ToSummary())
+			}
+		}
+	}
+
+	return c.JSON(resp)
+}
+
+// WatchWiki subscribes the user to changes of every page in the channel's
+// wiki.
+func (h *WikiHandler) WatchWiki(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	if _, ok := h.requireWikiReader(c, cid, userID); !ok {
+		return nil
+	}
+	uid, _ := uuid.Parse(userID)
+
+	return h.watchWiki(c, cid, uid, nil)
+}
+
+// UnwatchWiki stops the wiki-wide watch. Watches on single pages stay.
+func (h *WikiHandler) UnwatchWiki(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	return h.unwatchWiki(c, h.db.Where("channel_id = ? AND user_id = ? AND page_id IS NULL", cid, uid))
+}
+
+// WatchWikiPage subscribes the user to changes of one page.
+func (h *WikiHandler) WatchWikiPage(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	perms, ok := h.requireWikiReader(c, cid, userID)
+	if !ok {
+		return nil
+	}
+	uid, _ := uuid.Parse(userID)
+
+	wikiPage := h.loadWikiPage(c, cid, c.Params("slug"), perms)
+	if wikiPage == nil {
+		return nil
+	}
+
+	return h.watchWiki(c, cid, uid, &wikiPage.ID)
+}
+
+// UnwatchWikiPage stops watching one page. It does not touch a wiki-wide
+// watch.
+func (h *WikiHandler) UnwatchWikiPage(c fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	cid, err := uuid.Parse(c.Params("channelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid channel ID",
+		})
+	}
+
+	var wikiPage models.WikiPage
+	if err := h.db.Where("channel_id = ? AND slug = ?", cid, c.Params("slug")).First(&wikiPage).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Wiki page not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return h.unwatchWiki(c, h.db.Where("page_id = ? AND user_id = ?", wikiPage.ID, uid))
+}
+
+func (h *WikiHandler) watchWiki(c fiber.Ctx, channelID, userID uuid.UUID, pageID *uuid.UUID) error {
+	query := h.db.Model(&models.WikiWatch{}).Where("channel_id = ? AND user_id = ?", channelID, userID)
+	if pageID == nil {
+		query = query.Where("page_id IS NULL")
+	} else {
+		query = query.Where("page_id = ?", *pageID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		log.Printf("Error checking wiki watch: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if count > 0 {
+		return c.JSON(fiber.Map{
+			"message": "Already watching",
+		})
+	}
+
+	watch := models.WikiWatch{
+		UserID:    userID,
+		ChannelID: channelID,
+		PageID:    pageID,
+	}
+	if err := h.db.Create(&watch).Error; err != nil {
+		log.Printf("Error creating wiki watch: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to watch wiki",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(watch)
+}
+
+func (h *WikiHandler) unwatchWiki(c fiber.Ctx, query *gorm.DB) error {
+	if err := query.Delete(&models.WikiWatch{}).Error; err != nil {
+		log.Printf("Error deleting wiki watch: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to stop watching",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Stopped watching",
+	})
+}
+
+// announceWikiChange sends a wiki_changed event to the page's watchers and
+// queues the change for the channel's digest. The revision and summary are
+// those of the page's latest revision.
+func (h *WikiHandler) announceWikiChange(ctx context.Context, page *models.WikiPage, action models.WikiChangeAction, actorID uuid.UUID) {
+	change := models.WikiChange{
+		ChannelID: page.ChannelID,
+		PageID:    page.ID,
+		Slug:      page.Slug,
+		Title:     page.Title,
+		Action:    action,
+		UserID:    actorID,
+		ChangedAt: time.Now(),
+	}
+	if action != models.WikiChangeDeleted {
+		var revision models.WikiRevision
+		if err := h.db.Where("page_id = ?", page.ID).Order("revision_number DESC").First(&revision).Error; err != nil {
+			log.Printf("Error loading wiki revision: %v", err)
+		} else {
+			change.Revision = revision.RevisionNumber
+			change.Summary = revision.ChangeSummary
+		}
+		if change.Revision > 1 {
+			change.DiffURL = fmt.Sprintf("/api/v1/wiki/%s/%s/diff?to=%d", page.ChannelID, url.PathEscape(page.Slug), change.Revision)
+		}
+	}
+
+	var channel models.Channel
+	if err := h.db.First(&channel, page.ChannelID).Error; err != nil {
+		log.Printf("Error loading channel for wiki change: %v", err)
+		return
+	}
+
+	watchers, err := h.channelService.WikiWatchers(ctx, &channel, page, actorID)
+	if err != nil {
+		log.Printf("Error listing wiki watchers: %v", err)
+	}
+	if len(watchers) > 0 {
+		event, err := json.Marshal(struct {
+			Type string `json:"type"`
+			models.WikiChange
+		}{"wiki_changed", change})
+		if err == nil {
+			for _, watcher := range watchers {
+				h.wsHandler.BroadcastToUser(watcher.String(), event)
+			}
+		}
+	}
+
+	if err := h.channelService.QueueWikiDigest(ctx, &channel, page, &change); err != nil {
+		log.Printf("Error queueing wiki digest: %v", err)
+	}
+}
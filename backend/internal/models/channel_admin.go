@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChannelAdminRights is what a channel admin may do. The owner implicitly
+// holds every right.
+type ChannelAdminRights struct {
+	CanPost              bool `gorm:"default:false" json:"can_post"`
+	CanEditPosts         bool `gorm:"default:false" json:"can_edit_posts"`
+	CanDeletePosts       bool `gorm:"default:false" json:"can_delete_posts"`
+	CanManageSubscribers bool `gorm:"default:false" json:"can_manage_subscribers"`
+	CanManageWiki        bool `gorm:"default:false" json:"can_manage_wiki"`
+	CanManageRSS         bool `gorm:"default:false" json:"can_manage_rss"`
+}
+
+// FullChannelAdminRights returns every right, as held by the channel owner.
+func FullChannelAdminRights() ChannelAdminRights {
+	return ChannelAdminRights{
+		CanPost:              true,
+		CanEditPosts:         true,
+		CanDeletePosts:       true,
+		CanManageSubscribers: true,
+		CanManageWiki:        true,
+		CanManageRSS:         true,
+	}
+}
+
+type ChannelAdmin struct {
+	ChannelID    uuid.UUID          `gorm:"type:uuid;primaryKey" json:"channel_id"`
+	UserID       uuid.UUID          `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Rights       ChannelAdminRights `gorm:"embedded" json:"rights"`
+	PromotedByID uuid.UUID          `gorm:"type:uuid;not null" json:"promoted_by_id"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+type SetChannelAdminRequest struct {
+	Rights ChannelAdminRights `json:"rights"`
+}
+
+type ChannelAdminResponse struct {
+	UserID       uuid.UUID          `json:"user_id"`
+	IsOwner      bool               `json:"is_owner"`
+	Rights       ChannelAdminRights `json:"rights"`
+	PromotedByID *uuid.UUID         `json:"promoted_by_id,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+	User         *UserResponse      `json:"user,omitempty"`
+}
+
+func (a *ChannelAdmin) ToResponse() ChannelAdminResponse {
+	resp := ChannelAdminResponse{
+		UserID:       a.UserID,
+		Rights:       a.Rights,
+		PromotedByID: &a.PromotedByID,
+		CreatedAt:    a.CreatedAt,
+	}
+
+	if a.User != nil {
+		userResp := a.User.ToResponse()
+		resp.User = &userResp
+	}
+
+	return resp
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChannelInviteLink lets people subscribe to a channel, private or not,
+// without finding it first. Links can expire, be capped and require approval.
+type ChannelInviteLink struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ChannelID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"channel_id"`
+	Token            string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"token"`
+	Name             *string    `gorm:"type:varchar(255)" json:"name,omitempty"`
+	CreatedByID      uuid.UUID  `gorm:"type:uuid;not null" json:"created_by_id"`
+	RequiresApproval bool       `gorm:"default:false" json:"requires_approval"`
+	UsageLimit       *int       `json:"usage_limit,omitempty"`
+	UsageCount       int        `gorm:"default:0" json:"usage_count"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	IsRevoked        bool       `gorm:"default:false" json:"is_revoked"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// IsUsable reports whether the link can still admit people at now.
+func (l *ChannelInviteLink) IsUsable(now time.Time) bool {
+	if l.IsRevoked {
+		return false
+	}
+	if l.ExpiresAt != nil && !now.Before(*l.ExpiresAt) {
+		return false
+	}
+	if l.UsageLimit != nil && l.UsageCount >= *l.UsageLimit {
+		return false
+	}
+	return true
+}
+
+type JoinRequestStatus string
+
+const (
+	JoinRequestPending  JoinRequestStatus = "pending"
+	JoinRequestApproved JoinRequestStatus = "approved"
+	JoinRequestRejected JoinRequestStatus = "rejected"
+)
+
+type ChannelJoinRequest struct {
+	ID           uuid.UUID         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ChannelID    uuid.UUID         `gorm:"type:uuid;not null;index:idx_channel_join_requests_status" json:"channel_id"`
+	UserID       uuid.UUID         `gorm:"type:uuid;not null" json:"user_id"`
+	InviteLinkID *uuid.UUID        `gorm:"type:uuid" json:"invite_link_id,omitempty"`
+	Message      *string           `gorm:"type:text" json:"message,omitempty"`
+	Status       JoinRequestStatus `gorm:"type:varchar(20);not null;default:'pending';index:idx_channel_join_requests_status" json:"status"`
+	ReviewedByID *uuid.UUID        `gorm:"type:uuid" json:"reviewed_by_id,omitempty"`
+	ReviewedAt   *time.Time        `json:"reviewed_at,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+type CreateInviteLinkRequest struct {
+	Name             *string `json:"name" validate:"omitempty,max=255"`
+	RequiresApproval bool    `json:"requires_approval"`
+	UsageLimit       *int    `json:"usage_limit" validate:"omitempty,min=1,max=100000"`
+	ExpiresInHours   *int    `json:"expires_in_hours" validate:"omitempty,min=1,max=8760"`
+}
+
+type JoinChannelRequest struct {
+	Message *string `json:"message" validate:"omitempty,max=500"`
+}
+
+// InvitePreviewResponse is what a link reveals before joining.
+type InvitePreviewResponse struct {
+	ChannelID        uuid.UUID `json:"channel_id"`
+	Name             string    `json:"name"`
+	Description      *string   `json:"description,omitempty"`
+	AvatarURL        *string   `json:"avatar_url,omitempty"`
+	SubscriberCount  int       `json:"subscriber_count"`
+	IsPublic         bool      `json:"is_public"`
+	RequiresApproval bool      `json:"requires_approval"`
+	IsSubscribed     bool      `json:"is_subscribed"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChannelSubscriptionEvent logs every subscribe and unsubscribe so subscriber
+// growth can be charted after the fact.
+type ChannelSubscriptionEvent struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ChannelID uuid.UUID `gorm:"type:uuid;not null;index:idx_channel_subscription_events" json:"channel_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	Joined    bool      `gorm:"not null" json:"joined"`
+	CreatedAt time.Time `gorm:"index:idx_channel_subscription_events" json:"created_at"`
+}
+
+type SubscriberGrowthPoint struct {
+	Date        string `json:"date"`
+	Joined      int64  `json:"joined"`
+	Left        int64  `json:"left"`
+	Net         int64  `json:"net"`
+	Subscribers int64  `json:"subscribers"`
+}
+
+type PostViewStats struct {
+	PostID       uuid.UUID `json:"post_id"`
+	Content      string    `json:"content"`
+	CreatedAt    time.Time `json:"created_at"`
+	Views        int64     `json:"views"`
+	ViewsInRange int64     `json:"views_in_range"`
+}
+
+type ChannelStatsResponse struct {
+	ChannelID        uuid.UUID               `json:"channel_id"`
+	From             string                  `json:"from"`
+	To               string                  `json:"to"`
+	SubscriberCount  int                     `json:"subscriber_count"`
+	SubscriberGrowth []SubscriberGrowthPoint `json:"subscriber_growth"`
+	TotalViews       int64                   `json:"total_views"`
+	Posts            []PostViewStats         `json:"posts"`
+	TopPosts         []PostViewStats         `json:"top_posts"`
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+    "errors"
+    "regexp"
+    "strings"
     "time"
 
     "github.com/google/uuid"
@@ -15,8 +18,13 @@ const (
 
     MemberRoleAdmin  MemberRole = "admin"
     MemberRoleMember MemberRole = "member"
+
+    MaxPinnedChats = 5
 )
 
+// MutedForever is stored in MutedUntil when a chat is muted without an end date.
+var MutedForever = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
 type Chat struct {
     ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
     Name          *string    `gorm:"type:varchar(255)" json:"name,omitempty"`
@@ -28,28 +36,96 @@ type Chat struct {
     CreatedAt     time.Time  `json:"created_at"`
     UpdatedAt     time.Time  `json:"updated_at"`
     LastMessageAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"last_message_at"`
+    Restrictions  ChatRestrictions `gorm:"embedded" json:"restrictions"`
+    IsForum       bool       `gorm:"default:false" json:"is_forum"`
     
     Owner         *User        `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
     Members       []ChatMember `gorm:"foreignKey:ChatID" json:"members,omitempty"`
 }
 
+// ChatRestrictions limit what regular members may post. Admins are exempt.
+type ChatRestrictions struct {
+    SlowModeSeconds int  `gorm:"default:0" json:"slow_mode_seconds"`
+    AdminsOnly      bool `gorm:"default:false" json:"admins_only"`
+    NoMedia         bool `gorm:"default:false" json:"no_media"`
+    NoLinks         bool `gorm:"default:false" json:"no_links"`
+}
+
 type ChatMember struct {
     ChatID     uuid.UUID  `gorm:"primaryKey" json:"chat_id"`
     UserID     uuid.UUID  `gorm:"primaryKey" json:"user_id"`
     Role       MemberRole `gorm:"type:varchar(20);default:'member'" json:"role"`
     JoinedAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"joined_at"`
     LastReadAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"last_read_at"`
+
+    MutedUntil     *time.Time `json:"muted_until,omitempty"`
+    IsArchived     bool       `gorm:"default:false" json:"is_archived"`
+    PinnedPosition *int       `json:"pinned_position,omitempty"`
+    MarkedUnread   bool       `gorm:"default:false" json:"marked_unread"`
     
     User       *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
     Chat       *Chat      `gorm:"foreignKey:ChatID" json:"chat,omitempty"`
 }
 
+func (m *ChatMember) IsMuted(now time.Time) bool {
+    return m.MutedUntil != nil && m.MutedUntil.After(now)
+}
+
+type SanctionType string
+
+const (
+    SanctionTypeBan      SanctionType = "ban"
+    SanctionTypeReadOnly SanctionType = "read_only"
+    SanctionTypeKick     SanctionType = "kick"
+)
+
+// ChatSanction records a moderation action against a chat member. Bans and
+// read-only restrictions stay in force while active and, when ExpiresAt is set,
+// are lifted by the worker once it passes. Kicks are kept for history only.
+type ChatSanction struct {
+    ID         uuid.UUID    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+    ChatID     uuid.UUID    `gorm:"type:uuid;not null;index:idx_chat_sanctions_target" json:"chat_id"`
+    UserID     uuid.UUID    `gorm:"type:uuid;not null;index:idx_chat_sanctions_target" json:"user_id"`
+    Type       SanctionType `gorm:"type:varchar(20);not null" json:"type"`
+    Reason     *string      `gorm:"type:text" json:"reason,omitempty"`
+    IssuedByID uuid.UUID    `gorm:"type:uuid;not null" json:"issued_by_id"`
+    ExpiresAt  *time.Time   `gorm:"index:idx_chat_sanctions_expires" json:"expires_at,omitempty"`
+    IsActive   bool         `gorm:"default:true" json:"is_active"`
+    CreatedAt  time.Time    `json:"created_at"`
+    LiftedAt   *time.Time   `json:"lifted_at,omitempty"`
+
+    User       *User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
+    IssuedBy   *User        `gorm:"foreignKey:IssuedByID" json:"issued_by,omitempty"`
+}
+
+type CreateSanctionRequest struct {
+    UserID        string  `json:"user_id" validate:"required,uuid"`
+    DurationHours int     `json:"duration_hours" validate:"omitempty,min=0,max=8760"`
+    Reason        *string `json:"reason" validate:"omitempty,max=500"`
+}
+
 type CreateChatRequest struct {
     Name      *string   `json:"name" validate:"omitempty,max=255"`
     Type      ChatType  `json:"type" validate:"required,oneof=dm group"`
     MemberIDs []string  `json:"member_ids" validate:"required,min=1"`
 }
 
+// UpdateChatSettingsRequest changes the caller's own view of a chat.
+// MuteFor is in seconds: 0 unmutes, a negative value mutes forever.
+type UpdateChatSettingsRequest struct {
+    MuteFor      *int64 `json:"mute_for"`
+    IsArchived   *bool  `json:"is_archived"`
+    IsPinned     *bool  `json:"is_pinned"`
+    MarkedUnread *bool  `json:"marked_unread"`
+}
+
+type UpdateChatRestrictionsRequest struct {
+    SlowModeSeconds *int  `json:"slow_mode_seconds" validate:"omitempty,min=0,max=3600"`
+    AdminsOnly      *bool `json:"admins_only"`
+    NoMedia         *bool `json:"no_media"`
+    NoLinks         *bool `json:"no_links"`
+}
+
 type AddMemberRequest struct {
     UserID string     `json:"user_id" validate:"required,uuid"`
     Role   MemberRole `json:"role" validate:"omitempty,oneof=admin member"`
@@ -64,6 +140,8 @@ type ChatResponse struct {
     MemberCount   int             `json:"member_count"`
     CreatedAt     time.Time       `json:"created_at"`
     LastMessageAt time.Time       `json:"last_message_at"`
+    Restrictions  ChatRestrictions `json:"restrictions"`
+    IsForum       bool            `json:"is_forum"`
     Members       []MemberResponse `json:"members,omitempty"`
 }
 
@@ -71,6 +149,30 @@ type ChatWithLastMessageResponse struct {
     ChatResponse
     LastMessage *MessageResponse `json:"last_message,omitempty"`
     UnreadCount int64            `json:"unread_count"`
+    LastTopic   *ChatTopic       `json:"last_topic,omitempty"`
+    ChatSettingsResponse
+}
+
+type ChatSettingsResponse struct {
+    ChatID         uuid.UUID  `json:"chat_id"`
+    IsMuted        bool       `json:"is_muted"`
+    MutedUntil     *time.Time `json:"muted_until,omitempty"`
+    IsArchived     bool       `json:"is_archived"`
+    IsPinned       bool       `json:"is_pinned"`
+    PinnedPosition *int       `json:"pinned_position,omitempty"`
+    MarkedUnread   bool       `json:"marked_unread"`
+}
+
+func (m *ChatMember) ToSettingsResponse() ChatSettingsResponse {
+    return ChatSettingsResponse{
+        ChatID:         m.ChatID,
+        IsMuted:        m.IsMuted(time.Now()),
+        MutedUntil:     m.MutedUntil,
+        IsArchived:     m.IsArchived,
+        IsPinned:       m.PinnedPosition != nil,
+        PinnedPosition: m.PinnedPosition,
+        MarkedUnread:   m.MarkedUnread,
+    }
 }
 
 type MemberResponse struct {
@@ -90,6 +192,8 @@ func (c *Chat) ToResponse() ChatResponse {
         MemberCount:   c.MemberCount,
         CreatedAt:     c.CreatedAt,
         LastMessageAt: c.LastMessageAt,
+        Restrictions:  c.Restrictions,
+        IsForum:       c.IsForum,
     }
     
     if len(c.Members) > 0 {
@@ -114,11 +218,15 @@ func (c *Chat) ToResponse() ChatResponse {
 type Channel struct {
     ID              uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
     Name            string    `gorm:"type:varchar(255);not null" json:"name"`
+    Handle          *string   `gorm:"type:varchar(32);uniqueIndex" json:"handle,omitempty"`
     OwnerID         uuid.UUID `gorm:"type:uuid;not null" json:"owner_id"`
     Description     *string   `gorm:"type:text" json:"description,omitempty"`
     AvatarURL       *string   `gorm:"type:text" json:"avatar_url,omitempty"`
     SubscriberCount int       `gorm:"default:0" json:"subscriber_count"`
     IsPublic        bool      `gorm:"default:true" json:"is_public"`
+    SignPosts       bool      `gorm:"default:false" json:"sign_posts"`
+    WikiDigest      bool      `gorm:"default:false" json:"wiki_digest"`
+    DiscussionChatID *uuid.UUID `gorm:"type:uuid;uniqueIndex" json:"discussion_chat_id,omitempty"`
     CreatedAt       time.Time `json:"created_at"`
     UpdatedAt       time.Time `json:"updated_at"`
     
@@ -136,18 +244,50 @@ type ChannelSubscriber struct {
 
 type CreateChannelRequest struct {
     Name        string  `json:"name" validate:"required,max=255"`
+    Handle      *string `json:"handle" validate:"omitempty,min=5,max=32"`
+    Description *string `json:"description" validate:"omitempty,max=1000"`
+    IsPublic    *bool   `json:"is_public"`
+}
+
+type UpdateChannelRequest struct {
+    Name        *string `json:"name" validate:"omitempty,min=1,max=255"`
+    Handle      *string `json:"handle" validate:"omitempty,max=32"`
     Description *string `json:"description" validate:"omitempty,max=1000"`
+    SignPosts   *bool   `json:"sign_posts"`
     IsPublic    *bool   `json:"is_public"`
+    WikiDigest  *bool   `json:"wiki_digest"`
+}
+
+type LinkDiscussionRequest struct {
+    ChatID string `json:"chat_id" validate:"required,uuid"`
+}
+
+var ErrInvalidChannelHandle = errors.New("handle must be 5-32 characters: letters, digits and underscores, starting with a letter")
+
+var channelHandleRegex = regexp.MustCompile(`^[a-z][a-z0-9_]{4,31}$`)
+
+// NormalizeChannelHandle lowercases a handle and strips a leading "@".
+// Handles are stored and compared in this form.
+func NormalizeChannelHandle(handle string) (string, error) {
+    handle = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+    if !channelHandleRegex.MatchString(handle) {
+        return "", ErrInvalidChannelHandle
+    }
+    return handle, nil
 }
 
 type ChannelResponse struct {
     ID              uuid.UUID    `json:"id"`
     Name            string       `json:"name"`
+    Handle          *string      `json:"handle,omitempty"`
     OwnerID         uuid.UUID    `json:"owner_id"`
     Description     *string      `json:"description,omitempty"`
     AvatarURL       *string      `json:"avatar_url,omitempty"`
     SubscriberCount int          `json:"subscriber_count"`
     IsPublic        bool         `json:"is_public"`
+    SignPosts       bool         `json:"sign_posts"`
+    WikiDigest      bool         `json:"wiki_digest"`
+    DiscussionChatID *uuid.UUID  `json:"discussion_chat_id,omitempty"`
     CreatedAt       time.Time    `json:"created_at"`
     Owner           *UserResponse `json:"owner,omitempty"`
 }
@@ -156,11 +296,15 @@ func (c *Channel) ToResponse() ChannelResponse {
     resp := ChannelResponse{
         ID:              c.ID,
         Name:            c.Name,
+        Handle:          c.Handle,
         OwnerID:         c.OwnerID,
         Description:     c.Description,
         AvatarURL:       c.AvatarURL,
         SubscriberCount: c.SubscriberCount,
         IsPublic:        c.IsPublic,
+        SignPosts:       c.SignPosts,
+        WikiDigest:      c.WikiDigest,
+        DiscussionChatID: c.DiscussionChatID,
         CreatedAt:       c.CreatedAt,
     }
     
@@ -171,3 +315,12 @@ func (c *Channel) ToResponse() ChannelResponse {
     
     return resp
 }
+
+// MyChannelResponse is a channel in the caller's own list, with how the
+// caller relates to it.
+type MyChannelResponse struct {
+    ChannelResponse
+    IsOwner      bool `json:"is_owner"`
+    IsAdmin      bool `json:"is_admin"`
+    IsSubscribed bool `json:"is_subscribed"`
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/messenger/backend/pkg/diff"
+	"github.com/messenger/backend/pkg/highlight"
 )
 
 type CodeLanguage string
@@ -29,34 +31,64 @@ const (
 	CodeLanguageOther      CodeLanguage = "other"
 )
 
+var codeLanguages = map[CodeLanguage]bool{
+	CodeLanguageJavaScript: true, CodeLanguageTypeScript: true, CodeLanguagePython: true,
+	CodeLanguageGo: true, CodeLanguageJava: true, CodeLanguageC: true, CodeLanguageCPP: true,
+	CodeLanguageRust: true, CodeLanguagePHP: true, CodeLanguageRuby: true, CodeLanguageSQL: true,
+	CodeLanguageHTML: true, CodeLanguageCSS: true, CodeLanguageBash: true, CodeLanguageJSON: true,
+	CodeLanguageXML: true, CodeLanguageMarkdown: true, CodeLanguageOther: true,
+}
+
+// IsValid reports whether l is one of the languages above.
+func (l CodeLanguage) IsValid() bool {
+	return codeLanguages[l]
+}
+
 type CodeSnippet struct {
-	ID          uuid.UUID     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
-	MessageID   uuid.UUID     `gorm:"type:uuid;not null;unique" json:"message_id"`
-	ChatID      uuid.UUID     `gorm:"type:uuid;not null;index:idx_code_chat" json:"chat_id"`
-	Language    CodeLanguage  `gorm:"type:varchar(50);not null" json:"language"`
-	Code        string        `gorm:"type:text;not null" json:"code"`
-	FileName    *string       `gorm:"type:varchar(255)" json:"file_name,omitempty"`
-	CreatedByID uuid.UUID     `gorm:"type:uuid;not null" json:"created_by_id"`
-	CreatedAt   time.Time     `json:"created_at"`
-	UpdatedAt   time.Time     `json:"updated_at"`
+	ID          uuid.UUID    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	MessageID   uuid.UUID    `gorm:"type:uuid;not null;unique" json:"message_id"`
+	ChatID      uuid.UUID    `gorm:"type:uuid;not null;index:idx_code_chat" json:"chat_id"`
+	Language    CodeLanguage `gorm:"type:varchar(50);not null" json:"language"`
+	Code        string       `gorm:"type:text;not null" json:"code"`
+	FileName    *string      `gorm:"type:varchar(255)" json:"file_name,omitempty"`
+	CreatedByID uuid.UUID    `gorm:"type:uuid;not null" json:"created_by_id"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	// Version is the number of the snippet's latest version.
+	Version int `gorm:"not null;default:1" json:"version"`
 
-	CreatedBy *User  `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	// LanguageDetected is set when Language was inferred from the file name
+	// and code rather than chosen, with LanguageConfidence from 0 to 1.
+	LanguageDetected   bool    `gorm:"default:false" json:"language_detected"`
+	LanguageConfidence float64 `gorm:"default:0" json:"language_confidence,omitempty"`
+
+	// PromotedFromID and PromotedBlock name the text message and the index
+	// of the fenced code block the snippet was promoted from. PromotedHash
+	// identifies the block by its code, so edits of the message that add or
+	// remove blocks move PromotedBlock along, or clear it once the block is
+	// gone.
+	PromotedFromID *uuid.UUID `gorm:"type:uuid;index:idx_code_promoted_from" json:"promoted_from_id,omitempty"`
+	PromotedBlock  *int       `json:"promoted_block,omitempty"`
+	PromotedHash   string     `gorm:"type:varchar(64)" json:"-"`
+
+	CreatedBy *User    `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
 	Message   *Message `gorm:"foreignKey:MessageID" json:"message,omitempty"`
-	Chat      *Chat  `gorm:"foreignKey:ChatID" json:"chat,omitempty"`
+	Chat      *Chat    `gorm:"foreignKey:ChatID" json:"chat,omitempty"`
 }
 
 type CreateCodeSnippetRequest struct {
 	MessageID string       `json:"message_id" validate:"required,uuid"`
 	ChatID    string       `json:"chat_id" validate:"required,uuid"`
-	Language  CodeLanguage `json:"language" validate:"required"`
+	Language  CodeLanguage `json:"language" validate:"omitempty"`
 	Code      string       `json:"code" validate:"required"`
 	FileName  *string      `json:"file_name" validate:"omitempty,max=255"`
 }
 
 type UpdateCodeSnippetRequest struct {
-	Language *CodeLanguage `json:"language" validate:"omitempty"`
-	Code     *string       `json:"code" validate:"omitempty,min=1"`
-	FileName *string       `json:"file_name" validate:"omitempty,max=255"`
+	Language      *CodeLanguage `json:"language" validate:"omitempty"`
+	Code          *string       `json:"code" validate:"omitempty,min=1"`
+	FileName      *string       `json:"file_name" validate:"omitempty,max=255"`
+	ChangeSummary *string       `json:"change_summary" validate:"omitempty,max=255"`
 }
 
 type CodeSnippetResponse struct {
@@ -69,7 +101,18 @@ type CodeSnippetResponse struct {
 	CreatedByID uuid.UUID     `json:"created_by_id"`
 	CreatedAt   time.Time     `json:"created_at"`
 	UpdatedAt   time.Time     `json:"updated_at"`
+	Version     int           `json:"version"`
 	CreatedBy   *UserResponse `json:"created_by,omitempty"`
+
+	LanguageDetected   bool       `json:"language_detected"`
+	LanguageConfidence float64    `json:"language_confidence,omitempty"`
+	PromotedFromID     *uuid.UUID `json:"promoted_from_id,omitempty"`
+	PromotedBlock      *int       `json:"promoted_block,omitempty"`
+
+	// Set when the snippet is fetched with ?render=html or ?render=tokens.
+	Theme string           `json:"theme,omitempty"`
+	HTML  string           `json:"html,omitempty"`
+	Lines []highlight.Line `json:"lines,omitempty"`
 }
 
 func (c *CodeSnippet) ToResponse() CodeSnippetResponse {
@@ -83,6 +126,12 @@ func (c *CodeSnippet) ToResponse() CodeSnippetResponse {
 		CreatedByID: c.CreatedByID,
 		CreatedAt:   c.CreatedAt,
 		UpdatedAt:   c.UpdatedAt,
+		Version:     c.Version,
+
+		LanguageDetected:   c.LanguageDetected,
+		LanguageConfidence: c.LanguageConfidence,
+		PromotedFromID:     c.PromotedFromID,
+		PromotedBlock:      c.PromotedBlock,
 	}
 
 	if c.CreatedBy != nil {
@@ -92,3 +141,151 @@ func (c *CodeSnippet) ToResponse() CodeSnippetResponse {
 
 	return resp
 }
+
+// CodeBlock is a fenced code block found in a text message. Its sender can
+// promote it to a code message with a snippet.
+type CodeBlock struct {
+	Index      int          `json:"index"`
+	Language   CodeLanguage `json:"language"`
+	Confidence float64      `json:"confidence"`
+	Code       string       `json:"code"`
+	StartLine  int          `json:"start_line"`
+	EndLine    int          `json:"end_line"`
+	SnippetID  *uuid.UUID   `json:"snippet_id,omitempty"`
+}
+
+type PromoteCodeBlockRequest struct {
+	Language *CodeLanguage `json:"language" validate:"omitempty"`
+	FileName *string       `json:"file_name" validate:"omitempty,max=255"`
+}
+
+// CodeSnippetVersion is a snapshot of a snippet. Version 1 is the snippet as
+// created; every change of its code, language or file name adds one.
+type CodeSnippetVersion struct {
+	ID            uuid.UUID    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	SnippetID     uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_code_version" json:"snippet_id"`
+	VersionNumber int          `gorm:"not null;uniqueIndex:idx_code_version" json:"version_number"`
+	Language      CodeLanguage `gorm:"type:varchar(50);not null" json:"language"`
+	Code          string       `gorm:"type:text;not null" json:"code"`
+	FileName      *string      `gorm:"type:varchar(255)" json:"file_name,omitempty"`
+	ChangeSummary string       `gorm:"type:text" json:"change_summary"`
+	CreatedByID   uuid.UUID    `gorm:"type:uuid;not null" json:"created_by_id"`
+	CreatedAt     time.Time    `json:"created_at"`
+
+	CreatedBy *User `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+}
+
+// CodeVersionRef identifies one side of a snippet diff.
+type CodeVersionRef struct {
+	VersionNumber int          `json:"version_number"`
+	Language      CodeLanguage `json:"language"`
+	FileName      *string      `json:"file_name,omitempty"`
+	ChangeSummary string       `json:"change_summary"`
+	CreatedByID   uuid.UUID    `json:"created_by_id"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+func (v *CodeSnippetVersion) ToRef() CodeVersionRef {
+	return CodeVersionRef{
+		VersionNumber: v.VersionNumber,
+		Language:      v.Language,
+		FileName:      v.FileName,
+		ChangeSummary: v.ChangeSummary,
+		CreatedByID:   v.CreatedByID,
+		CreatedAt:     v.CreatedAt,
+	}
+}
+
+type CodeSnippetDiffResponse struct {
+	SnippetID uuid.UUID      `json:"snippet_id"`
+	From      CodeVersionRef `json:"from"`
+	To        CodeVersionRef `json:"to"`
+	Additions int            `json:"additions"`
+	Deletions int            `json:"deletions"`
+	Hunks     []diff.Hunk    `json:"hunks"`
+	Unified   string         `json:"unified"`
+}
+
+// CodeComment is a review comment on a range of lines of a snippet, or a
+// reply to one. Only top-level comments carry lines and a resolved state;
+// replies follow their parent.
+//
+// StartLine and EndLine refer to the snippet at Version. They follow the
+// code as new versions move the lines; when a new version changes or drops
+// them, the comment is marked Outdated and stays on the version it was
+// written for.
+type CodeComment struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	SnippetID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_code_comment_snippet" json:"snippet_id"`
+	ParentID     *uuid.UUID `gorm:"type:uuid;index:idx_code_comment_parent" json:"parent_id,omitempty"`
+	AuthorID     uuid.UUID  `gorm:"type:uuid;not null" json:"author_id"`
+	Body         string     `gorm:"type:text;not null" json:"body"`
+	StartLine    int        `gorm:"not null;default:0" json:"start_line"`
+	EndLine      int        `gorm:"not null;default:0" json:"end_line"`
+	Version      int        `gorm:"not null" json:"version"`
+	Outdated     bool       `gorm:"default:false" json:"outdated"`
+	Resolved     bool       `gorm:"default:false" json:"resolved"`
+	ResolvedByID *uuid.UUID `gorm:"type:uuid" json:"resolved_by_id,omitempty"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	Author *User `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+}
+
+type CreateCodeCommentRequest struct {
+	Body      string  `json:"body" validate:"required,max=10000"`
+	StartLine int     `json:"start_line"`
+	EndLine   int     `json:"end_line"`
+	ParentID  *string `json:"parent_id" validate:"omitempty,uuid"`
+}
+
+type UpdateCodeCommentRequest struct {
+	Body string `json:"body" validate:"required,max=10000"`
+}
+
+type CodeCommentResponse struct {
+	ID           uuid.UUID     `json:"id"`
+	SnippetID    uuid.UUID     `json:"snippet_id"`
+	ParentID     *uuid.UUID    `json:"parent_id,omitempty"`
+	AuthorID     uuid.UUID     `json:"author_id"`
+	Body         string        `json:"body"`
+	StartLine    int           `json:"start_line,omitempty"`
+	EndLine      int           `json:"end_line,omitempty"`
+	Version      int           `json:"version,omitempty"`
+	Outdated     bool          `json:"outdated,omitempty"`
+	Resolved     bool          `json:"resolved"`
+	ResolvedByID *uuid.UUID    `json:"resolved_by_id,omitempty"`
+	ResolvedAt   *time.Time    `json:"resolved_at,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Author       *UserResponse `json:"author,omitempty"`
+
+	Replies []CodeCommentResponse `json:"replies,omitempty"`
+}
+
+func (c *CodeComment) ToResponse() CodeCommentResponse {
+	resp := CodeCommentResponse{
+		ID:           c.ID,
+		SnippetID:    c.SnippetID,
+		ParentID:     c.ParentID,
+		AuthorID:     c.AuthorID,
+		Body:         c.Body,
+		StartLine:    c.StartLine,
+		EndLine:      c.EndLine,
+		Version:      c.Version,
+		Outdated:     c.Outdated,
+		Resolved:     c.Resolved,
+		ResolvedByID: c.ResolvedByID,
+		ResolvedAt:   c.ResolvedAt,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+	}
+
+	if c.Author != nil {
+		userResp := c.Author.ToResponse()
+		resp.Author = &userResp
+	}
+
+	return resp
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ExportStatus string
+
+const (
+	ExportStatusPending   ExportStatus = "pending"
+	ExportStatusRunning   ExportStatus = "running"
+	ExportStatusCompleted ExportStatus = "completed"
+	ExportStatusFailed    ExportStatus = "failed"
+	ExportStatusExpired   ExportStatus = "expired"
+)
+
+// ChatExport tracks a background job that archives a chat into a ZIP file.
+type ChatExport struct {
+	ID                uuid.UUID    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ChatID            uuid.UUID    `gorm:"type:uuid;not null;index:idx_chat_exports_chat" json:"chat_id"`
+	RequestedByID     uuid.UUID    `gorm:"type:uuid;not null" json:"requested_by_id"`
+	IncludeMedia      bool         `gorm:"default:true" json:"include_media"`
+	Status            ExportStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	TotalMessages     int64        `gorm:"default:0" json:"total_messages"`
+	ProcessedMessages int64        `gorm:"default:0" json:"processed_messages"`
+	FilePath          *string      `gorm:"type:text" json:"-"`
+	FileSize          *int64       `json:"file_size,omitempty"`
+	Error             *string      `gorm:"type:text" json:"error,omitempty"`
+	ExpiresAt         *time.Time   `gorm:"index:idx_chat_exports_expires" json:"expires_at,omitempty"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+	CompletedAt       *time.Time   `json:"completed_at,omitempty"`
+}
+
+type CreateChatExportRequest struct {
+	IncludeMedia *bool `json:"include_media"`
+}
+
+type ChatExportResponse struct {
+	ID                uuid.UUID    `json:"id"`
+	ChatID            uuid.UUID    `json:"chat_id"`
+	Status            ExportStatus `json:"status"`
+	Progress          int          `json:"progress"`
+	TotalMessages     int64        `json:"total_messages"`
+	ProcessedMessages int64        `json:"processed_messages"`
+	IncludeMedia      bool         `json:"include_media"`
+	FileSize          *int64       `json:"file_size,omitempty"`
+	Error             *string      `json:"error,omitempty"`
+	DownloadURL       *string      `json:"download_url,omitempty"`
+	ExpiresAt         *time.Time   `json:"expires_at,omitempty"`
+	CreatedAt         time.Time    `json:"created_at"`
+	CompletedAt       *time.Time   `json:"completed_at,omitempty"`
+}
+
+func (e *ChatExport) ToResponse() ChatExportResponse {
+	resp := ChatExportResponse{
+		ID:                e.ID,
+		ChatID:            e.ChatID,
+		Status:            e.Status,
+		TotalMessages:     e.TotalMessages,
+		ProcessedMessages: e.ProcessedMessages,
+		IncludeMedia:      e.IncludeMedia,
+		FileSize:          e.FileSize,
+		Error:             e.Error,
+		ExpiresAt:         e.ExpiresAt,
+		CreatedAt:         e.CreatedAt,
+		CompletedAt:       e.CompletedAt,
+	}
+
+	switch {
+	case e.Status == ExportStatusCompleted:
+		resp.Progress = 100
+	case e.TotalMessages > 0:
+		resp.Progress = int(e.ProcessedMessages * 100 / e.TotalMessages)
+	}
+
+	if e.Status == ExportStatusCompleted && e.ExpiresAt != nil && time.Now().Before(*e.ExpiresAt) {
+		url := "/api/v1/chats/" + e.ChatID.String() + "/exports/" + e.ID.String() + "/download"
+		resp.DownloadURL = &url
+	}
+
+	return resp
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const MaxChatFolders = 10
+
+// ChatFolder is a user-defined view over the chat list. Chats are picked by
+// explicit include/exclude lists and by rule filters; explicit lists win over rules.
+type ChatFolder struct {
+	ID              uuid.UUID   `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID          uuid.UUID   `gorm:"type:uuid;not null;index:idx_chat_folders_user" json:"user_id"`
+	Name            string      `gorm:"type:varchar(64);not null" json:"name"`
+	Emoji           *string     `gorm:"type:varchar(16)" json:"emoji,omitempty"`
+	Position        int         `gorm:"default:0" json:"position"`
+	IncludeChatIDs  []uuid.UUID `gorm:"type:jsonb;serializer:json" json:"include_chat_ids"`
+	ExcludeChatIDs  []uuid.UUID `gorm:"type:jsonb;serializer:json" json:"exclude_chat_ids"`
+	ChatTypes       []ChatType  `gorm:"type:jsonb;serializer:json" json:"chat_types"`
+	ContactsOnly    bool        `gorm:"default:false" json:"contacts_only"`
+	UnreadOnly      bool        `gorm:"default:false" json:"unread_only"`
+	ExcludeMuted    bool        `gorm:"default:false" json:"exclude_muted"`
+	IncludeArchived bool        `gorm:"default:false" json:"include_archived"`
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+}
+
+type CreateChatFolderRequest struct {
+	Name            string      `json:"name" validate:"required,min=1,max=64"`
+	Emoji           *string     `json:"emoji" validate:"omitempty,max=16"`
+	IncludeChatIDs  []uuid.UUID `json:"include_chat_ids"`
+	ExcludeChatIDs  []uuid.UUID `json:"exclude_chat_ids"`
+	ChatTypes       []ChatType  `json:"chat_types" validate:"omitempty,dive,oneof=dm group"`
+	ContactsOnly    bool        `json:"contacts_only"`
+	UnreadOnly      bool        `json:"unread_only"`
+	ExcludeMuted    bool        `json:"exclude_muted"`
+	IncludeArchived bool        `json:"include_archived"`
+}
+
+type UpdateChatFolderRequest struct {
+	Name            *string      `json:"name" validate:"omitempty,min=1,max=64"`
+	Emoji           *string      `json:"emoji" validate:"omitempty,max=16"`
+	Position        *int         `json:"position"`
+	IncludeChatIDs  *[]uuid.UUID `json:"include_chat_ids"`
+	ExcludeChatIDs  *[]uuid.UUID `json:"exclude_chat_ids"`
+	ChatTypes       *[]ChatType  `json:"chat_types" validate:"omitempty,dive,oneof=dm group"`
+	ContactsOnly    *bool        `json:"contacts_only"`
+	UnreadOnly      *bool        `json:"unread_only"`
+	ExcludeMuted    *bool        `json:"exclude_muted"`
+	IncludeArchived *bool        `json:"include_archived"`
+}
+
+type ChatFolderResponse struct {
+	ChatFolder
+	UnreadCount int64 `json:"unread_count"`
+	UnreadChats int   `json:"unread_chats"`
+}
+
+// HasRules reports whether the folder selects chats by rule, as opposed to
+// only through its explicit include list.
+func (f *ChatFolder) HasRules() bool {
+	return len(f.ChatTypes) > 0 || f.ContactsOnly || f.UnreadOnly || f.ExcludeMuted
+}
+
+// Matches reports whether chat belongs to the folder. withContact tells whether
+// the chat is a DM with one of the user's contacts.
+func (f *ChatFolder) Matches(chat *ChatWithLastMessageResponse, withContact bool) bool {
+	if containsUUID(f.ExcludeChatIDs, chat.ID) {
+		return false
+	}
+	if containsUUID(f.IncludeChatIDs, chat.ID) {
+		return true
+	}
+	if chat.IsArchived && !f.IncludeArchived {
+		return false
+	}
+	if !f.HasRules() {
+		return false
+	}
+
+	if len(f.ChatTypes) > 0 {
+		typeMatches := false
+		for _, t := range f.ChatTypes {
+			if t == chat.Type {
+				typeMatches = true
+				break
+			}
+		}
+		if !typeMatches {
+			return false
+		}
+	}
+	if f.ContactsOnly && !withContact {
+		return false
+	}
+	if f.UnreadOnly && chat.UnreadCount == 0 && !chat.MarkedUnread {
+		return false
+	}
+	if f.ExcludeMuted && chat.IsMuted {
+		return false
+	}
+
+	return true
+}
+
+func containsUUID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
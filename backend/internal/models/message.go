@@ -26,6 +26,9 @@ type Message struct {
     MediaURL    *string      `gorm:"type:text" json:"media_url,omitempty"`
     MediaSize   *int64       `json:"media_size,omitempty"`
     ReplyToID   *uuid.UUID   `gorm:"type:uuid" json:"reply_to_id,omitempty"`
+    TopicID     *uuid.UUID   `gorm:"type:uuid;index:idx_messages_topic" json:"topic_id,omitempty"`
+    ThreadRootID  *uuid.UUID `gorm:"type:uuid;index:idx_messages_thread_root" json:"thread_root_id,omitempty"`
+    ChannelPostID *uuid.UUID `gorm:"type:uuid" json:"channel_post_id,omitempty"`
     IsEdited    bool         `gorm:"default:false" json:"is_edited"`
     IsDeleted   bool         `gorm:"default:false" json:"is_deleted"`
     CreatedAt   time.Time    `gorm:"index:idx_messages_chat_created" json:"created_at"`
@@ -41,6 +44,7 @@ type SendMessageRequest struct {
     Content     string      `json:"content" validate:"required"`
     MessageType MessageType `json:"message_type" validate:"omitempty,oneof=text image video audio file"`
     ReplyToID   *string     `json:"reply_to_id" validate:"omitempty,uuid"`
+    TopicID     *string     `json:"topic_id" validate:"omitempty,uuid"`
 }
 
 type MessageResponse struct {
@@ -51,9 +55,15 @@ type MessageResponse struct {
     MessageType MessageType  `json:"message_type"`
     MediaURL    *string      `json:"media_url,omitempty"`
     ReplyToID   *uuid.UUID   `json:"reply_to_id,omitempty"`
+    TopicID     *uuid.UUID   `json:"topic_id,omitempty"`
+    ThreadRootID  *uuid.UUID `json:"thread_root_id,omitempty"`
+    ChannelPostID *uuid.UUID `json:"channel_post_id,omitempty"`
     IsEdited    bool         `json:"is_edited"`
     CreatedAt   time.Time    `json:"created_at"`
     Sender      *UserResponse `json:"sender,omitempty"`
+    // CodeBlocks is only set in the sender's response to sending or
+    // editing a text message, offering its code blocks for promotion.
+    CodeBlocks  []CodeBlock   `json:"code_blocks,omitempty"`
 }
 
 func (m *Message) ToResponse() MessageResponse {
@@ -65,6 +75,9 @@ func (m *Message) ToResponse() MessageResponse {
         MessageType: m.MessageType,
         MediaURL:    m.MediaURL,
         ReplyToID:   m.ReplyToID,
+        TopicID:     m.TopicID,
+        ThreadRootID:  m.ThreadRootID,
+        ChannelPostID: m.ChannelPostID,
         IsEdited:    m.IsEdited,
         CreatedAt:   m.CreatedAt,
     }
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+type EntityType string
+
+const (
+	EntityTypeBold          EntityType = "bold"
+	EntityTypeItalic        EntityType = "italic"
+	EntityTypeUnderline     EntityType = "underline"
+	EntityTypeStrikethrough EntityType = "strikethrough"
+	EntityTypeCode          EntityType = "code"
+	EntityTypePre           EntityType = "pre"
+	EntityTypeLink          EntityType = "link"
+	EntityTypeMention       EntityType = "mention"
+	EntityTypeHashtag       EntityType = "hashtag"
+)
+
+// MessageEntity marks up a span of post text. Offset and Length count runes.
+type MessageEntity struct {
+	Type     EntityType `json:"type"`
+	Offset   int        `json:"offset"`
+	Length   int        `json:"length"`
+	URL      *string    `json:"url,omitempty"`
+	Language *string    `json:"language,omitempty"`
+}
+
+// ChannelPost is a piece of content published to a channel's subscribers.
+// When the channel has a discussion group, DiscussionMessageID is the post's
+// thread root there and the messages in that thread are its comments.
+type ChannelPost struct {
+	ID                  uuid.UUID       `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ChannelID           uuid.UUID       `gorm:"type:uuid;not null;index:idx_channel_posts_channel_created" json:"channel_id"`
+	AuthorID            uuid.UUID       `gorm:"type:uuid;not null" json:"author_id"`
+	Content             string          `gorm:"type:text;not null" json:"content"`
+	PostType            MessageType     `gorm:"type:varchar(20);default:'text'" json:"post_type"`
+	MediaURL            *string         `gorm:"type:text" json:"media_url,omitempty"`
+	MediaSize           *int64          `json:"media_size,omitempty"`
+	CodeLanguage        *CodeLanguage   `gorm:"type:varchar(50)" json:"code_language,omitempty"`
+	Entities            []MessageEntity `gorm:"type:jsonb;serializer:json" json:"entities,omitempty"`
+	Signature           *string         `gorm:"type:varchar(255)" json:"signature,omitempty"`
+	Views               int64           `gorm:"default:0" json:"views"`
+	DiscussionMessageID *uuid.UUID      `gorm:"type:uuid" json:"discussion_message_id,omitempty"`
+	CommentCount        int64           `gorm:"-" json:"comment_count"`
+	IsEdited            bool            `gorm:"default:false" json:"is_edited"`
+	IsDeleted           bool            `gorm:"default:false" json:"is_deleted"`
+	CreatedAt           time.Time       `gorm:"index:idx_channel_posts_channel_created" json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+
+	Author  *User    `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	Channel *Channel `gorm:"foreignKey:ChannelID" json:"channel,omitempty"`
+}
+
+// ChannelPostView records the first time a user saw a post. Views are
+// collected in Redis and flushed here in batches.
+type ChannelPostView struct {
+	PostID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"post_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	ChannelID uuid.UUID `gorm:"type:uuid;not null;index:idx_channel_post_views_channel" json:"channel_id"`
+	ViewedAt  time.Time `gorm:"not null;index:idx_channel_post_views_channel" json:"viewed_at"`
+}
+
+type RecordPostViewsRequest struct {
+	PostIDs []string `json:"post_ids" validate:"required,min=1,max=100,dive,uuid"`
+}
+
+type CreateCommentRequest struct {
+	Content   string  `json:"content" validate:"required"`
+	ReplyToID *string `json:"reply_to_id" validate:"omitempty,uuid"`
+}
+
+type CreateChannelPostRequest struct {
+	Content      string          `json:"content" validate:"required"`
+	PostType     MessageType     `json:"post_type" validate:"omitempty,oneof=text code"`
+	CodeLanguage *CodeLanguage   `json:"code_language"`
+	Entities     []MessageEntity `json:"entities"`
+}
+
+type UpdateChannelPostRequest struct {
+	Content  *string          `json:"content" validate:"omitempty,min=1"`
+	Entities *[]MessageEntity `json:"entities"`
+}
+
+type ChannelPostResponse struct {
+	ID                  uuid.UUID        `json:"id"`
+	ChannelID           uuid.UUID        `json:"channel_id"`
+	AuthorID            uuid.UUID        `json:"author_id"`
+	Content             string           `json:"content"`
+	PostType            MessageType      `json:"post_type"`
+	MediaURL            *string          `json:"media_url,omitempty"`
+	MediaSize           *int64           `json:"media_size,omitempty"`
+	CodeLanguage        *CodeLanguage    `json:"code_language,omitempty"`
+	Entities            []MessageEntity  `json:"entities,omitempty"`
+	Signature           *string          `json:"signature,omitempty"`
+	Views               int64            `json:"views"`
+	DiscussionMessageID *uuid.UUID       `json:"discussion_message_id,omitempty"`
+	CommentCount        int64            `json:"comment_count"`
+	IsEdited            bool             `json:"is_edited"`
+	CreatedAt           time.Time        `json:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at"`
+	Author              *UserResponse    `json:"author,omitempty"`
+	Channel             *ChannelResponse `json:"channel,omitempty"`
+}
+
+func (p *ChannelPost) ToResponse() ChannelPostResponse {
+	resp := ChannelPostResponse{
+		ID:                  p.ID,
+		ChannelID:           p.ChannelID,
+		AuthorID:            p.AuthorID,
+		Content:             p.Content,
+		PostType:            p.PostType,
+		MediaURL:            p.MediaURL,
+		MediaSize:           p.MediaSize,
+		CodeLanguage:        p.CodeLanguage,
+		Entities:            p.Entities,
+		Signature:           p.Signature,
+		Views:               p.Views,
+		DiscussionMessageID: p.DiscussionMessageID,
+		CommentCount:        p.CommentCount,
+		IsEdited:            p.IsEdited,
+		CreatedAt:           p.CreatedAt,
+		UpdatedAt:           p.UpdatedAt,
+	}
+
+	if p.Author != nil {
+		authorResp := p.Author.ToResponse()
+		resp.Author = &authorResp
+	}
+
+	if p.Channel != nil {
+		channelResp := p.Channel.ToResponse()
+		resp.Channel = &channelResp
+	}
+
+	return resp
+}
+
+var ErrInvalidEntity = errors.New("entity is out of range or has an unknown type")
+
+// ValidateEntities checks that every entity has a known type and lies
+// within content.
+func ValidateEntities(content string, entities []MessageEntity) error {
+	length := utf8.RuneCountInString(content)
+	for _, e := range entities {
+		switch e.Type {
+		case EntityTypeBold, EntityTypeItalic, EntityTypeUnderline, EntityTypeStrikethrough,
+			EntityTypeCode, EntityTypePre, EntityTypeLink, EntityTypeMention, EntityTypeHashtag:
+		default:
+			return ErrInvalidEntity
+		}
+		if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > length {
+			return ErrInvalidEntity
+		}
+		if e.Type == EntityTypeLink && (e.URL == nil || *e.URL == "") {
+			return ErrInvalidEntity
+		}
+	}
+	return nil
+}
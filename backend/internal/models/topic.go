@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const GeneralTopicName = "General"
+
+// ChatTopic is a named thread inside a group chat running in forum mode.
+// Every forum has exactly one general topic, which is pinned to the top of
+// the list and cannot be deleted.
+type ChatTopic struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ChatID        uuid.UUID  `gorm:"type:uuid;not null;index:idx_chat_topics_chat" json:"chat_id"`
+	Name          string     `gorm:"type:varchar(128);not null" json:"name"`
+	IconEmoji     *string    `gorm:"type:varchar(16)" json:"icon_emoji,omitempty"`
+	IsGeneral     bool       `gorm:"default:false" json:"is_general"`
+	IsClosed      bool       `gorm:"default:false" json:"is_closed"`
+	CreatedByID   *uuid.UUID `gorm:"type:uuid" json:"created_by_id,omitempty"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ChatTopicRead stores how far a member has read a single topic. Without a
+// row the member's chat-wide LastReadAt applies.
+type ChatTopicRead struct {
+	TopicID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"topic_id"`
+	UserID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	LastReadAt time.Time `json:"last_read_at"`
+}
+
+type UpdateForumModeRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type CreateChatTopicRequest struct {
+	Name      string  `json:"name" validate:"required,min=1,max=128"`
+	IconEmoji *string `json:"icon_emoji" validate:"omitempty,max=16"`
+}
+
+type UpdateChatTopicRequest struct {
+	Name      *string `json:"name" validate:"omitempty,min=1,max=128"`
+	IconEmoji *string `json:"icon_emoji" validate:"omitempty,max=16"`
+	IsClosed  *bool   `json:"is_closed"`
+}
+
+type ChatTopicResponse struct {
+	ChatTopic
+	UnreadCount int64 `json:"unread_count"`
+}
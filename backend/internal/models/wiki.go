@@ -1,11 +1,17 @@
 package models
 
 import (
+    "net/url"
+    "strings"
     "time"
 
     "github.com/google/uuid"
+    "github.com/messenger/backend/pkg/diff"
 )
 
+// WikiPage is a page of a channel's wiki. Drafts (IsPublished false) are
+// only visible to editors, and protected pages may only be changed by wiki
+// admins.
 type WikiPage struct {
     ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
     ChannelID   uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_wiki_channel_slug" json:"channel_id"`
@@ -15,6 +21,7 @@ type WikiPage struct {
     CreatedByID uuid.UUID  `gorm:"type:uuid;not null" json:"created_by_id"`
     ParentID    *uuid.UUID `gorm:"type:uuid" json:"parent_id,omitempty"`
     IsPublished bool       `gorm:"default:true" json:"is_published"`
+    IsProtected bool       `gorm:"default:false" json:"is_protected"`
     Order       int        `gorm:"default:0" json:"order"`
     CreatedAt   time.Time  `json:"created_at"`
     UpdatedAt   time.Time  `json:"updated_at"`
@@ -39,6 +46,123 @@ type WikiRevision struct {
     CreatedBy *User `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
 }
 
+// WikiLink is an edge of a channel's wiki link graph. Links point at slugs
+// rather than pages, so a link to a page that does not exist yet is kept and
+// starts resolving once the page is created.
+type WikiLink struct {
+    FromPageID uuid.UUID `gorm:"type:uuid;primaryKey" json:"from_page_id"`
+    ToSlug     string    `gorm:"type:varchar(255);primaryKey;index:idx_wiki_links_target,priority:2" json:"to_slug"`
+    ChannelID  uuid.UUID `gorm:"type:uuid;not null;index:idx_wiki_links_target,priority:1" json:"channel_id"`
+}
+
+// WikiRedirect sends a slug a page used to have to that page. It points at
+// the page rather than its new slug, so it survives later renames.
+type WikiRedirect struct {
+    ChannelID uuid.UUID `gorm:"type:uuid;primaryKey" json:"channel_id"`
+    Slug      string    `gorm:"type:varchar(255);primaryKey" json:"slug"`
+    PageID    uuid.UUID `gorm:"type:uuid;not null;index" json:"page_id"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// WikiWatch subscribes a user to changes of one page, or of the whole wiki
+// when PageID is nil.
+type WikiWatch struct {
+    ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+    UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+    ChannelID uuid.UUID  `gorm:"type:uuid;not null;index" json:"channel_id"`
+    PageID    *uuid.UUID `gorm:"type:uuid;index" json:"page_id,omitempty"`
+    CreatedAt time.Time  `json:"created_at"`
+}
+
+// WikiTemplate is a starting point for new pages of a channel's wiki. Its
+// title and content may contain placeholders such as {{date}} and
+// {{author}}, filled in when a page is created from it.
+type WikiTemplate struct {
+    ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+    ChannelID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_wiki_template_name" json:"channel_id"`
+    Name        string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_wiki_template_name" json:"name"`
+    Description string    `gorm:"type:text" json:"description"`
+    Title       string    `gorm:"type:varchar(255)" json:"title"`
+    Content     string    `gorm:"type:text;not null" json:"content"`
+    CreatedByID uuid.UUID `gorm:"type:uuid;not null" json:"created_by_id"`
+    CreatedAt   time.Time `json:"created_at"`
+    UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WikiAttachment is a file attached to a wiki page, referenced from its
+// Markdown as attachment:<file name>. It lives as long as the page, so
+// older revisions that use it keep rendering.
+type WikiAttachment struct {
+    ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+    PageID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_wiki_attachment_name" json:"page_id"`
+    FileName     string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_wiki_attachment_name" json:"file_name"`
+    FilePath     string    `gorm:"type:text;not null" json:"file_path"`
+    FileSize     int64     `gorm:"not null" json:"file_size"`
+    MimeType     string    `gorm:"type:varchar(100);not null" json:"mime_type"`
+    UploadedByID uuid.UUID `gorm:"type:uuid;not null" json:"uploaded_by_id"`
+    CreatedAt    time.Time `json:"created_at"`
+}
+
+// Permissions temporary roles on a channel can grant over its wiki. A role
+// with the "admin" permission holds all of them and is a wiki admin.
+const (
+    WikiPermissionView    = "wiki.view"
+    WikiPermissionEdit    = "wiki.edit"
+    WikiPermissionCreate  = "wiki.create"
+    WikiPermissionDelete  = "wiki.delete"
+    WikiPermissionPublish = "wiki.publish"
+)
+
+// WikiPermissions is what a user may do in a channel's wiki. Admins may
+// also change protected pages and protect or unprotect pages.
+type WikiPermissions struct {
+    View    bool `json:"view"`
+    Edit    bool `json:"edit"`
+    Create  bool `json:"create"`
+    Delete  bool `json:"delete"`
+    Publish bool `json:"publish"`
+    Admin   bool `json:"admin"`
+}
+
+func FullWikiPermissions() WikiPermissions {
+    return WikiPermissions{View: true, Edit: true, Create: true, Delete: true, Publish: true, Admin: true}
+}
+
+// Grant adds the wiki permissions of a temporary role. Any wiki permission
+// includes viewing the wiki.
+func (p *WikiPermissions) Grant(permissions []string) {
+    for _, perm := range permissions {
+        switch perm {
+        case "admin":
+            *p = FullWikiPermissions()
+            return
+        case WikiPermissionView:
+            p.View = true
+        case WikiPermissionEdit:
+            p.View, p.Edit = true, true
+        case WikiPermissionCreate:
+            p.View, p.Create = true, true
+        case WikiPermissionDelete:
+            p.View, p.Delete = true, true
+        case WikiPermissionPublish:
+            p.View, p.Publish = true, true
+        }
+    }
+}
+
+// CanViewPage reports whether the page is visible: drafts only to editors.
+func (p WikiPermissions) CanViewPage(page *WikiPage) bool {
+    return p.View && (page.IsPublished || p.Edit)
+}
+
+func (p WikiPermissions) CanEditPage(page *WikiPage) bool {
+    return p.Edit && (!page.IsProtected || p.Admin)
+}
+
+func (p WikiPermissions) CanDeletePage(page *WikiPage) bool {
+    return p.Delete && (!page.IsProtected || p.Admin)
+}
+
 type CreateWikiPageRequest struct {
     ChannelID   string     `json:"channel_id" validate:"required,uuid"`
     Slug        string     `json:"slug" validate:"required,min=1,max=255,alphanum"`
@@ -46,16 +170,20 @@ type CreateWikiPageRequest struct {
     Content     string     `json:"content" validate:"required"`
     ParentID    *string    `json:"parent_id" validate:"omitempty,uuid"`
     IsPublished *bool      `json:"is_published"`
+    IsProtected *bool      `json:"is_protected"`
     Order       *int       `json:"order"`
+    TemplateID  *string    `json:"template_id" validate:"omitempty,uuid"`
 }
 
 type UpdateWikiPageRequest struct {
     Title         *string `json:"title" validate:"omitempty,min=1,max=255"`
     Content       *string `json:"content" validate:"omitempty,min=1"`
     IsPublished   *bool   `json:"is_published"`
+    IsProtected   *bool   `json:"is_protected"`
     Order         *int    `json:"order"`
     ParentID      *string `json:"parent_id" validate:"omitempty,uuid"`
     ChangeSummary *string `json:"change_summary"`
+    BaseRevision  *int    `json:"base_revision" validate:"omitempty,min=1"`
 }
 
 type WikiPageResponse struct {
@@ -67,11 +195,16 @@ type WikiPageResponse struct {
     CreatedByID uuid.UUID        `json:"created_by_id"`
     ParentID    *uuid.UUID       `json:"parent_id,omitempty"`
     IsPublished bool             `json:"is_published"`
+    IsProtected bool             `json:"is_protected"`
     Order       int              `json:"order"`
     CreatedAt   time.Time        `json:"created_at"`
     UpdatedAt   time.Time        `json:"updated_at"`
     CreatedBy   *UserResponse    `json:"created_by,omitempty"`
     Children    []WikiPageResponse `json:"children,omitempty"`
+    HTML        string           `json:"html,omitempty"`
+    BrokenLinks []string         `json:"broken_links,omitempty"`
+    Revision    int              `json:"revision,omitempty"`
+    Lock        *WikiEditLock    `json:"lock,omitempty"`
 }
 
 func (w *WikiPage) ToResponse() WikiPageResponse {
@@ -84,6 +217,7 @@ func (w *WikiPage) ToResponse() WikiPageResponse {
         CreatedByID: w.CreatedByID,
         ParentID:    w.ParentID,
         IsPublished: w.IsPublished,
+        IsProtected: w.IsProtected,
         Order:       w.Order,
         CreatedAt:   w.CreatedAt,
         UpdatedAt:   w.UpdatedAt,
@@ -103,3 +237,208 @@ func (w *WikiPage) ToResponse() WikiPageResponse {
 
     return resp
 }
+
+type RevertWikiPageRequest struct {
+    ChangeSummary *string `json:"change_summary"`
+    BaseRevision  *int    `json:"base_revision" validate:"omitempty,min=1"`
+}
+
+// WikiRevisionRef identifies one side of a wiki diff.
+type WikiRevisionRef struct {
+    RevisionNumber int       `json:"revision_number"`
+    Title          string    `json:"title"`
+    CreatedByID    uuid.UUID `json:"created_by_id"`
+    ChangeSummary  string    `json:"change_summary"`
+    CreatedAt      time.Time `json:"created_at"`
+}
+
+func (r *WikiRevision) ToRef() WikiRevisionRef {
+    return WikiRevisionRef{
+        RevisionNumber: r.RevisionNumber,
+        Title:          r.Title,
+        CreatedByID:    r.CreatedByID,
+        ChangeSummary:  r.ChangeSummary,
+        CreatedAt:      r.CreatedAt,
+    }
+}
+
+type WikiDiffResponse struct {
+    PageID       uuid.UUID       `json:"page_id"`
+    Slug         string          `json:"slug"`
+    From         WikiRevisionRef `json:"from"`
+    To           WikiRevisionRef `json:"to"`
+    TitleChanged bool            `json:"title_changed"`
+    Additions    int             `json:"additions"`
+    Deletions    int             `json:"deletions"`
+    Hunks        []diff.Hunk     `json:"hunks"`
+    Unified      string          `json:"unified"`
+}
+
+type RenameWikiPageRequest struct {
+    Slug         string `json:"slug" validate:"required,min=1,max=255"`
+    RewriteLinks bool   `json:"rewrite_links"`
+}
+
+// MoveWikiPageRequest moves a page to another parent, slug or position.
+// Omitted fields are left alone; an empty parent_id moves the page to the
+// top level.
+type MoveWikiPageRequest struct {
+    ParentID      *string `json:"parent_id" validate:"omitempty,uuid"`
+    Slug          *string `json:"slug" validate:"omitempty,min=1,max=255"`
+    Order         *int    `json:"order"`
+    RewriteLinks  bool    `json:"rewrite_links"`
+    ChangeSummary *string `json:"change_summary"`
+}
+
+// ReorderWikiPagesRequest lists sibling pages in their new order. Siblings
+// left out keep their relative order after the listed ones.
+type ReorderWikiPagesRequest struct {
+    ParentID *string  `json:"parent_id" validate:"omitempty,uuid"`
+    PageIDs  []string `json:"page_ids" validate:"required,min=1"`
+}
+
+// WikiPageSummary is a page without its content, for listings.
+type WikiPageSummary struct {
+    ID          uuid.UUID `json:"id"`
+    Slug        string    `json:"slug"`
+    Title       string    `json:"title"`
+    IsPublished bool      `json:"is_published"`
+    UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (w *WikiPage) ToSummary() WikiPageSummary {
+    return WikiPageSummary{
+        ID:          w.ID,
+        Slug:        w.Slug,
+        Title:       w.Title,
+        IsPublished: w.IsPublished,
+        UpdatedAt:   w.UpdatedAt,
+    }
+}
+
+// RenameWikiPageResponse lists the pages whose links were rewritten, those
+// skipped because the caller may not edit them, and those that still link
+// to the old slug.
+type RenameWikiPageResponse struct {
+    Page         WikiPageResponse  `json:"page"`
+    InboundLinks []WikiPageSummary `json:"inbound_links"`
+    Rewritten    []WikiPageSummary `json:"rewritten"`
+    Skipped      []WikiPageSummary `json:"skipped"`
+}
+
+// WikiEditLock is an advisory lock an editor takes while working on a page.
+// It does not block saves; it tells others someone is editing.
+type WikiEditLock struct {
+    UserID    uuid.UUID `json:"user_id"`
+    ExpiresAt time.Time `json:"expires_at"`
+}
+
+// WikiConflictResponse is returned when an update was based on an outdated
+// revision. Merge holds the edit merged with the current content, if the
+// edit changed the content.
+type WikiConflictResponse struct {
+    Error           string            `json:"error"`
+    BaseRevision    int               `json:"base_revision"`
+    CurrentRevision int               `json:"current_revision"`
+    Current         WikiPageResponse  `json:"current"`
+    Merge           *diff.MergeResult `json:"merge,omitempty"`
+}
+
+type WikiImportResponse struct {
+    Created   int               `json:"created"`
+    Updated   int               `json:"updated"`
+    Unchanged int               `json:"unchanged"`
+    Pages     []WikiPageSummary `json:"pages"`
+}
+
+// WikiSearchResult is a page found by a wiki search. TitleHighlight and
+// Snippet are HTML-escaped, with the matched words wrapped in <mark>.
+type WikiSearchResult struct {
+    PageID         uuid.UUID `json:"page_id"`
+    ChannelID      uuid.UUID `json:"channel_id"`
+    Slug           string    `json:"slug"`
+    Title          string    `json:"title"`
+    TitleHighlight string    `json:"title_highlight"`
+    Snippet        string    `json:"snippet"`
+    IsPublished    bool      `json:"is_published"`
+    Rank           float64   `json:"rank"`
+    UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// WikiWatchesResponse is what the user watches in one channel's wiki.
+type WikiWatchesResponse struct {
+    Wiki  bool              `json:"wiki"`
+    Pages []WikiPageSummary `json:"pages"`
+}
+
+type WikiChangeAction string
+
+const (
+    WikiChangeCreated WikiChangeAction = "created"
+    WikiChangeUpdated WikiChangeAction = "updated"
+    WikiChangeMoved   WikiChangeAction = "moved"
+    WikiChangeDeleted WikiChangeAction = "deleted"
+)
+
+// WikiChange is one change to a wiki page, as sent to watchers in a
+// wiki_changed event and collected for the channel's digest.
+type WikiChange struct {
+    ChannelID uuid.UUID        `json:"channel_id"`
+    PageID    uuid.UUID        `json:"page_id"`
+    Slug      string           `json:"slug"`
+    Title     string           `json:"title"`
+    Action    WikiChangeAction `json:"action"`
+    Revision  int              `json:"revision,omitempty"`
+    Summary   string           `json:"summary,omitempty"`
+    UserID    uuid.UUID        `json:"user_id"`
+    DiffURL   string           `json:"diff_url,omitempty"`
+    ChangedAt time.Time        `json:"changed_at"`
+}
+
+type CreateWikiTemplateRequest struct {
+    Name        string `json:"name" validate:"required,min=1,max=100"`
+    Description string `json:"description"`
+    Title       string `json:"title" validate:"max=255"`
+    Content     string `json:"content" validate:"required"`
+}
+
+type UpdateWikiTemplateRequest struct {
+    Name        *string `json:"name" validate:"omitempty,min=1,max=100"`
+    Description *string `json:"description"`
+    Title       *string `json:"title" validate:"omitempty,max=255"`
+    Content     *string `json:"content" validate:"omitempty,min=1"`
+}
+
+// WikiAttachmentResponse adds the Markdown that embeds or links the file.
+type WikiAttachmentResponse struct {
+    ID           uuid.UUID `json:"id"`
+    PageID       uuid.UUID `json:"page_id"`
+    FileName     string    `json:"file_name"`
+    URL          string    `json:"url"`
+    FileSize     int64     `json:"file_size"`
+    MimeType     string    `json:"mime_type"`
+    UploadedByID uuid.UUID `json:"uploaded_by_id"`
+    CreatedAt    time.Time `json:"created_at"`
+    Markdown     string    `json:"markdown"`
+}
+
+func (a *WikiAttachment) ToResponse() WikiAttachmentResponse {
+    ref := "attachment:" + url.PathEscape(a.FileName)
+    label := strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`).Replace(a.FileName)
+    markdown := "[" + label + "](" + ref + ")"
+    if strings.HasPrefix(a.MimeType, "image/") {
+        markdown = "!" + markdown
+    }
+
+    return WikiAttachmentResponse{
+        ID:           a.ID,
+        PageID:       a.PageID,
+        FileName:     a.FileName,
+        URL:          a.FilePath,
+        FileSize:     a.FileSize,
+        MimeType:     a.MimeType,
+        UploadedByID: a.UploadedByID,
+        CreatedAt:    a.CreatedAt,
+        Markdown:     markdown,
+    }
+}
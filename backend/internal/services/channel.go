@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAlreadySubscribed = errors.New("already subscribed")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+)
+
+type ChannelService struct {
+	db    *gorm.DB
+	redis *redis.Client
+}
+
+func NewChannelService(db *gorm.DB, redis *redis.Client) *ChannelService {
+	return &ChannelService{db: db, redis: redis}
+}
+
+// CreateChannel inserts the channel and subscribes its owner to it, logging
+// the subscription like any other so statistics count the owner too. A
+// handle another channel claimed first fails with ErrHandleTaken.
+func (s *ChannelService) CreateChannel(ctx context.Context, channel *models.Channel) error {
+	channel.SubscriberCount = 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(channel).Error; err != nil {
+			if channel.Handle != nil && isUniqueViolation(tx, err) {
+				return ErrHandleTaken
+			}
+			return err
+		}
+		return s.subscribe(tx, channel.ID, channel.OwnerID)
+	})
+	if err != nil {
+		return err
+	}
+	channel.SubscriberCount = 1
+	return nil
+}
+
+// Subscribe adds the user to the channel and bumps SubscriberCount in the
+// same transaction.
+func (s *ChannelService) Subscribe(ctx context.Context, channelID, userID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.subscribe(tx, channelID, userID)
+	})
+}
+
+func (s *ChannelService) subscribe(tx *gorm.DB, channelID, userID uuid.UUID) error {
+	var existing int64
+	if err := tx.Model(&models.ChannelSubscriber{}).
+		Where("channel_id = ? AND user_id = ?", channelID, userID).
+		Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return ErrAlreadySubscribed
+	}
+
+	if err := tx.Create(&models.ChannelSubscriber{
+		ChannelID: channelID,
+		UserID:    userID,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Create(&models.ChannelSubscriptionEvent{
+		ChannelID: channelID,
+		UserID:    userID,
+		Joined:    true,
+	}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Channel{}).
+		Where("id = ?", channelID).
+		Update("subscriber_count", gorm.Expr("subscriber_count + 1")).Error
+}
+
+// Unsubscribe removes the user from the channel along with any admin rights
+// they held there. It reports whether the user was subscribed.
+func (s *ChannelService) Unsubscribe(ctx context.Context, channelID, userID uuid.UUID) (bool, error) {
+	removed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("channel_id = ? AND user_id = ?", channelID, userID).
+			Delete(&models.ChannelSubscriber{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		removed = true
+
+		if err := tx.Where("channel_id = ? AND user_id = ?", channelID, userID).
+			Delete(&models.ChannelAdmin{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.ChannelSubscriptionEvent{
+			ChannelID: channelID,
+			UserID:    userID,
+			Joined:    false,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Channel{}).
+			Where("id = ? AND subscriber_count > 0", channelID).
+			Update("subscriber_count", gorm.Expr("subscriber_count - 1")).Error
+	})
+	return removed, err
+}
+
+// IsSubscribed reports whether the user follows the channel.
+func (s *ChannelService) IsSubscribed(channelID, userID uuid.UUID) bool {
+	var count int64
+	s.db.Model(&models.ChannelSubscriber{}).
+		Where("channel_id = ? AND user_id = ?", channelID, userID).
+		Count(&count)
+	return count > 0
+}
+
+// CanRead reports whether the user may see the channel's content. Private
+// channels are readable only by the owner and subscribers.
+func (s *ChannelService) CanRead(channel *models.Channel, userID uuid.UUID) bool {
+	if channel.IsPublic || channel.OwnerID == userID {
+		return true
+	}
+	return s.IsSubscribed(channel.ID, userID)
+}
+
+// CanManage reports whether the user may run the channel: see its
+// statistics and list its admins. The owner and every admin qualify.
+func (s *ChannelService) CanManage(channel *models.Channel, userID uuid.UUID) bool {
+	_, isAdmin := s.AdminRights(channel, userID)
+	return isAdmin
+}
+
+// CanPublish reports whether the user may publish posts to the channel.
+func (s *ChannelService) CanPublish(channel *models.Channel, userID uuid.UUID) bool {
+	rights, _ := s.AdminRights(channel, userID)
+	return rights.CanPost
+}
+
+// PostPage is one page of posts, newest first.
+type PostPage struct {
+	Posts      []models.ChannelPost
+	NextCursor string
+}
+
+// ListChannelPosts pages through a channel's posts, newest first.
+func (s *ChannelService) ListChannelPosts(ctx context.Context, channelID uuid.UUID, cursor string, limit int) (*PostPage, error) {
+	return s.listPosts(s.db.Where("channel_posts.channel_id = ?", channelID), cursor, limit, false)
+}
+
+// ListFeed merges the posts of every channel the user is subscribed to.
+func (s *ChannelService) ListFeed(ctx context.Context, userID uuid.UUID, cursor string, limit int) (*PostPage, error) {
+	subscribed := s.db.Model(&models.ChannelSubscriber{}).Select("channel_id").Where("user_id = ?", userID)
+	return s.listPosts(s.db.Where("channel_posts.channel_id IN (?)", subscribed), cursor, limit, true)
+}
+
+func (s *ChannelService) listPosts(filter *gorm.DB, cursor string, limit int, withChannel bool) (*PostPage, error) {
+	query := s.db.Model(&models.ChannelPost{}).
+		Where(filter).
+		Where("channel_posts.is_deleted = ?", false).
+		Preload("Author").
+		Order("channel_posts.created_at DESC, channel_posts.id DESC").
+		Limit(limit + 1)
+
+	if withChannel {
+		query = query.Preload("Channel")
+	}
+
+	if cursor != "" {
+		createdAt, id, err := DecodePostCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(channel_posts.created_at < ?) OR (channel_posts.created_at = ? AND channel_posts.id < ?)",
+			createdAt, createdAt, id)
+	}
+
+	var posts []models.ChannelPost
+	if err := query.Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.fillCommentCounts(posts); err != nil {
+		return nil, err
+	}
+
+	page := &PostPage{Posts: posts}
+	if len(posts) > limit {
+		page.Posts = posts[:limit]
+		last := page.Posts[limit-1]
+		page.NextCursor = EncodePostCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// EncodePostCursor builds the opaque cursor that resumes listing after the
+// given post.
+func EncodePostCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodePostCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	return createdAt, id, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotSubscribed = errors.New("user is not subscribed to the channel")
+
+// AdminRights returns what the user may do in the channel and whether they
+// are an admin at all. The owner holds every right.
+func (s *ChannelService) AdminRights(channel *models.Channel, userID uuid.UUID) (models.ChannelAdminRights, bool) {
+	if channel.OwnerID == userID {
+		return models.FullChannelAdminRights(), true
+	}
+
+	var admin models.ChannelAdmin
+	if err := s.db.Where("channel_id = ? AND user_id = ?", channel.ID, userID).First(&admin).Error; err != nil {
+		return models.ChannelAdminRights{}, false
+	}
+	return admin.Rights, true
+}
+
+// CanEditPost reports whether the user may edit the post: authors may edit
+// their own posts while they can still publish, admins with CanEditPosts
+// anyone's.
+func (s *ChannelService) CanEditPost(channel *models.Channel, post *models.ChannelPost, userID uuid.UUID) bool {
+	rights, _ := s.AdminRights(channel, userID)
+	if post.AuthorID == userID && rights.CanPost {
+		return true
+	}
+	return rights.CanEditPosts
+}
+
+// CanDeletePost reports whether the user may delete the post: authors may
+// delete their own posts while they can still publish, admins with
+// CanDeletePosts anyone's.
+func (s *ChannelService) CanDeletePost(channel *models.Channel, post *models.ChannelPost, userID uuid.UUID) bool {
+	rights, _ := s.AdminRights(channel, userID)
+	if post.AuthorID == userID && rights.CanPost {
+		return true
+	}
+	return rights.CanDeletePosts
+}
+
+// CanManageWiki reports whether the user may create, edit and delete the
+// channel's wiki pages.
+func (s *ChannelService) CanManageWiki(channel *models.Channel, userID uuid.UUID) bool {
+	rights, _ := s.AdminRights(channel, userID)
+	return rights.CanManageWiki
+}
+
+// CanManageRSS reports whether the user may add, change and refresh the
+// channel's RSS feed.
+func (s *ChannelService) CanManageRSS(channel *models.Channel, userID uuid.UUID) bool {
+	rights, _ := s.AdminRights(channel, userID)
+	return rights.CanManageRSS
+}
+
+// PostSignature returns the signature to stamp on a new post, or nil when
+// the channel does not sign its posts.
+func (s *ChannelService) PostSignature(channel *models.Channel, userID uuid.UUID) *string {
+	if !channel.SignPosts {
+		return nil
+	}
+
+	var user models.User
+	if err := s.db.Select("id", "username").First(&user, userID).Error; err != nil || user.Username == nil {
+		return nil
+	}
+	signature := *user.Username
+	return &signature
+}
+
+// ListAdmins returns the owner followed by the channel's admins.
+func (s *ChannelService) ListAdmins(ctx context.Context, channel *models.Channel) ([]models.ChannelAdminResponse, error) {
+	var admins []models.ChannelAdmin
+	if err := s.db.Preload("User").
+		Where("channel_id = ?", channel.ID).
+		Order("created_at ASC").
+		Find(&admins).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]models.ChannelAdminResponse, 0, len(admins)+1)
+
+	owner := models.ChannelAdminResponse{
+		UserID:    channel.OwnerID,
+		IsOwner:   true,
+		Rights:    models.FullChannelAdminRights(),
+		CreatedAt: channel.CreatedAt,
+	}
+	if channel.Owner != nil {
+		ownerResp := channel.Owner.ToResponse()
+		owner.User = &ownerResp
+	}
+	result = append(result, owner)
+
+	for i := range admins {
+		result = append(result, admins[i].ToResponse())
+	}
+	return result, nil
+}
+
+// SetAdmin promotes a subscriber to admin or replaces an admin's rights.
+func (s *ChannelService) SetAdmin(ctx context.Context, channelID, userID, promotedByID uuid.UUID, rights models.ChannelAdminRights) (*models.ChannelAdmin, error) {
+	if !s.IsSubscribed(channelID, userID) {
+		return nil, ErrNotSubscribed
+	}
+
+	admin := models.ChannelAdmin{
+		ChannelID:    channelID,
+		UserID:       userID,
+		Rights:       rights,
+		PromotedByID: promotedByID,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "channel_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"can_post", "can_edit_posts", "can_delete_posts",
+			"can_manage_subscribers", "can_manage_wiki", "can_manage_rss",
+			"promoted_by_id", "updated_at",
+		}),
+	}).Create(&admin).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Preload("User").
+		Where("channel_id = ? AND user_id = ?", channelID, userID).
+		First(&admin).Error; err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// RemoveAdmin demotes an admin. It reports whether the user was an admin.
+func (s *ChannelService) RemoveAdmin(ctx context.Context, channelID, userID uuid.UUID) (bool, error) {
+	result := s.db.Where("channel_id = ? AND user_id = ?", channelID, userID).Delete(&models.ChannelAdmin{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListSubscribers pages through a channel's subscribers, newest first.
+func (s *ChannelService) ListSubscribers(ctx context.Context, channelID uuid.UUID, limit, offset int) ([]models.ChannelSubscriber, int64, error) {
+	query := s.db.Model(&models.ChannelSubscriber{}).
+		Where("channel_id = ?", channelID).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var subscribers []models.ChannelSubscriber
+	if err := query.Preload("User").
+		Order("subscribed_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&subscribers).Error; err != nil {
+		return nil, 0, err
+	}
+	return subscribers, total, nil
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDiscussionNotGroup   = errors.New("discussion chat must be a group")
+	ErrDiscussionInUse      = errors.New("chat is already the discussion group of another channel")
+	ErrCommentsNotAvailable = errors.New("comments are not available for this post")
+)
+
+// LinkDiscussion makes a group chat the channel's discussion group. A group
+// can serve only one channel.
+func (s *ChannelService) LinkDiscussion(ctx context.Context, channel *models.Channel, chatID uuid.UUID) error {
+	var chat models.Chat
+	if err := s.db.Select("id", "type").First(&chat, chatID).Error; err != nil {
+		return err
+	}
+	if chat.Type != models.ChatTypeGroup {
+		return ErrDiscussionNotGroup
+	}
+
+	var taken int64
+	if err := s.db.Model(&models.Channel{}).
+		Where("discussion_chat_id = ? AND id <> ?", chatID, channel.ID).
+		Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrDiscussionInUse
+	}
+
+	return s.db.Model(channel).Update("discussion_chat_id", chatID).Error
+}
+
+// UnlinkDiscussion detaches the discussion group. Existing threads stay in
+// the group and keep their comments.
+func (s *ChannelService) UnlinkDiscussion(ctx context.Context, channel *models.Channel) error {
+	return s.db.Model(channel).Update("discussion_chat_id", nil).Error
+}
+
+// CreateDiscussionThread posts a copy of the channel post into the channel's
+// discussion group as the root of its comment thread. It returns nil when the
+// channel has no discussion group.
+func (s *ChannelService) CreateDiscussionThread(ctx context.Context, channel *models.Channel, post *models.ChannelPost) (*models.Message, error) {
+	if channel.DiscussionChatID == nil {
+		return nil, nil
+	}
+
+	// Threads go to the general topic when the group is a forum.
+	var topicID *uuid.UUID
+	var general models.ChatTopic
+	if err := s.db.Joins("JOIN chats ON chats.id = chat_topics.chat_id AND chats.is_forum = ?", true).
+		Where("chat_topics.chat_id = ? AND chat_topics.is_general = ?", *channel.DiscussionChatID, true).
+		First(&general).Error; err == nil {
+		topicID = &general.ID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	root := models.Message{
+		ChatID:        *channel.DiscussionChatID,
+		Content:       post.Content,
+		MessageType:   post.PostType,
+		MediaURL:      post.MediaURL,
+		MediaSize:     post.MediaSize,
+		TopicID:       topicID,
+		ChannelPostID: &post.ID,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&root).Error; err != nil {
+			return err
+		}
+		return tx.Model(post).Update("discussion_message_id", root.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &root, nil
+}
+
+// DiscussionRoot returns the thread root message of a post.
+func (s *ChannelService) DiscussionRoot(ctx context.Context, post *models.ChannelPost) (*models.Message, error) {
+	if post.DiscussionMessageID == nil {
+		return nil, ErrCommentsNotAvailable
+	}
+
+	var root models.Message
+	if err := s.db.First(&root, *post.DiscussionMessageID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCommentsNotAvailable
+		}
+		return nil, err
+	}
+	return &root, nil
+}
+
+// ListComments pages through a post's comments, oldest first.
+func (s *ChannelService) ListComments(ctx context.Context, rootID uuid.UUID, limit, offset int) ([]models.Message, int64, error) {
+	query := s.db.Model(&models.Message{}).
+		Where("thread_root_id = ? AND is_deleted = ?", rootID, false).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var comments []models.Message
+	if err := query.Preload("Sender").
+		Order("created_at ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&comments).Error; err != nil {
+		return nil, 0, err
+	}
+	return comments, total, nil
+}
+
+// CountComments returns the number of live comments on a post.
+func (s *ChannelService) CountComments(ctx context.Context, post *models.ChannelPost) int64 {
+	if post.DiscussionMessageID == nil {
+		return 0
+	}
+	var count int64
+	s.db.Model(&models.Message{}).
+		Where("thread_root_id = ? AND is_deleted = ?", *post.DiscussionMessageID, false).
+		Count(&count)
+	return count
+}
+
+// fillCommentCounts sets CommentCount on every post with a discussion thread
+// using a single grouped query.
+func (s *ChannelService) fillCommentCounts(posts []models.ChannelPost) error {
+	rootIDs := make([]uuid.UUID, 0, len(posts))
+	for _, post := range posts {
+		if post.DiscussionMessageID != nil {
+			rootIDs = append(rootIDs, *post.DiscussionMessageID)
+		}
+	}
+	if len(rootIDs) == 0 {
+		return nil
+	}
+
+	var counts []struct {
+		ThreadRootID uuid.UUID
+		Count        int64
+	}
+	if err := s.db.Model(&models.Message{}).
+		Select("thread_root_id, COUNT(*) AS count").
+		Where("thread_root_id IN ? AND is_deleted = ?", rootIDs, false).
+		Group("thread_root_id").
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+
+	byRoot := make(map[uuid.UUID]int64, len(counts))
+	for _, c := range counts {
+		byRoot[c.ThreadRootID] = c.Count
+	}
+	for i := range posts {
+		if posts[i].DiscussionMessageID != nil {
+			posts[i].CommentCount = byRoot[*posts[i].DiscussionMessageID]
+		}
+	}
+	return nil
+}
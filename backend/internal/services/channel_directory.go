@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrHandleTaken = errors.New("handle already taken")
+
+// likeEscaper escapes the LIKE wildcards in user input so it matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// HandleAvailable reports whether the handle is free, ignoring the channel
+// that already holds it when exceptID is set.
+func (s *ChannelService) HandleAvailable(handle string, exceptID uuid.UUID) (bool, error) {
+	var count int64
+	query := s.db.Model(&models.Channel{}).Where("handle = ?", handle)
+	if exceptID != uuid.Nil {
+		query = query.Where("id <> ?", exceptID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// UpdateChannel applies the owner's edits to the channel. HandleAvailable
+// is only a courtesy check before the write; a handle claimed by another
+// channel in the meantime fails here with ErrHandleTaken.
+func (s *ChannelService) UpdateChannel(ctx context.Context, channel *models.Channel, updates map[string]interface{}) error {
+	err := s.db.Model(channel).Updates(updates).Error
+	if _, ok := updates["handle"]; ok && isUniqueViolation(s.db, err) {
+		return ErrHandleTaken
+	}
+	return err
+}
+
+// isUniqueViolation reports whether err is the database rejecting a
+// duplicate value for a unique column.
+func isUniqueViolation(db *gorm.DB, err error) bool {
+	if err == nil {
+		return false
+	}
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// SearchChannels matches public channels by name, handle or description.
+// An exact handle match comes first, then handle prefixes, then everything
+// else; ties go to the bigger channel.
+func (s *ChannelService) SearchChannels(ctx context.Context, q string, limit, offset int) ([]models.Channel, int64, error) {
+	term := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(q), "@"))
+	pattern := "%" + likeEscaper.Replace(term) + "%"
+	prefix := likeEscaper.Replace(term) + "%"
+
+	query := s.db.Model(&models.Channel{}).
+		Where("is_public = ?", true).
+		Where(`LOWER(name) LIKE ? ESCAPE '\' OR handle LIKE ? ESCAPE '\' OR LOWER(COALESCE(description, '')) LIKE ? ESCAPE '\'`,
+			pattern, pattern, pattern).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var channels []models.Channel
+	err := query.
+		Preload("Owner").
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                `CASE WHEN handle = ? THEN 0 WHEN handle LIKE ? ESCAPE '\' THEN 1 ELSE 2 END, subscriber_count DESC, id ASC`,
+			Vars:               []interface{}{term, prefix},
+			WithoutParentheses: true,
+		}}).
+		Limit(limit).
+		Offset(offset).
+		Find(&channels).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return channels, total, nil
+}
+
+// ResolveHandle finds a channel by handle. Private channels are only found by
+// users who can already read them, so a handle never reveals that a private
+// channel exists.
+func (s *ChannelService) ResolveHandle(ctx context.Context, handle string, userID uuid.UUID) (*models.Channel, error) {
+	var channel models.Channel
+	if err := s.db.Preload("Owner").Where("handle = ?", handle).First(&channel).Error; err != nil {
+		return nil, err
+	}
+	if !s.CanRead(&channel, userID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &channel, nil
+}
+
+// ListMyChannels returns the channels the user owns or is subscribed to,
+// alphabetically.
+func (s *ChannelService) ListMyChannels(ctx context.Context, userID uuid.UUID) ([]models.MyChannelResponse, error) {
+	subscribed := s.db.Model(&models.ChannelSubscriber{}).Select("channel_id").Where("user_id = ?", userID)
+
+	var channels []models.Channel
+	if err := s.db.Preload("Owner").
+		Where("owner_id = ? OR id IN (?)", userID, subscribed).
+		Order("LOWER(name) ASC, id ASC").
+		Find(&channels).Error; err != nil {
+		return nil, err
+	}
+
+	var subscribedIDs []uuid.UUID
+	if err := s.db.Model(&models.ChannelSubscriber{}).
+		Where("user_id = ?", userID).
+		Pluck("channel_id", &subscribedIDs).Error; err != nil {
+		return nil, err
+	}
+	isSubscribed := make(map[uuid.UUID]bool, len(subscribedIDs))
+	for _, id := range subscribedIDs {
+		isSubscribed[id] = true
+	}
+
+	var adminIDs []uuid.UUID
+	if err := s.db.Model(&models.ChannelAdmin{}).
+		Where("user_id = ?", userID).
+		Pluck("channel_id", &adminIDs).Error; err != nil {
+		return nil, err
+	}
+	isAdmin := make(map[uuid.UUID]bool, len(adminIDs))
+	for _, id := range adminIDs {
+		isAdmin[id] = true
+	}
+
+	result := make([]models.MyChannelResponse, len(channels))
+	for i := range channels {
+		isOwner := channels[i].OwnerID == userID
+		result[i] = models.MyChannelResponse{
+			ChannelResponse: channels[i].ToResponse(),
+			IsOwner:         isOwner,
+			IsAdmin:         isOwner || isAdmin[channels[i].ID],
+			IsSubscribed:    isSubscribed[channels[i].ID],
+		}
+	}
+	return result, nil
+}
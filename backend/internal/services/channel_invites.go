@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInviteInvalid        = errors.New("invite link is invalid or has expired")
+	ErrJoinRequestPending   = errors.New("join request is already pending")
+	ErrJoinRequestNotFound  = errors.New("join request not found")
+	ErrJoinRequestProcessed = errors.New("join request was already reviewed")
+)
+
+// CreateInviteLink issues a new invite link for the channel.
+func (s *ChannelService) CreateInviteLink(ctx context.Context, channelID, createdByID uuid.UUID, req models.CreateInviteLinkRequest) (*models.ChannelInviteLink, error) {
+	token, err := newInviteToken()
+	if err != nil {
+		return nil, err
+	}
+
+	link := models.ChannelInviteLink{
+		ChannelID:        channelID,
+		Token:            token,
+		Name:             req.Name,
+		CreatedByID:      createdByID,
+		RequiresApproval: req.RequiresApproval,
+		UsageLimit:       req.UsageLimit,
+	}
+	if req.ExpiresInHours != nil {
+		expiresAt := time.Now().Add(time.Duration(*req.ExpiresInHours) * time.Hour)
+		link.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.Create(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (s *ChannelService) ListInviteLinks(ctx context.Context, channelID uuid.UUID) ([]models.ChannelInviteLink, error) {
+	var links []models.ChannelInviteLink
+	err := s.db.Where("channel_id = ?", channelID).Order("created_at DESC").Find(&links).Error
+	return links, err
+}
+
+// RevokeInviteLink disables a link. It reports whether the link existed.
+func (s *ChannelService) RevokeInviteLink(ctx context.Context, channelID, linkID uuid.UUID) (bool, error) {
+	result := s.db.Model(&models.ChannelInviteLink{}).
+		Where("id = ? AND channel_id = ?", linkID, channelID).
+		Update("is_revoked", true)
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindInviteLink looks up a usable link by token.
+func (s *ChannelService) FindInviteLink(ctx context.Context, token string) (*models.ChannelInviteLink, *models.Channel, error) {
+	var link models.ChannelInviteLink
+	if err := s.db.Where("token = ?", token).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInviteInvalid
+		}
+		return nil, nil, err
+	}
+	if !link.IsUsable(time.Now()) {
+		return nil, nil, ErrInviteInvalid
+	}
+
+	var channel models.Channel
+	if err := s.db.First(&channel, link.ChannelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInviteInvalid
+		}
+		return nil, nil, err
+	}
+	return &link, &channel, nil
+}
+
+// JoinByInvite subscribes the user through a link, or files a join request
+// when the link requires approval. It returns the request in that case.
+func (s *ChannelService) JoinByInvite(ctx context.Context, link *models.ChannelInviteLink, userID uuid.UUID, message *string) (*models.ChannelJoinRequest, error) {
+	if s.IsSubscribed(link.ChannelID, userID) {
+		return nil, ErrAlreadySubscribed
+	}
+
+	if link.RequiresApproval {
+		// The link may have been used up or revoked since it was looked up.
+		var usable int64
+		if err := usableInviteLink(s.db, link.ID).Count(&usable).Error; err != nil {
+			return nil, err
+		}
+		if usable == 0 {
+			return nil, ErrInviteInvalid
+		}
+
+		var pending int64
+		if err := s.db.Model(&models.ChannelJoinRequest{}).
+			Where("channel_id = ? AND user_id = ? AND status = ?", link.ChannelID, userID, models.JoinRequestPending).
+			Count(&pending).Error; err != nil {
+			return nil, err
+		}
+		if pending > 0 {
+			return nil, ErrJoinRequestPending
+		}
+
+		request := models.ChannelJoinRequest{
+			ChannelID:    link.ChannelID,
+			UserID:       userID,
+			InviteLinkID: &link.ID,
+			Message:      message,
+			Status:       models.JoinRequestPending,
+		}
+		if err := s.db.Create(&request).Error; err != nil {
+			return nil, err
+		}
+		return &request, nil
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Claim a use first so concurrent joins cannot exceed the limit.
+		if err := claimInviteUse(tx, link.ID); err != nil {
+			return err
+		}
+		return s.subscribe(tx, link.ChannelID, userID)
+	})
+	return nil, err
+}
+
+// ListJoinRequests returns the channel's join requests with the given status,
+// oldest first.
+func (s *ChannelService) ListJoinRequests(ctx context.Context, channelID uuid.UUID, status models.JoinRequestStatus) ([]models.ChannelJoinRequest, error) {
+	var requests []models.ChannelJoinRequest
+	err := s.db.Preload("User").
+		Where("channel_id = ? AND status = ?", channelID, status).
+		Order("created_at ASC").
+		Find(&requests).Error
+	return requests, err
+}
+
+// ReviewJoinRequest approves or rejects a pending request. Approval
+// subscribes the requester and uses up one use of the link the request came
+// through; it fails with ErrInviteInvalid when the link has been revoked or
+// used up since, leaving the request pending.
+func (s *ChannelService) ReviewJoinRequest(ctx context.Context, channelID, requestID, reviewerID uuid.UUID, approve bool) (*models.ChannelJoinRequest, error) {
+	var request models.ChannelJoinRequest
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND channel_id = ?", requestID, channelID).First(&request).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrJoinRequestNotFound
+			}
+			return err
+		}
+		if request.Status != models.JoinRequestPending {
+			return ErrJoinRequestProcessed
+		}
+
+		now := time.Now()
+		request.Status = models.JoinRequestRejected
+		if approve {
+			request.Status = models.JoinRequestApproved
+		}
+		request.ReviewedByID = &reviewerID
+		request.ReviewedAt = &now
+
+		if err := tx.Save(&request).Error; err != nil {
+			return err
+		}
+
+		if !approve {
+			return nil
+		}
+		if request.InviteLinkID != nil {
+			if err := claimInviteUse(tx, *request.InviteLinkID); err != nil {
+				return err
+			}
+		}
+		if err := s.subscribe(tx, channelID, request.UserID); err != nil && !errors.Is(err, ErrAlreadySubscribed) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// usableInviteLink scopes a query to the link while it is neither revoked
+// nor used up.
+func usableInviteLink(db *gorm.DB, linkID uuid.UUID) *gorm.DB {
+	return db.Model(&models.ChannelInviteLink{}).
+		Where("id = ? AND is_revoked = ? AND (usage_limit IS NULL OR usage_count < usage_limit)", linkID, false)
+}
+
+// claimInviteUse counts one use of the link, failing with ErrInviteInvalid
+// when it is revoked or has no uses left.
+func claimInviteUse(tx *gorm.DB, linkID uuid.UUID) error {
+	result := usableInviteLink(tx, linkID).Update("usage_count", gorm.Expr("usage_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInviteInvalid
+	}
+	return nil
+}
+
+func newInviteToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
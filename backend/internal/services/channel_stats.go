@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	pendingPostViewsKey = "channel:views:pending"
+	postViewSeenTTL     = 7 * 24 * time.Hour
+	postViewFlushBatch  = 1000
+	topPostsLimit       = 10
+	statsDateLayout     = "2006-01-02"
+)
+
+// RecordPostViews notes that the user has seen the given posts. Each user is
+// counted once per post: Redis drops repeats cheaply and the flush to
+// Postgres enforces uniqueness for good.
+func (s *ChannelService) RecordPostViews(ctx context.Context, channelID, userID uuid.UUID, postIDs []uuid.UUID) error {
+	var existing []uuid.UUID
+	if err := s.db.Model(&models.ChannelPost{}).
+		Where("id IN ? AND channel_id = ? AND is_deleted = ?", postIDs, channelID, false).
+		Pluck("id", &existing).Error; err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, postID := range existing {
+		seenKey := fmt.Sprintf("channel:views:seen:%s", postID)
+		added, err := s.redis.SAdd(ctx, seenKey, userID.String()).Result()
+		if err != nil {
+			return err
+		}
+		if added == 0 {
+			continue
+		}
+		s.redis.Expire(ctx, seenKey, postViewSeenTTL)
+
+		entry := fmt.Sprintf("%s:%s:%s:%d", postID, channelID, userID, now)
+		if err := s.redis.LPush(ctx, pendingPostViewsKey, entry).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FlushPostViews moves pending views from Redis into channel_post_views and
+// refreshes the view counters of the affected posts.
+func (s *ChannelService) FlushPostViews(ctx context.Context) (int, error) {
+	flushed := 0
+	for {
+		entries, err := s.redis.RPopCount(ctx, pendingPostViewsKey, postViewFlushBatch).Result()
+		if errors.Is(err, redis.Nil) || (err == nil && len(entries) == 0) {
+			return flushed, nil
+		}
+		if err != nil {
+			return flushed, err
+		}
+
+		views := make([]models.ChannelPostView, 0, len(entries))
+		posts := make(map[uuid.UUID]bool)
+		for _, entry := range entries {
+			view, ok := parsePendingView(entry)
+			if !ok {
+				log.Printf("Skipping malformed pending post view %q", entry)
+				continue
+			}
+			views = append(views, view)
+			posts[view.PostID] = true
+		}
+
+		if len(views) > 0 {
+			if err := s.storePostViews(views, posts); err != nil {
+				// Put the batch back so the next flush retries it.
+				s.redis.RPush(ctx, pendingPostViewsKey, entries)
+				return flushed, err
+			}
+		}
+
+		flushed += len(views)
+		if len(entries) < postViewFlushBatch {
+			return flushed, nil
+		}
+	}
+}
+
+func (s *ChannelService) storePostViews(views []models.ChannelPostView, posts map[uuid.UUID]bool) error {
+	postIDs := make([]uuid.UUID, 0, len(posts))
+	for id := range posts {
+		postIDs = append(postIDs, id)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&views).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.ChannelPost{}).
+			Where("id IN ?", postIDs).
+			Update("views", tx.Model(&models.ChannelPostView{}).
+				Select("COUNT(*)").
+				Where("channel_post_views.post_id = channel_posts.id")).Error
+	})
+}
+
+func parsePendingView(entry string) (models.ChannelPostView, bool) {
+	parts := strings.Split(entry, ":")
+	if len(parts) != 4 {
+		return models.ChannelPostView{}, false
+	}
+
+	postID, err1 := uuid.Parse(parts[0])
+	channelID, err2 := uuid.Parse(parts[1])
+	userID, err3 := uuid.Parse(parts[2])
+	unix, err4 := strconv.ParseInt(parts[3], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return models.ChannelPostView{}, false
+	}
+
+	return models.ChannelPostView{
+		PostID:    postID,
+		ChannelID: channelID,
+		UserID:    userID,
+		ViewedAt:  time.Unix(unix, 0),
+	}, true
+}
+
+// DailySubscriptionChange is the number of subscribes and unsubscribes on one day.
+type DailySubscriptionChange struct {
+	Day    string
+	Joined int64
+	Left   int64
+}
+
+// GetChannelStats reports subscriber growth and post reach for the days
+// from..to inclusive.
+func (s *ChannelService) GetChannelStats(ctx context.Context, channel *models.Channel, from, to time.Time) (*models.ChannelStatsResponse, error) {
+	end := to.AddDate(0, 0, 1)
+
+	var events []models.ChannelSubscriptionEvent
+	if err := s.db.Where("channel_id = ? AND created_at >= ?", channel.ID, from).
+		Order("created_at ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	// Walk back from today's count to the count at the start of the range.
+	baseline := int64(channel.SubscriberCount)
+	byDay := make(map[string]*DailySubscriptionChange)
+	for _, e := range events {
+		if e.Joined {
+			baseline--
+		} else {
+			baseline++
+		}
+		if !e.CreatedAt.Before(end) {
+			continue
+		}
+		day := e.CreatedAt.UTC().Format(statsDateLayout)
+		change, ok := byDay[day]
+		if !ok {
+			change = &DailySubscriptionChange{Day: day}
+			byDay[day] = change
+		}
+		if e.Joined {
+			change.Joined++
+		} else {
+			change.Left++
+		}
+	}
+
+	changes := make([]DailySubscriptionChange, 0, len(byDay))
+	for _, change := range byDay {
+		changes = append(changes, *change)
+	}
+
+	var posts []models.ChannelPost
+	if err := s.db.Where("channel_id = ? AND is_deleted = ? AND created_at >= ? AND created_at < ?",
+		channel.ID, false, from, end).
+		Order("created_at ASC").
+		Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	var rangeViews []struct {
+		PostID uuid.UUID
+		Count  int64
+	}
+	if err := s.db.Model(&models.ChannelPostView{}).
+		Select("post_id, COUNT(*) AS count").
+		Where("channel_id = ? AND viewed_at >= ? AND viewed_at < ?", channel.ID, from, end).
+		Group("post_id").
+		Scan(&rangeViews).Error; err != nil {
+		return nil, err
+	}
+
+	viewsInRange := make(map[uuid.UUID]int64, len(rangeViews))
+	var totalViews int64
+	for _, v := range rangeViews {
+		viewsInRange[v.PostID] = v.Count
+		totalViews += v.Count
+	}
+
+	postStats := make([]models.PostViewStats, len(posts))
+	for i := range posts {
+		postStats[i] = toPostViewStats(&posts[i], viewsInRange)
+	}
+
+	// Top posts may be older than the range; they are ranked by the views
+	// they gained within it.
+	sort.Slice(rangeViews, func(i, j int) bool {
+		return rangeViews[i].Count > rangeViews[j].Count
+	})
+	if len(rangeViews) > topPostsLimit {
+		rangeViews = rangeViews[:topPostsLimit]
+	}
+	topIDs := make([]uuid.UUID, len(rangeViews))
+	for i, v := range rangeViews {
+		topIDs[i] = v.PostID
+	}
+
+	var topPosts []models.ChannelPost
+	if len(topIDs) > 0 {
+		if err := s.db.Where("id IN ? AND is_deleted = ?", topIDs, false).Find(&topPosts).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	topStats := make([]models.PostViewStats, len(topPosts))
+	for i := range topPosts {
+		topStats[i] = toPostViewStats(&topPosts[i], viewsInRange)
+	}
+	sort.SliceStable(topStats, func(i, j int) bool {
+		return topStats[i].ViewsInRange > topStats[j].ViewsInRange
+	})
+
+	return &models.ChannelStatsResponse{
+		ChannelID:        channel.ID,
+		From:             from.Format(statsDateLayout),
+		To:               to.Format(statsDateLayout),
+		SubscriberCount:  channel.SubscriberCount,
+		SubscriberGrowth: BuildSubscriberGrowth(from, to, baseline, changes),
+		TotalViews:       totalViews,
+		Posts:            postStats,
+		TopPosts:         topStats,
+	}, nil
+}
+
+// BuildSubscriberGrowth turns per-day changes into one point per day from..to,
+// carrying the running subscriber total forward from baseline.
+func BuildSubscriberGrowth(from, to time.Time, baseline int64, changes []DailySubscriptionChange) []models.SubscriberGrowthPoint {
+	byDay := make(map[string]DailySubscriptionChange, len(changes))
+	for _, change := range changes {
+		byDay[change.Day] = change
+	}
+
+	points := make([]models.SubscriberGrowthPoint, 0)
+	total := baseline
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format(statsDateLayout)
+		change := byDay[key]
+		net := change.Joined - change.Left
+		total += net
+		points = append(points, models.SubscriberGrowthPoint{
+			Date:        key,
+			Joined:      change.Joined,
+			Left:        change.Left,
+			Net:         net,
+			Subscribers: total,
+		})
+	}
+	return points
+}
+
+func toPostViewStats(post *models.ChannelPost, viewsInRange map[uuid.UUID]int64) models.PostViewStats {
+	return models.PostViewStats{
+		PostID:       post.ID,
+		Content:      post.Content,
+		CreatedAt:    post.CreatedAt,
+		Views:        post.Views,
+		ViewsInRange: viewsInRange[post.ID],
+	}
+}
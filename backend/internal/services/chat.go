@@ -4,6 +4,8 @@ import (
     "context"
     "encoding/json"
     "fmt"
+    "log"
+    "sort"
     "time"
 
     "github.com/google/uuid"
@@ -12,6 +14,8 @@ import (
     "gorm.io/gorm"
 )
 
+var ErrPinnedChatsLimit = fmt.Errorf("cannot pin more than %d chats", models.MaxPinnedChats)
+
 type ChatService struct {
     db    *gorm.DB
     redis *redis.Client
@@ -94,8 +98,13 @@ func (s *ChatService) GetOrCreateDMChat(ctx context.Context, userID1, userID2 uu
     return &chat, nil
 }
 
-func (s *ChatService) GetUserChatsWithLastMessage(ctx context.Context, userID uuid.UUID) ([]models.ChatWithLastMessageResponse, error) {
-    cacheKey := fmt.Sprintf("user:chats:%s", userID.String())
+// ChatListOptions selects which of the user's chats are listed.
+type ChatListOptions struct {
+    Archived bool
+}
+
+func (s *ChatService) GetUserChatsWithLastMessage(ctx context.Context, userID uuid.UUID, opts ChatListOptions) ([]models.ChatWithLastMessageResponse, error) {
+    cacheKey := userChatsCacheKey(userID, opts.Archived)
 
     cached, err := s.redis.Get(ctx, cacheKey).Result()
     if err == nil && cached != "" {
@@ -106,7 +115,7 @@ func (s *ChatService) GetUserChatsWithLastMessage(ctx context.Context, userID uu
     }
 
     var chatMembers []models.ChatMember
-    if err := s.db.Where("user_id = ?", userID).Find(&chatMembers).Error; err != nil {
+    if err := s.db.Where("user_id = ? AND is_archived = ?", userID, opts.Archived).Find(&chatMembers).Error; err != nil {
         return nil, err
     }
 
@@ -115,8 +124,10 @@ func (s *ChatService) GetUserChatsWithLastMessage(ctx context.Context, userID uu
     }
 
     chatIDs := make([]uuid.UUID, len(chatMembers))
+    membersByChat := make(map[uuid.UUID]models.ChatMember, len(chatMembers))
     for i, cm := range chatMembers {
         chatIDs[i] = cm.ChatID
+        membersByChat[cm.ChatID] = cm
     }
 
     var chats []models.Chat
@@ -134,20 +145,44 @@ func (s *ChatService) GetUserChatsWithLastMessage(ctx context.Context, userID uu
         }
 
         lastMessage, unreadCount := s.getChatMetadata(chat.ID, userID)
+        member := membersByChat[chat.ID]
 
         responses[i] = models.ChatWithLastMessageResponse{
-            ChatResponse: chat.ToResponse(),
-            LastMessage:  lastMessage,
-            UnreadCount:  unreadCount,
+            ChatResponse:         chat.ToResponse(),
+            LastMessage:          lastMessage,
+            UnreadCount:          unreadCount,
+            ChatSettingsResponse: member.ToSettingsResponse(),
+        }
+
+        if chat.IsForum {
+            responses[i].UnreadCount = s.forumUnreadCount(chat.ID, userID)
+            responses[i].LastTopic = s.latestTopic(chat.ID)
         }
     }
 
+    sortChatList(responses)
+
     chatData, _ := json.Marshal(responses)
     s.redis.Set(ctx, cacheKey, chatData, 5*time.Minute)
 
     return responses, nil
 }
 
+// sortChatList orders a chat list as the chat list shows it: pinned chats
+// first in pin order, then the rest by their last message, newest first.
+func sortChatList(chats []models.ChatWithLastMessageResponse) {
+    sort.SliceStable(chats, func(i, j int) bool {
+        pi, pj := chats[i].PinnedPosition, chats[j].PinnedPosition
+        if pi != nil && pj != nil {
+            return *pi < *pj
+        }
+        if pi != nil || pj != nil {
+            return pi != nil
+        }
+        return chats[i].LastMessageAt.After(chats[j].LastMessageAt)
+    })
+}
+
 func (s *ChatService) getChatMetadata(chatID, userID uuid.UUID) (*models.MessageResponse, int64) {
     var lastMessage models.Message
     if err := s.db.Where("chat_id = ? AND is_deleted = ?", chatID, false).
@@ -184,9 +219,143 @@ func (s *ChatService) enrichDMChatName(chat models.Chat, currentUserID uuid.UUID
 }
 
 func (s *ChatService) UpdateLastRead(ctx context.Context, chatID, userID uuid.UUID) error {
-    return s.db.Model(&models.ChatMember{}).
+    if err := s.db.Model(&models.ChatMember{}).
         Where("chat_id = ? AND user_id = ?", chatID, userID).
-        Update("last_read_at", time.Now()).Error
+        Updates(map[string]interface{}{
+            "last_read_at":  time.Now(),
+            "marked_unread": false,
+        }).Error; err != nil {
+        return err
+    }
+
+    // Reading the whole chat supersedes any per-topic progress.
+    return s.db.Where("user_id = ? AND topic_id IN (?)", userID,
+        s.db.Model(&models.ChatTopic{}).Select("id").Where("chat_id = ?", chatID)).
+        Delete(&models.ChatTopicRead{}).Error
+}
+
+// UpdateChatSettings applies the caller's per-chat preferences (mute, archive,
+// pin, manual unread mark) and returns the updated membership row.
+func (s *ChatService) UpdateChatSettings(ctx context.Context, chatID, userID uuid.UUID, req models.UpdateChatSettingsRequest) (*models.ChatMember, error) {
+    var member models.ChatMember
+    err := s.db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Where("chat_id = ? AND user_id = ?", chatID, userID).First(&member).Error; err != nil {
+            return err
+        }
+
+        updates := map[string]interface{}{}
+
+        if req.MuteFor != nil {
+            switch {
+            case *req.MuteFor == 0:
+                updates["muted_until"] = nil
+            case *req.MuteFor < 0:
+                updates["muted_until"] = models.MutedForever
+            default:
+                updates["muted_until"] = time.Now().Add(time.Duration(*req.MuteFor) * time.Second)
+            }
+        }
+
+        if req.IsArchived != nil {
+            updates["is_archived"] = *req.IsArchived
+        }
+
+        if req.MarkedUnread != nil {
+            updates["marked_unread"] = *req.MarkedUnread
+        }
+
+        if req.IsPinned != nil {
+            if !*req.IsPinned {
+                updates["pinned_position"] = nil
+            } else if member.PinnedPosition == nil {
+                var pinned []models.ChatMember
+                if err := tx.Where("user_id = ? AND pinned_position IS NOT NULL", userID).Find(&pinned).Error; err != nil {
+                    return err
+                }
+                if len(pinned) >= models.MaxPinnedChats {
+                    return ErrPinnedChatsLimit
+                }
+                next := 0
+                for _, p := range pinned {
+                    if *p.PinnedPosition >= next {
+                        next = *p.PinnedPosition + 1
+                    }
+                }
+                updates["pinned_position"] = next
+            }
+        }
+
+        if len(updates) == 0 {
+            return nil
+        }
+
+        if err := tx.Model(&models.ChatMember{}).
+            Where("chat_id = ? AND user_id = ?", chatID, userID).
+            Updates(updates).Error; err != nil {
+            return err
+        }
+
+        return tx.Where("chat_id = ? AND user_id = ?", chatID, userID).First(&member).Error
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    s.InvalidateUserChatsCache(ctx, userID)
+
+    return &member, nil
+}
+
+// HandleNewMessage runs the side effects of a new message: its topic's
+// activity time moves forward, archived chats come back to the main list
+// unless muted, and members who have not muted the chat get a notification on
+// their personal channel.
+func (s *ChatService) HandleNewMessage(ctx context.Context, message *models.Message) {
+    if message.TopicID != nil {
+        if err := s.db.Model(&models.ChatTopic{}).
+            Where("id = ?", *message.TopicID).
+            Update("last_message_at", message.CreatedAt).Error; err != nil {
+            log.Printf("Error updating topic activity: %v", err)
+        }
+    }
+
+    var members []models.ChatMember
+    if err := s.db.Where("chat_id = ?", message.ChatID).Find(&members).Error; err != nil {
+        log.Printf("Error loading chat members for notifications: %v", err)
+        return
+    }
+
+    notification, err := json.Marshal(map[string]interface{}{
+        "type":    "notification",
+        "chat_id": message.ChatID,
+        "message": message.ToResponse(),
+    })
+    if err != nil {
+        return
+    }
+
+    now := time.Now()
+    for _, member := range members {
+        muted := member.IsMuted(now)
+
+        // Muted chats stay archived; anyone else's come back to the list.
+        if member.IsArchived && !muted {
+            if err := s.db.Model(&models.ChatMember{}).
+                Where("chat_id = ? AND user_id = ?", member.ChatID, member.UserID).
+                Update("is_archived", false).Error; err != nil {
+                log.Printf("Error unarchiving chat %s for user %s: %v", member.ChatID, member.UserID, err)
+            }
+        }
+
+        // Every member's cached list shows a new last message and unread
+        // count, muted or not.
+        s.InvalidateUserChatsCache(ctx, member.UserID)
+
+        if muted || (message.SenderID != nil && member.UserID == *message.SenderID) {
+            continue
+        }
+        s.redis.Publish(ctx, "user:"+member.UserID.String(), notification)
+    }
 }
 
 func (s *ChatService) GetUnreadCount(ctx context.Context, chatID, userID uuid.UUID) (int64, error) {
@@ -204,8 +373,14 @@ func (s *ChatService) GetUnreadCount(ctx context.Context, chatID, userID uuid.UU
 }
 
 func (s *ChatService) InvalidateUserChatsCache(ctx context.Context, userID uuid.UUID) {
-    cacheKey := fmt.Sprintf("user:chats:%s", userID.String())
-    s.redis.Del(ctx, cacheKey)
+    s.redis.Del(ctx, userChatsCacheKey(userID, false), userChatsCacheKey(userID, true))
+}
+
+func userChatsCacheKey(userID uuid.UUID, archived bool) string {
+    if archived {
+        return fmt.Sprintf("user:chats:%s:archived", userID.String())
+    }
+    return fmt.Sprintf("user:chats:%s", userID.String())
 }
 
 func (s *ChatService) getDMCacheKey(userID1, userID2 uuid.UUID) string {
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/pkg/highlight"
+	"github.com/messenger/backend/pkg/markdown"
+	"gorm.io/gorm"
+)
+
+// CodeBlocks returns the fenced code blocks of a message. A language named
+// after the fence is taken with full confidence, otherwise it is detected
+// from the code.
+func CodeBlocks(content string) []models.CodeBlock {
+	var blocks []models.CodeBlock
+	for i, fenced := range markdown.FencedBlocks(content) {
+		block := models.CodeBlock{
+			Index:     i,
+			Language:  models.CodeLanguageOther,
+			Code:      fenced.Code,
+			StartLine: fenced.StartLine,
+			EndLine:   fenced.EndLine,
+		}
+		if language := highlight.LanguageAlias(fenced.Info); language != "" {
+			block.Language = models.CodeLanguage(language)
+			block.Confidence = 1
+		} else if detection := highlight.Detect("", fenced.Code); detection.Language != "" {
+			block.Language = models.CodeLanguage(detection.Language)
+			block.Confidence = detection.Confidence
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// CodeBlockHash identifies a code block by its code.
+func CodeBlockHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// MarkPromotedBlocks matches the snippets promoted from a message to its
+// current blocks by the hash of their code, taking identical blocks in
+// order. It sets SnippetID on the matched blocks and PromotedBlock on the
+// snippets, which is nil for snippets whose block is gone. Snippets without
+// a hash keep the block at their index.
+func MarkPromotedBlocks(blocks []models.CodeBlock, snippets []models.CodeSnippet) {
+	hashes := make([]string, len(blocks))
+	for i := range blocks {
+		hashes[i] = CodeBlockHash(blocks[i].Code)
+	}
+
+	for i := range snippets {
+		snippet := &snippets[i]
+		match := -1
+		if snippet.PromotedHash == "" {
+			if snippet.PromotedBlock != nil && *snippet.PromotedBlock < len(blocks) && blocks[*snippet.PromotedBlock].SnippetID == nil {
+				match = *snippet.PromotedBlock
+			}
+		} else {
+			for j, hash := range hashes {
+				if hash == snippet.PromotedHash && blocks[j].SnippetID == nil {
+					match = j
+					break
+				}
+			}
+		}
+
+		if match < 0 {
+			snippet.PromotedBlock = nil
+			continue
+		}
+		id := snippet.ID
+		blocks[match].SnippetID = &id
+		index := match
+		snippet.PromotedBlock = &index
+	}
+}
+
+// RematchPromotedBlocks updates the block indexes of the snippets promoted
+// from a message after its content changed to content.
+func RematchPromotedBlocks(db *gorm.DB, messageID uuid.UUID, content string) error {
+	var snippets []models.CodeSnippet
+	if err := db.Select("id", "promoted_block", "promoted_hash").Where("promoted_from_id = ?", messageID).Find(&snippets).Error; err != nil {
+		return err
+	}
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	before := make(map[uuid.UUID]*int, len(snippets))
+	for _, snippet := range snippets {
+		before[snippet.ID] = snippet.PromotedBlock
+	}
+	MarkPromotedBlocks(CodeBlocks(content), snippets)
+
+	for _, snippet := range snippets {
+		if old := before[snippet.ID]; old == snippet.PromotedBlock || old != nil && snippet.PromotedBlock != nil && *old == *snippet.PromotedBlock {
+			continue
+		}
+		if err := db.Model(&models.CodeSnippet{}).Where("id = ?", snippet.ID).Update("promoted_block", snippet.PromotedBlock).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
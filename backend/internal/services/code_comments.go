@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/pkg/diff"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrStaleCodeSnippet         = errors.New("code snippet changed since it was loaded")
+	ErrCodeCommentParentMissing = errors.New("parent comment not found")
+	ErrCodeCommentLines         = errors.New("invalid line range")
+	ErrCodeCommentNotThread     = errors.New("only threads can be resolved")
+)
+
+// CodeService keeps the versions of code snippets and the review threads on
+// them.
+type CodeService struct {
+	db *gorm.DB
+}
+
+func NewCodeService(db *gorm.DB) *CodeService {
+	return &CodeService{db: db}
+}
+
+// CreateCodeSnippet inserts a snippet together with its first version.
+func CreateCodeSnippet(db *gorm.DB, snippet *models.CodeSnippet) error {
+	snippet.Version = 1
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(snippet).Error; err != nil {
+			return err
+		}
+		version := CurrentCodeVersion(snippet)
+		return tx.Create(&version).Error
+	})
+}
+
+// SaveVersion saves the changed snippet as its next version and moves its
+// line comments along with the code. previous is the snippet as loaded,
+// before the change; it is recorded as version 1 first if the snippet
+// predates versioning. It returns the comments whose lines moved or went
+// outdated, or ErrStaleCodeSnippet if another change was saved since
+// previous was loaded.
+func (s *CodeService) SaveVersion(ctx context.Context, previous, snippet *models.CodeSnippet, userID uuid.UUID, summary string) ([]models.CodeComment, error) {
+	var moved []models.CodeComment
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the snippet so concurrent saves number their versions one
+		// after another.
+		var current models.CodeSnippet
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "version").First(&current, snippet.ID).Error; err != nil {
+			return err
+		}
+		if current.Version != previous.Version {
+			return ErrStaleCodeSnippet
+		}
+
+		var count int64
+		if err := tx.Model(&models.CodeSnippetVersion{}).Where("snippet_id = ?", snippet.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			baseline := CurrentCodeVersion(previous)
+			baseline.CreatedAt = previous.CreatedAt
+			if err := tx.Create(&baseline).Error; err != nil {
+				return err
+			}
+		}
+
+		snippet.Version = previous.Version + 1
+		if err := tx.Save(snippet).Error; err != nil {
+			return err
+		}
+
+		version := CurrentCodeVersion(snippet)
+		version.CreatedByID = userID
+		version.ChangeSummary = summary
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+
+		var err error
+		moved, err = reanchorCodeComments(tx, previous.Code, snippet)
+		return err
+	})
+	return moved, err
+}
+
+// SaveLanguageDetection saves a change that leaves the snippet's code,
+// language and file name alone, which only touches how its language was
+// chosen. Like SaveVersion it fails with ErrStaleCodeSnippet if another
+// change was saved since the snippet was loaded at previousVersion.
+func (s *CodeService) SaveLanguageDetection(ctx context.Context, snippet *models.CodeSnippet, previousVersion int) error {
+	result := s.db.WithContext(ctx).Model(snippet).Where("version = ?", previousVersion).Updates(map[string]interface{}{
+		"language_detected":   snippet.LanguageDetected,
+		"language_confidence": snippet.LanguageConfidence,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleCodeSnippet
+	}
+	return nil
+}
+
+// reanchorCodeComments maps the lines of the snippet's current comments from
+// oldCode to its new code. A comment whose lines are all kept, in the same
+// order and without lines inserted between them, follows them; any other is
+// marked outdated and keeps the lines and version it was written for.
+func reanchorCodeComments(tx *gorm.DB, oldCode string, snippet *models.CodeSnippet) ([]models.CodeComment, error) {
+	var comments []models.CodeComment
+	if err := tx.Where("snippet_id = ? AND parent_id IS NULL AND outdated = ?", snippet.ID, false).
+		Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	if len(comments) == 0 {
+		return nil, nil
+	}
+
+	lines := diff.MapLines(oldCode, snippet.Code)
+	var moved []models.CodeComment
+	for _, comment := range comments {
+		start, end, kept := lines.Range(comment.StartLine, comment.EndLine)
+		switch {
+		case !kept:
+			comment.Outdated = true
+		case start == comment.StartLine:
+			// The lines stayed in place; only the version moves on.
+			if err := tx.Model(&comment).Update("version", snippet.Version).Error; err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			comment.StartLine = start
+			comment.EndLine = end
+			comment.Version = snippet.Version
+		}
+
+		if err := tx.Model(&comment).Updates(map[string]interface{}{
+			"start_line": comment.StartLine,
+			"end_line":   comment.EndLine,
+			"version":    comment.Version,
+			"outdated":   comment.Outdated,
+		}).Error; err != nil {
+			return nil, err
+		}
+		moved = append(moved, comment)
+	}
+	return moved, nil
+}
+
+// SameCodeVersion reports whether two states of a snippet have the same
+// code, language and file name, the fields a version records.
+func SameCodeVersion(a, b *models.CodeSnippet) bool {
+	if a.Code != b.Code || a.Language != b.Language {
+		return false
+	}
+	if a.FileName == nil || b.FileName == nil {
+		return a.FileName == b.FileName
+	}
+	return *a.FileName == *b.FileName
+}
+
+// CurrentCodeVersion builds the version row for the snippet's current state.
+func CurrentCodeVersion(snippet *models.CodeSnippet) models.CodeSnippetVersion {
+	return models.CodeSnippetVersion{
+		SnippetID:     snippet.ID,
+		VersionNumber: snippet.Version,
+		Language:      snippet.Language,
+		Code:          snippet.Code,
+		FileName:      snippet.FileName,
+		CreatedByID:   snippet.CreatedByID,
+		CreatedAt:     snippet.CreatedAt,
+	}
+}
+
+// ListThreads returns the review threads on a snippet with their replies,
+// ordered by line. resolved, when set, keeps only threads in that state.
+func (s *CodeService) ListThreads(ctx context.Context, snippetID uuid.UUID, resolved *bool) ([]models.CodeCommentResponse, error) {
+	var comments []models.CodeComment
+	if err := s.db.WithContext(ctx).Preload("Author").Where("snippet_id = ?", snippetID).
+		Order("created_at ASC").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	threads := []models.CodeCommentResponse{}
+	index := make(map[uuid.UUID]int)
+	for _, comment := range comments {
+		if comment.ParentID != nil {
+			continue
+		}
+		if resolved != nil && *resolved != comment.Resolved {
+			continue
+		}
+		index[comment.ID] = len(threads)
+		threads = append(threads, comment.ToResponse())
+	}
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			continue
+		}
+		if i, ok := index[*comment.ParentID]; ok {
+			threads[i].Replies = append(threads[i].Replies, comment.ToResponse())
+		}
+	}
+
+	// Outdated threads go last, as their lines refer to an older version.
+	sort.SliceStable(threads, func(i, j int) bool {
+		if threads[i].Outdated != threads[j].Outdated {
+			return !threads[i].Outdated
+		}
+		return !threads[i].Outdated && threads[i].StartLine < threads[j].StartLine
+	})
+	return threads, nil
+}
+
+// CreateComment starts a review thread on lines startLine to endLine of the
+// snippet's latest version, or replies to the thread of parentID. An endLine
+// of 0 means the thread covers startLine only. Threads are one level deep:
+// a reply to a reply joins its thread.
+func (s *CodeService) CreateComment(ctx context.Context, snippet *models.CodeSnippet, authorID uuid.UUID, body string, parentID *uuid.UUID, startLine, endLine int) (*models.CodeComment, error) {
+	db := s.db.WithContext(ctx)
+	comment := models.CodeComment{
+		SnippetID: snippet.ID,
+		AuthorID:  authorID,
+		Body:      body,
+	}
+
+	if parentID != nil {
+		var parent models.CodeComment
+		if err := db.Where("id = ? AND snippet_id = ?", *parentID, snippet.ID).First(&parent).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrCodeCommentParentMissing
+			}
+			return nil, err
+		}
+		pid := parent.ID
+		if parent.ParentID != nil {
+			pid = *parent.ParentID
+		}
+		comment.ParentID = &pid
+	} else {
+		if endLine == 0 {
+			endLine = startLine
+		}
+		lineCount := len(diff.SplitLines(snippet.Code))
+		if startLine < 1 || endLine < startLine || endLine > lineCount {
+			return nil, ErrCodeCommentLines
+		}
+		comment.StartLine = startLine
+		comment.EndLine = endLine
+		comment.Version = snippet.Version
+	}
+
+	if err := db.Create(&comment).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// UpdateComment changes the body of a comment.
+func (s *CodeService) UpdateComment(ctx context.Context, comment *models.CodeComment, body string) error {
+	comment.Body = body
+	return s.db.WithContext(ctx).Save(comment).Error
+}
+
+// DeleteComment removes a comment, and its replies if it starts a thread.
+func (s *CodeService) DeleteComment(ctx context.Context, comment *models.CodeComment) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("parent_id = ?", comment.ID).Delete(&models.CodeComment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(comment).Error
+	})
+}
+
+// SetResolved marks a thread resolved by userID, or reopens it. It reports
+// whether the state changed; replies fail with ErrCodeCommentNotThread.
+func (s *CodeService) SetResolved(ctx context.Context, comment *models.CodeComment, userID uuid.UUID, resolved bool) (bool, error) {
+	if comment.ParentID != nil {
+		return false, ErrCodeCommentNotThread
+	}
+	if comment.Resolved == resolved {
+		return false, nil
+	}
+
+	comment.Resolved = resolved
+	if resolved {
+		now := time.Now()
+		comment.ResolvedByID = &userID
+		comment.ResolvedAt = &now
+	} else {
+		comment.ResolvedByID = nil
+		comment.ResolvedAt = nil
+	}
+	if err := s.db.WithContext(ctx).Save(comment).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
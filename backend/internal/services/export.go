@@ -0,0 +1,511 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	ExportDir       = "exports"
+	ExportTTL       = 24 * time.Hour
+	exportBatchSize = 500
+
+	// ExportStaleAfter is how long an unfinished export may go without
+	// progress before it is taken for lost.
+	ExportStaleAfter = 15 * time.Minute
+)
+
+type ExportService struct {
+	db *gorm.DB
+}
+
+func NewExportService(db *gorm.DB) *ExportService {
+	return &ExportService{db: db}
+}
+
+type exportedUser struct {
+	ID       uuid.UUID `json:"id"`
+	Username *string   `json:"username,omitempty"`
+}
+
+type exportedSnippet struct {
+	Language models.CodeLanguage `json:"language"`
+	FileName *string             `json:"file_name,omitempty"`
+	Code     string              `json:"code"`
+}
+
+type exportedMessage struct {
+	ID          uuid.UUID          `json:"id"`
+	Sender      *exportedUser      `json:"sender,omitempty"`
+	MessageType models.MessageType `json:"message_type"`
+	Content     string             `json:"content"`
+	ReplyToID   *uuid.UUID         `json:"reply_to_id,omitempty"`
+	TopicID     *uuid.UUID         `json:"topic_id,omitempty"`
+	MediaFile   *string            `json:"media_file,omitempty"`
+	MediaSize   *int64             `json:"media_size,omitempty"`
+	Code        *exportedSnippet   `json:"code,omitempty"`
+	IsEdited    bool               `json:"is_edited"`
+	EditedAt    *time.Time         `json:"edited_at,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// exportedChat is the head of messages.json. The messages are streamed
+// after it, so a large chat is never held in memory.
+type exportedChat struct {
+	ID           uuid.UUID       `json:"id"`
+	Type         models.ChatType `json:"type"`
+	Name         *string         `json:"name,omitempty"`
+	ExportedAt   time.Time       `json:"exported_at"`
+	ExportedBy   uuid.UUID       `json:"exported_by"`
+	Members      []exportedUser  `json:"members"`
+	MessageCount int64           `json:"message_count"`
+}
+
+// Run builds the archive for a pending export. It is meant to be started in
+// its own goroutine; failures, panics included, are recorded on the export
+// row.
+func (s *ExportService) Run(exportID uuid.UUID) {
+	var export models.ChatExport
+	if err := s.db.First(&export, exportID).Error; err != nil {
+		log.Printf("Export: failed to load export %s: %v", exportID, err)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.fail(&export, fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	if err := s.build(&export); err != nil {
+		s.fail(&export, err)
+	}
+}
+
+func (s *ExportService) fail(export *models.ChatExport, err error) {
+	log.Printf("Export: export %s failed: %v", export.ID, err)
+	msg := err.Error()
+	s.db.Model(export).Updates(map[string]interface{}{
+		"status": models.ExportStatusFailed,
+		"error":  &msg,
+	})
+}
+
+// FailStaleExports marks unfinished exports that made no progress for
+// ExportStaleAfter as failed. Their job is gone, usually because the server
+// restarted while it ran, and they would otherwise block new exports of the
+// chat.
+func (s *ExportService) FailStaleExports() (int64, error) {
+	msg := "Export was interrupted"
+	result := s.db.Model(&models.ChatExport{}).
+		Where("status IN ? AND COALESCE(updated_at, created_at) < ?",
+			[]models.ExportStatus{models.ExportStatusPending, models.ExportStatusRunning},
+			time.Now().Add(-ExportStaleAfter)).
+		Updates(map[string]interface{}{
+			"status": models.ExportStatusFailed,
+			"error":  &msg,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// build writes the archive batch by batch. Media files go into the archive
+// as they come; the JSON and HTML renderings of the messages are spooled to
+// temporary files and copied in at the end, since a ZIP entry has to be
+// written in one go.
+func (s *ExportService) build(export *models.ChatExport) error {
+	var chat models.Chat
+	if err := s.db.Preload("Members.User").First(&chat, export.ChatID).Error; err != nil {
+		return fmt.Errorf("load chat: %w", err)
+	}
+
+	var total int64
+	if err := s.db.Model(&models.Message{}).
+		Where("chat_id = ? AND is_deleted = ?", chat.ID, false).
+		Count(&total).Error; err != nil {
+		return fmt.Errorf("count messages: %w", err)
+	}
+
+	if err := s.db.Model(export).Updates(map[string]interface{}{
+		"status":         models.ExportStatusRunning,
+		"total_messages": total,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(ExportDir, 0755); err != nil {
+		return fmt.Errorf("create export directory: %w", err)
+	}
+
+	finalPath := filepath.Join(ExportDir, export.ID.String()+".zip")
+	tmpPath := finalPath + ".tmp"
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("create archive: %w", err)
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	jsonSpool, err := newSpool(export.ID, "json")
+	if err != nil {
+		return err
+	}
+	defer jsonSpool.Close()
+	htmlSpool, err := newSpool(export.ID, "html")
+	if err != nil {
+		return err
+	}
+	defer htmlSpool.Close()
+
+	archive := zip.NewWriter(file)
+
+	doc := exportedChat{
+		ID:         chat.ID,
+		Type:       chat.Type,
+		Name:       chat.Name,
+		ExportedAt: time.Now(),
+		ExportedBy: export.RequestedByID,
+		Members:    make([]exportedUser, 0, len(chat.Members)),
+	}
+	for _, member := range chat.Members {
+		if member.User != nil {
+			doc.Members = append(doc.Members, toExportedUser(member.User))
+		}
+	}
+
+	var processed int64
+	var batch []models.Message
+	err = s.db.Preload("Sender").
+		Where("chat_id = ? AND is_deleted = ?", chat.ID, false).
+		Order("created_at ASC, id ASC").
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			snippets, err := s.loadSnippets(batch)
+			if err != nil {
+				return err
+			}
+
+			for i := range batch {
+				entry := toExportedMessage(&batch[i], snippets)
+				if export.IncludeMedia && batch[i].MediaURL != nil {
+					if name, err := addMediaFile(archive, &batch[i]); err != nil {
+						log.Printf("Export: skipping media of message %s: %v", batch[i].ID, err)
+					} else {
+						entry.MediaFile = &name
+					}
+				}
+				if err := writeJSONMessage(jsonSpool, &entry, processed+int64(i) == 0); err != nil {
+					return err
+				}
+				if err := exportTemplate.ExecuteTemplate(htmlSpool, "message", &entry); err != nil {
+					return fmt.Errorf("write index.html: %w", err)
+				}
+			}
+
+			processed += int64(len(batch))
+			return s.db.Model(export).Update("processed_messages", processed).Error
+		}).Error
+	if err != nil {
+		return fmt.Errorf("export messages: %w", err)
+	}
+
+	doc.MessageCount = processed
+	if err := writeJSON(archive, &doc, jsonSpool); err != nil {
+		return err
+	}
+	if err := writeHTML(archive, &doc, htmlSpool); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("finalize archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("finalize archive: %w", err)
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return fmt.Errorf("store archive: %w", err)
+	}
+
+	info, err := os.Stat(finalPath)
+	if err != nil {
+		return fmt.Errorf("store archive: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ExportTTL)
+	size := info.Size()
+	// FailStaleExports may have given up on the export meanwhile, and a new
+	// export of the chat may be running; a failed export stays failed.
+	result := s.db.Model(export).Where("status = ?", models.ExportStatusRunning).Updates(map[string]interface{}{
+		"status":             models.ExportStatusCompleted,
+		"processed_messages": processed,
+		"file_path":          &finalPath,
+		"file_size":          &size,
+		"completed_at":       &now,
+		"expires_at":         &expiresAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		log.Printf("Export: export %s was given up while it ran, dropping its archive", export.ID)
+		os.Remove(finalPath)
+	}
+	return nil
+}
+
+func (s *ExportService) loadSnippets(messages []models.Message) (map[uuid.UUID]*models.CodeSnippet, error) {
+	ids := make([]uuid.UUID, 0)
+	for _, msg := range messages {
+		if msg.MessageType == models.MessageTypeCode {
+			ids = append(ids, msg.ID)
+		}
+	}
+
+	snippets := make(map[uuid.UUID]*models.CodeSnippet, len(ids))
+	if len(ids) == 0 {
+		return snippets, nil
+	}
+
+	var found []models.CodeSnippet
+	if err := s.db.Where("message_id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("load code snippets: %w", err)
+	}
+	for i := range found {
+		snippets[found[i].MessageID] = &found[i]
+	}
+	return snippets, nil
+}
+
+func toExportedUser(user *models.User) exportedUser {
+	return exportedUser{
+		ID:       user.ID,
+		Username: user.Username,
+	}
+}
+
+func toExportedMessage(msg *models.Message, snippets map[uuid.UUID]*models.CodeSnippet) exportedMessage {
+	entry := exportedMessage{
+		ID:          msg.ID,
+		MessageType: msg.MessageType,
+		Content:     msg.Content,
+		ReplyToID:   msg.ReplyToID,
+		TopicID:     msg.TopicID,
+		MediaSize:   msg.MediaSize,
+		IsEdited:    msg.IsEdited,
+		CreatedAt:   msg.CreatedAt,
+	}
+
+	if msg.Sender != nil {
+		sender := toExportedUser(msg.Sender)
+		entry.Sender = &sender
+	}
+	if msg.IsEdited {
+		editedAt := msg.UpdatedAt
+		entry.EditedAt = &editedAt
+	}
+	if snippet, ok := snippets[msg.ID]; ok {
+		entry.Code = &exportedSnippet{
+			Language: snippet.Language,
+			FileName: snippet.FileName,
+			Code:     snippet.Code,
+		}
+	}
+
+	return entry
+}
+
+// addMediaFile copies the message attachment into the archive under media/
+// and returns its path inside the archive.
+func addMediaFile(archive *zip.Writer, msg *models.Message) (string, error) {
+	src, err := openMediaFile(*msg.MediaURL)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	name := "media/" + msg.ID.String() + "_" + filepath.Base(*msg.MediaURL)
+	dst, err := archive.Create(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// openMediaFile resolves a stored media path, which is either usable as-is or
+// relative to the uploads directory.
+func openMediaFile(mediaPath string) (*os.File, error) {
+	if f, err := os.Open(mediaPath); err == nil {
+		return f, nil
+	}
+	return os.Open(filepath.Join("uploads", mediaPath))
+}
+
+// spool is a temporary file that removes itself when closed.
+type spool struct {
+	*os.File
+}
+
+func newSpool(exportID uuid.UUID, ext string) (*spool, error) {
+	f, err := os.CreateTemp(ExportDir, exportID.String()+"-*."+ext)
+	if err != nil {
+		return nil, fmt.Errorf("create spool file: %w", err)
+	}
+	return &spool{f}, nil
+}
+
+func (s *spool) Close() error {
+	err := s.File.Close()
+	os.Remove(s.Name())
+	return err
+}
+
+// copyTo writes the spooled content to w.
+func (s *spool) copyTo(w io.Writer) error {
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, s)
+	return err
+}
+
+// writeJSONMessage spools one element of the messages array, indented as
+// it will sit in messages.json.
+func writeJSONMessage(w io.Writer, entry *exportedMessage, first bool) error {
+	data, err := json.MarshalIndent(entry, "    ", "  ")
+	if err != nil {
+		return fmt.Errorf("write messages.json: %w", err)
+	}
+	sep := ",\n    "
+	if first {
+		sep = "\n    "
+	}
+	if _, err := io.WriteString(w, sep); err != nil {
+		return fmt.Errorf("write messages.json: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("write messages.json: %w", err)
+	}
+	return nil
+}
+
+// writeJSON writes messages.json: the chat fields followed by the spooled
+// messages array.
+func writeJSON(archive *zip.Writer, doc *exportedChat, messages *spool) error {
+	w, err := archive.Create("messages.json")
+	if err != nil {
+		return fmt.Errorf("write messages.json: %w", err)
+	}
+
+	head, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("write messages.json: %w", err)
+	}
+	// Reopen the object to append the messages after the other fields.
+	head = head[:len(head)-2]
+	if _, err := io.WriteString(w, string(head)+",\n  \"messages\": ["); err != nil {
+		return fmt.Errorf("write messages.json: %w", err)
+	}
+	if err := messages.copyTo(w); err != nil {
+		return fmt.Errorf("write messages.json: %w", err)
+	}
+	tail := "\n  ]\n}\n"
+	if doc.MessageCount == 0 {
+		tail = "]\n}\n"
+	}
+	if _, err := io.WriteString(w, tail); err != nil {
+		return fmt.Errorf("write messages.json: %w", err)
+	}
+	return nil
+}
+
+// writeHTML writes index.html around the spooled messages.
+func writeHTML(archive *zip.Writer, doc *exportedChat, messages *spool) error {
+	w, err := archive.Create("index.html")
+	if err != nil {
+		return fmt.Errorf("write index.html: %w", err)
+	}
+	if err := exportTemplate.ExecuteTemplate(w, "head", doc); err != nil {
+		return fmt.Errorf("write index.html: %w", err)
+	}
+	if err := messages.copyTo(w); err != nil {
+		return fmt.Errorf("write index.html: %w", err)
+	}
+	if err := exportTemplate.ExecuteTemplate(w, "foot", doc); err != nil {
+		return fmt.Errorf("write index.html: %w", err)
+	}
+	return nil
+}
+
+var exportTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"senderName": func(u *exportedUser) string {
+		if u == nil {
+			return "Deleted account"
+		}
+		if u.Username != nil && *u.Username != "" {
+			return *u.Username
+		}
+		return "User " + u.ID.String()[:8]
+	},
+	"chatTitle": func(doc *exportedChat) string {
+		if doc.Name != nil && *doc.Name != "" {
+			return *doc.Name
+		}
+		return "Direct messages"
+	},
+	"isImage": func(t models.MessageType) bool { return t == models.MessageTypeImage },
+	"isVideo": func(t models.MessageType) bool { return t == models.MessageTypeVideo },
+	"isAudio": func(t models.MessageType) bool { return t == models.MessageTypeAudio },
+	"formatTime": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04:05 UTC")
+	},
+}).Parse(`{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{chatTitle .}} — export</title>
+<style>
+body{font-family:-apple-system,Segoe UI,Roboto,sans-serif;background:#f4f5f7;color:#1c1e21;margin:0;padding:24px}
+header,.message{max-width:820px;margin:0 auto 12px;background:#fff;border-radius:8px;padding:12px 16px;box-shadow:0 1px 2px rgba(0,0,0,.08)}
+.meta{color:#65676b;font-size:12px;margin-bottom:4px}
+.sender{font-weight:600;color:#1c1e21;margin-right:8px}
+.reply{display:block;border-left:3px solid #4a90d9;padding-left:8px;color:#4a90d9;font-size:12px;margin-bottom:4px;text-decoration:none}
+.content{white-space:pre-wrap;word-wrap:break-word}
+pre{background:#1e1e1e;color:#d4d4d4;padding:12px;border-radius:6px;overflow-x:auto}
+img,video{max-width:100%;border-radius:6px;margin-top:6px}
+</style>
+</head>
+<body>
+<header>
+<h1>{{chatTitle .}}</h1>
+<div class="meta">Exported {{formatTime .ExportedAt}} · {{.MessageCount}} messages · {{len .Members}} members</div>
+</header>
+{{end}}{{define "message"}}
+<div class="message" id="msg-{{.ID}}">
+<div class="meta"><span class="sender">{{senderName .Sender}}</span>{{formatTime .CreatedAt}}{{if .IsEdited}} · edited{{end}}</div>
+{{if .ReplyToID}}<a class="reply" href="#msg-{{.ReplyToID}}">In reply to a message</a>{{end}}
+{{if .Code}}<div class="meta">{{.Code.Language}}{{if .Code.FileName}} · {{.Code.FileName}}{{end}}</div><pre><code>{{.Code.Code}}</code></pre>
+{{else}}<div class="content">{{.Content}}</div>{{end}}
+{{if .MediaFile}}{{if isImage .MessageType}}<img src="{{.MediaFile}}" alt="">
+{{else if isVideo .MessageType}}<video src="{{.MediaFile}}" controls></video>
+{{else if isAudio .MessageType}}<audio src="{{.MediaFile}}" controls></audio>
+{{else}}<a href="{{.MediaFile}}">Attachment</a>{{end}}{{end}}
+</div>
+{{end}}{{define "foot"}}
+</body>
+</html>
+{{end}}`))
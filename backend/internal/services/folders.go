@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+)
+
+// FolderUnread holds the unread totals of the chats inside a folder.
+type FolderUnread struct {
+	UnreadCount int64
+	UnreadChats int
+}
+
+// GetUserChatsInFolder lists the user's chats, archived ones included, that the folder selects.
+func (s *ChatService) GetUserChatsInFolder(ctx context.Context, userID uuid.UUID, folder *models.ChatFolder) ([]models.ChatWithLastMessageResponse, error) {
+	chats, err := s.getAllUserChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	contacts := s.getContactIDs(userID)
+
+	result := make([]models.ChatWithLastMessageResponse, 0, len(chats))
+	for i := range chats {
+		if folder.Matches(&chats[i], isDMWithContact(&chats[i], userID, contacts)) {
+			result = append(result, chats[i])
+		}
+	}
+
+	return result, nil
+}
+
+// GetFolderUnreadCounts computes per-folder unread totals over one load of the chat list.
+func (s *ChatService) GetFolderUnreadCounts(ctx context.Context, userID uuid.UUID, folders []models.ChatFolder) (map[uuid.UUID]FolderUnread, error) {
+	chats, err := s.getAllUserChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	contacts := s.getContactIDs(userID)
+
+	totals := make(map[uuid.UUID]FolderUnread, len(folders))
+	for _, folder := range folders {
+		var unread FolderUnread
+		for i := range chats {
+			if chats[i].UnreadCount == 0 && !chats[i].MarkedUnread {
+				continue
+			}
+			if folder.Matches(&chats[i], isDMWithContact(&chats[i], userID, contacts)) {
+				unread.UnreadCount += chats[i].UnreadCount
+				unread.UnreadChats++
+			}
+		}
+		totals[folder.ID] = unread
+	}
+
+	return totals, nil
+}
+
+func (s *ChatService) getAllUserChats(ctx context.Context, userID uuid.UUID) ([]models.ChatWithLastMessageResponse, error) {
+	active, err := s.GetUserChatsWithLastMessage(ctx, userID, ChatListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	archived, err := s.GetUserChatsWithLastMessage(ctx, userID, ChatListOptions{Archived: true})
+	if err != nil {
+		return nil, err
+	}
+
+	// Archived chats take their place among the others, as if the list
+	// had been loaded in one go.
+	chats := append(active, archived...)
+	sortChatList(chats)
+	return chats, nil
+}
+
+func (s *ChatService) getContactIDs(userID uuid.UUID) map[uuid.UUID]bool {
+	var contacts []models.Contact
+	s.db.Where("user_id = ?", userID).Find(&contacts)
+
+	ids := make(map[uuid.UUID]bool, len(contacts))
+	for _, contact := range contacts {
+		ids[contact.ContactID] = true
+	}
+	return ids
+}
+
+func isDMWithContact(chat *models.ChatWithLastMessageResponse, userID uuid.UUID, contacts map[uuid.UUID]bool) bool {
+	if chat.Type != models.ChatTypeDM {
+		return false
+	}
+	for _, member := range chat.Members {
+		if member.UserID != userID && contacts[member.UserID] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+|\b[a-z0-9-]+(\.[a-z0-9-]+)*\.(com|net|org|io|me|ru|dev|app|info|xyz)\b`)
+
+// PostingRestrictedError is returned when a chat's restrictions reject a message.
+// RetryAfter is set when the member only has to wait out slow mode.
+type PostingRestrictedError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *PostingRestrictedError) Error() string {
+	return e.Reason
+}
+
+// ContainsLink reports whether text contains something that looks like a URL.
+func ContainsLink(text string) bool {
+	return linkPattern.MatchString(text)
+}
+
+// CheckPostingAllowed enforces read-only sanctions, the chat's posting
+// restrictions and slow mode for member. Admins bypass the chat-wide ones.
+// On success the member's slow-mode slot is consumed, so call it only right
+// before the message is stored, and call ReleaseSlowMode if storing fails.
+func (s *ChatService) CheckPostingAllowed(ctx context.Context, member *models.ChatMember, messageType models.MessageType, content string) error {
+	if err := s.CheckReadOnly(ctx, member.ChatID, member.UserID); err != nil {
+		return err
+	}
+
+	if member.Role == models.MemberRoleAdmin {
+		return nil
+	}
+
+	var chat models.Chat
+	if err := s.db.Select("id", "slow_mode_seconds", "admins_only", "no_media", "no_links").
+		First(&chat, member.ChatID).Error; err != nil {
+		return err
+	}
+	restrictions := chat.Restrictions
+
+	if restrictions.AdminsOnly {
+		return &PostingRestrictedError{Reason: "Only admins can post in this chat"}
+	}
+
+	if restrictions.NoMedia && isMediaMessage(messageType) {
+		return &PostingRestrictedError{Reason: "Media messages are not allowed in this chat"}
+	}
+
+	if restrictions.NoLinks && ContainsLink(content) {
+		return &PostingRestrictedError{Reason: "Links are not allowed in this chat"}
+	}
+
+	if restrictions.SlowModeSeconds > 0 {
+		interval := time.Duration(restrictions.SlowModeSeconds) * time.Second
+		wait, err := s.claimSlowMode(ctx, member, interval)
+		if err != nil {
+			return err
+		}
+		if wait > 0 {
+			return &PostingRestrictedError{
+				Reason:     fmt.Sprintf("Slow mode is enabled: wait %d seconds before sending another message", int(wait.Seconds())),
+				RetryAfter: wait,
+			}
+		}
+	}
+
+	return nil
+}
+
+// claimSlowMode claims the member's slow-mode slot. It returns zero when the
+// member may post now, otherwise how long they still have to wait. The slot
+// is kept in Redis so the cooldown is shared by every API instance.
+func (s *ChatService) claimSlowMode(ctx context.Context, member *models.ChatMember, interval time.Duration) (time.Duration, error) {
+	key := slowModeKey(member)
+
+	claimed, err := s.redis.SetNX(ctx, key, 1, interval).Result()
+	if err != nil {
+		return 0, err
+	}
+	if claimed {
+		return 0, nil
+	}
+
+	ttl, err := s.redis.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		ttl = time.Second
+	}
+	return ttl, nil
+}
+
+// CheckReadOnly returns a PostingRestrictedError while the user is under a
+// read-only sanction in the chat. It is the part of CheckPostingAllowed that
+// also covers writing that isn't a message, such as code review comments.
+func (s *ChatService) CheckReadOnly(ctx context.Context, chatID, userID uuid.UUID) error {
+	readOnly, err := s.ActiveSanction(ctx, chatID, userID, models.SanctionTypeReadOnly)
+	if err != nil {
+		return err
+	}
+	if readOnly != nil {
+		reason := "You are restricted from posting in this chat"
+		if readOnly.ExpiresAt != nil {
+			reason += " until " + readOnly.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		return &PostingRestrictedError{Reason: reason}
+	}
+	return nil
+}
+
+// ReleaseSlowMode gives back the slow-mode slot CheckPostingAllowed claimed
+// for a message that could not be stored, so the member can try again.
+func (s *ChatService) ReleaseSlowMode(ctx context.Context, member *models.ChatMember) {
+	if member.Role == models.MemberRoleAdmin {
+		return
+	}
+	if err := s.redis.Del(ctx, slowModeKey(member)).Err(); err != nil {
+		log.Printf("Error releasing slow mode slot: %v", err)
+	}
+}
+
+func slowModeKey(member *models.ChatMember) string {
+	return fmt.Sprintf("ratelimit:slowmode:%s:%s", member.ChatID, member.UserID)
+}
+
+func isMediaMessage(messageType models.MessageType) bool {
+	switch messageType {
+	case models.MessageTypeImage, models.MessageTypeVideo, models.MessageTypeAudio, models.MessageTypeFile:
+		return true
+	}
+	return false
+}
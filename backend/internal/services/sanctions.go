@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ActiveSanction returns the user's sanction of the given type in a chat that is
+// still in force, or nil when there is none.
+func (s *ChatService) ActiveSanction(ctx context.Context, chatID, userID uuid.UUID, sanctionType models.SanctionType) (*models.ChatSanction, error) {
+	var sanction models.ChatSanction
+	err := s.db.Where("chat_id = ? AND user_id = ? AND type = ? AND is_active = ?", chatID, userID, sanctionType, true).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		First(&sanction).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sanction, nil
+}
+
+// LiftSanctions deactivates every active sanction of the given type for the user in a chat.
+func (s *ChatService) LiftSanctions(ctx context.Context, chatID, userID uuid.UUID, sanctionType models.SanctionType) (int64, error) {
+	result := s.db.Model(&models.ChatSanction{}).
+		Where("chat_id = ? AND user_id = ? AND type = ? AND is_active = ?", chatID, userID, sanctionType, true).
+		Updates(map[string]interface{}{
+			"is_active": false,
+			"lifted_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrReplyNotInThread = errors.New("reply target is not in this thread")
+
+// ResolveThreadRoot returns the thread a reply belongs to. Replying to a
+// channel post's root message or to any message in its thread keeps the
+// reply in that thread; other replies are not threaded.
+func (s *ChatService) ResolveThreadRoot(ctx context.Context, chatID uuid.UUID, replyToID *uuid.UUID) (*uuid.UUID, error) {
+	if replyToID == nil {
+		return nil, nil
+	}
+
+	var target models.Message
+	if err := s.db.Select("id", "chat_id", "thread_root_id", "channel_post_id").
+		Where("id = ? AND chat_id = ?", *replyToID, chatID).
+		First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if target.ThreadRootID != nil {
+		return target.ThreadRootID, nil
+	}
+	if target.ChannelPostID != nil {
+		return &target.ID, nil
+	}
+	return nil, nil
+}
+
+// JoinDiscussion makes the user a member of a channel's discussion group so
+// they can comment. Banned users are refused with a PostingRestrictedError.
+func (s *ChatService) JoinDiscussion(ctx context.Context, chatID, userID uuid.UUID) (*models.ChatMember, error) {
+	var member models.ChatMember
+	err := s.db.Where("chat_id = ? AND user_id = ?", chatID, userID).First(&member).Error
+	if err == nil {
+		return &member, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	ban, err := s.ActiveSanction(ctx, chatID, userID, models.SanctionTypeBan)
+	if err != nil {
+		return nil, err
+	}
+	if ban != nil {
+		return nil, &PostingRestrictedError{Reason: "You are banned from the discussion group"}
+	}
+
+	member = models.ChatMember{
+		ChatID: chatID,
+		UserID: userID,
+		Role:   models.MemberRoleMember,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Chat{}).
+			Where("id = ?", chatID).
+			Update("member_count", gorm.Expr("member_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.InvalidateUserChatsCache(ctx, userID)
+	return &member, nil
+}
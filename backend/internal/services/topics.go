@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrTopicNotFound = errors.New("topic not found")
+
+// SetForumMode switches a group chat in or out of topics mode. Enabling it
+// creates the general topic on first use and moves every message without a
+// topic into it; disabling it keeps topics so they come back when re-enabled.
+func (s *ChatService) SetForumMode(ctx context.Context, chatID, userID uuid.UUID, enabled bool) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Chat{}).Where("id = ?", chatID).Update("is_forum", enabled).Error; err != nil {
+			return err
+		}
+		if !enabled {
+			return nil
+		}
+
+		var general models.ChatTopic
+		err := tx.Where("chat_id = ? AND is_general = ?", chatID, true).First(&general).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			general = models.ChatTopic{
+				ChatID:      chatID,
+				Name:        models.GeneralTopicName,
+				IsGeneral:   true,
+				CreatedByID: &userID,
+			}
+			err = tx.Create(&general).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Message{}).
+			Where("chat_id = ? AND topic_id IS NULL", chatID).
+			Update("topic_id", general.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidateChatMembersCache(ctx, chatID)
+	return nil
+}
+
+// ResolveMessageTopic picks the topic a new message goes to. Outside forum
+// mode messages carry no topic; inside it a missing topic means the general
+// one. Only admins may post into closed topics.
+func (s *ChatService) ResolveMessageTopic(ctx context.Context, member *models.ChatMember, topicID *string) (*uuid.UUID, error) {
+	var chat models.Chat
+	if err := s.db.Select("id", "is_forum").First(&chat, member.ChatID).Error; err != nil {
+		return nil, err
+	}
+	if !chat.IsForum {
+		return nil, nil
+	}
+
+	query := s.db.Where("chat_id = ?", chat.ID)
+	if topicID != nil && *topicID != "" {
+		tid, err := uuid.Parse(*topicID)
+		if err != nil {
+			return nil, ErrTopicNotFound
+		}
+		query = query.Where("id = ?", tid)
+	} else {
+		query = query.Where("is_general = ?", true)
+	}
+
+	var topic models.ChatTopic
+	if err := query.First(&topic).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTopicNotFound
+		}
+		return nil, err
+	}
+
+	if topic.IsClosed && member.Role != models.MemberRoleAdmin {
+		return nil, &PostingRestrictedError{Reason: "This topic is closed"}
+	}
+
+	return &topic.ID, nil
+}
+
+// GetTopicsWithUnread lists the chat's topics with the user's unread count
+// in each: the general topic first, then by latest activity.
+func (s *ChatService) GetTopicsWithUnread(ctx context.Context, chatID, userID uuid.UUID) ([]models.ChatTopicResponse, error) {
+	var topics []models.ChatTopic
+	if err := s.db.Where("chat_id = ?", chatID).
+		Order("is_general DESC").
+		Order("last_message_at DESC NULLS LAST").
+		Order("created_at DESC").
+		Find(&topics).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		TopicID uuid.UUID
+		Count   int64
+	}
+	if err := s.topicUnreadQuery(chatID, userID).
+		Select("messages.topic_id AS topic_id, COUNT(*) AS count").
+		Group("messages.topic_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	unread := make(map[uuid.UUID]int64, len(counts))
+	for _, c := range counts {
+		unread[c.TopicID] = c.Count
+	}
+
+	responses := make([]models.ChatTopicResponse, len(topics))
+	for i, topic := range topics {
+		responses[i] = models.ChatTopicResponse{
+			ChatTopic:   topic,
+			UnreadCount: unread[topic.ID],
+		}
+	}
+	return responses, nil
+}
+
+// MarkTopicRead records that the user has read the topic up to now.
+func (s *ChatService) MarkTopicRead(ctx context.Context, topicID, userID uuid.UUID) error {
+	read := models.ChatTopicRead{
+		TopicID:    topicID,
+		UserID:     userID,
+		LastReadAt: time.Now(),
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "topic_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_read_at"}),
+	}).Create(&read).Error; err != nil {
+		return err
+	}
+
+	s.InvalidateUserChatsCache(ctx, userID)
+	return nil
+}
+
+// topicUnreadQuery selects the messages of a forum chat the user has not read,
+// judged per topic with the chat-wide LastReadAt as fallback.
+func (s *ChatService) topicUnreadQuery(chatID, userID uuid.UUID) *gorm.DB {
+	return s.db.Model(&models.Message{}).
+		Joins("JOIN chat_members cm ON messages.chat_id = cm.chat_id AND cm.user_id = ?", userID).
+		Joins("LEFT JOIN chat_topic_reads tr ON tr.topic_id = messages.topic_id AND tr.user_id = cm.user_id").
+		Where("messages.chat_id = ? AND messages.is_deleted = ? AND messages.sender_id != ?", chatID, false, userID).
+		Where("messages.created_at > COALESCE(tr.last_read_at, cm.last_read_at)")
+}
+
+func (s *ChatService) forumUnreadCount(chatID, userID uuid.UUID) int64 {
+	var count int64
+	s.topicUnreadQuery(chatID, userID).Count(&count)
+	return count
+}
+
+func (s *ChatService) latestTopic(chatID uuid.UUID) *models.ChatTopic {
+	var topic models.ChatTopic
+	if err := s.db.Where("chat_id = ? AND last_message_at IS NOT NULL", chatID).
+		Order("last_message_at DESC").
+		First(&topic).Error; err != nil {
+		return nil
+	}
+	return &topic
+}
+
+func (s *ChatService) invalidateChatMembersCache(ctx context.Context, chatID uuid.UUID) {
+	var userIDs []uuid.UUID
+	s.db.Model(&models.ChatMember{}).Where("chat_id = ?", chatID).Pluck("user_id", &userIDs)
+	for _, userID := range userIDs {
+		s.InvalidateUserChatsCache(ctx, userID)
+	}
+}
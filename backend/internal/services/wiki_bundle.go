@@ -0,0 +1,616 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+)
+
+const (
+	WikiBundleZip = "zip"
+	WikiBundleTar = "tar"
+
+	// MaxWikiBundleSize caps the Markdown read from one import.
+	MaxWikiBundleSize = 20 << 20
+
+	maxWikiBundleFiles    = 2000
+	maxWikiBundleFileSize = 1 << 20
+
+	wikiRevisionsDir = ".revisions"
+)
+
+var (
+	ErrInvalidWikiBundle  = errors.New("invalid wiki bundle")
+	ErrWikiBundleTooLarge = errors.New("wiki bundle is too large")
+	ErrDuplicateWikiSlug  = errors.New("duplicate page slug in wiki bundle")
+
+	bundleSlugPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,255}$`)
+	bundleSlugInvalid = regexp.MustCompile(`[^\p{L}\p{N}_-]+`)
+)
+
+// WikiBundleFile is one file of an uploaded bundle, with its path inside
+// the bundle.
+type WikiBundleFile struct {
+	Path string
+	Data []byte
+}
+
+// WikiBundlePage is a page read from a bundle. ParentSlug is empty for
+// top-level pages. Revisions holds the page's history from .revisions/,
+// oldest first.
+type WikiBundlePage struct {
+	Path        string
+	Slug        string
+	Title       string
+	Content     string
+	Order       int
+	IsPublished bool
+	ParentSlug  string
+	Revisions   []WikiBundleRevision
+}
+
+// WikiBundleRevision is one revision read from a bundle's .revisions/
+// history. AuthorID and CreatedAt are zero when the file does not give them.
+type WikiBundleRevision struct {
+	Number    int
+	Title     string
+	Content   string
+	Summary   string
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
+}
+
+// WriteWikiBundle writes the pages as Markdown files laid out like the page
+// tree: a page's children live in a directory named after its slug. With
+// revisions, every revision is written under .revisions/ as well.
+func WriteWikiBundle(w io.Writer, format string, pages []models.WikiPage, revisions map[uuid.UUID][]models.WikiRevision) error {
+	var add func(name string, data []byte) error
+	var closeArchive func() error
+
+	switch format {
+	case WikiBundleZip:
+		archive := zip.NewWriter(w)
+		add = func(name string, data []byte) error {
+			f, err := archive.Create(name)
+			if err != nil {
+				return err
+			}
+			_, err = f.Write(data)
+			return err
+		}
+		closeArchive = archive.Close
+	case WikiBundleTar:
+		gz := gzip.NewWriter(w)
+		archive := tar.NewWriter(gz)
+		now := time.Now()
+		add = func(name string, data []byte) error {
+			if err := archive.WriteHeader(&tar.Header{
+				Name:    name,
+				Mode:    0644,
+				Size:    int64(len(data)),
+				ModTime: now,
+			}); err != nil {
+				return err
+			}
+			_, err := archive.Write(data)
+			return err
+		}
+		closeArchive = func() error {
+			if err := archive.Close(); err != nil {
+				return err
+			}
+			return gz.Close()
+		}
+	default:
+		return fmt.Errorf("unknown bundle format %q", format)
+	}
+
+	for _, entry := range wikiBundlePaths(pages) {
+		page := entry.page
+		var buf bytes.Buffer
+		writeFrontMatter(&buf, [][2]string{
+			{"title", strconv.Quote(page.Title)},
+			{"slug", strconv.Quote(page.Slug)},
+			{"order", strconv.Itoa(page.Order)},
+			{"published", strconv.FormatBool(page.IsPublished)},
+		})
+		buf.WriteString(page.Content)
+		if err := add(entry.path, buf.Bytes()); err != nil {
+			return err
+		}
+
+		dir := path.Join(wikiRevisionsDir, strings.TrimSuffix(entry.path, ".md"))
+		for _, rev := range revisions[page.ID] {
+			buf.Reset()
+			writeFrontMatter(&buf, [][2]string{
+				{"title", strconv.Quote(rev.Title)},
+				{"revision", strconv.Itoa(rev.RevisionNumber)},
+				{"author", strconv.Quote(rev.CreatedByID.String())},
+				{"summary", strconv.Quote(rev.ChangeSummary)},
+				{"created_at", strconv.Quote(rev.CreatedAt.UTC().Format(time.RFC3339))},
+			})
+			buf.WriteString(rev.Content)
+			if err := add(path.Join(dir, strconv.Itoa(rev.RevisionNumber)+".md"), buf.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return closeArchive()
+}
+
+type bundleEntry struct {
+	path string
+	page *models.WikiPage
+}
+
+// wikiBundlePaths assigns each page its path in a bundle, parents before
+// children. Pages whose parent is not among pages are placed at the top.
+func wikiBundlePaths(pages []models.WikiPage) []bundleEntry {
+	byID := make(map[uuid.UUID]bool, len(pages))
+	for i := range pages {
+		byID[pages[i].ID] = true
+	}
+
+	children := make(map[uuid.UUID][]*models.WikiPage)
+	var roots []*models.WikiPage
+	for i := range pages {
+		page := &pages[i]
+		if page.ParentID != nil && byID[*page.ParentID] && *page.ParentID != page.ID {
+			children[*page.ParentID] = append(children[*page.ParentID], page)
+		} else {
+			roots = append(roots, page)
+		}
+	}
+
+	var entries []bundleEntry
+	visited := make(map[uuid.UUID]bool, len(pages))
+	var walk func(dir string, level []*models.WikiPage)
+	walk = func(dir string, level []*models.WikiPage) {
+		sort.SliceStable(level, func(i, j int) bool {
+			if level[i].Order != level[j].Order {
+				return level[i].Order < level[j].Order
+			}
+			return level[i].CreatedAt.Before(level[j].CreatedAt)
+		})
+		for _, page := range level {
+			if visited[page.ID] {
+				continue
+			}
+			visited[page.ID] = true
+			entries = append(entries, bundleEntry{path: path.Join(dir, page.Slug+".md"), page: page})
+			walk(path.Join(dir, page.Slug), children[page.ID])
+		}
+	}
+	walk("", roots)
+
+	// Pages caught in a parent cycle are unreachable from the roots.
+	var stranded []*models.WikiPage
+	for i := range pages {
+		if !visited[pages[i].ID] {
+			stranded = append(stranded, &pages[i])
+		}
+	}
+	walk("", stranded)
+
+	return entries
+}
+
+func writeFrontMatter(buf *bytes.Buffer, fields [][2]string) {
+	buf.WriteString("---\n")
+	for _, f := range fields {
+		buf.WriteString(f[0])
+		buf.WriteString(": ")
+		buf.WriteString(f[1])
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("---\n\n")
+}
+
+// ParseFrontMatter splits a leading "---" block of "key: value" lines from
+// the body. Quoted values are unquoted.
+func ParseFrontMatter(data string) (map[string]string, string) {
+	normalized := strings.ReplaceAll(strings.TrimPrefix(data, "\ufeff"), "\r\n", "\n")
+	if !strings.HasPrefix(normalized, "---\n") {
+		return nil, data
+	}
+
+	rest := normalized[len("---\n"):]
+	var block, body string
+	switch {
+	case strings.HasPrefix(rest, "---\n") || rest == "---":
+		body = strings.TrimPrefix(rest, "---")
+	case strings.Contains(rest, "\n---\n"):
+		i := strings.Index(rest, "\n---\n")
+		block, body = rest[:i], rest[i+len("\n---"):]
+	case strings.HasSuffix(rest, "\n---"):
+		block = strings.TrimSuffix(rest, "\n---")
+	default:
+		return nil, data
+	}
+
+	fields := make(map[string]string)
+	for _, line := range strings.Split(block, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+		fields[strings.TrimSpace(key)] = value
+	}
+
+	// Drop the line break ending the closing marker and the blank line
+	// that usually follows it.
+	body = strings.TrimPrefix(body, "\n")
+	body = strings.TrimPrefix(body, "\n")
+	return fields, body
+}
+
+// ExtractWikiBundle unpacks an uploaded file: a ZIP, a tar (optionally
+// gzipped) or a single Markdown file.
+func ExtractWikiBundle(name string, data []byte) ([]WikiBundleFile, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".markdown"):
+		if len(data) > maxWikiBundleFileSize {
+			return nil, ErrWikiBundleTooLarge
+		}
+		return []WikiBundleFile{{Path: name, Data: data}}, nil
+	case bytes.HasPrefix(data, []byte("PK")):
+		return extractZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidWikiBundle
+		}
+		defer gz.Close()
+		return extractTar(gz)
+	default:
+		return extractTar(bytes.NewReader(data))
+	}
+}
+
+func extractZip(data []byte) ([]WikiBundleFile, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrInvalidWikiBundle
+	}
+
+	var files []WikiBundleFile
+	total := 0
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || !isMarkdownPath(f.Name) {
+			continue
+		}
+		if len(files) >= maxWikiBundleFiles {
+			return nil, ErrWikiBundleTooLarge
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, ErrInvalidWikiBundle
+		}
+		content, err := readLimited(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		total += len(content)
+		if total > MaxWikiBundleSize {
+			return nil, ErrWikiBundleTooLarge
+		}
+		files = append(files, WikiBundleFile{Path: f.Name, Data: content})
+	}
+	return files, nil
+}
+
+func extractTar(r io.Reader) ([]WikiBundleFile, error) {
+	archive := tar.NewReader(r)
+
+	var files []WikiBundleFile
+	total := 0
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, ErrInvalidWikiBundle
+		}
+		if header.Typeflag != tar.TypeReg || !isMarkdownPath(header.Name) {
+			continue
+		}
+		if len(files) >= maxWikiBundleFiles {
+			return nil, ErrWikiBundleTooLarge
+		}
+		content, err := readLimited(archive)
+		if err != nil {
+			return nil, err
+		}
+		total += len(content)
+		if total > MaxWikiBundleSize {
+			return nil, ErrWikiBundleTooLarge
+		}
+		files = append(files, WikiBundleFile{Path: header.Name, Data: content})
+	}
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxWikiBundleFileSize+1))
+	if err != nil {
+		return nil, ErrInvalidWikiBundle
+	}
+	if len(content) > maxWikiBundleFileSize {
+		return nil, ErrWikiBundleTooLarge
+	}
+	return content, nil
+}
+
+func isMarkdownPath(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".markdown")
+}
+
+// ReadWikiBundle turns bundle files into pages. Directories give the page
+// tree: "setup/linux.md" is a child of "setup.md", or of "setup/index.md" or
+// "setup/README.md". Front-matter, when present, sets the title, slug, order
+// and published flag; otherwise the title comes from the first heading or
+// the file name. The .revisions history is attached to the pages it belongs
+// to; other hidden files are skipped.
+func ReadWikiBundle(files []WikiBundleFile) ([]WikiBundlePage, error) {
+	type item struct {
+		dir   string // directory holding the file
+		owner string // directory of the page's parent
+		key   string // directory this page stands for
+		base  string
+		index bool
+		data  []byte
+		path  string
+	}
+
+	var items []item
+	revisions := make(map[string][]WikiBundleRevision)
+	for _, f := range files {
+		clean := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(f.Path, "\\", "/")), "/")
+		if pagePath, number, ok := revisionPath(clean); ok {
+			revisions[pagePath] = append(revisions[pagePath], readBundleRevision(f.Data, number))
+			continue
+		}
+		if clean == "" || hidden(clean) {
+			continue
+		}
+		dir, file := path.Split(clean)
+		items = append(items, item{
+			dir:  strings.TrimSuffix(dir, "/"),
+			base: strings.TrimSuffix(file, path.Ext(file)),
+			data: f.Data,
+			path: clean,
+		})
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: no Markdown files found", ErrInvalidWikiBundle)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].path < items[j].path })
+
+	// A bundle zipped from a folder usually has one top-level directory
+	// holding everything; it is not a page of its own.
+	dirs := make([]string, len(items))
+	for i := range items {
+		dirs[i] = items[i].dir
+	}
+	if top := commonTopDir(dirs); top != "" {
+		for i := range items {
+			items[i].dir = strings.TrimPrefix(strings.TrimPrefix(items[i].dir, top), "/")
+		}
+	}
+
+	// index.md and README.md inside a directory stand for the directory's
+	// own page, unless a sibling file of the directory's name does.
+	dirPage := make(map[string]int)
+	for i := range items {
+		it := &items[i]
+		lower := strings.ToLower(it.base)
+		it.index = it.dir != "" && (lower == "index" || lower == "readme")
+		if it.index {
+			it.key = it.dir
+		} else {
+			it.key = path.Join(it.dir, it.base)
+		}
+		if prev, taken := dirPage[it.key]; !taken || items[prev].index {
+			dirPage[it.key] = i
+		}
+	}
+	for i := range items {
+		it := &items[i]
+		if it.index && dirPage[it.key] != i {
+			// Overridden by the sibling file: an ordinary page in the
+			// directory.
+			it.index = false
+			it.key = path.Join(it.dir, it.base)
+		}
+		it.owner = parentDir(it.key)
+	}
+
+	pages := make([]WikiBundlePage, len(items))
+	parents := make([]int, len(items))
+	seen := make(map[string]string)
+	siblings := make(map[string]int)
+	for i, it := range items {
+		fields, body := ParseFrontMatter(string(it.data))
+
+		slug := fields["slug"]
+		if slug == "" {
+			slug = path.Base(it.key)
+		}
+		slug = normalizeBundleSlug(slug)
+		if prev, dup := seen[slug]; dup {
+			return nil, fmt.Errorf("%w: %q (%s and %s)", ErrDuplicateWikiSlug, slug, prev, it.path)
+		}
+		seen[slug] = it.path
+
+		title := fields["title"]
+		if title == "" {
+			title = firstHeading(body)
+		}
+		if title == "" {
+			title = slug
+		}
+		title = truncateRunes(title, 255)
+
+		order, err := strconv.Atoi(fields["order"])
+		if err != nil {
+			order = siblings[it.owner]
+		}
+		siblings[it.owner]++
+
+		history := revisions[it.path]
+		sort.SliceStable(history, func(a, b int) bool { return history[a].Number < history[b].Number })
+
+		pages[i] = WikiBundlePage{
+			Path:        it.path,
+			Slug:        slug,
+			Title:       title,
+			Content:     body,
+			Order:       order,
+			IsPublished: fields["published"] != "false",
+			Revisions:   history,
+		}
+
+		// The parent is the page of the nearest enclosing directory that
+		// has one.
+		parents[i] = -1
+		for dir := it.owner; dir != ""; dir = parentDir(dir) {
+			if parent, ok := dirPage[dir]; ok && parent != i {
+				parents[i] = parent
+				break
+			}
+		}
+	}
+
+	for i, parent := range parents {
+		if parent >= 0 {
+			pages[i].ParentSlug = pages[parent].Slug
+		}
+	}
+	return pages, nil
+}
+
+func parentDir(p string) string {
+	dir := path.Dir(p)
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+func hidden(p string) bool {
+	for _, part := range strings.Split(p, "/") {
+		if strings.HasPrefix(part, ".") || strings.HasPrefix(part, "__MACOSX") {
+			return true
+		}
+	}
+	return false
+}
+
+func commonTopDir(dirs []string) string {
+	top := ""
+	for i, dir := range dirs {
+		first, _, _ := strings.Cut(dir, "/")
+		if first == "" || (i > 0 && first != top) {
+			return ""
+		}
+		top = first
+	}
+	return top
+}
+
+func normalizeBundleSlug(slug string) string {
+	slug = strings.TrimSpace(slug)
+	if !bundleSlugPattern.MatchString(slug) {
+		slug = strings.Trim(bundleSlugInvalid.ReplaceAllString(slug, "-"), "-")
+		if slug == "" {
+			slug = "page"
+		}
+	}
+	return truncateRunes(UnreservedWikiSlug(slug), 255)
+}
+
+// revisionPath recognizes a file of the .revisions history, written as
+// .revisions/<page path without .md>/<n>.md, and returns the path of the
+// page it belongs to and the revision number from the file name.
+func revisionPath(p string) (string, int, bool) {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if part != wikiRevisionsDir {
+			continue
+		}
+		rest := parts[i+1:]
+		if len(rest) < 2 || !isMarkdownPath(rest[len(rest)-1]) {
+			return "", 0, false
+		}
+		file := rest[len(rest)-1]
+		number, err := strconv.Atoi(strings.TrimSuffix(file, path.Ext(file)))
+		if err != nil {
+			return "", 0, false
+		}
+		page := path.Join(append(parts[:i:i], rest[:len(rest)-1]...)...) + ".md"
+		return page, number, true
+	}
+	return "", 0, false
+}
+
+func readBundleRevision(data []byte, number int) WikiBundleRevision {
+	fields, body := ParseFrontMatter(string(data))
+	rev := WikiBundleRevision{
+		Number:  number,
+		Title:   truncateRunes(fields["title"], 255),
+		Content: body,
+		Summary: fields["summary"],
+	}
+	if n, err := strconv.Atoi(fields["revision"]); err == nil {
+		rev.Number = n
+	}
+	if id, err := uuid.Parse(fields["author"]); err == nil {
+		rev.AuthorID = id
+	}
+	if at, err := time.Parse(time.RFC3339, fields["created_at"]); err == nil {
+		rev.CreatedAt = at
+	}
+	return rev
+}
+
+// truncateRunes cuts s to at most max characters without splitting one.
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
+
+func firstHeading(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "# ") {
+			return strings.TrimSpace(trimmed[2:])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/pkg/markdown"
+	"gorm.io/gorm"
+)
+
+// AppendWikiRevision records the page's current title and content as its
+// next revision.
+func AppendWikiRevision(tx *gorm.DB, page *models.WikiPage, userID uuid.UUID, summary string) error {
+	var lastRevision models.WikiRevision
+	tx.Where("page_id = ?", page.ID).Order("revision_number DESC").First(&lastRevision)
+
+	revision := models.WikiRevision{
+		PageID:         page.ID,
+		Title:          page.Title,
+		Content:        page.Content,
+		CreatedByID:    userID,
+		ChangeSummary:  summary,
+		RevisionNumber: lastRevision.RevisionNumber + 1,
+	}
+	return tx.Create(&revision).Error
+}
+
+// SyncWikiLinks replaces the page's outgoing links with those found in its
+// current content.
+func SyncWikiLinks(tx *gorm.DB, page *models.WikiPage) error {
+	if err := tx.Where("from_page_id = ?", page.ID).Delete(&models.WikiLink{}).Error; err != nil {
+		return err
+	}
+
+	var links []models.WikiLink
+	for _, target := range markdown.WikiLinks(page.Content) {
+		if len(target) > 255 {
+			continue
+		}
+		links = append(links, models.WikiLink{
+			FromPageID: page.ID,
+			ToSlug:     target,
+			ChannelID:  page.ChannelID,
+		})
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	return tx.Create(&links).Error
+}
+
+// LinkingWikiPages lists the pages of the channel that link to slug.
+func LinkingWikiPages(db *gorm.DB, channelID uuid.UUID, slug string) ([]models.WikiPage, error) {
+	var pages []models.WikiPage
+	err := db.Joins("JOIN wiki_links ON wiki_links.from_page_id = wiki_pages.id").
+		Where("wiki_links.channel_id = ? AND wiki_links.to_slug = ?", channelID, slug).
+		Order("wiki_pages.title ASC").
+		Find(&pages).Error
+	return pages, err
+}
+
+// RewriteWikiLinks points the [[oldSlug]] links of other pages at the
+// page's new slug, saving each changed page as a new revision by userID.
+// Pages the user may not edit, such as protected pages for anyone but a
+// wiki admin, are left alone and returned as skipped.
+func RewriteWikiLinks(tx *gorm.DB, page *models.WikiPage, oldSlug string, userID uuid.UUID, perms models.WikiPermissions) (rewritten, skipped []models.WikiPage, err error) {
+	pages, err := LinkingWikiPages(tx, page.ChannelID, oldSlug)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summary := fmt.Sprintf("Updated links to renamed page %s", page.Slug)
+	for i := range pages {
+		linking := &pages[i]
+		if !perms.CanEditPage(linking) {
+			skipped = append(skipped, *linking)
+			continue
+		}
+		content, count := markdown.RewriteWikiLinks(linking.Content, oldSlug, page.Slug)
+		if count == 0 {
+			continue
+		}
+		linking.Content = content
+		if err := tx.Save(linking).Error; err != nil {
+			return nil, nil, err
+		}
+		if err := AppendWikiRevision(tx, linking, userID, summary); err != nil {
+			return nil, nil, err
+		}
+		if err := SyncWikiLinks(tx, linking); err != nil {
+			return nil, nil, err
+		}
+		rewritten = append(rewritten, *linking)
+	}
+	return rewritten, skipped, nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+)
+
+// WikiPermissions resolves what the user may do in the channel's wiki. The
+// owner and admins with the wiki right hold every permission, readers of the
+// channel may view it, and active temporary roles on the channel add the
+// wiki permissions they list.
+func (s *ChannelService) WikiPermissions(ctx context.Context, channel *models.Channel, userID uuid.UUID) (models.WikiPermissions, error) {
+	if s.CanManageWiki(channel, userID) {
+		return models.FullWikiPermissions(), nil
+	}
+
+	perms := models.WikiPermissions{View: s.CanRead(channel, userID)}
+	roles, err := s.activeChannelRoles(ctx, userID, &channel.ID)
+	if err != nil {
+		return perms, err
+	}
+	for _, role := range roles {
+		perms.Grant(role.Permissions)
+	}
+	return perms, nil
+}
+
+// WikiChannels lists the channels whose wikis the user can search: those
+// they own, run or are subscribed to, plus any a temporary role lets them
+// view. editable are the ones among them whose drafts they can see.
+func (s *ChannelService) WikiChannels(ctx context.Context, userID uuid.UUID) (readable, editable []uuid.UUID, err error) {
+	db := s.db.WithContext(ctx)
+	wikiAdmins := db.Model(&models.ChannelAdmin{}).Select("channel_id").Where("user_id = ? AND can_manage_wiki = ?", userID, true)
+	subscriptions := db.Model(&models.ChannelSubscriber{}).Select("channel_id").Where("user_id = ?", userID)
+
+	if err := db.Model(&models.Channel{}).
+		Where("owner_id = ? OR id IN (?) OR id IN (?)", userID, subscriptions, wikiAdmins).
+		Pluck("id", &readable).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := db.Model(&models.Channel{}).
+		Where("owner_id = ? OR id IN (?)", userID, wikiAdmins).
+		Pluck("id", &editable).Error; err != nil {
+		return nil, nil, err
+	}
+
+	roles, err := s.activeChannelRoles(ctx, userID, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	perms := make(map[uuid.UUID]*models.WikiPermissions)
+	for _, role := range roles {
+		if perms[role.TargetID] == nil {
+			perms[role.TargetID] = &models.WikiPermissions{}
+		}
+		perms[role.TargetID].Grant(role.Permissions)
+	}
+
+	seenRead := make(map[uuid.UUID]bool, len(readable))
+	for _, id := range readable {
+		seenRead[id] = true
+	}
+	seenEdit := make(map[uuid.UUID]bool, len(editable))
+	for _, id := range editable {
+		seenEdit[id] = true
+	}
+	for id, p := range perms {
+		if p.View && !seenRead[id] {
+			readable = append(readable, id)
+		}
+		if p.Edit && !seenEdit[id] {
+			editable = append(editable, id)
+		}
+	}
+	return readable, editable, nil
+}
+
+// activeChannelRoles returns the user's unexpired temporary roles on one
+// channel, or on every channel when channelID is nil.
+func (s *ChannelService) activeChannelRoles(ctx context.Context, userID uuid.UUID, channelID *uuid.UUID) ([]models.TempRole, error) {
+	query := s.db.WithContext(ctx).
+		Where("user_id = ? AND target_type = ? AND is_active = ?", userID, models.TempRoleTargetChannel, true).
+		Where("expires_at > ?", time.Now())
+	if channelID != nil {
+		query = query.Where("target_id = ?", *channelID)
+	}
+
+	var roles []models.TempRole
+	err := query.Find(&roles).Error
+	return roles, err
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+const (
+	wikiSnippetLength  = 160
+	wikiSnippetLeadIn  = 40
+	wikiTitleWeight    = 1.0
+	wikiContentWeight  = 0.4
+	wikiHighlightStart = "<mark>"
+	wikiHighlightEnd   = "</mark>"
+)
+
+// WikiSearchOptions selects the pages a wiki search looks at.
+type WikiSearchOptions struct {
+	Query string
+	// ChannelIDs are the channels whose wikis are searched.
+	ChannelIDs []uuid.UUID
+	// DraftChannelIDs are the channels whose unpublished pages the caller
+	// may see; drafts elsewhere are never returned.
+	DraftChannelIDs []uuid.UUID
+	// Published, when set, keeps only published pages or only drafts.
+	Published *bool
+	Limit     int
+	Offset    int
+}
+
+// WikiSearchHit is a page matching a search, with its relevance.
+type WikiSearchHit struct {
+	Page models.WikiPage
+	Rank float64
+}
+
+type WikiSearchService struct {
+	db *gorm.DB
+}
+
+func NewWikiSearchService(db *gorm.DB) *WikiSearchService {
+	return &WikiSearchService{db: db}
+}
+
+type wikiSearchRow struct {
+	models.WikiPage
+	SearchRank float64
+}
+
+// Search finds the pages whose title or content contain every word of the
+// query, best matches first. Postgres uses the full-text index; other
+// databases use the FTS table EnsureWikiSearchIndex sets up and rank in Go.
+func (s *WikiSearchService) Search(ctx context.Context, opts WikiSearchOptions) ([]WikiSearchHit, int64, error) {
+	terms := WikiSearchTerms(opts.Query)
+	if len(terms) == 0 || len(opts.ChannelIDs) == 0 {
+		return []WikiSearchHit{}, 0, nil
+	}
+
+	query := s.db.WithContext(ctx).Model(&models.WikiPage{}).Where("channel_id IN ?", opts.ChannelIDs)
+	if len(opts.DraftChannelIDs) > 0 {
+		query = query.Where("is_published = ? OR channel_id IN ?", true, opts.DraftChannelIDs)
+	} else {
+		query = query.Where("is_published = ?", true)
+	}
+	if opts.Published != nil {
+		query = query.Where("is_published = ?", *opts.Published)
+	}
+
+	if s.db.Dialector.Name() == "postgres" {
+		return s.searchPostgres(query, opts)
+	}
+	return s.searchFTS(query, terms, opts)
+}
+
+func (s *WikiSearchService) searchPostgres(query *gorm.DB, opts WikiSearchOptions) ([]WikiSearchHit, int64, error) {
+	const tsquery = "websearch_to_tsquery('simple', ?)"
+	query = query.Where(database.WikiSearchVector+" @@ "+tsquery, opts.Query).Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []wikiSearchRow
+	err := query.
+		Select("wiki_pages.*, ts_rank("+database.WikiSearchVector+", "+tsquery+") AS search_rank", opts.Query).
+		Order("search_rank DESC, updated_at DESC").
+		Limit(opts.Limit).
+		Offset(opts.Offset).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]WikiSearchHit, len(rows))
+	for i := range rows {
+		hits[i] = WikiSearchHit{Page: rows[i].WikiPage, Rank: rows[i].SearchRank}
+	}
+	return hits, total, nil
+}
+
+func (s *WikiSearchService) searchFTS(query *gorm.DB, terms []string, opts WikiSearchOptions) ([]WikiSearchHit, int64, error) {
+	phrases := make([]string, len(terms))
+	for i, term := range terms {
+		phrases[i] = `"` + term + `"`
+	}
+
+	var pages []models.WikiPage
+	err := query.
+		Where("id IN (SELECT page_id FROM wiki_pages_fts WHERE wiki_pages_fts MATCH ?)", strings.Join(phrases, " ")).
+		Find(&pages).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]WikiSearchHit, len(pages))
+	for i := range pages {
+		hits[i] = WikiSearchHit{Page: pages[i], Rank: RankWikiPage(pages[i].Title, pages[i].Content, terms)}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].Page.UpdatedAt.After(hits[j].Page.UpdatedAt)
+	})
+
+	total := int64(len(hits))
+	if opts.Offset >= len(hits) {
+		return []WikiSearchHit{}, total, nil
+	}
+	hits = hits[opts.Offset:]
+	if opts.Limit > 0 && len(hits) > opts.Limit {
+		hits = hits[:opts.Limit]
+	}
+	return hits, total, nil
+}
+
+// WikiSearchTerms returns the lower-cased words of a search query that
+// results must contain. Excluded words ("-word") and the OR operator are
+// left out.
+func WikiSearchTerms(q string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Fields(q) {
+		field = strings.Trim(field, `"`)
+		if strings.HasPrefix(field, "-") || strings.EqualFold(field, "or") {
+			continue
+		}
+		for _, w := range wikiWords(field) {
+			term := strings.ToLower(field[w[0]:w[1]])
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+// RankWikiPage scores how well a page matches the terms, weighting title
+// matches above content ones and damping long pages, much like ts_rank.
+func RankWikiPage(title, content string, terms []string) float64 {
+	want := termSet(terms)
+	count := func(text string) (hits, words int) {
+		for _, w := range wikiWords(text) {
+			words++
+			if want[strings.ToLower(text[w[0]:w[1]])] {
+				hits++
+			}
+		}
+		return hits, words
+	}
+
+	titleHits, _ := count(title)
+	contentHits, contentWords := count(content)
+	score := wikiTitleWeight*float64(titleHits) + wikiContentWeight*float64(contentHits)
+	return score / (1 + math.Log1p(float64(contentWords)))
+}
+
+// HighlightWikiText HTML-escapes text and wraps each word matching one of
+// the terms in <mark>.
+func HighlightWikiText(text string, terms []string) string {
+	return highlightRange(text, wikiWords(text), termSet(terms), 0, len(text))
+}
+
+// WikiSnippet picks the part of content with the most matches, about 160
+// characters long, and highlights it like HighlightWikiText. Whitespace is
+// collapsed and cut-off ends are marked with an ellipsis.
+func WikiSnippet(content string, terms []string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	words := wikiWords(content)
+	want := termSet(terms)
+
+	var matches []int
+	for i, w := range words {
+		if want[strings.ToLower(content[w[0]:w[1]])] {
+			matches = append(matches, i)
+		}
+	}
+
+	// Start at the match followed by the most other matches within the
+	// snippet length.
+	start := 0
+	best := 0
+	for i, m := range matches {
+		n := 0
+		for _, other := range matches[i:] {
+			if words[other][1]-words[m][0] > wikiSnippetLength {
+				break
+			}
+			n++
+		}
+		if n > best {
+			best, start = n, words[m][0]
+		}
+	}
+
+	// Back up to show some context before the first match, starting on a
+	// word boundary.
+	if start > 0 {
+		from := start
+		for _, w := range words {
+			if w[0] >= start {
+				break
+			}
+			if start-w[0] <= wikiSnippetLeadIn {
+				from = w[0]
+				break
+			}
+		}
+		start = from
+	}
+
+	end := len(content)
+	if end-start > wikiSnippetLength {
+		end = start
+		for _, w := range words {
+			if w[0] < start {
+				continue
+			}
+			if w[1]-start > wikiSnippetLength {
+				break
+			}
+			end = w[1]
+		}
+		if end == start {
+			end = start + wikiSnippetLength
+			for !utf8.RuneStart(content[end]) {
+				end--
+			}
+		}
+	}
+
+	snippet := highlightRange(content, words, want, start, end)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(content) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+func highlightRange(text string, words [][2]int, want map[string]bool, start, end int) string {
+	var b strings.Builder
+	pos := start
+	for _, w := range words {
+		if w[0] < start || w[1] > end || !want[strings.ToLower(text[w[0]:w[1]])] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:w[0]]))
+		b.WriteString(wikiHighlightStart)
+		b.WriteString(html.EscapeString(text[w[0]:w[1]]))
+		b.WriteString(wikiHighlightEnd)
+		pos = w[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	return b.String()
+}
+
+// wikiWords returns the byte ranges of the runs of letters and digits in
+// text, the words full-text search indexes.
+func wikiWords(text string) [][2]int {
+	var words [][2]int
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			words = append(words, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, [2]int{start, len(text)})
+	}
+	return words
+}
+
+func termSet(terms []string) map[string]bool {
+	set := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		set[term] = true
+	}
+	return set
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"regexp"
+	"time"
+)
+
+var wikiPlaceholderRe = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// WikiTemplateVars returns the values of the wiki template placeholders for
+// a page titled title, created by author at now.
+func WikiTemplateVars(now time.Time, author, title string) map[string]string {
+	return map[string]string{
+		"date":     now.Format("2006-01-02"),
+		"time":     now.Format("15:04"),
+		"datetime": now.Format("2006-01-02 15:04"),
+		"author":   author,
+		"title":    title,
+	}
+}
+
+// ExpandWikiTemplate replaces each {{name}} in text with vars[name].
+// Placeholders without a value are left as they are.
+func ExpandWikiTemplate(text string, vars map[string]string) string {
+	return wikiPlaceholderRe.ReplaceAllStringFunc(text, func(m string) string {
+		name := wikiPlaceholderRe.FindStringSubmatch(m)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return m
+	})
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrWikiParentNotFound = errors.New("parent page not found")
+	ErrWikiParentCycle    = errors.New("a page cannot be moved under itself or one of its descendants")
+	ErrWikiNotSibling     = errors.New("all pages must be children of the same parent")
+)
+
+// reservedWikiSlugs are the channel-level routes under /wiki/:channelId/
+// that a page at /wiki/:channelId/:slug would collide with.
+var reservedWikiSlugs = map[string]bool{
+	"search":      true,
+	"permissions": true,
+	"watch":       true,
+	"templates":   true,
+	"export":      true,
+	"import":      true,
+	"reorder":     true,
+	"tree":        true,
+}
+
+// WikiSlugReserved reports whether slug names a channel-level wiki route and
+// so cannot be the slug of a page. Routes match regardless of case.
+func WikiSlugReserved(slug string) bool {
+	return reservedWikiSlugs[strings.ToLower(slug)]
+}
+
+// UnreservedWikiSlug returns slug, with "-page" appended if it is reserved.
+// It is for slugs derived from titles and file names rather than chosen.
+func UnreservedWikiSlug(slug string) string {
+	if WikiSlugReserved(slug) {
+		return slug + "-page"
+	}
+	return slug
+}
+
+// CheckWikiParent loads the page that is to become page's parent. It must be
+// in the same channel and must not be the page itself or one of its
+// descendants. tx is the caller's transaction, which goes on to set the
+// parent.
+func CheckWikiParent(tx *gorm.DB, page *models.WikiPage, parentID uuid.UUID) (*models.WikiPage, error) {
+	var parent models.WikiPage
+	if err := tx.Where("channel_id = ?", page.ChannelID).First(&parent, parentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWikiParentNotFound
+		}
+		return nil, err
+	}
+
+	// Walk up from the new parent; reaching the page means a cycle. The
+	// seen set stops on cycles left by older data.
+	seen := map[uuid.UUID]bool{}
+	for ancestor := &parent; ; {
+		if ancestor.ID == page.ID {
+			return nil, ErrWikiParentCycle
+		}
+		if ancestor.ParentID == nil || seen[ancestor.ID] {
+			return &parent, nil
+		}
+		seen[ancestor.ID] = true
+
+		var next models.WikiPage
+		if err := tx.Select("id", "parent_id").First(&next, *ancestor.ParentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &parent, nil
+			}
+			return nil, err
+		}
+		ancestor = &next
+	}
+}
+
+// ReorderWikiPages puts the children of parentID (nil for the top level) in
+// the order of pageIDs, followed by the children not listed in their current
+// order. Every listed page must be one of those children, or it fails with
+// ErrWikiNotSibling. It returns the children in their new order.
+func (s *ChannelService) ReorderWikiPages(ctx context.Context, channelID uuid.UUID, parentID *uuid.UUID, pageIDs []uuid.UUID) ([]models.WikiPage, error) {
+	var ordered []models.WikiPage
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("channel_id = ?", channelID)
+		if parentID == nil {
+			query = query.Where("parent_id IS NULL")
+		} else {
+			query = query.Where("parent_id = ?", *parentID)
+		}
+
+		var siblings []models.WikiPage
+		if err := query.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Order(clause.OrderByColumn{Column: clause.Column{Name: "order"}}).
+			Order("created_at ASC").
+			Find(&siblings).Error; err != nil {
+			return err
+		}
+
+		byID := make(map[uuid.UUID]models.WikiPage, len(siblings))
+		for _, page := range siblings {
+			byID[page.ID] = page
+		}
+		listed := make(map[uuid.UUID]bool, len(pageIDs))
+		for _, id := range pageIDs {
+			page, found := byID[id]
+			if !found || listed[id] {
+				return ErrWikiNotSibling
+			}
+			listed[id] = true
+			ordered = append(ordered, page)
+		}
+		for _, page := range siblings {
+			if !listed[page.ID] {
+				ordered = append(ordered, page)
+			}
+		}
+
+		for i := range ordered {
+			if ordered[i].Order == i {
+				continue
+			}
+			if err := tx.Model(&ordered[i]).Update("order", i).Error; err != nil {
+				return err
+			}
+			ordered[i].Order = i
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ordered, nil
+}
+
+// RenameWikiPage changes the page's slug and leaves a redirect from the old
+// one. tx is the caller's transaction.
+func RenameWikiPage(tx *gorm.DB, page *models.WikiPage, newSlug string) error {
+	oldSlug := page.Slug
+	if err := tx.Model(page).Update("slug", newSlug).Error; err != nil {
+		return err
+	}
+
+	// The new slug now names a real page, which wins over any redirect.
+	if err := tx.Where("channel_id = ? AND slug = ?", page.ChannelID, newSlug).Delete(&models.WikiRedirect{}).Error; err != nil {
+		return err
+	}
+	redirect := models.WikiRedirect{ChannelID: page.ChannelID, Slug: oldSlug, PageID: page.ID}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel_id"}, {Name: "slug"}},
+		DoUpdates: clause.AssignmentColumns([]string{"page_id", "created_at"}),
+	}).Create(&redirect).Error
+}
+
+// WikiRedirectTarget returns the page an old slug of the channel's wiki
+// redirects to, or nil. Redirects point at pages, so a slug keeps leading
+// to its page however often the page is renamed afterwards.
+func (s *ChannelService) WikiRedirectTarget(ctx context.Context, channelID uuid.UUID, slug string) (*models.WikiPage, error) {
+	db := s.db.WithContext(ctx)
+
+	var redirect models.WikiRedirect
+	if err := db.Where("channel_id = ? AND slug = ?", channelID, slug).First(&redirect).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var page models.WikiPage
+	if err := db.Where("channel_id = ?", channelID).First(&page, redirect.PageID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &page, nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+)
+
+const (
+	wikiDigestKeyPrefix  = "wiki:digest:"
+	wikiDigestChannelKey = "wiki:digest:channels"
+)
+
+// WikiWatchers lists the users to tell about a change to the page: those
+// watching it or its whole wiki who can still see it, except the user who
+// made the change.
+func (s *ChannelService) WikiWatchers(ctx context.Context, channel *models.Channel, page *models.WikiPage, actorID uuid.UUID) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	err := s.db.WithContext(ctx).Model(&models.WikiWatch{}).
+		Distinct("user_id").
+		Where("channel_id = ? AND (page_id IS NULL OR page_id = ?)", channel.ID, page.ID).
+		Where("user_id <> ?", actorID).
+		Pluck("user_id", &userIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	watchers := userIDs[:0]
+	for _, userID := range userIDs {
+		perms, err := s.WikiPermissions(ctx, channel, userID)
+		if err != nil {
+			return nil, err
+		}
+		if perms.CanViewPage(page) {
+			watchers = append(watchers, userID)
+		}
+	}
+	return watchers, nil
+}
+
+// QueueWikiDigest records a change for the channel's next wiki digest, if
+// the channel posts digests. Drafts are left out, as subscribers cannot
+// see them.
+func (s *ChannelService) QueueWikiDigest(ctx context.Context, channel *models.Channel, page *models.WikiPage, change *models.WikiChange) error {
+	if !channel.WikiDigest || !page.IsPublished {
+		return nil
+	}
+
+	entry, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.RPush(ctx, wikiDigestKeyPrefix+channel.ID.String(), entry)
+	pipe.SAdd(ctx, wikiDigestChannelKey, channel.ID.String())
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// FlushWikiDigests posts the queued changes of every channel as one digest
+// post per channel and returns the number of posts made.
+func (s *ChannelService) FlushWikiDigests(ctx context.Context) (int, error) {
+	channelIDs, err := s.redis.SMembers(ctx, wikiDigestChannelKey).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	posted := 0
+	for _, id := range channelIDs {
+		key := wikiDigestKeyPrefix + id
+		pipe := s.redis.TxPipeline()
+		entriesCmd := pipe.LRange(ctx, key, 0, -1)
+		pipe.Del(ctx, key)
+		pipe.SRem(ctx, wikiDigestChannelKey, id)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return posted, err
+		}
+
+		changes := make([]models.WikiChange, 0, len(entriesCmd.Val()))
+		for _, entry := range entriesCmd.Val() {
+			var change models.WikiChange
+			if err := json.Unmarshal([]byte(entry), &change); err != nil {
+				log.Printf("Skipping malformed wiki digest entry %q", entry)
+				continue
+			}
+			changes = append(changes, change)
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		if err := s.postWikiDigest(ctx, changes); err != nil {
+			log.Printf("Error posting wiki digest for channel %s: %v", id, err)
+			continue
+		}
+		posted++
+	}
+	return posted, nil
+}
+
+// postWikiDigest publishes the digest as a post by the channel owner.
+func (s *ChannelService) postWikiDigest(ctx context.Context, changes []models.WikiChange) error {
+	var channel models.Channel
+	if err := s.db.WithContext(ctx).First(&channel, changes[0].ChannelID).Error; err != nil {
+		return err
+	}
+
+	post := models.ChannelPost{
+		ChannelID: channel.ID,
+		AuthorID:  channel.OwnerID,
+		Content:   WikiDigestContent(changes),
+		PostType:  models.MessageTypeText,
+	}
+	if err := s.db.WithContext(ctx).Create(&post).Error; err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Preload("Author").First(&post, post.ID).Error; err != nil {
+		log.Printf("Error loading wiki digest post with author: %v", err)
+	}
+
+	event, err := json.Marshal(map[string]interface{}{
+		"type":       "channel_post",
+		"channel_id": channel.ID,
+		"post":       post.ToResponse(),
+	})
+	if err != nil {
+		return err
+	}
+	return s.redis.Publish(ctx, "channel:"+channel.ID.String(), event).Err()
+}
+
+// WikiDigestContent summarises changes, one line per page in the order the
+// pages were first changed, e.g. "• Install: updated 2 times (Fix typo)".
+// The summary is that of the page's last change.
+func WikiDigestContent(changes []models.WikiChange) string {
+	type pageChanges struct {
+		last    models.WikiChange
+		actions []models.WikiChangeAction
+		counts  map[models.WikiChangeAction]int
+	}
+
+	var order []uuid.UUID
+	pages := make(map[uuid.UUID]*pageChanges)
+	for _, change := range changes {
+		p := pages[change.PageID]
+		if p == nil {
+			p = &pageChanges{counts: make(map[models.WikiChangeAction]int)}
+			pages[change.PageID] = p
+			order = append(order, change.PageID)
+		}
+		if p.counts[change.Action] == 0 {
+			p.actions = append(p.actions, change.Action)
+		}
+		p.counts[change.Action]++
+		p.last = change
+	}
+
+	var b strings.Builder
+	b.WriteString("Wiki updates:")
+	for _, id := range order {
+		p := pages[id]
+		actions := make([]string, len(p.actions))
+		for i, action := range p.actions {
+			actions[i] = string(action)
+			if n := p.counts[action]; n > 1 {
+				actions[i] += fmt.Sprintf(" %d times", n)
+			}
+		}
+		fmt.Fprintf(&b, "\n• %s: %s", p.last.Title, strings.Join(actions, ", "))
+		if p.last.Summary != "" {
+			fmt.Fprintf(&b, " (%s)", p.last.Summary)
+		}
+	}
+	return b.String()
+}
@@ -3,34 +3,54 @@ package services
 import (
 	"context"
 	"log"
+	"os"
 	"time"
 
 	"github.com/messenger/backend/internal/models"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 type WorkerService struct {
-	db *gorm.DB
+	db             *gorm.DB
+	channelService *ChannelService
 }
 
-func NewWorkerService(db *gorm.DB) *WorkerService {
-	return &WorkerService{db: db}
+func NewWorkerService(db *gorm.DB, redisClient *redis.Client) *WorkerService {
+	return &WorkerService{
+		db:             db,
+		channelService: NewChannelService(db, redisClient),
+	}
 }
 
 func (s *WorkerService) Start(ctx context.Context) {
 	roleTicker := time.NewTicker(5 * time.Minute)
 	mediaTicker := time.NewTicker(24 * time.Hour)
 	rssTicker := time.NewTicker(30 * time.Minute)
+	exportTicker := time.NewTicker(time.Hour)
+	viewsTicker := time.NewTicker(time.Minute)
+	wikiDigestTicker := time.NewTicker(time.Hour)
 
 	go func() {
+		s.recountChannelSubscribers()
+		s.failStaleExports()
+
 		for {
 			select {
 			case <-roleTicker.C:
 				s.cleanupExpiredRoles()
+				s.liftExpiredSanctions()
 			case <-mediaTicker.C:
 				s.cleanupOldMedia()
 			case <-rssTicker.C:
 				s.refreshAllActiveRSSFeeds()
+			case <-exportTicker.C:
+				s.failStaleExports()
+				s.cleanupExpiredExports()
+			case <-viewsTicker.C:
+				s.flushPostViews(ctx)
+			case <-wikiDigestTicker.C:
+				s.postWikiDigests(ctx)
 			case <-ctx.Done():
 				return
 			}
@@ -50,6 +70,86 @@ func (s *WorkerService) cleanupExpiredRoles() {
 	}
 }
 
+func (s *WorkerService) liftExpiredSanctions() {
+	now := time.Now()
+	result := s.db.Model(&models.ChatSanction{}).
+		Where("expires_at <= ? AND is_active = ?", now, true).
+		Updates(map[string]interface{}{
+			"is_active": false,
+			"lifted_at": now,
+		})
+
+	if result.Error != nil {
+		log.Printf("Worker: Error lifting expired chat sanctions: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Worker: Lifted %d expired chat bans and restrictions", result.RowsAffected)
+	}
+}
+
+func (s *WorkerService) failStaleExports() {
+	count, err := NewExportService(s.db).FailStaleExports()
+	if err != nil {
+		log.Printf("Worker: Error failing stale chat exports: %v", err)
+	} else if count > 0 {
+		log.Printf("Worker: Marked %d interrupted chat exports as failed", count)
+	}
+}
+
+func (s *WorkerService) cleanupExpiredExports() {
+	var exports []models.ChatExport
+	if err := s.db.Where("status = ? AND expires_at <= ?", models.ExportStatusCompleted, time.Now()).
+		Find(&exports).Error; err != nil {
+		log.Printf("Worker: Error loading expired chat exports: %v", err)
+		return
+	}
+
+	for _, export := range exports {
+		if export.FilePath != nil {
+			if err := os.Remove(*export.FilePath); err != nil && !os.IsNotExist(err) {
+				log.Printf("Worker: Error removing export archive %s: %v", *export.FilePath, err)
+				continue
+			}
+		}
+		s.db.Model(&export).Updates(map[string]interface{}{
+			"status":    models.ExportStatusExpired,
+			"file_path": nil,
+		})
+	}
+
+	if len(exports) > 0 {
+		log.Printf("Worker: Removed %d expired chat exports", len(exports))
+	}
+}
+
+func (s *WorkerService) flushPostViews(ctx context.Context) {
+	flushed, err := s.channelService.FlushPostViews(ctx)
+	if err != nil {
+		log.Printf("Worker: Error flushing channel post views: %v", err)
+	} else if flushed > 0 {
+		log.Printf("Worker: Flushed %d channel post views", flushed)
+	}
+}
+
+func (s *WorkerService) postWikiDigests(ctx context.Context) {
+	posted, err := s.channelService.FlushWikiDigests(ctx)
+	if err != nil {
+		log.Printf("Worker: Error posting wiki digests: %v", err)
+	} else if posted > 0 {
+		log.Printf("Worker: Posted %d wiki digests", posted)
+	}
+}
+
+// recountChannelSubscribers repairs subscriber counters that drifted before
+// Subscribe and Unsubscribe kept them up to date.
+func (s *WorkerService) recountChannelSubscribers() {
+	result := s.db.Exec(`UPDATE channels SET subscriber_count =
+		(SELECT COUNT(*) FROM channel_subscribers WHERE channel_subscribers.channel_id = channels.id)`)
+
+	if result.Error != nil {
+		log.Printf("Worker: Error recounting channel subscribers: %v", result.Error)
+	}
+}
+
 func (s *WorkerService) cleanupOldMedia() {
 	// Simple DB-based cleanup, files would need a separate process or the cleanup.sh script
 	log.Println("Worker: Starting old media cleanup task")
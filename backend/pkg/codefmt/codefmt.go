@@ -0,0 +1,243 @@
+// Package codefmt pretty-prints source code in plain Go: gofmt for Go,
+// indentation for JSON and XML and keyword casing for SQL. Other languages
+// are not supported.
+package codefmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"strings"
+
+	"github.com/messenger/backend/pkg/highlight"
+)
+
+// ErrUnsupported is returned for languages without a formatter.
+var ErrUnsupported = errors.New("formatting is not supported for this language")
+
+const indent = "  "
+
+var formatters = map[string]func(string) (string, error){
+	"go":   formatGo,
+	"json": formatJSON,
+	"xml":  formatXML,
+	"sql":  formatSQL,
+}
+
+// Supported reports whether the language has a formatter.
+func Supported(language string) bool {
+	_, ok := formatters[language]
+	return ok
+}
+
+// Format pretty-prints code in the named language. Code that does not parse
+// yields an error describing the problem; the result ends with a newline.
+func Format(language, code string) (string, error) {
+	formatter, ok := formatters[language]
+	if !ok {
+		return "", ErrUnsupported
+	}
+	return formatter(strings.ReplaceAll(code, "\r\n", "\n"))
+}
+
+func formatGo(code string) (string, error) {
+	out, err := format.Source([]byte(code))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func formatJSON(code string) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(strings.TrimSpace(code)), "", indent); err != nil {
+		return "", err
+	}
+	buf.WriteByte('\n')
+	return buf.String(), nil
+}
+
+// formatXML puts every element, comment and instruction on its own line,
+// indented by depth. Elements that hold text are kept on one line as
+// written, so mixed content keeps its spacing, and empty ones are
+// self-closed. Whitespace between elements is dropped.
+func formatXML(code string) (string, error) {
+	dec := xml.NewDecoder(strings.NewReader(code))
+	dec.Strict = true
+
+	var tokens []xml.Token
+	var open []xml.Name
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			open = append(open, t.Name)
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				line, _ := dec.InputPos()
+				return "", fmt.Errorf("line %d: unexpected end tag </%s>", line, xmlName(t.Name))
+			}
+			open = open[:len(open)-1]
+		}
+		tokens = append(tokens, xml.CopyToken(tok))
+	}
+	if len(open) > 0 {
+		return "", fmt.Errorf("element <%s> is not closed", xmlName(open[len(open)-1]))
+	}
+
+	var sb strings.Builder
+	depth := 0
+	newLine := func() {
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(strings.Repeat(indent, depth))
+	}
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i].(type) {
+		case xml.StartElement:
+			newLine()
+			end := matchingEnd(tokens, i)
+			if end == i+1 || holdsText(tokens[i+1:end]) {
+				for ; i <= end; i++ {
+					writeXMLToken(&sb, tokens, i)
+				}
+				i = end
+				continue
+			}
+			writeXMLToken(&sb, tokens, i)
+			depth++
+		case xml.EndElement:
+			depth--
+			newLine()
+			writeXMLToken(&sb, tokens, i)
+		case xml.CharData:
+			if text := strings.TrimSpace(string(t)); text != "" {
+				newLine()
+				sb.WriteString(xmlTextEscaper.Replace(text))
+			}
+		default:
+			newLine()
+			writeXMLToken(&sb, tokens, i)
+		}
+	}
+	if sb.Len() == 0 {
+		return "", errors.New("no XML content")
+	}
+	sb.WriteByte('\n')
+	return sb.String(), nil
+}
+
+// matchingEnd returns the index of the end tag closing the element that
+// starts at tokens[start].
+func matchingEnd(tokens []xml.Token, start int) int {
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		switch tokens[i].(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// holdsText reports whether an element's content has text of its own.
+func holdsText(content []xml.Token) bool {
+	depth := 0
+	for _, tok := range content {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && len(bytes.TrimSpace(t)) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeXMLToken writes tokens[i] as is. A start tag directly followed by
+// its end tag is written self-closed, and the end tag is then skipped.
+func writeXMLToken(sb *strings.Builder, tokens []xml.Token, i int) {
+	switch t := tokens[i].(type) {
+	case xml.StartElement:
+		sb.WriteString("<" + xmlName(t.Name))
+		for _, attr := range t.Attr {
+			fmt.Fprintf(sb, ` %s="%s"`, xmlName(attr.Name), xmlAttrEscaper.Replace(attr.Value))
+		}
+		if i+1 < len(tokens) {
+			if _, ok := tokens[i+1].(xml.EndElement); ok {
+				sb.WriteString("/>")
+				return
+			}
+		}
+		sb.WriteString(">")
+	case xml.EndElement:
+		if _, ok := tokens[i-1].(xml.StartElement); !ok {
+			sb.WriteString("</" + xmlName(t.Name) + ">")
+		}
+	case xml.CharData:
+		sb.WriteString(xmlTextEscaper.Replace(string(t)))
+	case xml.Comment:
+		sb.WriteString("<!--" + string(t) + "-->")
+	case xml.ProcInst:
+		sb.WriteString("<?" + t.Target)
+		if len(t.Inst) > 0 {
+			sb.WriteString(" " + string(t.Inst))
+		}
+		sb.WriteString("?>")
+	case xml.Directive:
+		sb.WriteString("<!" + string(t) + ">")
+	}
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;")
+)
+
+// xmlName writes a name with its prefix as it was written, since
+// namespaces are not resolved.
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// formatSQL upper-cases keywords and strips trailing whitespace. Strings,
+// quoted identifiers and comments are left alone.
+func formatSQL(code string) (string, error) {
+	var sb strings.Builder
+	for _, tok := range highlight.Tokenize("sql", code) {
+		if tok.Type == highlight.Keyword {
+			sb.WriteString(strings.ToUpper(tok.Value))
+		} else {
+			sb.WriteString(tok.Value)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
@@ -63,6 +63,10 @@ func Initialize() (*gorm.DB, error) {
         return nil, fmt.Errorf("failed to run migrations: %w", err)
     }
 
+    if err := EnsureWikiSearchIndex(db); err != nil {
+        return nil, fmt.Errorf("failed to create wiki search index: %w", err)
+    }
+
     log.Println("Database migrations and auto-migration completed successfully")
 
     return db, nil
@@ -73,9 +77,20 @@ func autoMigrate(db *gorm.DB) error {
         &models.User{},
         &models.Chat{},
         &models.ChatMember{},
+        &models.ChatFolder{},
+        &models.ChatSanction{},
+        &models.ChatExport{},
+        &models.ChatTopic{},
+        &models.ChatTopicRead{},
         &models.Message{},
         &models.Channel{},
         &models.ChannelSubscriber{},
+        &models.ChannelPost{},
+        &models.ChannelPostView{},
+        &models.ChannelSubscriptionEvent{},
+        &models.ChannelAdmin{},
+        &models.ChannelInviteLink{},
+        &models.ChannelJoinRequest{},
         &models.Subscription{},
         &models.PaymentLog{},
         &models.Contact{},
@@ -87,7 +102,14 @@ func autoMigrate(db *gorm.DB) error {
         &models.UserOneTimeKey{},
         &models.WikiPage{},
         &models.WikiRevision{},
+        &models.WikiLink{},
+        &models.WikiRedirect{},
+        &models.WikiWatch{},
+        &models.WikiTemplate{},
+        &models.WikiAttachment{},
         &models.CodeSnippet{},
+        &models.CodeSnippetVersion{},
+        &models.CodeComment{},
         &models.TempRole{},
         &models.RSSFeed{},
         &models.RSSItem{},
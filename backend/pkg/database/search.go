@@ -0,0 +1,39 @@
+package database
+
+import "gorm.io/gorm"
+
+// WikiSearchVector is the weighted document wiki pages are searched by on
+// Postgres: title words rank above content words. Queries must use the same
+// expression for the index to apply.
+const WikiSearchVector = "setweight(to_tsvector('simple', title), 'A') || " +
+	"setweight(to_tsvector('simple', content), 'B')"
+
+// EnsureWikiSearchIndex creates the full-text index wiki search relies on:
+// a GIN expression index on Postgres, or on SQLite an FTS4 table kept in
+// step with wiki_pages by triggers.
+func EnsureWikiSearchIndex(db *gorm.DB) error {
+	if db.Dialector.Name() == "postgres" {
+		return db.Exec("CREATE INDEX IF NOT EXISTS idx_wiki_pages_search ON wiki_pages USING GIN ((" + WikiSearchVector + "))").Error
+	}
+
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS wiki_pages_fts USING fts4(page_id, title, content, notindexed=page_id, tokenize=unicode61)`,
+		`CREATE TRIGGER IF NOT EXISTS wiki_pages_fts_insert AFTER INSERT ON wiki_pages BEGIN
+			INSERT INTO wiki_pages_fts (page_id, title, content) VALUES (new.id, new.title, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS wiki_pages_fts_update AFTER UPDATE OF title, content ON wiki_pages BEGIN
+			UPDATE wiki_pages_fts SET title = new.title, content = new.content WHERE page_id = new.id;
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS wiki_pages_fts_delete AFTER DELETE ON wiki_pages BEGIN
+			DELETE FROM wiki_pages_fts WHERE page_id = old.id;
+		END`,
+		`INSERT INTO wiki_pages_fts (page_id, title, content)
+			SELECT id, title, content FROM wiki_pages WHERE id NOT IN (SELECT page_id FROM wiki_pages_fts)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
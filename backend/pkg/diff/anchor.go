@@ -0,0 +1,37 @@
+package diff
+
+// LineMap tells where the lines of an old text are in a new one. Index i
+// holds the 0-based new index of old line i, or -1 if that line was changed
+// or removed.
+type LineMap []int
+
+// MapLines maps the lines of oldText to newText.
+func MapLines(oldText, newText string) LineMap {
+	a := SplitLines(oldText)
+	m := make(LineMap, len(a))
+	for i := range m {
+		m[i] = -1
+	}
+	for _, e := range Compute(a, SplitLines(newText)) {
+		if e.Kind == Equal {
+			m[e.OldIndex] = e.NewIndex
+		}
+	}
+	return m
+}
+
+// Range maps the 1-based line range start..end. ok is false unless every
+// line in it was kept and no lines were inserted between them, so the range
+// still shows the same code.
+func (m LineMap) Range(start, end int) (newStart, newEnd int, ok bool) {
+	if start < 1 || end < start || end > len(m) {
+		return 0, 0, false
+	}
+	first := m[start-1]
+	for i := start - 1; i < end; i++ {
+		if m[i] < 0 || m[i]-first != i-(start-1) {
+			return 0, 0, false
+		}
+	}
+	return first + 1, m[end-1] + 1, true
+}
@@ -0,0 +1,419 @@
+// Package diff compares texts line by line and word by word. It is a plain Go
+// implementation of Myers' algorithm so it needs no external tools.
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type Kind string
+
+const (
+	Equal  Kind = "equal"
+	Insert Kind = "insert"
+	Delete Kind = "delete"
+)
+
+// maxEditDistance bounds the work spent on a single comparison. Inputs that
+// differ by more than this are reported as a full replacement.
+const maxEditDistance = 2000
+
+// Edit is one step of an edit script. OldIndex is -1 for inserts and
+// NewIndex is -1 for deletes.
+type Edit struct {
+	Kind     Kind
+	OldIndex int
+	NewIndex int
+}
+
+// Compute returns a shortest edit script turning a into b.
+func Compute(a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b)-prefix-suffix)
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, Edit{Kind: Equal, OldIndex: i, NewIndex: i})
+	}
+
+	for _, e := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		if e.OldIndex >= 0 {
+			e.OldIndex += prefix
+		}
+		if e.NewIndex >= 0 {
+			e.NewIndex += prefix
+		}
+		edits = append(edits, e)
+	}
+
+	for i := suffix; i > 0; i-- {
+		edits = append(edits, Edit{Kind: Equal, OldIndex: len(a) - i, NewIndex: len(b) - i})
+	}
+	return edits
+}
+
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds v for diagonals -d..d after round d.
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= max && d <= maxEditDistance; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+		if found {
+			break
+		}
+	}
+
+	if !found {
+		return replaceAll(n, m)
+	}
+
+	edits := make([]Edit, 0, max)
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, Edit{Kind: Equal, OldIndex: x, NewIndex: y})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, Edit{Kind: Insert, OldIndex: -1, NewIndex: y})
+		} else {
+			x--
+			edits = append(edits, Edit{Kind: Delete, OldIndex: x, NewIndex: -1})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, Edit{Kind: Equal, OldIndex: x, NewIndex: y})
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func replaceAll(n, m int) []Edit {
+	edits := make([]Edit, 0, n+m)
+	for i := 0; i < n; i++ {
+		edits = append(edits, Edit{Kind: Delete, OldIndex: i, NewIndex: -1})
+	}
+	for j := 0; j < m; j++ {
+		edits = append(edits, Edit{Kind: Insert, OldIndex: -1, NewIndex: j})
+	}
+	return edits
+}
+
+// Span is a run of words with the same fate.
+type Span struct {
+	Kind Kind   `json:"kind"`
+	Text string `json:"text"`
+}
+
+// Line is one line of a line diff. Changed lines that replace one another
+// carry Words, the word-level diff restricted to their own side.
+type Line struct {
+	Kind    Kind   `json:"kind"`
+	Text    string `json:"text"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	Words   []Span `json:"words,omitempty"`
+}
+
+// Hunk is a group of changes with their surrounding context, as in a
+// unified diff.
+type Hunk struct {
+	OldStart int    `json:"old_start"`
+	OldLines int    `json:"old_lines"`
+	NewStart int    `json:"new_start"`
+	NewLines int    `json:"new_lines"`
+	Lines    []Line `json:"lines"`
+}
+
+// Result is a line diff grouped into hunks.
+type Result struct {
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Hunks     []Hunk `json:"hunks"`
+}
+
+// SplitLines splits text into lines without their terminators. A trailing
+// newline does not start an extra empty line.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines diffs two texts line by line, keeping context unchanged lines
+// around each change.
+func Lines(oldText, newText string, context int) *Result {
+	a, b := SplitLines(oldText), SplitLines(newText)
+	edits := Compute(a, b)
+
+	lines := make([]Line, len(edits))
+	result := &Result{Hunks: []Hunk{}}
+	for i, e := range edits {
+		switch e.Kind {
+		case Equal:
+			lines[i] = Line{Kind: Equal, Text: a[e.OldIndex], OldLine: e.OldIndex + 1, NewLine: e.NewIndex + 1}
+		case Delete:
+			lines[i] = Line{Kind: Delete, Text: a[e.OldIndex], OldLine: e.OldIndex + 1}
+			result.Deletions++
+		case Insert:
+			lines[i] = Line{Kind: Insert, Text: b[e.NewIndex], NewLine: e.NewIndex + 1}
+			result.Additions++
+		}
+	}
+	pairWords(lines)
+
+	if context < 0 {
+		context = 0
+	}
+	for start := 0; start < len(lines); {
+		if lines[start].Kind == Equal {
+			start++
+			continue
+		}
+
+		// Extend the hunk while the next change is within reach of the
+		// context of the previous one.
+		end := start
+		for i := start; i < len(lines); i++ {
+			if lines[i].Kind == Equal {
+				if i-end >= 2*context {
+					break
+				}
+				continue
+			}
+			end = i + 1
+		}
+
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		to := end + context
+		if to > len(lines) {
+			to = len(lines)
+		}
+		result.Hunks = append(result.Hunks, newHunk(lines[from:to], lines[:from]))
+		start = to
+	}
+
+	return result
+}
+
+func newHunk(lines, before []Line) Hunk {
+	h := Hunk{Lines: lines}
+	for _, l := range before {
+		if l.Kind != Insert {
+			h.OldStart++
+		}
+		if l.Kind != Delete {
+			h.NewStart++
+		}
+	}
+	for _, l := range lines {
+		if l.Kind != Insert {
+			h.OldLines++
+		}
+		if l.Kind != Delete {
+			h.NewLines++
+		}
+	}
+	// Unified diffs number an empty side by the line before it.
+	if h.OldLines > 0 {
+		h.OldStart++
+	}
+	if h.NewLines > 0 {
+		h.NewStart++
+	}
+	return h
+}
+
+// pairWords matches each run of deleted lines with the inserted lines that
+// follow it and attaches word-level diffs to the pairs.
+func pairWords(lines []Line) {
+	for i := 0; i < len(lines); {
+		if lines[i].Kind != Delete {
+			i++
+			continue
+		}
+		delStart := i
+		for i < len(lines) && lines[i].Kind == Delete {
+			i++
+		}
+		insStart := i
+		for i < len(lines) && lines[i].Kind == Insert {
+			i++
+		}
+
+		pairs := insStart - delStart
+		if i-insStart < pairs {
+			pairs = i - insStart
+		}
+		for p := 0; p < pairs; p++ {
+			oldLine, newLine := &lines[delStart+p], &lines[insStart+p]
+			for _, s := range Words(oldLine.Text, newLine.Text) {
+				if s.Kind != Insert {
+					oldLine.Words = append(oldLine.Words, s)
+				}
+				if s.Kind != Delete {
+					newLine.Words = append(newLine.Words, s)
+				}
+			}
+		}
+	}
+}
+
+// Words diffs two strings word by word. Whitespace and punctuation are
+// tokens of their own so spans line up with what a reader sees.
+func Words(oldText, newText string) []Span {
+	a, b := tokenize(oldText), tokenize(newText)
+
+	var spans []Span
+	for _, e := range Compute(a, b) {
+		text := ""
+		if e.Kind == Insert {
+			text = b[e.NewIndex]
+		} else {
+			text = a[e.OldIndex]
+		}
+		if n := len(spans); n > 0 && spans[n-1].Kind == e.Kind {
+			spans[n-1].Text += text
+			continue
+		}
+		spans = append(spans, Span{Kind: e.Kind, Text: text})
+	}
+	return spans
+}
+
+func tokenize(text string) []string {
+	var tokens []string
+	start := -1
+	class := 0
+	for i, r := range text {
+		c := runeClass(r)
+		if start >= 0 && c == class && c != classPunct {
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, text[start:i])
+		}
+		start, class = i, c
+	}
+	if start >= 0 {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+const (
+	classWord = iota + 1
+	classSpace
+	classPunct
+)
+
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+		return classWord
+	case unicode.IsSpace(r):
+		return classSpace
+	default:
+		return classPunct
+	}
+}
+
+// Unified renders the result as a unified diff between the named files.
+func (r *Result) Unified(oldName, newName string) string {
+	if len(r.Hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range r.Hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case Equal:
+				sb.WriteByte(' ')
+			case Delete:
+				sb.WriteByte('-')
+			case Insert:
+				sb.WriteByte('+')
+			}
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,142 @@
+package diff
+
+import "strings"
+
+const (
+	conflictOurs   = "<<<<<<< ours"
+	conflictSep    = "======="
+	conflictTheirs = ">>>>>>> theirs"
+)
+
+// MergeResult is the outcome of a three-way merge. Conflicting regions are
+// left in Content between conflict markers, as git does.
+type MergeResult struct {
+	Content   string `json:"content"`
+	Clean     bool   `json:"clean"`
+	Conflicts int    `json:"conflicts"`
+}
+
+// chunk replaces the base lines [start, end) with lines.
+type chunk struct {
+	start, end int
+	lines      []string
+}
+
+func chunks(base, other []string) []chunk {
+	var out []chunk
+	var cur *chunk
+	pos := 0
+	for _, e := range Compute(base, other) {
+		switch e.Kind {
+		case Equal:
+			if cur != nil {
+				out = append(out, *cur)
+				cur = nil
+			}
+			pos = e.OldIndex + 1
+		case Delete:
+			if cur == nil {
+				cur = &chunk{start: e.OldIndex}
+			}
+			cur.end = e.OldIndex + 1
+			pos = e.OldIndex + 1
+		case Insert:
+			if cur == nil {
+				cur = &chunk{start: pos, end: pos}
+			}
+			cur.lines = append(cur.lines, other[e.NewIndex])
+		}
+	}
+	if cur != nil {
+		out = append(out, *cur)
+	}
+	return out
+}
+
+// apply returns base[lo:hi] with the chunks, which must lie inside it,
+// applied.
+func apply(base []string, lo, hi int, chs []chunk) []string {
+	var out []string
+	i := lo
+	for _, ch := range chs {
+		out = append(out, base[i:ch.start]...)
+		out = append(out, ch.lines...)
+		i = ch.end
+	}
+	return append(out, base[i:hi]...)
+}
+
+// Merge3 merges the changes ours and theirs each made to base, line by line.
+// Changes to separate regions are combined; overlapping or adjacent changes
+// that differ are conflicts.
+func Merge3(base, ours, theirs string) *MergeResult {
+	baseLines := SplitLines(base)
+	a := chunks(baseLines, SplitLines(ours))
+	b := chunks(baseLines, SplitLines(theirs))
+
+	var out []string
+	conflicts := 0
+	pos, i, j := 0, 0, 0
+	for i < len(a) || j < len(b) {
+		var groupA, groupB []chunk
+		lo, hi := 0, 0
+		if j >= len(b) || (i < len(a) && a[i].start <= b[j].start) {
+			lo, hi = a[i].start, a[i].end
+		} else {
+			lo, hi = b[j].start, b[j].end
+		}
+
+		// Gather every chunk from either side that touches the region,
+		// growing it as they come in.
+		for {
+			if i < len(a) && a[i].start <= hi {
+				groupA = append(groupA, a[i])
+				if a[i].end > hi {
+					hi = a[i].end
+				}
+				i++
+				continue
+			}
+			if j < len(b) && b[j].start <= hi {
+				groupB = append(groupB, b[j])
+				if b[j].end > hi {
+					hi = b[j].end
+				}
+				j++
+				continue
+			}
+			break
+		}
+
+		out = append(out, baseLines[pos:lo]...)
+		oursLines := apply(baseLines, lo, hi, groupA)
+		theirsLines := apply(baseLines, lo, hi, groupB)
+		switch {
+		case len(groupB) == 0:
+			out = append(out, oursLines...)
+		case len(groupA) == 0:
+			out = append(out, theirsLines...)
+		case strings.Join(oursLines, "\n") == strings.Join(theirsLines, "\n") && len(oursLines) == len(theirsLines):
+			out = append(out, oursLines...)
+		default:
+			conflicts++
+			out = append(out, conflictOurs)
+			out = append(out, oursLines...)
+			out = append(out, conflictSep)
+			out = append(out, theirsLines...)
+			out = append(out, conflictTheirs)
+		}
+		pos = hi
+	}
+	out = append(out, baseLines[pos:]...)
+
+	content := strings.Join(out, "\n")
+	if len(out) > 0 && (strings.HasSuffix(ours, "\n") || strings.HasSuffix(theirs, "\n")) {
+		content += "\n"
+	}
+	return &MergeResult{
+		Content:   content,
+		Clean:     conflicts == 0,
+		Conflicts: conflicts,
+	}
+}
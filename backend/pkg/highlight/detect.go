@@ -0,0 +1,272 @@
+package highlight
+
+import (
+	"encoding/json"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Detection is a guessed language with a confidence between 0 and 1. An
+// empty Language means no guess.
+type Detection struct {
+	Language   string
+	Confidence float64
+}
+
+// aliases maps file extensions and the names used after Markdown fences to
+// languages.
+var aliases = map[string]string{
+	"go": "go", "golang": "go",
+	"js": "javascript", "jsx": "javascript", "mjs": "javascript", "cjs": "javascript", "javascript": "javascript", "node": "javascript",
+	"ts": "typescript", "tsx": "typescript", "mts": "typescript", "typescript": "typescript",
+	"py": "python", "pyw": "python", "python": "python", "python3": "python",
+	"java": "java",
+	"c":    "c", "h": "c",
+	"cpp": "cpp", "cc": "cpp", "cxx": "cpp", "hpp": "cpp", "hh": "cpp", "hxx": "cpp", "c++": "cpp",
+	"rs": "rust", "rust": "rust",
+	"php": "php",
+	"rb":  "ruby", "ruby": "ruby", "rake": "ruby", "gemspec": "ruby",
+	"sql": "sql", "psql": "sql", "mysql": "sql", "sqlite": "sql", "postgresql": "sql",
+	"html": "html", "htm": "html", "xhtml": "html",
+	"css": "css",
+	"sh":  "bash", "bash": "bash", "zsh": "bash", "shell": "bash", "console": "bash",
+	"json": "json", "jsonc": "json",
+	"xml": "xml", "svg": "xml", "xsd": "xml", "xsl": "xml", "plist": "xml",
+	"md": "markdown", "markdown": "markdown",
+}
+
+// fileNames maps well-known file names without a telling extension.
+var fileNames = map[string]string{
+	"Gemfile":  "ruby",
+	"Rakefile": "ruby",
+	".bashrc":  "bash",
+	".zshrc":   "bash",
+	".profile": "bash",
+}
+
+// LanguageAlias resolves a file extension or fence name like "py" or
+// "golang" to a language, or returns "" if it names none.
+func LanguageAlias(name string) string {
+	return aliases[strings.ToLower(strings.TrimPrefix(name, "."))]
+}
+
+type clue struct {
+	re     *regexp.Regexp
+	weight float64
+}
+
+func clues(weight float64, patterns ...string) []clue {
+	out := make([]clue, len(patterns))
+	for i, p := range patterns {
+		out[i] = clue{regexp.MustCompile(`(?m)` + p), weight}
+	}
+	return out
+}
+
+func join(groups ...[]clue) []clue {
+	var out []clue
+	for _, g := range groups {
+		out = append(out, g...)
+	}
+	return out
+}
+
+// contentClues are patterns typical of each language. Each pattern counts
+// once, however often it matches.
+var contentClues = map[string][]clue{
+	"go": join(
+		clues(4, `^package \w+\s*$`, `\berr != nil\b`),
+		clues(3, `^func (\(\w+ \*?\w+\) )?\w+\(`, `^import \($`, `\bfmt\.\w+\(`),
+		clues(1, `:=`, `\bchan\b`, `\bdefer\b`),
+	),
+	"python": join(
+		clues(4, `^\s*def \w+\(.*\)( -> [\w\[\], .]+)?:\s*$`, `__name__ == ['"]__main__['"]`),
+		clues(3, `^\s*class \w+(\(.*\))?:\s*$`, `\bself\.\w+`, `^\s*elif .*:\s*$`, `^from [\w.]+ import \w`),
+		clues(1, `^import \w+\s*$`, `\bprint\(`, `\bNone\b`, `\b(True|False)\b`, `^\s*(if|for|while) .*:\s*$`),
+	),
+	"javascript": join(
+		clues(4, `\bconsole\.log\(`, `\bmodule\.exports\b`),
+		clues(3, `\bfunction\s*\w*\s*\([^)]*\)\s*\{`, `\brequire\(['"]`, `\bdocument\.\w+`, `\bexport default\b`),
+		clues(2, `===|!==`, `\b(const|let) \w+ = `, `=>`),
+		clues(1, `;\s*$`, `\bundefined\b`),
+	),
+	"typescript": join(
+		clues(4, `\binterface \w+\s*(extends [\w, ]+)?\{`, `\b(const|let|var) \w+\s*:\s*[\w<>\[\]|]+ =`),
+		clues(3, `\w\)?\s*:\s*(string|number|boolean|any|void|unknown|never)\b`, `\btype \w+(<.*>)? = `, `\bas const\b`),
+		clues(2, `\b(private|public|protected|readonly) \w+\s*[:;=]`, `\bimport .* from ['"]`),
+	),
+	"java": join(
+		clues(5, `\bSystem\.out\.print`, `^import java\.`),
+		clues(4, `\bpublic (static )?(final )?(class|interface|enum|void) \w+`, `\bString\[\] args\b`),
+		clues(3, `@Override\b`, `\bprivate (static )?(final )?\w+(<.*>)? \w+\s*[;=]`),
+	),
+	"c": join(
+		clues(4, `^#include\s*<\w+\.h>`),
+		clues(3, `\bprintf\(`, `\bmalloc\(`, `\bint main\((void|int argc)`),
+		clues(2, `^#define \w+`, `\bstruct \w+\s*\{`),
+		clues(1, `\bNULL\b`, `->`),
+	),
+	"cpp": join(
+		clues(5, `\bstd::\w+`, `\bstd::cout\b|\bcout\s*<<`),
+		clues(4, `^#include\s*<\w+>\s*$`, `\btemplate\s*<`, `^using namespace \w+;`),
+		clues(3, `\bnullptr\b`, `\bclass \w+\s*(:\s*public \w+\s*)?\{`),
+	),
+	"rust": join(
+		clues(5, `\blet mut\b`, `\bprintln!\(`, `#\[derive\(`),
+		clues(4, `^\s*(pub )?fn \w+(<.*>)?\(`, `^use \w+(::\w+)+`, `\bimpl(<.*>)? \w+`),
+		clues(2, `&(mut |'\w+ )?str\b`, `\bSome\(|\bNone\b|\bOk\(|\bErr\(`, `::new\(`),
+	),
+	"php": join(
+		clues(8, `<\?php`),
+		clues(3, `\$\w+\s*=`, `\bfunction \w+\(\$`, `\$this->`),
+		clues(2, `^namespace [\w\\]+;`, `\becho\b`),
+	),
+	"ruby": join(
+		clues(4, `\battr_(accessor|reader|writer)\b`, `\bdo\s*\|[\w, ]+\|`),
+		clues(3, `^\s*def \w+[?!]?(\(.*\))?\s*$`, `^\s*end\s*$`, `\belsif\b`, `\bputs\b`, `^require ['"]`),
+		clues(1, `\.each\b`, `@\w+`, `\bnil\b`),
+	),
+	"sql": join(
+		clues(5, `\bSELECT\b[\s\S]*\bFROM\b`, `(?i)\binsert\s+into\b`, `(?i)\bcreate\s+table\b`, `(?i)\bupdate\s+\w+\s+set\b`, `(?i)\bdelete\s+from\b`),
+		clues(4, `(?i)^\s*select\s+[\w*,.\s]+\s+from\s+\w+\s*(where\b|;|$)`),
+		clues(3, `(?i)\b(inner|left|right|outer)\s+join\b`, `(?i)\b(group|order)\s+by\b`),
+		clues(1, `(?i)\bwhere\b`, `^--`),
+	),
+	"html": join(
+		clues(8, `(?i)<!doctype html`),
+		clues(4, `(?i)</(html|body|head|div|span|p|ul|li|table|a)>`),
+		clues(2, `(?i)<(div|span|p|a|img|ul|li|table|form|input)( [\w-]+(="[^"]*")?)*\s*/?>`),
+	),
+	"css": join(
+		clues(4, `^\s*@(media|import|keyframes|font-face)\b`, `^\s*[\w-]+\s*:\s*[^;{}]+;\s*$`),
+		clues(3, `^\s*[.#]?[\w-]+(\s*[,>+~ ]\s*[.#:]?[\w-]+)*\s*\{\s*$`),
+		clues(2, `\b\d+(px|em|rem|vh|vw)\b`, `#[0-9a-fA-F]{3,6}\b`),
+	),
+	"bash": join(
+		clues(5, `^\s*(if|while) \[\[? `, `^\s*fi\s*$`),
+		clues(3, `^\s*done\s*$`, `^\s*export \w+=`, `\|\s*(grep|awk|sed|xargs|sort|wc)\b`, `^\s*(sudo|apt-get|apt|brew|npm|pip|git|cd|mkdir|curl|chmod) `),
+		clues(1, `\becho `, `\$\{?\w+\}?`),
+	),
+	"xml": join(
+		clues(8, `^<\?xml`),
+		clues(3, `\bxmlns(:\w+)?="`, `<!\[CDATA\[`),
+	),
+	"markdown": join(
+		clues(4, "^```"),
+		clues(3, `^#{1,6} \S`),
+		clues(2, `\[[^\]]+\]\([^)]+\)`, `^\s*[-*] \S`, `\*\*\S[^*]*\*\*`),
+	),
+}
+
+// dialects score as their base language unless they show their own clues,
+// like types in TypeScript.
+var dialects = map[string]string{
+	"typescript": "javascript",
+	"cpp":        "c",
+}
+
+// Detect guesses the language of code from its file name, a shebang line
+// and its content, in that order of trust.
+func Detect(fileName, code string) Detection {
+	if fileName != "" {
+		base := path.Base(strings.ReplaceAll(fileName, `\`, "/"))
+		if language, ok := fileNames[base]; ok {
+			return Detection{Language: language, Confidence: 0.95}
+		}
+		if language := LanguageAlias(path.Ext(base)); language != "" {
+			confidence := 0.95
+			if language == "c" && strings.EqualFold(path.Ext(base), ".h") {
+				// Headers are shared by C and C++.
+				confidence = 0.6
+			}
+			return Detection{Language: language, Confidence: confidence}
+		}
+	}
+
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	trimmed := strings.TrimSpace(code)
+	if trimmed == "" {
+		return Detection{}
+	}
+
+	if strings.HasPrefix(trimmed, "#!") {
+		if language := shebangLanguage(strings.SplitN(trimmed, "\n", 2)[0]); language != "" {
+			return Detection{Language: language, Confidence: 0.9}
+		}
+	}
+
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return Detection{Language: "json", Confidence: 0.95}
+	}
+
+	return detectContent(code)
+}
+
+func shebangLanguage(line string) string {
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	switch interpreter {
+	case "sh", "bash", "zsh", "dash", "ksh":
+		return "bash"
+	case "python", "node", "ruby", "php":
+		return LanguageAlias(interpreter)
+	}
+	return ""
+}
+
+// detectContent scores the code against every language's clues. The
+// confidence grows with the winning score and shrinks with the runner-up's.
+func detectContent(code string) Detection {
+	scores := make(map[string]float64)
+	for language, cs := range contentClues {
+		for _, c := range cs {
+			if c.re.MatchString(code) {
+				scores[language] += c.weight
+			}
+		}
+	}
+	for dialect, base := range dialects {
+		if scores[dialect] > 0 {
+			scores[dialect] += scores[base]
+			delete(scores, base)
+		}
+	}
+
+	var best, second float64
+	language := ""
+	for _, name := range sortedKeys(scores) {
+		score := scores[name]
+		switch {
+		case score > best:
+			best, second, language = score, best, name
+		case score > second:
+			second = score
+		}
+	}
+	if best < 3 {
+		return Detection{}
+	}
+
+	confidence := best / (best + second) * math.Min(1, best/10)
+	confidence = math.Min(confidence, 0.9)
+	return Detection{Language: language, Confidence: math.Round(confidence*100) / 100}
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
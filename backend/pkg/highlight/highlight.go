@@ -0,0 +1,98 @@
+// Package highlight splits source code into classified tokens and renders
+// them as HTML, so every client shows snippets the same way. Lexing is done
+// by chroma; its token types are folded into the handful of types below.
+package highlight
+
+import "strings"
+
+// TokenType classifies a token. Text covers whitespace and anything no
+// lexer rule matched.
+type TokenType string
+
+const (
+	Text         TokenType = "text"
+	Keyword      TokenType = "keyword"
+	Type         TokenType = "type"
+	Builtin      TokenType = "builtin"
+	Constant     TokenType = "constant"
+	Name         TokenType = "name"
+	Function     TokenType = "function"
+	String       TokenType = "string"
+	Number       TokenType = "number"
+	Comment      TokenType = "comment"
+	Operator     TokenType = "operator"
+	Punctuation  TokenType = "punctuation"
+	Preprocessor TokenType = "preprocessor"
+	Variable     TokenType = "variable"
+	Property     TokenType = "property"
+	Tag          TokenType = "tag"
+	Attribute    TokenType = "attribute"
+	Heading      TokenType = "heading"
+	Emphasis     TokenType = "emphasis"
+)
+
+type Token struct {
+	Type  TokenType `json:"type"`
+	Value string    `json:"value"`
+}
+
+// Line is one line of highlighted code, without its line break.
+type Line struct {
+	Number      int     `json:"number"`
+	Highlighted bool    `json:"highlighted,omitempty"`
+	Tokens      []Token `json:"tokens"`
+}
+
+// Tokenize lexes code as the named language. Unknown languages come back as
+// a single Text token. Concatenating the values gives back code.
+func Tokenize(language, code string) []Token {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+
+	l := lexer(language)
+	if l == nil {
+		return []Token{{Type: Text, Value: code}}
+	}
+	tokens, err := lex(l, language, code)
+	if err != nil {
+		return []Token{{Type: Text, Value: code}}
+	}
+	return merge(tokens)
+}
+
+// Lines splits tokens at line breaks and numbers the lines from 1. A final
+// line break does not start another line.
+func Lines(tokens []Token) []Line {
+	lines := []Line{{Number: 1, Tokens: []Token{}}}
+	for _, tok := range tokens {
+		parts := strings.Split(tok.Value, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				lines = append(lines, Line{Number: len(lines) + 1, Tokens: []Token{}})
+			}
+			if part != "" {
+				cur := &lines[len(lines)-1]
+				cur.Tokens = append(cur.Tokens, Token{Type: tok.Type, Value: part})
+			}
+		}
+	}
+	if len(lines) > 1 && len(lines[len(lines)-1].Tokens) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// merge joins neighbouring tokens of the same type and drops empty ones.
+func merge(tokens []Token) []Token {
+	out := make([]Token, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.Value == "" {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].Type == tok.Type {
+			out[n-1].Value += tok.Value
+			continue
+		}
+		out = append(out, tok)
+	}
+	return out
+}
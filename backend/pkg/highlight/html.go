@@ -0,0 +1,57 @@
+package highlight
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// HTMLOptions customises RenderHTML.
+type HTMLOptions struct {
+	// Theme colours the output; nil means the default theme.
+	Theme       *Theme
+	LineNumbers bool
+}
+
+// RenderHTML renders lines as a <pre> block with inline styles, so it needs
+// no stylesheet. Each line is a span with a data-line attribute and every
+// token a span with a tok-<type> class for clients that restyle it.
+func RenderHTML(lines []Line, opts HTMLOptions) string {
+	theme := opts.Theme
+	if theme == nil {
+		theme, _ = LookupTheme(DefaultTheme)
+	}
+
+	width := len(strconv.Itoa(len(lines)))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<pre class="code-highlight theme-%s" style="background:%s;color:%s"><code>`,
+		html.EscapeString(theme.Name), theme.Background, theme.Foreground)
+	for _, line := range lines {
+		style := "display:block"
+		if line.Highlighted {
+			style += ";background:" + theme.HighlightBackground
+		}
+		fmt.Fprintf(&sb, `<span class="line" data-line="%d" style="%s">`, line.Number, style)
+		if opts.LineNumbers {
+			fmt.Fprintf(&sb, `<span class="line-number" style="color:%s;user-select:none;padding-right:1em">%*d</span>`,
+				theme.LineNumber, width, line.Number)
+		}
+		for _, tok := range line.Tokens {
+			value := html.EscapeString(tok.Value)
+			if tok.Type == Text {
+				sb.WriteString(value)
+				continue
+			}
+			fmt.Fprintf(&sb, `<span class="tok-%s"`, tok.Type)
+			if style := theme.Styles[tok.Type]; style != "" {
+				fmt.Fprintf(&sb, ` style="%s"`, style)
+			}
+			sb.WriteString(">" + value + "</span>")
+		}
+		sb.WriteString("\n</span>")
+	}
+	sb.WriteString("</code></pre>")
+	return sb.String()
+}
@@ -0,0 +1,118 @@
+package highlight
+
+import (
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// chromaLexers maps the languages snippets may declare to chroma's lexer
+// names.
+var chromaLexers = map[string]string{
+	"javascript": "JavaScript",
+	"typescript": "TypeScript",
+	"python":     "Python",
+	"go":         "Go",
+	"java":       "Java",
+	"c":          "C",
+	"cpp":        "C++",
+	"rust":       "Rust",
+	"php":        "PHP",
+	"ruby":       "Ruby",
+	"sql":        "SQL",
+	"css":        "CSS",
+	"bash":       "Bash",
+	"json":       "JSON",
+	"html":       "HTML",
+	"xml":        "XML",
+	"markdown":   "Markdown",
+}
+
+// lexer returns the chroma lexer for a language, or nil.
+func lexer(language string) chroma.Lexer {
+	name, ok := chromaLexers[language]
+	if !ok {
+		return nil
+	}
+	return lexers.Get(name)
+}
+
+// lex runs a chroma lexer over code. Lexers that want a final line break get
+// one added, which is cut off again so the tokens still join to code.
+func lex(l chroma.Lexer, language, code string) ([]Token, error) {
+	it, err := l.Tokenise(nil, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []Token
+	left := len(code)
+	for tok := it(); tok != chroma.EOF && left > 0; tok = it() {
+		value := tok.Value
+		if len(value) > left {
+			value = value[:left]
+		}
+		left -= len(value)
+		tokens = append(tokens, Token{Type: tokenType(language, tok.Type), Value: value})
+	}
+	return tokens, nil
+}
+
+// tokenType folds chroma's fine-grained token types into ours.
+func tokenType(language string, t chroma.TokenType) TokenType {
+	switch t {
+	case chroma.KeywordType, chroma.NameClass, chroma.NameException, chroma.NameNamespace:
+		return Type
+	case chroma.KeywordConstant, chroma.NameConstant, chroma.NameEntity, chroma.LiteralStringBoolean, chroma.LiteralOther:
+		return Constant
+	case chroma.NameBuiltin, chroma.NameBuiltinPseudo:
+		return Builtin
+	case chroma.NameFunction, chroma.NameFunctionMagic:
+		return Function
+	case chroma.NameDecorator:
+		return Preprocessor
+	case chroma.NameProperty:
+		return Property
+	case chroma.NameAttribute:
+		return Attribute
+	case chroma.NameTag:
+		// JSON object keys come out as tags.
+		if language == "json" {
+			return Property
+		}
+		return Tag
+	case chroma.LiteralDate:
+		return Number
+	case chroma.OperatorWord:
+		return Keyword
+	case chroma.GenericHeading, chroma.GenericSubheading:
+		return Heading
+	case chroma.GenericEmph, chroma.GenericStrong, chroma.GenericUnderline:
+		return Emphasis
+	case chroma.NameVariable, chroma.NameVariableAnonymous, chroma.NameVariableClass,
+		chroma.NameVariableGlobal, chroma.NameVariableInstance, chroma.NameVariableMagic:
+		return Variable
+	}
+
+	switch t.SubCategory() {
+	case chroma.CommentPreproc:
+		return Preprocessor
+	case chroma.LiteralString:
+		return String
+	case chroma.LiteralNumber:
+		return Number
+	}
+
+	switch t.Category() {
+	case chroma.Keyword:
+		return Keyword
+	case chroma.Name:
+		return Name
+	case chroma.Operator:
+		return Operator
+	case chroma.Punctuation:
+		return Punctuation
+	case chroma.Comment:
+		return Comment
+	}
+	return Text
+}
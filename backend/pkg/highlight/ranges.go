@@ -0,0 +1,52 @@
+package highlight
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive range of line numbers.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// ParseLineRanges parses a comma separated list of lines and ranges such as
+// "3-5,9". An empty string yields no ranges.
+func ParseLineRanges(s string) ([]LineRange, error) {
+	var ranges []LineRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil || start < 1 {
+			return nil, fmt.Errorf("invalid line %q", part)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(strings.TrimSpace(to))
+			if err != nil || end < start {
+				return nil, fmt.Errorf("invalid line range %q", part)
+			}
+		}
+		ranges = append(ranges, LineRange{Start: start, End: end})
+	}
+	return ranges, nil
+}
+
+// MarkLines sets Highlighted on the lines inside any of the ranges.
+func MarkLines(lines []Line, ranges []LineRange) {
+	for i := range lines {
+		for _, r := range ranges {
+			if lines[i].Number >= r.Start && lines[i].Number <= r.End {
+				lines[i].Highlighted = true
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package highlight
+
+import "sort"
+
+// Theme holds the colours used by RenderHTML. Styles are inline CSS
+// declarations per token type; types without a style use the foreground.
+type Theme struct {
+	Name                string
+	Background          string
+	Foreground          string
+	LineNumber          string
+	HighlightBackground string
+	Styles              map[TokenType]string
+}
+
+// DefaultTheme is used when no theme is asked for.
+const DefaultTheme = "github"
+
+var themes = map[string]*Theme{
+	"github": {
+		Name:                "github",
+		Background:          "#ffffff",
+		Foreground:          "#24292e",
+		LineNumber:          "#959da5",
+		HighlightBackground: "#fffbdd",
+		Styles: map[TokenType]string{
+			Keyword:      "color:#d73a49",
+			Type:         "color:#6f42c1",
+			Builtin:      "color:#005cc5",
+			Constant:     "color:#005cc5",
+			Function:     "color:#6f42c1",
+			String:       "color:#032f62",
+			Number:       "color:#005cc5",
+			Comment:      "color:#6a737d;font-style:italic",
+			Operator:     "color:#d73a49",
+			Preprocessor: "color:#d73a49",
+			Variable:     "color:#e36209",
+			Property:     "color:#005cc5",
+			Tag:          "color:#22863a",
+			Attribute:    "color:#6f42c1",
+			Heading:      "color:#005cc5;font-weight:bold",
+			Emphasis:     "font-style:italic",
+		},
+	},
+	"monokai": {
+		Name:                "monokai",
+		Background:          "#272822",
+		Foreground:          "#f8f8f2",
+		LineNumber:          "#90908a",
+		HighlightBackground: "#3e3d32",
+		Styles: map[TokenType]string{
+			Keyword:      "color:#f92672",
+			Type:         "color:#66d9ef;font-style:italic",
+			Builtin:      "color:#66d9ef",
+			Constant:     "color:#ae81ff",
+			Function:     "color:#a6e22e",
+			String:       "color:#e6db74",
+			Number:       "color:#ae81ff",
+			Comment:      "color:#75715e",
+			Operator:     "color:#f92672",
+			Preprocessor: "color:#f92672",
+			Variable:     "color:#fd971f",
+			Property:     "color:#66d9ef",
+			Tag:          "color:#f92672",
+			Attribute:    "color:#a6e22e",
+			Heading:      "color:#a6e22e;font-weight:bold",
+			Emphasis:     "font-style:italic",
+		},
+	},
+	"nord": {
+		Name:                "nord",
+		Background:          "#2e3440",
+		Foreground:          "#d8dee9",
+		LineNumber:          "#4c566a",
+		HighlightBackground: "#3b4252",
+		Styles: map[TokenType]string{
+			Keyword:      "color:#81a1c1",
+			Type:         "color:#8fbcbb",
+			Builtin:      "color:#88c0d0",
+			Constant:     "color:#81a1c1",
+			Function:     "color:#88c0d0",
+			String:       "color:#a3be8c",
+			Number:       "color:#b48ead",
+			Comment:      "color:#616e88;font-style:italic",
+			Operator:     "color:#81a1c1",
+			Preprocessor: "color:#5e81ac",
+			Variable:     "color:#d8dee9",
+			Property:     "color:#8fbcbb",
+			Tag:          "color:#81a1c1",
+			Attribute:    "color:#8fbcbb",
+			Heading:      "color:#88c0d0;font-weight:bold",
+			Emphasis:     "font-style:italic",
+		},
+	},
+}
+
+// LookupTheme returns the named theme, or the default one for an empty name.
+func LookupTheme(name string) (*Theme, bool) {
+	if name == "" {
+		name = DefaultTheme
+	}
+	theme, ok := themes[name]
+	return theme, ok
+}
+
+// ThemeNames lists the available themes in alphabetical order.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
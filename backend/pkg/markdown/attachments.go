@@ -0,0 +1,49 @@
+package markdown
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// AttachmentScheme prefixes link and image destinations that refer to a
+// file attached to the page, as in ![diagram](attachment:diagram.png).
+const AttachmentScheme = "attachment:"
+
+var attachmentRefRe = regexp.MustCompile(`\]\(\s*<?` + AttachmentScheme + `([^\s)>]+)`)
+
+// AttachmentRefs returns the names of the attachments src links to or
+// embeds, unescaped and without duplicates.
+func AttachmentRefs(src string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range attachmentRefRe.FindAllStringSubmatch(src, -1) {
+		name := attachmentName(m[1])
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// attachmentName undoes the percent-encoding that lets names with spaces
+// be written as link destinations.
+func attachmentName(ref string) string {
+	if name, err := url.PathUnescape(ref); err == nil {
+		return name
+	}
+	return ref
+}
+
+// linkURL resolves a link or image destination: attachment references go
+// through ResolveAttachment, everything else must be a safe URL.
+func (r *renderer) linkURL(dest string) (string, bool) {
+	if ref, ok := strings.CutPrefix(strings.TrimSpace(dest), AttachmentScheme); ok {
+		if r.opts.ResolveAttachment == nil {
+			return "", false
+		}
+		return r.opts.ResolveAttachment(attachmentName(ref))
+	}
+	return safeURL(dest)
+}
@@ -0,0 +1,53 @@
+package markdown
+
+import "strings"
+
+// FencedBlock is a fenced code block in a Markdown text. Lines count from
+// 1 and include the fences.
+type FencedBlock struct {
+	// Info is the first word after the opening fence, usually a language.
+	Info      string
+	Code      string
+	StartLine int
+	EndLine   int
+}
+
+// FencedBlocks returns the fenced code blocks of src that hold any code, in
+// order. Like the renderer, it runs an unclosed fence to the end of src.
+func FencedBlocks(src string) []FencedBlock {
+	var blocks []FencedBlock
+	lines := splitLines(src)
+	for i := 0; i < len(lines); i++ {
+		m := fenceRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+
+		block := FencedBlock{StartLine: i + 1}
+		if fields := strings.Fields(m[2]); len(fields) > 0 {
+			block.Info = languageClean.ReplaceAllString(fields[0], "")
+		}
+
+		var code []string
+		for i++; i < len(lines) && !closesFence(lines[i], m[1]); i++ {
+			code = append(code, lines[i])
+		}
+		block.EndLine = i + 1
+		if i == len(lines) {
+			block.EndLine = len(lines)
+		}
+
+		block.Code = strings.Join(code, "\n")
+		if strings.TrimSpace(block.Code) != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// closesFence reports whether line closes a fence opened with marker.
+func closesFence(line, marker string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, marker[:1]) && len(trimmed) >= len(marker) &&
+		strings.Trim(trimmed, marker[:1]) == ""
+}
@@ -0,0 +1,516 @@
+// Package markdown renders the Markdown used by wiki pages to HTML. Raw HTML
+// in the source is escaped rather than passed through, and only links with
+// safe schemes are emitted, so the output can be embedded as is.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Options customises rendering.
+type Options struct {
+	// ResolveWikiLink maps the target of a [[slug]] link to its URL and
+	// reports whether the page exists. Without it wiki links render as text.
+	ResolveWikiLink func(slug string) (href string, exists bool)
+	// ResolveAttachment maps the name in an attachment:name link or image
+	// to the file's URL, reporting false if there is no such attachment.
+	// Without it such links render as text.
+	ResolveAttachment func(name string) (href string, ok bool)
+}
+
+var (
+	headingRe     = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	ruleRe        = regexp.MustCompile(`^ {0,3}([-*_])(?:[ \t]*([-*_])){2,}[ \t]*$`)
+	fenceRe       = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`]*)$")
+	bulletRe      = regexp.MustCompile(`^ {0,3}([-*+])[ \t]+(.*)$`)
+	orderedRe     = regexp.MustCompile(`^ {0,3}(\d{1,9})[.)][ \t]+(.*)$`)
+	quoteRe       = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	autolinkRe    = regexp.MustCompile(`^<((?:https?|mailto):[^\s<>]+)>`)
+	languageClean = regexp.MustCompile(`[^A-Za-z0-9_+#.-]`)
+)
+
+// ToHTML renders src to sanitized HTML.
+func ToHTML(src string, opts Options) string {
+	r := &renderer{opts: opts}
+	var sb strings.Builder
+	r.blocks(&sb, splitLines(src))
+	return sb.String()
+}
+
+type renderer struct {
+	opts Options
+}
+
+func splitLines(src string) []string {
+	return strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// startsBlock reports whether line opens a block other than a paragraph,
+// which ends a running paragraph.
+func startsBlock(line string) bool {
+	return headingRe.MatchString(line) || ruleRe.MatchString(line) || fenceRe.MatchString(line) ||
+		bulletRe.MatchString(line) || orderedRe.MatchString(line) || quoteRe.MatchString(line)
+}
+
+func (r *renderer) blocks(sb *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case isBlank(line):
+			i++
+
+		case fenceRe.MatchString(line):
+			i = r.fence(sb, lines, i)
+
+		case headingRe.MatchString(line):
+			m := headingRe.FindStringSubmatch(line)
+			level := len(m[1])
+			fmt.Fprintf(sb, "<h%d>%s</h%d>\n", level, r.inline(m[2]), level)
+			i++
+
+		case ruleRe.MatchString(line) && sameRuleChars(line):
+			sb.WriteString("<hr>\n")
+			i++
+
+		case quoteRe.MatchString(line):
+			var inner []string
+			for i < len(lines) && !isBlank(lines[i]) {
+				if m := quoteRe.FindStringSubmatch(lines[i]); m != nil {
+					inner = append(inner, m[1])
+				} else {
+					inner = append(inner, lines[i])
+				}
+				i++
+			}
+			sb.WriteString("<blockquote>\n")
+			r.blocks(sb, inner)
+			sb.WriteString("</blockquote>\n")
+
+		case bulletRe.MatchString(line) || orderedRe.MatchString(line):
+			i = r.list(sb, lines, i)
+
+		default:
+			var para []string
+			for i < len(lines) && !isBlank(lines[i]) && (len(para) == 0 || !startsBlock(lines[i])) {
+				para = append(para, lines[i])
+				i++
+			}
+			sb.WriteString("<p>")
+			sb.WriteString(r.paragraph(para))
+			sb.WriteString("</p>\n")
+		}
+	}
+}
+
+func sameRuleChars(line string) bool {
+	trimmed := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, line)
+	return strings.Count(trimmed, trimmed[:1]) == len(trimmed)
+}
+
+func (r *renderer) fence(sb *strings.Builder, lines []string, i int) int {
+	m := fenceRe.FindStringSubmatch(lines[i])
+	marker := m[1]
+	language := ""
+	if fields := strings.Fields(m[2]); len(fields) > 0 {
+		language = languageClean.ReplaceAllString(fields[0], "")
+	}
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		if closesFence(lines[i], marker) {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	if language != "" {
+		fmt.Fprintf(sb, "<pre><code class=\"language-%s\">", html.EscapeString(language))
+	} else {
+		sb.WriteString("<pre><code>")
+	}
+	for _, line := range code {
+		sb.WriteString(html.EscapeString(line))
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("</code></pre>\n")
+	return i
+}
+
+func (r *renderer) list(sb *strings.Builder, lines []string, i int) int {
+	ordered := !bulletRe.MatchString(lines[i])
+	var items [][]string
+
+	for i < len(lines) {
+		line := lines[i]
+		var m []string
+		if ordered {
+			m = orderedRe.FindStringSubmatch(line)
+		} else {
+			m = bulletRe.FindStringSubmatch(line)
+		}
+
+		switch {
+		case m != nil:
+			if len(items) == 0 && ordered {
+				if start, err := strconv.Atoi(m[1]); err == nil && start != 1 {
+					fmt.Fprintf(sb, "<ol start=\"%d\">\n", start)
+				} else {
+					sb.WriteString("<ol>\n")
+				}
+			} else if len(items) == 0 {
+				sb.WriteString("<ul>\n")
+			}
+			items = append(items, []string{m[2]})
+			i++
+
+		case isBlank(line):
+			// A blank line continues the list only if indented content or
+			// another item follows.
+			if i+1 < len(lines) && isIndented(lines[i+1]) {
+				items[len(items)-1] = append(items[len(items)-1], "")
+				i++
+				continue
+			}
+			if i+1 < len(lines) && (ordered && orderedRe.MatchString(lines[i+1]) ||
+				!ordered && bulletRe.MatchString(lines[i+1])) {
+				i++
+				continue
+			}
+			return r.closeList(sb, items, ordered, i)
+
+		case isIndented(line):
+			items[len(items)-1] = append(items[len(items)-1], dedent(line))
+			i++
+
+		case !startsBlock(line) && !isBlank(lines[i-1]):
+			// Lazy continuation of the item's paragraph.
+			items[len(items)-1] = append(items[len(items)-1], line)
+			i++
+
+		default:
+			return r.closeList(sb, items, ordered, i)
+		}
+	}
+	return r.closeList(sb, items, ordered, i)
+}
+
+func (r *renderer) closeList(sb *strings.Builder, items [][]string, ordered bool, i int) int {
+	for _, item := range items {
+		var inner strings.Builder
+		r.blocks(&inner, item)
+		body := strings.TrimSuffix(inner.String(), "\n")
+		// An item's leading paragraph is rendered without <p> unless the
+		// item holds several paragraphs.
+		if strings.HasPrefix(body, "<p>") && strings.Count(body, "<p>") == 1 {
+			closing := strings.Index(body, "</p>")
+			body = body[len("<p>"):closing] + body[closing+len("</p>"):]
+		}
+		sb.WriteString("<li>")
+		sb.WriteString(body)
+		sb.WriteString("</li>\n")
+	}
+	if ordered {
+		sb.WriteString("</ol>\n")
+	} else {
+		sb.WriteString("</ul>\n")
+	}
+	return i
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+}
+
+func dedent(line string) string {
+	if strings.HasPrefix(line, "\t") {
+		return line[1:]
+	}
+	for n := 0; n < 4 && strings.HasPrefix(line, " "); n++ {
+		line = line[1:]
+	}
+	return line
+}
+
+// paragraph renders the lines of a paragraph; two trailing spaces or a
+// trailing backslash make a hard line break.
+func (r *renderer) paragraph(lines []string) string {
+	var sb strings.Builder
+	for i, line := range lines {
+		text := strings.TrimLeft(line, " ")
+		hardBreak := false
+		if i < len(lines)-1 {
+			if strings.HasSuffix(text, "  ") {
+				hardBreak = true
+			} else if strings.HasSuffix(text, "\\") {
+				hardBreak = true
+				text = strings.TrimSuffix(text, "\\")
+			}
+		}
+		sb.WriteString(r.inline(strings.TrimRight(text, " ")))
+		if i < len(lines)-1 {
+			if hardBreak {
+				sb.WriteString("<br>")
+			}
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+func (r *renderer) inline(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			sb.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if end, code, ok := codeSpan(s, i); ok {
+				sb.WriteString("<code>")
+				sb.WriteString(html.EscapeString(code))
+				sb.WriteString("</code>")
+				i = end
+				continue
+			}
+			run := backtickRun(s, i)
+			sb.WriteString(s[i : i+run])
+			i += run
+			continue
+
+		case strings.HasPrefix(s[i:], "[["):
+			if end, target, label, ok := parseWikiLink(s, i); ok {
+				r.wikiLink(&sb, target, label)
+				i = end
+				continue
+			}
+
+		case c == '!' && strings.HasPrefix(s[i:], "!["):
+			if end, alt, dest, ok := parseLink(s, i+1); ok {
+				if url, safe := r.linkURL(dest); safe {
+					fmt.Fprintf(&sb, "<img src=\"%s\" alt=\"%s\">", html.EscapeString(url), html.EscapeString(alt))
+				} else {
+					sb.WriteString(html.EscapeString(alt))
+				}
+				i = end
+				continue
+			}
+
+		case c == '[':
+			if end, label, dest, ok := parseLink(s, i); ok {
+				if url, safe := r.linkURL(dest); safe {
+					fmt.Fprintf(&sb, "<a href=\"%s\" rel=\"nofollow noopener noreferrer\">%s</a>", html.EscapeString(url), r.inline(label))
+				} else {
+					sb.WriteString(r.inline(label))
+				}
+				i = end
+				continue
+			}
+
+		case c == '<':
+			if m := autolinkRe.FindStringSubmatch(s[i:]); m != nil {
+				url := html.EscapeString(m[1])
+				fmt.Fprintf(&sb, "<a href=\"%s\" rel=\"nofollow noopener noreferrer\">%s</a>", url, url)
+				i += len(m[0])
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if end, tag, inner, ok := emphasis(s, i); ok {
+				fmt.Fprintf(&sb, "<%s>%s</%s>", tag, r.inline(inner), tag)
+				i = end
+				continue
+			}
+			// Leave the whole delimiter run literal so its tail is not
+			// mistaken for a shorter delimiter.
+			run := delimiterRun(s, i)
+			sb.WriteString(s[i : i+run])
+			i += run
+			continue
+		}
+
+		sb.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return sb.String()
+}
+
+func (r *renderer) wikiLink(sb *strings.Builder, target, label string) {
+	if r.opts.ResolveWikiLink == nil {
+		sb.WriteString(html.EscapeString(label))
+		return
+	}
+	href, exists := r.opts.ResolveWikiLink(target)
+	class := "wiki-link"
+	if !exists {
+		class += " wiki-link-broken"
+	}
+	fmt.Fprintf(sb, "<a href=\"%s\" class=\"%s\">%s</a>", html.EscapeString(href), class, html.EscapeString(label))
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func backtickRun(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+// codeSpan parses a code span opening at s[i]: a run of backticks closed by
+// a run of the same length.
+func codeSpan(s string, i int) (end int, code string, ok bool) {
+	run := backtickRun(s, i)
+	for j := i + run; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		closing := backtickRun(s, j)
+		if closing == run {
+			code = s[i+run : j]
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+				code = code[1 : len(code)-1]
+			}
+			return j + closing, code, true
+		}
+		j += closing
+	}
+	return 0, "", false
+}
+
+// parseLink parses [label](destination) starting at the '[' in s[i].
+func parseLink(s string, i int) (end int, label, dest string, ok bool) {
+	depth := 0
+	closeBracket := -1
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth == 0 {
+			closeBracket = j
+			break
+		}
+	}
+	if closeBracket < 0 || closeBracket+1 >= len(s) || s[closeBracket+1] != '(' {
+		return 0, "", "", false
+	}
+
+	depth = 0
+	for j := closeBracket + 1; j < len(s); j++ {
+		switch s[j] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if depth == 0 {
+			dest = strings.TrimSpace(s[closeBracket+2 : j])
+			// Drop an optional "title".
+			if k := strings.IndexAny(dest, " \t"); k >= 0 {
+				dest = dest[:k]
+			}
+			dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+			return j + 1, s[i+1 : closeBracket], dest, true
+		}
+	}
+	return 0, "", "", false
+}
+
+func delimiterRun(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+// emphasis parses **strong**, __strong__, *em*, _em_ and ~~del~~ starting at
+// s[i]. Underscores inside words do not count, so snake_case stays intact.
+func emphasis(s string, i int) (end int, tag, inner string, ok bool) {
+	c := s[i]
+	run := delimiterRun(s, i)
+
+	var delim string
+	switch {
+	case c == '~' && run == 2:
+		delim, tag = "~~", "del"
+	case c != '~' && run == 2:
+		delim, tag = s[i:i+2], "strong"
+	case c != '~' && run == 1:
+		delim, tag = s[i:i+1], "em"
+	default:
+		return 0, "", "", false
+	}
+
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return 0, "", "", false
+	}
+	start := i + len(delim)
+	if start >= len(s) || s[start] == ' ' {
+		return 0, "", "", false
+	}
+
+	for j := start + 1; j+len(delim) <= len(s); j++ {
+		if s[j] == '`' {
+			if codeEnd, _, isCode := codeSpan(s, j); isCode {
+				j = codeEnd - 1
+				continue
+			}
+		}
+		if !strings.HasPrefix(s[j:], delim) || s[j-1] == ' ' || delimiterRun(s, j) != len(delim) {
+			continue
+		}
+		if c == '_' && j+len(delim) < len(s) && isWordByte(s[j+len(delim)]) {
+			continue
+		}
+		return j + len(delim), tag, s[start:j], true
+	}
+	return 0, "", "", false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// safeURL accepts relative URLs and absolute ones with an http, https or
+// mailto scheme.
+func safeURL(raw string) (string, bool) {
+	url := strings.TrimSpace(raw)
+	if url == "" {
+		return "", false
+	}
+	if k := strings.IndexAny(url, ":/?#"); k >= 0 && url[k] == ':' {
+		switch strings.ToLower(url[:k]) {
+		case "http", "https", "mailto":
+		default:
+			return "", false
+		}
+	}
+	return url, true
+}
@@ -0,0 +1,119 @@
+package markdown
+
+import "strings"
+
+// parseWikiLink parses [[target]] or [[target|label]] starting at s[i].
+func parseWikiLink(s string, i int) (end int, target, label string, ok bool) {
+	closing := strings.Index(s[i+2:], "]]")
+	if closing < 0 {
+		return 0, "", "", false
+	}
+	inner := s[i+2 : i+2+closing]
+	if strings.ContainsAny(inner, "[]\n") {
+		return 0, "", "", false
+	}
+
+	target, label, hasLabel := strings.Cut(inner, "|")
+	target = strings.TrimSpace(target)
+	label = strings.TrimSpace(label)
+	if target == "" {
+		return 0, "", "", false
+	}
+	if !hasLabel || label == "" {
+		label = target
+	}
+	return i + 2 + closing + 2, target, label, true
+}
+
+// eachWikiLink calls fn with the byte range, target and explicit label of
+// every wiki link in src, skipping fenced code blocks and code spans the
+// same way rendering does.
+func eachWikiLink(src string, fn func(start, end int, target, label string)) {
+	inFence := ""
+	offset := 0
+	for _, line := range strings.SplitAfter(src, "\n") {
+		lineStart := offset
+		offset += len(line)
+
+		trimmed := strings.TrimSpace(line)
+		if inFence != "" {
+			if strings.HasPrefix(trimmed, inFence) && strings.Trim(trimmed, inFence[:1]) == "" {
+				inFence = ""
+			}
+			continue
+		}
+		if m := fenceRe.FindStringSubmatch(strings.TrimRight(line, "\r\n")); m != nil {
+			inFence = m[1]
+			continue
+		}
+
+		for i := 0; i < len(line); {
+			switch {
+			case line[i] == '\\':
+				i += 2
+			case line[i] == '`':
+				if end, _, ok := codeSpan(line, i); ok {
+					i = end
+				} else {
+					i += backtickRun(line, i)
+				}
+			case strings.HasPrefix(line[i:], "[["):
+				end, target, label, ok := parseWikiLink(line, i)
+				if !ok {
+					i++
+					continue
+				}
+				inner := line[i+2 : end-2]
+				if !strings.Contains(inner, "|") {
+					label = ""
+				}
+				fn(lineStart+i, lineStart+end, target, label)
+				i = end
+			default:
+				i++
+			}
+		}
+	}
+}
+
+// WikiLinks returns the distinct targets of the wiki links in src, in order
+// of first appearance.
+func WikiLinks(src string) []string {
+	seen := make(map[string]bool)
+	var targets []string
+	eachWikiLink(src, func(_, _ int, target, _ string) {
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	})
+	return targets
+}
+
+// RewriteWikiLinks points every wiki link to from at to instead, keeping
+// explicit labels. It returns the new source and the number of links
+// rewritten.
+func RewriteWikiLinks(src, from, to string) (string, int) {
+	var sb strings.Builder
+	last, count := 0, 0
+	eachWikiLink(src, func(start, end int, target, label string) {
+		if target != from {
+			return
+		}
+		sb.WriteString(src[last:start])
+		sb.WriteString("[[")
+		sb.WriteString(to)
+		if label != "" {
+			sb.WriteString("|")
+			sb.WriteString(label)
+		}
+		sb.WriteString("]]")
+		last = end
+		count++
+	})
+	if count == 0 {
+		return src, 0
+	}
+	sb.WriteString(src[last:])
+	return sb.String(), count
+}
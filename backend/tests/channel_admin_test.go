@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestFullChannelAdminRights(t *testing.T) {
+	r := models.FullChannelAdminRights()
+	if !r.CanPost || !r.CanEditPosts || !r.CanDeletePosts ||
+		!r.CanManageSubscribers || !r.CanManageWiki || !r.CanManageRSS {
+		t.Errorf("owner rights are missing a right: %+v", r)
+	}
+}
+
+func TestChannelAdminToResponse(t *testing.T) {
+	promoter := uuid.New()
+	admin := models.ChannelAdmin{
+		ChannelID:    uuid.New(),
+		UserID:       uuid.New(),
+		Rights:       models.ChannelAdminRights{CanPost: true, CanManageWiki: true},
+		PromotedByID: promoter,
+	}
+
+	resp := admin.ToResponse()
+	if resp.IsOwner {
+		t.Error("an admin must not be reported as owner")
+	}
+	if resp.PromotedByID == nil || *resp.PromotedByID != promoter {
+		t.Errorf("expected promoted_by_id %s, got %v", promoter, resp.PromotedByID)
+	}
+	if !resp.Rights.CanPost || resp.Rights.CanDeletePosts {
+		t.Errorf("rights not carried over: %+v", resp.Rights)
+	}
+}
+
+func TestDemotedAuthorCannotChangePosts(t *testing.T) {
+	db := newSQLiteDB(t, &models.User{}, &models.Channel{}, &models.ChannelAdmin{}, &models.ChannelPost{})
+	svc := services.NewChannelService(db, nil)
+
+	owner := models.User{Phone: "+10000000001", PasswordHash: "x"}
+	author := models.User{Phone: "+10000000002", PasswordHash: "x"}
+	mustCreate(t, db, &owner)
+	mustCreate(t, db, &author)
+
+	channel := models.Channel{Name: "News", OwnerID: owner.ID}
+	mustCreate(t, db, &channel)
+	admin := models.ChannelAdmin{ChannelID: channel.ID, UserID: author.ID, PromotedByID: owner.ID,
+		Rights: models.ChannelAdminRights{CanPost: true}}
+	mustCreate(t, db, &admin)
+
+	post := models.ChannelPost{ChannelID: channel.ID, AuthorID: author.ID, Content: "hello"}
+	mustCreate(t, db, &post)
+
+	if !svc.CanEditPost(&channel, &post, author.ID) || !svc.CanDeletePost(&channel, &post, author.ID) {
+		t.Fatal("an admin with can_post should be able to change their own post")
+	}
+
+	if err := db.Model(&admin).Update("can_post", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if svc.CanEditPost(&channel, &post, author.ID) || svc.CanDeletePost(&channel, &post, author.ID) {
+		t.Error("an author who lost can_post should not change their post")
+	}
+
+	if err := db.Delete(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	if svc.CanEditPost(&channel, &post, author.ID) || svc.CanDeletePost(&channel, &post, author.ID) {
+		t.Error("a demoted author should not change their post")
+	}
+
+	if !svc.CanEditPost(&channel, &post, owner.ID) || !svc.CanDeletePost(&channel, &post, owner.ID) {
+		t.Error("the owner should still change any post")
+	}
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestNormalizeChannelHandle(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"golang_news", "golang_news", false},
+		{"@GolangNews", "golangnews", false},
+		{"  @daily_digest ", "daily_digest", false},
+		{"abcd", "", true},
+		{"1channel", "", true},
+		{"has-dash", "", true},
+		{"has space", "", true},
+		{"a23456789012345678901234567890123", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := models.NormalizeChannelHandle(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeChannelHandle(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeChannelHandle(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// The handlers check HandleAvailable first, but two requests can both pass
+// that check; the unique index decides and the loser gets ErrHandleTaken.
+func TestChannelHandleTakenOnWrite(t *testing.T) {
+	db := newSQLiteDB(t, &models.User{}, &models.Channel{}, &models.ChannelSubscriber{},
+		&models.ChannelSubscriptionEvent{})
+	svc := services.NewChannelService(db, nil)
+	ctx := context.Background()
+
+	owner := models.User{Phone: "+10000000001", PasswordHash: "x"}
+	mustCreate(t, db, &owner)
+
+	handle := "news"
+	first := models.Channel{Name: "News", Handle: &handle, OwnerID: owner.ID}
+	if err := svc.CreateChannel(ctx, &first); err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+
+	second := models.Channel{Name: "More news", Handle: &handle, OwnerID: owner.ID}
+	if err := svc.CreateChannel(ctx, &second); !errors.Is(err, services.ErrHandleTaken) {
+		t.Errorf("creating with a taken handle: got %v, want ErrHandleTaken", err)
+	}
+
+	third := models.Channel{Name: "Other", OwnerID: owner.ID}
+	if err := svc.CreateChannel(ctx, &third); err != nil {
+		t.Fatalf("CreateChannel without handle: %v", err)
+	}
+	if err := svc.UpdateChannel(ctx, &third, map[string]interface{}{"handle": handle}); !errors.Is(err, services.ErrHandleTaken) {
+		t.Errorf("renaming to a taken handle: got %v, want ErrHandleTaken", err)
+	}
+	if err := svc.UpdateChannel(ctx, &third, map[string]interface{}{"handle": "other"}); err != nil {
+		t.Errorf("renaming to a free handle: %v", err)
+	}
+
+	var count int64
+	db.Model(&models.Channel{}).Count(&count)
+	if count != 2 {
+		t.Errorf("got %d channels, want 2", count)
+	}
+}
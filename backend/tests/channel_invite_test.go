@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestChannelInviteLinkIsUsable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	limit := 2
+
+	tests := []struct {
+		name string
+		link models.ChannelInviteLink
+		want bool
+	}{
+		{"plain link", models.ChannelInviteLink{}, true},
+		{"revoked", models.ChannelInviteLink{IsRevoked: true}, false},
+		{"expired", models.ChannelInviteLink{ExpiresAt: &past}, false},
+		{"not yet expired", models.ChannelInviteLink{ExpiresAt: &future}, true},
+		{"under usage limit", models.ChannelInviteLink{UsageLimit: &limit, UsageCount: 1}, true},
+		{"usage limit reached", models.ChannelInviteLink{UsageLimit: &limit, UsageCount: 2}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.link.IsUsable(now); got != tt.want {
+				t.Errorf("IsUsable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinRequestApprovalRespectsUsageLimit(t *testing.T) {
+	db := newSQLiteDB(t, &models.User{}, &models.Channel{}, &models.ChannelSubscriber{},
+		&models.ChannelSubscriptionEvent{}, &models.ChannelInviteLink{}, &models.ChannelJoinRequest{})
+	svc := services.NewChannelService(db, nil)
+	ctx := context.Background()
+
+	users := make([]models.User, 4)
+	for i := range users {
+		users[i] = models.User{Phone: fmt.Sprintf("+1000000000%d", i+1), PasswordHash: "x"}
+		mustCreate(t, db, &users[i])
+	}
+	owner := users[0]
+
+	channel := models.Channel{Name: "Club", OwnerID: owner.ID}
+	if err := svc.CreateChannel(ctx, &channel); err != nil {
+		t.Fatal(err)
+	}
+
+	limit := 1
+	link, err := svc.CreateInviteLink(ctx, channel.ID, owner.ID, models.CreateInviteLinkRequest{
+		RequiresApproval: true,
+		UsageLimit:       &limit,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := svc.JoinByInvite(ctx, link, users[1].ID, nil)
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	second, err := svc.JoinByInvite(ctx, link, users[2].ID, nil)
+	if err != nil {
+		t.Fatalf("second request: %v", err)
+	}
+
+	if _, err := svc.ReviewJoinRequest(ctx, channel.ID, first.ID, owner.ID, true); err != nil {
+		t.Fatalf("approving the first request: %v", err)
+	}
+	if _, err := svc.ReviewJoinRequest(ctx, channel.ID, second.ID, owner.ID, true); !errors.Is(err, services.ErrInviteInvalid) {
+		t.Errorf("approving past the usage limit: got %v, want ErrInviteInvalid", err)
+	}
+	if svc.IsSubscribed(channel.ID, users[2].ID) {
+		t.Error("the second requester should not be subscribed")
+	}
+
+	var stored models.ChannelJoinRequest
+	if err := db.First(&stored, "id = ?", second.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.JoinRequestPending {
+		t.Errorf("second request status = %s, want pending", stored.Status)
+	}
+	if _, err := svc.ReviewJoinRequest(ctx, channel.ID, second.ID, owner.ID, false); err != nil {
+		t.Errorf("rejecting the second request: %v", err)
+	}
+
+	var used models.ChannelInviteLink
+	if err := db.First(&used, "id = ?", link.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if used.UsageCount != 1 {
+		t.Errorf("usage count = %d, want 1", used.UsageCount)
+	}
+
+	// link still holds the state from before any approval.
+	if _, err := svc.JoinByInvite(ctx, link, users[3].ID, nil); !errors.Is(err, services.ErrInviteInvalid) {
+		t.Errorf("requesting through a used-up link: got %v, want ErrInviteInvalid", err)
+	}
+}
+
+func TestJoinRequestApprovalOnRevokedLink(t *testing.T) {
+	db := newSQLiteDB(t, &models.User{}, &models.Channel{}, &models.ChannelSubscriber{},
+		&models.ChannelSubscriptionEvent{}, &models.ChannelInviteLink{}, &models.ChannelJoinRequest{})
+	svc := services.NewChannelService(db, nil)
+	ctx := context.Background()
+
+	owner := models.User{Phone: "+10000000001", PasswordHash: "x"}
+	requester := models.User{Phone: "+10000000002", PasswordHash: "x"}
+	mustCreate(t, db, &owner)
+	mustCreate(t, db, &requester)
+
+	channel := models.Channel{Name: "Club", OwnerID: owner.ID}
+	if err := svc.CreateChannel(ctx, &channel); err != nil {
+		t.Fatal(err)
+	}
+	link, err := svc.CreateInviteLink(ctx, channel.ID, owner.ID, models.CreateInviteLinkRequest{RequiresApproval: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := svc.JoinByInvite(ctx, link, requester.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.RevokeInviteLink(ctx, channel.ID, link.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ReviewJoinRequest(ctx, channel.ID, request.ID, owner.ID, true); !errors.Is(err, services.ErrInviteInvalid) {
+		t.Errorf("approving through a revoked link: got %v, want ErrInviteInvalid", err)
+	}
+	if svc.IsSubscribed(channel.ID, requester.ID) {
+		t.Error("the requester should not be subscribed")
+	}
+}
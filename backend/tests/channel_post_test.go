@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestValidateEntities(t *testing.T) {
+	url := "https://example.com"
+
+	tests := []struct {
+		name     string
+		content  string
+		entities []models.MessageEntity
+		wantErr  bool
+	}{
+		{"no entities", "hello", nil, false},
+		{"bold in range", "hello world", []models.MessageEntity{{Type: models.EntityTypeBold, Offset: 6, Length: 5}}, false},
+		{"counts runes", "привет мир", []models.MessageEntity{{Type: models.EntityTypeItalic, Offset: 7, Length: 3}}, false},
+		{"past the end", "hello", []models.MessageEntity{{Type: models.EntityTypeBold, Offset: 3, Length: 5}}, true},
+		{"empty span", "hello", []models.MessageEntity{{Type: models.EntityTypeBold, Offset: 0, Length: 0}}, true},
+		{"unknown type", "hello", []models.MessageEntity{{Type: "blink", Offset: 0, Length: 5}}, true},
+		{"link needs url", "site", []models.MessageEntity{{Type: models.EntityTypeLink, Offset: 0, Length: 4}}, true},
+		{"link with url", "site", []models.MessageEntity{{Type: models.EntityTypeLink, Offset: 0, Length: 4, URL: &url}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := models.ValidateEntities(tt.content, tt.entities)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEntities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPostCursor(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	id := uuid.New()
+
+	cursor := services.EncodePostCursor(createdAt, id)
+	gotTime, gotID, err := services.DecodePostCursor(cursor)
+	if err != nil {
+		t.Fatalf("DecodePostCursor() error = %v", err)
+	}
+	if !gotTime.Equal(createdAt) || gotID != id {
+		t.Errorf("Cursor round trip mismatch: got %v %v, want %v %v", gotTime, gotID, createdAt, id)
+	}
+
+	if _, _, err := services.DecodePostCursor("not-a-cursor"); err == nil {
+		t.Error("Expected error for malformed cursor")
+	}
+}
+
+func TestDiscussionThreadResponses(t *testing.T) {
+	postID := uuid.New()
+	rootID := uuid.New()
+
+	post := models.ChannelPost{ID: postID, DiscussionMessageID: &rootID, CommentCount: 3}
+	resp := post.ToResponse()
+	if resp.DiscussionMessageID == nil || *resp.DiscussionMessageID != rootID {
+		t.Errorf("expected discussion_message_id %s, got %v", rootID, resp.DiscussionMessageID)
+	}
+	if resp.CommentCount != 3 {
+		t.Errorf("expected comment_count 3, got %d", resp.CommentCount)
+	}
+
+	root := models.Message{ID: rootID, ChannelPostID: &postID}
+	if r := root.ToResponse(); r.ChannelPostID == nil || *r.ChannelPostID != postID {
+		t.Errorf("thread root should point back at post %s, got %v", postID, r.ChannelPostID)
+	}
+
+	comment := models.Message{ID: uuid.New(), ThreadRootID: &rootID, ReplyToID: &rootID}
+	if r := comment.ToResponse(); r.ThreadRootID == nil || *r.ThreadRootID != rootID {
+		t.Errorf("comment should carry thread_root_id %s, got %v", rootID, r.ThreadRootID)
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestBuildSubscriberGrowth(t *testing.T) {
+	from := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)
+
+	changes := []services.DailySubscriptionChange{
+		{Day: "2026-05-02", Joined: 5, Left: 1},
+		{Day: "2026-05-04", Joined: 0, Left: 3},
+	}
+
+	points := services.BuildSubscriberGrowth(from, to, 100, changes)
+	if len(points) != 4 {
+		t.Fatalf("expected one point per day, got %d", len(points))
+	}
+
+	want := []struct {
+		date        string
+		net         int64
+		subscribers int64
+	}{
+		{"2026-05-01", 0, 100},
+		{"2026-05-02", 4, 104},
+		{"2026-05-03", 0, 104},
+		{"2026-05-04", -3, 101},
+	}
+
+	for i, w := range want {
+		p := points[i]
+		if p.Date != w.date || p.Net != w.net || p.Subscribers != w.subscribers {
+			t.Errorf("point %d = %+v, want date %s net %d subscribers %d", i, p, w.date, w.net, w.subscribers)
+		}
+	}
+}
+
+func TestBuildSubscriberGrowth_SingleDay(t *testing.T) {
+	day := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	points := services.BuildSubscriberGrowth(day, day, 7, nil)
+	if len(points) != 1 || points[0].Subscribers != 7 {
+		t.Errorf("expected a single point with 7 subscribers, got %+v", points)
+	}
+}
+
+func TestCreateChannelLogsOwnerSubscription(t *testing.T) {
+	db := newSQLiteDB(t, &models.User{}, &models.Channel{}, &models.ChannelSubscriber{},
+		&models.ChannelSubscriptionEvent{})
+	svc := services.NewChannelService(db, nil)
+
+	owner := models.User{Phone: "+10000000001", PasswordHash: "x"}
+	mustCreate(t, db, &owner)
+
+	channel := models.Channel{Name: "News", OwnerID: owner.ID, IsPublic: true}
+	if err := svc.CreateChannel(context.Background(), &channel); err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+
+	var stored models.Channel
+	if err := db.First(&stored, "id = ?", channel.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.SubscriberCount != 1 || channel.SubscriberCount != 1 {
+		t.Errorf("subscriber count = %d (returned %d), want 1", stored.SubscriberCount, channel.SubscriberCount)
+	}
+	if !svc.IsSubscribed(channel.ID, owner.ID) {
+		t.Error("owner should be subscribed to their channel")
+	}
+
+	var events []models.ChannelSubscriptionEvent
+	if err := db.Where("channel_id = ?", channel.ID).Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].UserID != owner.ID || !events[0].Joined {
+		t.Errorf("got events %+v, want one join by the owner", events)
+	}
+}
@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+func newCodeTestSnippet(t *testing.T, code string) (*gorm.DB, *services.CodeService, *models.CodeSnippet, uuid.UUID) {
+	t.Helper()
+	db := newSQLiteDB(t, &models.User{}, &models.CodeSnippet{}, &models.CodeSnippetVersion{}, &models.CodeComment{})
+
+	author := models.User{Phone: "+10000000001", PasswordHash: "x"}
+	mustCreate(t, db, &author)
+	snippet := models.CodeSnippet{
+		MessageID:   uuid.New(),
+		ChatID:      uuid.New(),
+		Language:    models.CodeLanguageGo,
+		Code:        code,
+		CreatedByID: author.ID,
+	}
+	if err := services.CreateCodeSnippet(db, &snippet); err != nil {
+		t.Fatal(err)
+	}
+	return db, services.NewCodeService(db), &snippet, author.ID
+}
+
+// saveCode saves new code as the snippet's next version.
+func saveCode(t *testing.T, svc *services.CodeService, snippet *models.CodeSnippet, userID uuid.UUID, code string) {
+	t.Helper()
+	previous := *snippet
+	snippet.Code = code
+	if _, err := svc.SaveVersion(context.Background(), &previous, snippet, userID, ""); err != nil {
+		t.Fatalf("SaveVersion: %v", err)
+	}
+}
+
+func TestCodeCommentsFollowTheirLines(t *testing.T) {
+	db, svc, snippet, userID := newCodeTestSnippet(t, "a\nb\nc\nd\n")
+	ctx := context.Background()
+
+	moving, err := svc.CreateComment(ctx, snippet, userID, "on c", nil, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changing, err := svc.CreateComment(ctx, snippet, userID, "on a", nil, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two lines in front move the thread on c and d down; a changes, so its
+	// thread goes outdated.
+	saveCode(t, svc, snippet, userID, "x\ny\nA\nb\nc\nd\n")
+
+	load := func(id uuid.UUID) models.CodeComment {
+		var comment models.CodeComment
+		if err := db.First(&comment, "id = ?", id).Error; err != nil {
+			t.Fatal(err)
+		}
+		return comment
+	}
+
+	got := load(moving.ID)
+	if got.StartLine != 5 || got.EndLine != 6 || got.Version != 2 || got.Outdated {
+		t.Errorf("moved thread = lines %d-%d at version %d (outdated %v), want 5-6 at 2",
+			got.StartLine, got.EndLine, got.Version, got.Outdated)
+	}
+
+	got = load(changing.ID)
+	if !got.Outdated || got.StartLine != 1 || got.EndLine != 1 || got.Version != 1 {
+		t.Errorf("changed thread = lines %d-%d at version %d (outdated %v), want outdated 1-1 at 1",
+			got.StartLine, got.EndLine, got.Version, got.Outdated)
+	}
+
+	// An outdated thread stays on the version it was written for.
+	saveCode(t, svc, snippet, userID, "x\ny\nA\nb\nc\nd\ne\n")
+	got = load(changing.ID)
+	if !got.Outdated || got.Version != 1 || got.StartLine != 1 {
+		t.Errorf("outdated thread moved on to version %d, lines %d-%d", got.Version, got.StartLine, got.EndLine)
+	}
+	if got := load(moving.ID); got.Version != 3 || got.StartLine != 5 {
+		t.Errorf("thread in place = lines %d-%d at version %d, want 5 at 3", got.StartLine, got.EndLine, got.Version)
+	}
+}
+
+func TestSaveCodeVersionRejectsStaleSnippet(t *testing.T) {
+	db, svc, snippet, userID := newCodeTestSnippet(t, "a\n")
+
+	stale := *snippet
+	saveCode(t, svc, snippet, userID, "b\n")
+
+	previous := stale
+	stale.Code = "c\n"
+	if _, err := svc.SaveVersion(context.Background(), &previous, &stale, userID, ""); !errors.Is(err, services.ErrStaleCodeSnippet) {
+		t.Fatalf("got %v, want ErrStaleCodeSnippet", err)
+	}
+	var versions int64
+	db.Model(&models.CodeSnippetVersion{}).Where("snippet_id = ?", snippet.ID).Count(&versions)
+	if versions != 2 {
+		t.Errorf("got %d versions, want 2", versions)
+	}
+}
+
+func TestSaveLanguageDetectionRejectsStaleSnippet(t *testing.T) {
+	db, svc, snippet, userID := newCodeTestSnippet(t, "a\n")
+	ctx := context.Background()
+
+	stale := *snippet
+	snippet.LanguageDetected = true
+	snippet.LanguageConfidence = 0.9
+	if err := svc.SaveLanguageDetection(ctx, snippet, snippet.Version); err != nil {
+		t.Fatalf("SaveLanguageDetection: %v", err)
+	}
+
+	saveCode(t, svc, snippet, userID, "b\n")
+	stale.LanguageDetected = false
+	if err := svc.SaveLanguageDetection(ctx, &stale, stale.Version); !errors.Is(err, services.ErrStaleCodeSnippet) {
+		t.Fatalf("got %v, want ErrStaleCodeSnippet", err)
+	}
+	var got models.CodeSnippet
+	if err := db.First(&got, "id = ?", snippet.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != "b\n" || !got.LanguageDetected {
+		t.Errorf("stale save overwrote the snippet: code %q, detected %v", got.Code, got.LanguageDetected)
+	}
+}
+
+func TestCodeCommentThreads(t *testing.T) {
+	db, svc, snippet, userID := newCodeTestSnippet(t, "a\nb\n")
+	ctx := context.Background()
+
+	thread, err := svc.CreateComment(ctx, snippet, userID, "thread", nil, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := svc.CreateComment(ctx, snippet, userID, "reply", &thread.ID, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := svc.CreateComment(ctx, snippet, userID, "reply to reply", &reply.ID, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nested.ParentID == nil || *nested.ParentID != thread.ID {
+		t.Errorf("reply to a reply has parent %v, want the thread %s", nested.ParentID, thread.ID)
+	}
+
+	if _, err := svc.CreateComment(ctx, snippet, userID, "x", nil, 2, 3); !errors.Is(err, services.ErrCodeCommentLines) {
+		t.Errorf("lines past the end: got %v, want ErrCodeCommentLines", err)
+	}
+	missing := uuid.New()
+	if _, err := svc.CreateComment(ctx, snippet, userID, "x", &missing, 0, 0); !errors.Is(err, services.ErrCodeCommentParentMissing) {
+		t.Errorf("unknown parent: got %v, want ErrCodeCommentParentMissing", err)
+	}
+	if _, err := svc.SetResolved(ctx, reply, userID, true); !errors.Is(err, services.ErrCodeCommentNotThread) {
+		t.Errorf("resolving a reply: got %v, want ErrCodeCommentNotThread", err)
+	}
+
+	threads, err := svc.ListThreads(ctx, snippet.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 || len(threads[0].Replies) != 2 {
+		t.Fatalf("got %+v, want one thread with two replies", threads)
+	}
+
+	other, err := svc.CreateComment(ctx, snippet, userID, "other", nil, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteComment(ctx, thread); err != nil {
+		t.Fatal(err)
+	}
+	var left []models.CodeComment
+	if err := db.Where("snippet_id = ?", snippet.ID).Find(&left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 || left[0].ID != other.ID {
+		t.Errorf("after deleting the thread %d comments are left, want only the other thread", len(left))
+	}
+}
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/pkg/highlight"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		code     string
+		want     string
+	}{
+		{"extension", "handlers/main.go", "whatever", "go"},
+		{"extension wins over content", "query.sql", "def f():\n    pass\n", "sql"},
+		{"well-known file name", "Gemfile", "source 'https://rubygems.org'", "ruby"},
+		{"shebang", "", "#!/usr/bin/env python3\nprint('hi')\n", "python"},
+		{"shell shebang", "", "#!/bin/sh\nls\n", "bash"},
+		{"json", "", `{"name": "x", "tags": [1, 2]}`, "json"},
+		{"go", "", "package main\n\nfunc main() {\n\tif err != nil {\n\t\treturn\n\t}\n}\n", "go"},
+		{"python", "", "class A:\n    def f(self, x):\n        return self.x\n", "python"},
+		{"javascript", "", "const a = require('a');\nconsole.log(a === 1);\n", "javascript"},
+		{"typescript", "", "interface User {\n  name: string;\n}\nconst u: User = { name: 'a' };\n", "typescript"},
+		{"cpp", "", "#include <vector>\nint main() {\n  std::vector<int> v;\n}\n", "cpp"},
+		{"rust", "", "fn main() {\n    let mut v = Vec::new();\n    println!(\"{:?}\", v);\n}\n", "rust"},
+		{"sql", "", "select id, name from users where id = 1;", "sql"},
+		{"html", "", "<!DOCTYPE html>\n<html><body></body></html>", "html"},
+		{"css", "", ".btn {\n  color: #333;\n  margin: 4px;\n}\n", "css"},
+		{"prose", "", "let's select a venue from the list", ""},
+		{"empty", "", "   ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := highlight.Detect(tt.fileName, tt.code)
+			if got.Language != tt.want {
+				t.Fatalf("Detect(%q) = %q, want %q", tt.code, got.Language, tt.want)
+			}
+			if tt.want == "" && got.Confidence != 0 {
+				t.Errorf("confidence = %v without a language", got.Confidence)
+			}
+			if tt.want != "" && (got.Confidence <= 0 || got.Confidence > 1) {
+				t.Errorf("confidence = %v, want within (0, 1]", got.Confidence)
+			}
+		})
+	}
+}
+
+func TestCodeBlocks(t *testing.T) {
+	content := "Try this:\n\n```py\nprint('hi')\n```\n\nor\n\n```\npackage main\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n\n```\n```\n"
+
+	blocks := services.CodeBlocks(content)
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2: %+v", len(blocks), blocks)
+	}
+
+	if blocks[0].Language != models.CodeLanguagePython || blocks[0].Confidence != 1 {
+		t.Errorf("block 0 = %s (%v), want python named by the fence", blocks[0].Language, blocks[0].Confidence)
+	}
+	if blocks[0].Code != "print('hi')" || blocks[0].StartLine != 3 || blocks[0].EndLine != 5 {
+		t.Errorf("block 0 = %+v", blocks[0])
+	}
+
+	if blocks[1].Index != 1 || blocks[1].Language != models.CodeLanguageGo || blocks[1].Confidence >= 1 {
+		t.Errorf("block 1 = %s (%v), want detected go", blocks[1].Language, blocks[1].Confidence)
+	}
+
+	if got := services.CodeBlocks("no code here"); len(got) != 0 {
+		t.Errorf("plain text has blocks: %+v", got)
+	}
+}
+
+func TestRematchPromotedBlocks(t *testing.T) {
+	db := newSQLiteDB(t, &models.CodeSnippet{})
+
+	content := "```\nfirst()\n```\n\n```\nsecond()\n```\n"
+	blocks := services.CodeBlocks(content)
+	messageID := uuid.New()
+	snippet := models.CodeSnippet{
+		MessageID:      uuid.New(),
+		ChatID:         uuid.New(),
+		Language:       models.CodeLanguageOther,
+		Code:           blocks[1].Code,
+		CreatedByID:    uuid.New(),
+		PromotedFromID: &messageID,
+		PromotedBlock:  &blocks[1].Index,
+		PromotedHash:   services.CodeBlockHash(blocks[1].Code),
+	}
+	mustCreate(t, db, &snippet)
+
+	promotedBlock := func(content string) *int {
+		t.Helper()
+		if err := services.RematchPromotedBlocks(db, messageID, content); err != nil {
+			t.Fatal(err)
+		}
+		var stored models.CodeSnippet
+		if err := db.First(&stored, "id = ?", snippet.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return stored.PromotedBlock
+	}
+
+	// A block added in front moves the promoted one along.
+	edited := "```\nzeroth()\n```\n\n" + content
+	if got := promotedBlock(edited); got == nil || *got != 2 {
+		t.Errorf("after adding a block, promoted_block = %v, want 2", got)
+	}
+	blocks = services.CodeBlocks(edited)
+	var stored models.CodeSnippet
+	db.Select("id", "promoted_block", "promoted_hash").First(&stored, "id = ?", snippet.ID)
+	services.MarkPromotedBlocks(blocks, []models.CodeSnippet{stored})
+	if blocks[2].SnippetID == nil || *blocks[2].SnippetID != snippet.ID || blocks[1].SnippetID != nil {
+		t.Errorf("blocks = %+v, want the snippet on block 2 only", blocks)
+	}
+
+	// Removing the block clears the index.
+	if got := promotedBlock("```\nfirst()\n```\n"); got != nil {
+		t.Errorf("after removing the block, promoted_block = %d, want none", *got)
+	}
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/messenger/backend/pkg/codefmt"
+)
+
+func TestCodeFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		want     string
+	}{
+		{"go", "go", "package main\nfunc main(){\nx:=1\n_ = x}", "package main\n\nfunc main() {\n\tx := 1\n\t_ = x\n}\n"},
+		{"json", "json", `{"a":[1,2],"b":{}}`, "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}\n"},
+		{"xml", "xml", `<?xml version="1.0"?><a x="1"><b>hi <i>there</i></b><c></c><!-- note --></a>`,
+			"<?xml version=\"1.0\"?>\n<a x=\"1\">\n  <b>hi <i>there</i></b>\n  <c/>\n  <!-- note -->\n</a>\n"},
+		{"sql", "sql", "select name from users  \nwhere id = 'select' -- from\n", "SELECT name FROM users\nWHERE id = 'select' -- from\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := codefmt.Format(tt.language, tt.code)
+			if err != nil {
+				t.Fatalf("Format: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			again, err := codefmt.Format(tt.language, got)
+			if err != nil || again != got {
+				t.Errorf("formatting again gave %q (%v)", again, err)
+			}
+		})
+	}
+}
+
+func TestCodeFormatErrors(t *testing.T) {
+	for _, tt := range []struct{ language, code string }{
+		{"go", "func {"},
+		{"json", `{"a":}`},
+		{"xml", "<a><b></a>"},
+		{"xml", "<a>"},
+	} {
+		if _, err := codefmt.Format(tt.language, tt.code); err == nil {
+			t.Errorf("Format(%s, %q) succeeded, want error", tt.language, tt.code)
+		}
+	}
+
+	if _, err := codefmt.Format("python", "x = 1"); !errors.Is(err, codefmt.ErrUnsupported) {
+		t.Errorf("python: got %v, want ErrUnsupported", err)
+	}
+	if codefmt.Supported("ruby") || !codefmt.Supported("go") {
+		t.Error("Supported reports the wrong languages")
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/messenger/backend/pkg/diff"
+)
+
+func TestComputeEditScript(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+
+	edits := diff.Compute(a, b)
+
+	var rebuilt []string
+	changes := 0
+	for _, e := range edits {
+		switch e.Kind {
+		case diff.Equal:
+			if a[e.OldIndex] != b[e.NewIndex] {
+				t.Fatalf("equal edit pairs %q with %q", a[e.OldIndex], b[e.NewIndex])
+			}
+			rebuilt = append(rebuilt, a[e.OldIndex])
+		case diff.Insert:
+			rebuilt = append(rebuilt, b[e.NewIndex])
+			changes++
+		case diff.Delete:
+			changes++
+		}
+	}
+
+	if strings.Join(rebuilt, " ") != strings.Join(b, " ") {
+		t.Errorf("applying the script gives %v, want %v", rebuilt, b)
+	}
+	// The classic Myers example has an edit distance of 5.
+	if changes != 5 {
+		t.Errorf("expected 5 changes, got %d", changes)
+	}
+}
+
+func TestLinesUnified(t *testing.T) {
+	oldText := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	newText := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nTEN\neleven\n"
+
+	result := diff.Lines(oldText, newText, 3)
+	if result.Additions != 2 || result.Deletions != 1 {
+		t.Fatalf("expected +2 -1, got +%d -%d", result.Additions, result.Deletions)
+	}
+	if len(result.Hunks) != 1 {
+		t.Fatalf("expected 1 hunk, got %d", len(result.Hunks))
+	}
+
+	want := "--- a\n+++ b\n@@ -7,4 +7,5 @@\n seven\n eight\n nine\n-ten\n+TEN\n+eleven\n"
+	if got := result.Unified("a", "b"); got != want {
+		t.Errorf("unexpected unified diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLinesSplitsDistantChanges(t *testing.T) {
+	var oldLines, newLines []string
+	for i := 0; i < 20; i++ {
+		line := strings.Repeat("x", i+1)
+		oldLines = append(oldLines, line)
+		if i == 1 || i == 18 {
+			line += "!"
+		}
+		newLines = append(newLines, line)
+	}
+
+	result := diff.Lines(strings.Join(oldLines, "\n"), strings.Join(newLines, "\n"), 2)
+	if len(result.Hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(result.Hunks))
+	}
+	if h := result.Hunks[0]; h.OldStart != 1 || h.OldLines != 4 {
+		t.Errorf("first hunk covers -%d,%d, want -1,4", h.OldStart, h.OldLines)
+	}
+}
+
+func TestLinesIdentical(t *testing.T) {
+	result := diff.Lines("same\ntext\n", "same\ntext\n", 3)
+	if len(result.Hunks) != 0 || result.Unified("a", "b") != "" {
+		t.Errorf("identical texts produced a diff: %+v", result)
+	}
+}
+
+func TestLinesWordLevel(t *testing.T) {
+	result := diff.Lines("the quick brown fox\n", "the slow brown fox\n", 0)
+	if len(result.Hunks) != 1 || len(result.Hunks[0].Lines) != 2 {
+		t.Fatalf("unexpected hunks: %+v", result.Hunks)
+	}
+
+	removed, added := result.Hunks[0].Lines[0], result.Hunks[0].Lines[1]
+	wantRemoved := []diff.Span{{Kind: diff.Equal, Text: "the "}, {Kind: diff.Delete, Text: "quick"}, {Kind: diff.Equal, Text: " brown fox"}}
+	wantAdded := []diff.Span{{Kind: diff.Equal, Text: "the "}, {Kind: diff.Insert, Text: "slow"}, {Kind: diff.Equal, Text: " brown fox"}}
+
+	if !spansEqual(removed.Words, wantRemoved) {
+		t.Errorf("removed line words = %+v, want %+v", removed.Words, wantRemoved)
+	}
+	if !spansEqual(added.Words, wantAdded) {
+		t.Errorf("added line words = %+v, want %+v", added.Words, wantAdded)
+	}
+}
+
+func spansEqual(a, b []diff.Span) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMapLinesRange(t *testing.T) {
+	oldText := "a\nb\nc\nd\ne\n"
+	newText := "new\na\nb\nc\nx\ne\n"
+	lines := diff.MapLines(oldText, newText)
+
+	tests := []struct {
+		start, end         int
+		wantStart, wantEnd int
+		wantOK             bool
+	}{
+		{1, 3, 2, 4, true},  // moved down by the inserted line
+		{5, 5, 6, 6, true},  // kept after the change
+		{3, 4, 0, 0, false}, // d was replaced
+		{4, 4, 0, 0, false}, // d itself
+		{0, 1, 0, 0, false}, // out of range
+		{2, 9, 0, 0, false}, // past the end
+	}
+	for _, tt := range tests {
+		start, end, ok := lines.Range(tt.start, tt.end)
+		if start != tt.wantStart || end != tt.wantEnd || ok != tt.wantOK {
+			t.Errorf("Range(%d, %d) = %d, %d, %v, want %d, %d, %v",
+				tt.start, tt.end, start, end, ok, tt.wantStart, tt.wantEnd, tt.wantOK)
+		}
+	}
+
+	// Lines inserted inside a range change the code it shows.
+	split := diff.MapLines("a\nb\n", "a\nnew\nb\n")
+	if _, _, ok := split.Range(1, 2); ok {
+		t.Error("range with an inserted line is still kept")
+	}
+}
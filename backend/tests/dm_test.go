@@ -2,6 +2,7 @@ package tests
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/messenger/backend/internal/models"
@@ -103,7 +104,7 @@ func TestChatService_GetUserChatsWithLastMessage(t *testing.T) {
 		chat1, _ := chatService.GetOrCreateDMChat(nil, user1.ID, user2.ID)
 		chat2, _ := chatService.GetOrCreateDMChat(nil, user1.ID, user3.ID)
 
-		chats, err := chatService.GetUserChatsWithLastMessage(nil, user1.ID)
+		chats, err := chatService.GetUserChatsWithLastMessage(nil, user1.ID, services.ChatListOptions{})
 		if err != nil {
 			t.Fatalf("failed to get user chats: %v", err)
 		}
@@ -132,7 +133,7 @@ func TestChatService_GetUserChatsWithLastMessage(t *testing.T) {
 
 	t.Run("Empty chats for new user", func(t *testing.T) {
 		newUser := createTestUser(t, db, "+4444444444")
-		chats, err := chatService.GetUserChatsWithLastMessage(nil, newUser.ID)
+		chats, err := chatService.GetUserChatsWithLastMessage(nil, newUser.ID, services.ChatListOptions{})
 		if err != nil {
 			t.Fatalf("failed to get user chats: %v", err)
 		}
@@ -228,3 +229,29 @@ func TestDMChatUniqueness(t *testing.T) {
 		t.Errorf("expected 1 DM chat in database, got %d", chatCount)
 	}
 }
+
+func TestChatMember_IsMuted(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		mutedUntil *time.Time
+		want       bool
+	}{
+		{name: "not muted", mutedUntil: nil, want: false},
+		{name: "mute expired", mutedUntil: &past, want: false},
+		{name: "muted for an hour", mutedUntil: &future, want: true},
+		{name: "muted forever", mutedUntil: &models.MutedForever, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			member := models.ChatMember{MutedUntil: tt.mutedUntil}
+			if got := member.IsMuted(now); got != tt.want {
+				t.Errorf("IsMuted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package tests
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+func TestChatExport_ToResponse(t *testing.T) {
+	running := models.ChatExport{
+		ID:                uuid.New(),
+		ChatID:            uuid.New(),
+		Status:            models.ExportStatusRunning,
+		TotalMessages:     200,
+		ProcessedMessages: 50,
+	}
+
+	resp := running.ToResponse()
+	if resp.Progress != 25 {
+		t.Errorf("Expected progress 25, got %d", resp.Progress)
+	}
+	if resp.DownloadURL != nil {
+		t.Error("Running export should not have a download URL")
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	completed := running
+	completed.Status = models.ExportStatusCompleted
+	completed.ExpiresAt = &expiresAt
+
+	resp = completed.ToResponse()
+	if resp.Progress != 100 {
+		t.Errorf("Expected progress 100, got %d", resp.Progress)
+	}
+	if resp.DownloadURL == nil {
+		t.Fatal("Completed export should have a download URL")
+	}
+	expected := "/api/v1/chats/" + completed.ChatID.String() + "/exports/" + completed.ID.String() + "/download"
+	if *resp.DownloadURL != expected {
+		t.Errorf("Expected download URL %s, got %s", expected, *resp.DownloadURL)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	completed.ExpiresAt = &expired
+	if completed.ToResponse().DownloadURL != nil {
+		t.Error("Expired export should not have a download URL")
+	}
+
+	empty := models.ChatExport{Status: models.ExportStatusRunning}
+	if empty.ToResponse().Progress != 0 {
+		t.Error("Export without messages should report zero progress")
+	}
+}
+
+func TestExportServiceBuildsArchive(t *testing.T) {
+	db := newSQLiteDB(t, &models.User{}, &models.Chat{}, &models.ChatMember{},
+		&models.Message{}, &models.CodeSnippet{}, &models.ChatExport{})
+
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.MkdirAll("uploads", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("uploads", "cat.png"), []byte("png bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := "alice"
+	user := models.User{Phone: "+10000000001", PasswordHash: "x", Username: &alice}
+	name := "Team <chat>"
+	chat := models.Chat{Name: &name, Type: models.ChatTypeGroup}
+	mustCreate(t, db, &user)
+	mustCreate(t, db, &chat)
+	mustCreate(t, db, &models.ChatMember{ChatID: chat.ID, UserID: user.ID, Role: models.MemberRoleAdmin})
+
+	base := time.Now().Add(-time.Hour)
+	mediaURL := "cat.png"
+	messages := []models.Message{
+		{SenderID: &user.ID, ChatID: chat.ID, Content: "hello <b>world</b>", MessageType: models.MessageTypeText, CreatedAt: base},
+		{SenderID: &user.ID, ChatID: chat.ID, Content: "gone", MessageType: models.MessageTypeText, IsDeleted: true, CreatedAt: base.Add(time.Minute)},
+		{SenderID: &user.ID, ChatID: chat.ID, Content: "cat.png", MessageType: models.MessageTypeImage, MediaURL: &mediaURL, CreatedAt: base.Add(2 * time.Minute)},
+		{SenderID: &user.ID, ChatID: chat.ID, Content: "fmt.Println(1)", MessageType: models.MessageTypeCode, CreatedAt: base.Add(3 * time.Minute)},
+	}
+	for i := range messages {
+		mustCreate(t, db, &messages[i])
+	}
+	mustCreate(t, db, &models.CodeSnippet{MessageID: messages[3].ID, ChatID: chat.ID, Language: models.CodeLanguageGo,
+		Code: "fmt.Println(1)", CreatedByID: user.ID, Version: 1})
+
+	export := models.ChatExport{ChatID: chat.ID, RequestedByID: user.ID, IncludeMedia: true, Status: models.ExportStatusPending}
+	mustCreate(t, db, &export)
+
+	services.NewExportService(db).Run(export.ID)
+
+	if err := db.First(&export, "id = ?", export.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if export.Status != models.ExportStatusCompleted || export.FilePath == nil {
+		t.Fatalf("export status = %s, error = %v", export.Status, export.Error)
+	}
+	if export.TotalMessages != 3 || export.ProcessedMessages != 3 {
+		t.Errorf("processed %d of %d messages, want 3 of 3", export.ProcessedMessages, export.TotalMessages)
+	}
+
+	archive, err := zip.OpenReader(*export.FilePath)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]string)
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(data)
+	}
+
+	var doc struct {
+		Name         string `json:"name"`
+		MessageCount int    `json:"message_count"`
+		Members      []struct {
+			Username string `json:"username"`
+		} `json:"members"`
+		Messages []struct {
+			ID        uuid.UUID `json:"id"`
+			Content   string    `json:"content"`
+			MediaFile *string   `json:"media_file"`
+			Code      *struct {
+				Language string `json:"language"`
+				Code     string `json:"code"`
+			} `json:"code"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(files["messages.json"]), &doc); err != nil {
+		t.Fatalf("messages.json is not valid JSON: %v\n%s", err, files["messages.json"])
+	}
+	if doc.Name != name || doc.MessageCount != 3 || len(doc.Members) != 1 || doc.Members[0].Username != "alice" {
+		t.Errorf("chat fields = %+v", doc)
+	}
+	if len(doc.Messages) != 3 {
+		t.Fatalf("got %d messages, want 3 without the deleted one", len(doc.Messages))
+	}
+	if doc.Messages[0].Content != "hello <b>world</b>" {
+		t.Errorf("first message = %q", doc.Messages[0].Content)
+	}
+	mediaName := "media/" + messages[2].ID.String() + "_cat.png"
+	if doc.Messages[1].MediaFile == nil || *doc.Messages[1].MediaFile != mediaName {
+		t.Errorf("media file = %v, want %s", doc.Messages[1].MediaFile, mediaName)
+	}
+	if files[mediaName] != "png bytes" {
+		t.Errorf("archived media = %q", files[mediaName])
+	}
+	if doc.Messages[2].Code == nil || doc.Messages[2].Code.Language != "go" {
+		t.Errorf("code message = %+v", doc.Messages[2].Code)
+	}
+
+	page := files["index.html"]
+	for _, want := range []string{
+		"<title>Team &lt;chat&gt; — export</title>",
+		"3 messages · 1 members",
+		"hello &lt;b&gt;world&lt;/b&gt;",
+		`<img src="` + mediaName + `"`,
+		"</html>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("index.html lacks %q", want)
+		}
+	}
+	if strings.Contains(page, "gone") {
+		t.Error("index.html shows the deleted message")
+	}
+
+	left, _ := filepath.Glob(filepath.Join(services.ExportDir, "*"))
+	if len(left) != 1 || left[0] != *export.FilePath {
+		t.Errorf("export directory holds %v, want only the archive", left)
+	}
+}
+
+func TestExportServiceFailsStaleExports(t *testing.T) {
+	db := newSQLiteDB(t, &models.ChatExport{})
+
+	stale := models.ChatExport{ChatID: uuid.New(), RequestedByID: uuid.New(), Status: models.ExportStatusRunning}
+	fresh := models.ChatExport{ChatID: uuid.New(), RequestedByID: uuid.New(), Status: models.ExportStatusRunning}
+	mustCreate(t, db, &stale)
+	mustCreate(t, db, &fresh)
+	old := time.Now().Add(-services.ExportStaleAfter - time.Minute)
+	if err := db.Model(&stale).UpdateColumn("updated_at", old).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := services.NewExportService(db).FailStaleExports()
+	if err != nil || count != 1 {
+		t.Fatalf("FailStaleExports = %d, %v, want 1", count, err)
+	}
+
+	db.First(&stale, "id = ?", stale.ID)
+	db.First(&fresh, "id = ?", fresh.ID)
+	if stale.Status != models.ExportStatusFailed || stale.Error == nil {
+		t.Errorf("stale export is %s", stale.Status)
+	}
+	if fresh.Status != models.ExportStatusRunning {
+		t.Errorf("fresh export is %s", fresh.Status)
+	}
+}
+
+func TestExportServiceKeepsGivenUpExportsFailed(t *testing.T) {
+	db := newSQLiteDB(t, &models.User{}, &models.Chat{}, &models.ChatMember{},
+		&models.Message{}, &models.CodeSnippet{}, &models.ChatExport{})
+
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	user := models.User{Phone: "+10000000001", PasswordHash: "x"}
+	chat := models.Chat{Type: models.ChatTypeGroup}
+	mustCreate(t, db, &user)
+	mustCreate(t, db, &chat)
+	mustCreate(t, db, &models.Message{SenderID: &user.ID, ChatID: chat.ID, Content: "hi", MessageType: models.MessageTypeText})
+
+	export := models.ChatExport{ChatID: chat.ID, RequestedByID: user.ID, Status: models.ExportStatusPending}
+	mustCreate(t, db, &export)
+
+	// The stale export sweep gives up on the export while its messages are
+	// written.
+	msg := "Export was interrupted"
+	err = db.Callback().Update().After("gorm:update").Register("tests:give_up_export", func(tx *gorm.DB) {
+		if tx.Statement.Table == "chat_exports" && strings.Contains(tx.Statement.SQL.String(), "processed_messages") &&
+			!strings.Contains(tx.Statement.SQL.String(), "completed_at") {
+			tx.Session(&gorm.Session{NewDB: true}).Exec("UPDATE chat_exports SET status = ?, error = ? WHERE id = ?",
+				models.ExportStatusFailed, msg, export.ID)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	services.NewExportService(db).Run(export.ID)
+
+	var stored models.ChatExport
+	if err := db.First(&stored, "id = ?", export.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.ExportStatusFailed || stored.Error == nil || *stored.Error != msg || stored.FilePath != nil {
+		t.Errorf("export is %s (error %v, file %v), want it to stay failed", stored.Status, stored.Error, stored.FilePath)
+	}
+	if left, _ := filepath.Glob(filepath.Join(services.ExportDir, "*")); len(left) != 0 {
+		t.Errorf("export directory holds %v, want no archive", left)
+	}
+}
+
+func mustCreate(t *testing.T, db *gorm.DB, value interface{}) {
+	t.Helper()
+	if err := db.Create(value).Error; err != nil {
+		t.Fatalf("create %T: %v", value, err)
+	}
+}
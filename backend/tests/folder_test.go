@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+)
+
+func newFolderTestChat(chatType models.ChatType, unread int64) models.ChatWithLastMessageResponse {
+	id := uuid.New()
+	return models.ChatWithLastMessageResponse{
+		ChatResponse:         models.ChatResponse{ID: id, Type: chatType},
+		UnreadCount:          unread,
+		ChatSettingsResponse: models.ChatSettingsResponse{ChatID: id},
+	}
+}
+
+func TestChatFolder_Matches(t *testing.T) {
+	group := newFolderTestChat(models.ChatTypeGroup, 0)
+	dm := newFolderTestChat(models.ChatTypeDM, 2)
+
+	mutedGroup := newFolderTestChat(models.ChatTypeGroup, 5)
+	mutedGroup.IsMuted = true
+	until := time.Now().Add(time.Hour)
+	mutedGroup.MutedUntil = &until
+
+	archivedDM := newFolderTestChat(models.ChatTypeDM, 1)
+	archivedDM.IsArchived = true
+
+	markedUnread := newFolderTestChat(models.ChatTypeGroup, 0)
+	markedUnread.MarkedUnread = true
+
+	tests := []struct {
+		name        string
+		folder      models.ChatFolder
+		chat        models.ChatWithLastMessageResponse
+		withContact bool
+		want        bool
+	}{
+		{
+			name:   "type rule matches",
+			folder: models.ChatFolder{ChatTypes: []models.ChatType{models.ChatTypeGroup}},
+			chat:   group,
+			want:   true,
+		},
+		{
+			name:   "type rule rejects",
+			folder: models.ChatFolder{ChatTypes: []models.ChatType{models.ChatTypeGroup}},
+			chat:   dm,
+			want:   false,
+		},
+		{
+			name:   "no rules and not included",
+			folder: models.ChatFolder{},
+			chat:   group,
+			want:   false,
+		},
+		{
+			name:   "explicitly included without rules",
+			folder: models.ChatFolder{IncludeChatIDs: []uuid.UUID{group.ID}},
+			chat:   group,
+			want:   true,
+		},
+		{
+			name: "exclusion wins over inclusion",
+			folder: models.ChatFolder{
+				IncludeChatIDs: []uuid.UUID{group.ID},
+				ExcludeChatIDs: []uuid.UUID{group.ID},
+			},
+			chat: group,
+			want: false,
+		},
+		{
+			name:   "unread only skips read chats",
+			folder: models.ChatFolder{UnreadOnly: true},
+			chat:   group,
+			want:   false,
+		},
+		{
+			name:   "unread only keeps manually marked chats",
+			folder: models.ChatFolder{UnreadOnly: true},
+			chat:   markedUnread,
+			want:   true,
+		},
+		{
+			name:   "exclude muted",
+			folder: models.ChatFolder{UnreadOnly: true, ExcludeMuted: true},
+			chat:   mutedGroup,
+			want:   false,
+		},
+		{
+			name:   "archived chats hidden by default",
+			folder: models.ChatFolder{ChatTypes: []models.ChatType{models.ChatTypeDM}},
+			chat:   archivedDM,
+			want:   false,
+		},
+		{
+			name:   "archived chats shown when requested",
+			folder: models.ChatFolder{ChatTypes: []models.ChatType{models.ChatTypeDM}, IncludeArchived: true},
+			chat:   archivedDM,
+			want:   true,
+		},
+		{
+			name:        "contacts only with contact",
+			folder:      models.ChatFolder{ContactsOnly: true},
+			chat:        dm,
+			withContact: true,
+			want:        true,
+		},
+		{
+			name:   "contacts only without contact",
+			folder: models.ChatFolder{ContactsOnly: true},
+			chat:   dm,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.folder.Matches(&tt.chat, tt.withContact); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
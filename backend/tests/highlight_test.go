@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/messenger/backend/pkg/highlight"
+)
+
+func TestHighlightTokenize(t *testing.T) {
+	tests := []struct {
+		language string
+		code     string
+		want     map[string]highlight.TokenType
+	}{
+		{"go", "func main() {\n\t// hi\n\tx := `raw`\n\treturn nil, 42\n}\n", map[string]highlight.TokenType{
+			"func": highlight.Keyword, "main": highlight.Function, "// hi\n": highlight.Comment,
+			"`raw`": highlight.String, "nil": highlight.Constant, "42": highlight.Number,
+		}},
+		{"python", "@cache\ndef f(s):\n    \"\"\"Doc\n    string\"\"\"\n    return len(s)  # done\n", map[string]highlight.TokenType{
+			"@cache": highlight.Preprocessor, "def": highlight.Keyword, "len": highlight.Builtin,
+			"\"\"\"Doc\n    string\"\"\"": highlight.String, "# done": highlight.Comment,
+		}},
+		{"sql", "select * FROM t WHERE a = 'x' -- note", map[string]highlight.TokenType{
+			"select": highlight.Keyword, "FROM": highlight.Keyword, "'x'": highlight.String, "-- note": highlight.Comment,
+		}},
+		{"bash", "echo \"$HOME\" $1 ${PATH}", map[string]highlight.TokenType{
+			"echo": highlight.Builtin, "$HOME": highlight.Variable, "$1": highlight.Variable, "PATH": highlight.Variable,
+		}},
+		{"json", `{"name": "x", "ok": true}`, map[string]highlight.TokenType{
+			`"name"`: highlight.Property, `"x"`: highlight.String, "true": highlight.Constant,
+		}},
+		{"css", "a.active { color: #fff; }", map[string]highlight.TokenType{
+			"a": highlight.Tag, "active": highlight.Type, "color": highlight.Keyword, "#fff": highlight.Number,
+		}},
+		{"html", "<!-- c --><a href=\"/\">&amp;</a><script>let x</script>", map[string]highlight.TokenType{
+			"<!-- c -->": highlight.Comment, "href": highlight.Attribute, `"/"`: highlight.String,
+			"&amp;": highlight.Constant, "let": highlight.Keyword,
+		}},
+		{"markdown", "# Title\n\nSome **bold** and `code`\n", map[string]highlight.TokenType{
+			"# Title\n": highlight.Heading, "**bold**": highlight.Emphasis, "`code`": highlight.String,
+		}},
+		{"other", "anything at all", map[string]highlight.TokenType{
+			"anything at all": highlight.Text,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			tokens := highlight.Tokenize(tt.language, tt.code)
+
+			var sb strings.Builder
+			got := make(map[string]highlight.TokenType)
+			for _, tok := range tokens {
+				sb.WriteString(tok.Value)
+				got[tok.Value] = tok.Type
+			}
+			if sb.String() != tt.code {
+				t.Errorf("tokens join to %q, want %q", sb.String(), tt.code)
+			}
+			for value, want := range tt.want {
+				if got[value] != want {
+					t.Errorf("token %q has type %q, want %q", value, got[value], want)
+				}
+			}
+		})
+	}
+}
+
+func TestHighlightLines(t *testing.T) {
+	lines := highlight.Lines(highlight.Tokenize("go", "/* a\nb */\nx\n"))
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	if lines[1].Number != 2 || len(lines[1].Tokens) != 1 || lines[1].Tokens[0].Value != "b */" || lines[1].Tokens[0].Type != highlight.Comment {
+		t.Errorf("line 2 = %+v, want the end of the comment", lines[1])
+	}
+}
+
+func TestParseLineRanges(t *testing.T) {
+	ranges, err := highlight.ParseLineRanges("3-5, 9")
+	if err != nil {
+		t.Fatalf("ParseLineRanges: %v", err)
+	}
+	want := []highlight.LineRange{{Start: 3, End: 5}, {Start: 9, End: 9}}
+	if len(ranges) != len(want) || ranges[0] != want[0] || ranges[1] != want[1] {
+		t.Errorf("got %+v, want %+v", ranges, want)
+	}
+
+	for _, bad := range []string{"0", "5-3", "a", "2-"} {
+		if _, err := highlight.ParseLineRanges(bad); err == nil {
+			t.Errorf("ParseLineRanges(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestHighlightRenderHTML(t *testing.T) {
+	lines := highlight.Lines(highlight.Tokenize("go", "if a < b {\n}\n"))
+	highlight.MarkLines(lines, []highlight.LineRange{{Start: 2, End: 2}})
+
+	theme, ok := highlight.LookupTheme("monokai")
+	if !ok {
+		t.Fatal("monokai theme missing")
+	}
+	html := highlight.RenderHTML(lines, highlight.HTMLOptions{Theme: theme, LineNumbers: true})
+
+	for _, want := range []string{
+		`class="code-highlight theme-monokai"`,
+		`<span class="tok-keyword" style="color:#f92672">if</span>`,
+		`&lt;`,
+		`data-line="2" style="display:block;background:#3e3d32"`,
+		`class="line-number"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML lacks %q:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, `data-line="1" style="display:block;background`) {
+		t.Error("line 1 is highlighted")
+	}
+
+	if _, ok := highlight.LookupTheme("nope"); ok {
+		t.Error("unknown theme found")
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/messenger/backend/pkg/markdown"
+)
+
+func TestMarkdownToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"heading", "## Setup", "<h2>Setup</h2>\n"},
+		{"paragraph with emphasis", "Some **bold**, *italic* and ~~gone~~ text", "<p>Some <strong>bold</strong>, <em>italic</em> and <del>gone</del> text</p>\n"},
+		{"snake_case stays", "use snake_case_names", "<p>use snake_case_names</p>\n"},
+		{"code span", "run `go test ./...` now", "<p>run <code>go test ./...</code> now</p>\n"},
+		{"fenced code", "```go\nfmt.Println(\"<hi>\")\n```", "<pre><code class=\"language-go\">fmt.Println(&#34;&lt;hi&gt;&#34;)\n</code></pre>\n"},
+		{"list", "- one\n- two", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"},
+		{"ordered list", "3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"quote", "> quoted", "<blockquote>\n<p>quoted</p>\n</blockquote>\n"},
+		{"rule", "---", "<hr>\n"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)", "<p><a href=\"https://example.com/a?b=1&amp;c=2\" rel=\"nofollow noopener noreferrer\">docs</a></p>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdown.ToHTML(tt.src, markdown.Options{}); got != tt.want {
+				t.Errorf("ToHTML(%q) = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownSanitizes(t *testing.T) {
+	tests := []string{
+		"<script>alert(1)</script>",
+		"[click](javascript:alert(1))",
+		"[click](JavaScript:alert(1))",
+		"![x](data:text/html;base64,PHNjcmlwdD4=)",
+		"<img src=x onerror=alert(1)>",
+		"[x](https://example.com/\"onmouseover=\"alert(1))",
+	}
+
+	for _, src := range tests {
+		got := markdown.ToHTML(src, markdown.Options{})
+		if strings.Contains(got, "<script") || strings.Contains(got, "<img src=x") ||
+			strings.Contains(strings.ToLower(got), "javascript:") || strings.Contains(got, "data:") ||
+			strings.Contains(got, "\"onmouseover") {
+			t.Errorf("ToHTML(%q) produced unsafe output %q", src, got)
+		}
+	}
+}
+
+func TestMarkdownWikiLinks(t *testing.T) {
+	opts := markdown.Options{
+		ResolveWikiLink: func(slug string) (string, bool) {
+			return "/wiki/c/" + slug, slug == "setup"
+		},
+	}
+
+	got := markdown.ToHTML("See [[setup]] and [[faq|the FAQ]].", opts)
+	want := "<p>See <a href=\"/wiki/c/setup\" class=\"wiki-link\">setup</a> and " +
+		"<a href=\"/wiki/c/faq\" class=\"wiki-link wiki-link-broken\">the FAQ</a>.</p>\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWikiLinksSkipsCode(t *testing.T) {
+	src := "[[a]] `[[b]]` [[c|C]] [[a]]\n```\n[[d]]\n```\n[[e]]"
+	got := markdown.WikiLinks(src)
+	want := []string{"a", "c", "e"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("WikiLinks = %v, want %v", got, want)
+	}
+}
+
+func TestRewriteWikiLinks(t *testing.T) {
+	src := "[[old]], [[old|Old page]], [[other]] and `[[old]]`"
+	got, n := markdown.RewriteWikiLinks(src, "old", "new")
+	want := "[[new]], [[new|Old page]], [[other]] and `[[old]]`"
+	if n != 2 || got != want {
+		t.Errorf("RewriteWikiLinks = %q (%d), want %q (2)", got, n, want)
+	}
+}
+
+func TestMarkdownAttachments(t *testing.T) {
+	src := "![Diagram](attachment:flow%20chart.png) and [spec](attachment:spec.pdf) and [gone](attachment:missing.txt)"
+	opts := markdown.Options{
+		ResolveAttachment: func(name string) (string, bool) {
+			files := map[string]string{
+				"flow chart.png": "/uploads/a/flow.png",
+				"spec.pdf":       "/uploads/b/spec.pdf",
+			}
+			href, ok := files[name]
+			return href, ok
+		},
+	}
+
+	got := markdown.ToHTML(src, opts)
+	for _, want := range []string{
+		`<img src="/uploads/a/flow.png" alt="Diagram">`,
+		`<a href="/uploads/b/spec.pdf" rel="nofollow noopener noreferrer">spec</a>`,
+		"and gone</p>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ToHTML = %q, missing %q", got, want)
+		}
+	}
+
+	if got := markdown.ToHTML("[spec](attachment:spec.pdf)", markdown.Options{}); strings.Contains(got, "<a") {
+		t.Errorf("attachment without resolver rendered as link: %q", got)
+	}
+
+	refs := markdown.AttachmentRefs(src + "\n![again](attachment:spec.pdf)")
+	if want := []string{"flow chart.png", "spec.pdf", "missing.txt"}; strings.Join(refs, ",") != strings.Join(want, ",") {
+		t.Errorf("AttachmentRefs = %v, want %v", refs, want)
+	}
+}
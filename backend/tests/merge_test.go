@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/messenger/backend/pkg/diff"
+)
+
+func TestMerge3(t *testing.T) {
+	base := "title\nintro\nbody\nfooter\n"
+
+	tests := []struct {
+		name          string
+		ours, theirs  string
+		wantContent   string
+		wantConflicts int
+	}{
+		{
+			name:        "separate regions",
+			ours:        "title\nbetter intro\nbody\nfooter\n",
+			theirs:      "title\nintro\nbody\nnew footer\n",
+			wantContent: "title\nbetter intro\nbody\nnew footer\n",
+		},
+		{
+			name:        "same change on both sides",
+			ours:        "title\nintro\nbody v2\nfooter\n",
+			theirs:      "title\nintro\nbody v2\nfooter\n",
+			wantContent: "title\nintro\nbody v2\nfooter\n",
+		},
+		{
+			name:        "only ours changed",
+			ours:        "title\nintro\nbody\nfooter\nappendix\n",
+			theirs:      base,
+			wantContent: "title\nintro\nbody\nfooter\nappendix\n",
+		},
+		{
+			name:          "conflicting change",
+			ours:          "title\nintro\nour body\nfooter\n",
+			theirs:        "title\nintro\ntheir body\nfooter\n",
+			wantContent:   "title\nintro\n<<<<<<< ours\nour body\n=======\ntheir body\n>>>>>>> theirs\nfooter\n",
+			wantConflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diff.Merge3(base, tt.ours, tt.theirs)
+			if got.Content != tt.wantContent {
+				t.Errorf("content = %q, want %q", got.Content, tt.wantContent)
+			}
+			if got.Conflicts != tt.wantConflicts || got.Clean != (tt.wantConflicts == 0) {
+				t.Errorf("conflicts = %d (clean %v), want %d", got.Conflicts, got.Clean, tt.wantConflicts)
+			}
+		})
+	}
+}
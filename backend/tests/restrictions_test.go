@@ -0,0 +1,29 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestContainsLink(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: "hello there", want: false},
+		{text: "see https://example.com/page", want: true},
+		{text: "go to www.example.org", want: true},
+		{text: "mirror at files.example.io today", want: true},
+		{text: "version 1.2.3 released", want: false},
+		{text: "end of sentence.Next one", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := services.ContainsLink(tt.text); got != tt.want {
+				t.Errorf("ContainsLink(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
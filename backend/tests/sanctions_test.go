@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	db := newSQLiteDB(t, &models.ChatSanction{})
+	svc := services.NewChatService(db, nil)
+	ctx := context.Background()
+	chatID, userID := uuid.New(), uuid.New()
+
+	if err := svc.CheckReadOnly(ctx, chatID, userID); err != nil {
+		t.Fatalf("without a sanction: got %v, want nil", err)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	mustCreate(t, db, &models.ChatSanction{ID: uuid.New(), ChatID: chatID, UserID: userID,
+		Type: models.SanctionTypeReadOnly, IssuedByID: uuid.New(), ExpiresAt: &expired, IsActive: true})
+	if err := svc.CheckReadOnly(ctx, chatID, userID); err != nil {
+		t.Fatalf("with an expired sanction: got %v, want nil", err)
+	}
+
+	mustCreate(t, db, &models.ChatSanction{ID: uuid.New(), ChatID: chatID, UserID: userID,
+		Type: models.SanctionTypeReadOnly, IssuedByID: uuid.New(), IsActive: true})
+	var restricted *services.PostingRestrictedError
+	if err := svc.CheckReadOnly(ctx, chatID, userID); !errors.As(err, &restricted) {
+		t.Fatalf("with a sanction: got %v, want PostingRestrictedError", err)
+	}
+	if err := svc.CheckReadOnly(ctx, uuid.New(), userID); err != nil {
+		t.Errorf("in another chat: got %v, want nil", err)
+	}
+}
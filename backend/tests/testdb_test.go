@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// newSQLiteDB opens a private in-memory database with tables for models.
+// SQLite has no uuid_generate_v4(), so those column defaults are dropped
+// and new rows get their IDs in Go instead.
+func newSQLiteDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get database handle: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("failed to parse %T: %v", model, err)
+		}
+		dropUUIDDefaults(stmt.Schema, map[*schema.Schema]bool{})
+	}
+
+	if err := db.Callback().Create().Before("gorm:create").Register("tests:uuid", assignUUIDs); err != nil {
+		t.Fatalf("failed to register uuid callback: %v", err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
+
+// dropUUIDDefaults clears uuid_generate_v4() defaults from s and from the
+// models it relates to, which AutoMigrate creates along with it.
+func dropUUIDDefaults(s *schema.Schema, seen map[*schema.Schema]bool) {
+	if s == nil || seen[s] {
+		return
+	}
+	seen[s] = true
+
+	withDefault := s.FieldsWithDefaultDBValue[:0]
+	for _, field := range s.FieldsWithDefaultDBValue {
+		if strings.Contains(field.DefaultValue, "uuid_generate_v4") {
+			field.DefaultValue = ""
+			field.HasDefaultValue = false
+			field.DefaultValueInterface = nil
+			continue
+		}
+		withDefault = append(withDefault, field)
+	}
+	s.FieldsWithDefaultDBValue = withDefault
+
+	for _, rel := range s.Relationships.Relations {
+		dropUUIDDefaults(rel.FieldSchema, seen)
+	}
+}
+
+// assignUUIDs fills in zero uuid.UUID primary keys before an insert.
+func assignUUIDs(db *gorm.DB) {
+	if db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	if field == nil || field.FieldType != reflect.TypeOf(uuid.UUID{}) {
+		return
+	}
+
+	ctx := db.Statement.Context
+	assign := func(rv reflect.Value) {
+		if _, zero := field.ValueOf(ctx, rv); zero {
+			if err := field.Set(ctx, rv, uuid.New()); err != nil {
+				db.AddError(err)
+			}
+		}
+	}
+
+	rv := reflect.Indirect(db.Statement.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			assign(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		assign(rv)
+	}
+}
@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestForumResponses(t *testing.T) {
+	chat := models.Chat{ID: uuid.New(), Type: models.ChatTypeGroup, IsForum: true}
+	if !chat.ToResponse().IsForum {
+		t.Error("Chat response should report forum mode")
+	}
+
+	topicID := uuid.New()
+	msg := models.Message{ID: uuid.New(), ChatID: chat.ID, TopicID: &topicID}
+	resp := msg.ToResponse()
+	if resp.TopicID == nil || *resp.TopicID != topicID {
+		t.Errorf("Expected topic %s in message response, got %v", topicID, resp.TopicID)
+	}
+
+	plain := models.Message{ID: uuid.New(), ChatID: chat.ID}
+	if plain.ToResponse().TopicID != nil {
+		t.Error("Message outside forum mode should have no topic")
+	}
+}
+
+func TestTopicUnreadCounts(t *testing.T) {
+	db := newSQLiteDB(t, &models.User{}, &models.Chat{}, &models.ChatMember{},
+		&models.Message{}, &models.ChatTopic{}, &models.ChatTopicRead{})
+	svc := services.NewChatService(db, nil)
+
+	reader := models.User{Phone: "+10000000001", PasswordHash: "x"}
+	writer := models.User{Phone: "+10000000002", PasswordHash: "x"}
+	chat := models.Chat{Type: models.ChatTypeGroup, IsForum: true}
+	mustCreate(t, db, &reader)
+	mustCreate(t, db, &writer)
+	mustCreate(t, db, &chat)
+
+	base := time.Now().Add(-time.Hour)
+	mustCreate(t, db, &models.ChatMember{ChatID: chat.ID, UserID: reader.ID, LastReadAt: base})
+	mustCreate(t, db, &models.ChatMember{ChatID: chat.ID, UserID: writer.ID, LastReadAt: base})
+
+	general := models.ChatTopic{ChatID: chat.ID, Name: models.GeneralTopicName, IsGeneral: true}
+	news := models.ChatTopic{ChatID: chat.ID, Name: "News"}
+	quiet := models.ChatTopic{ChatID: chat.ID, Name: "Quiet"}
+	mustCreate(t, db, &general)
+	mustCreate(t, db, &news)
+	mustCreate(t, db, &quiet)
+
+	post := func(topic models.ChatTopic, sender models.User, at time.Duration, deleted bool) {
+		mustCreate(t, db, &models.Message{SenderID: &sender.ID, ChatID: chat.ID, TopicID: &topic.ID,
+			Content: "hi", MessageType: models.MessageTypeText, IsDeleted: deleted, CreatedAt: base.Add(at)})
+	}
+	// General: two unread from the writer, plus the reader's own message and
+	// a deleted one, neither of which counts.
+	post(general, writer, time.Minute, false)
+	post(general, writer, 2*time.Minute, false)
+	post(general, reader, 3*time.Minute, false)
+	post(general, writer, 4*time.Minute, true)
+	// News: read up to the second message, so only the third is unread.
+	post(news, writer, time.Minute, false)
+	post(news, writer, 2*time.Minute, false)
+	post(news, writer, 3*time.Minute, false)
+	mustCreate(t, db, &models.ChatTopicRead{TopicID: news.ID, UserID: reader.ID, LastReadAt: base.Add(150 * time.Second)})
+	// Quiet: only a message from before the chat-wide read mark.
+	post(quiet, writer, -time.Minute, false)
+
+	topics, err := svc.GetTopicsWithUnread(context.Background(), chat.ID, reader.ID)
+	if err != nil {
+		t.Fatalf("GetTopicsWithUnread: %v", err)
+	}
+	want := map[uuid.UUID]int64{general.ID: 2, news.ID: 1, quiet.ID: 0}
+	if len(topics) != len(want) {
+		t.Fatalf("got %d topics, want %d", len(topics), len(want))
+	}
+	if !topics[0].IsGeneral {
+		t.Errorf("first topic is %q, want the general topic", topics[0].Name)
+	}
+	for _, topic := range topics {
+		if topic.UnreadCount != want[topic.ID] {
+			t.Errorf("topic %q: unread %d, want %d", topic.Name, topic.UnreadCount, want[topic.ID])
+		}
+	}
+
+	writerTopics, err := svc.GetTopicsWithUnread(context.Background(), chat.ID, writer.ID)
+	if err != nil {
+		t.Fatalf("GetTopicsWithUnread: %v", err)
+	}
+	for _, topic := range writerTopics {
+		if topic.ID == general.ID && topic.UnreadCount != 1 {
+			t.Errorf("writer's general topic: unread %d, want 1", topic.UnreadCount)
+		}
+	}
+}
+
+func TestResolveMessageTopic(t *testing.T) {
+	db := newSQLiteDB(t, &models.Chat{}, &models.ChatMember{}, &models.ChatTopic{})
+	svc := services.NewChatService(db, nil)
+	ctx := context.Background()
+
+	forum := models.Chat{Type: models.ChatTypeGroup, IsForum: true}
+	other := models.Chat{Type: models.ChatTypeGroup, IsForum: true}
+	plain := models.Chat{Type: models.ChatTypeGroup}
+	mustCreate(t, db, &forum)
+	mustCreate(t, db, &other)
+	mustCreate(t, db, &plain)
+
+	general := models.ChatTopic{ChatID: forum.ID, Name: models.GeneralTopicName, IsGeneral: true}
+	open := models.ChatTopic{ChatID: forum.ID, Name: "Open"}
+	closed := models.ChatTopic{ChatID: forum.ID, Name: "Closed", IsClosed: true}
+	foreign := models.ChatTopic{ChatID: other.ID, Name: "Elsewhere"}
+	for _, topic := range []*models.ChatTopic{&general, &open, &closed, &foreign} {
+		mustCreate(t, db, topic)
+	}
+
+	member := &models.ChatMember{ChatID: forum.ID, UserID: uuid.New(), Role: models.MemberRoleMember}
+	admin := &models.ChatMember{ChatID: forum.ID, UserID: uuid.New(), Role: models.MemberRoleAdmin}
+	ref := func(id uuid.UUID) *string {
+		s := id.String()
+		return &s
+	}
+	empty := ""
+
+	tests := []struct {
+		name    string
+		member  *models.ChatMember
+		topicID *string
+		want    uuid.UUID
+		wantErr string
+	}{
+		{"default topic", member, nil, general.ID, ""},
+		{"empty topic", member, &empty, general.ID, ""},
+		{"chosen topic", member, ref(open.ID), open.ID, ""},
+		{"closed topic", member, ref(closed.ID), uuid.Nil, "closed"},
+		{"closed topic as admin", admin, ref(closed.ID), closed.ID, ""},
+		{"topic from another chat", member, ref(foreign.ID), uuid.Nil, "not found"},
+		{"unknown topic", member, ref(uuid.New()), uuid.Nil, "not found"},
+		{"malformed topic", member, func() *string { s := "nope"; return &s }(), uuid.Nil, "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.ResolveMessageTopic(ctx, tt.member, tt.topicID)
+			switch tt.wantErr {
+			case "":
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got == nil || *got != tt.want {
+					t.Errorf("got topic %v, want %s", got, tt.want)
+				}
+			case "closed":
+				var restricted *services.PostingRestrictedError
+				if !errors.As(err, &restricted) {
+					t.Errorf("got %v, want a posting restriction", err)
+				}
+			case "not found":
+				if !errors.Is(err, services.ErrTopicNotFound) {
+					t.Errorf("got %v, want ErrTopicNotFound", err)
+				}
+			}
+		})
+	}
+
+	got, err := svc.ResolveMessageTopic(ctx, &models.ChatMember{ChatID: plain.ID, UserID: member.UserID}, ref(open.ID))
+	if err != nil || got != nil {
+		t.Errorf("outside forum mode got topic %v (%v), want none", got, err)
+	}
+}
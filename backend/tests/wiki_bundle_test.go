@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestWikiBundleRoundTrip(t *testing.T) {
+	rootID, childID := uuid.New(), uuid.New()
+	pages := []models.WikiPage{
+		{ID: childID, Slug: "install", Title: "Install", Content: "Run the installer.\n", ParentID: &rootID, IsPublished: false, Order: 2},
+		{ID: rootID, Slug: "setup", Title: `Setup "guide"`, Content: "# Setup\n\nSee [[install]].\n", IsPublished: true, Order: 1},
+	}
+	revisions := map[uuid.UUID][]models.WikiRevision{
+		rootID: {{PageID: rootID, RevisionNumber: 1, Title: "Setup", Content: "old"}},
+	}
+
+	for _, format := range []string{services.WikiBundleZip, services.WikiBundleTar} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := services.WriteWikiBundle(&buf, format, pages, revisions); err != nil {
+				t.Fatalf("WriteWikiBundle: %v", err)
+			}
+
+			files, err := services.ExtractWikiBundle("wiki."+format, buf.Bytes())
+			if err != nil {
+				t.Fatalf("ExtractWikiBundle: %v", err)
+			}
+			paths := make(map[string]bool)
+			for _, f := range files {
+				paths[f.Path] = true
+			}
+			for _, want := range []string{"setup.md", "setup/install.md", ".revisions/setup/1.md"} {
+				if !paths[want] {
+					t.Errorf("bundle is missing %s (have %v)", want, paths)
+				}
+			}
+
+			got, err := services.ReadWikiBundle(files)
+			if err != nil {
+				t.Fatalf("ReadWikiBundle: %v", err)
+			}
+			if len(got) != 2 {
+				t.Fatalf("read %d pages, want 2: %+v", len(got), got)
+			}
+
+			bySlug := make(map[string]services.WikiBundlePage)
+			for _, p := range got {
+				bySlug[p.Slug] = p
+			}
+			setup, install := bySlug["setup"], bySlug["install"]
+			if setup.Title != `Setup "guide"` || setup.Content != pages[1].Content || setup.Order != 1 || !setup.IsPublished || setup.ParentSlug != "" {
+				t.Errorf("setup = %+v", setup)
+			}
+			if install.Title != "Install" || install.Content != pages[0].Content || install.Order != 2 || install.IsPublished || install.ParentSlug != "setup" {
+				t.Errorf("install = %+v", install)
+			}
+			if len(setup.Revisions) != 1 || setup.Revisions[0].Title != "Setup" || setup.Revisions[0].Content != "old" {
+				t.Errorf("setup revisions = %+v", setup.Revisions)
+			}
+			if len(install.Revisions) != 0 {
+				t.Errorf("install revisions = %+v, want none", install.Revisions)
+			}
+		})
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	fields, body := services.ParseFrontMatter("---\ntitle: \"A: B\"\norder: 3\n---\nBody\n")
+	if fields["title"] != "A: B" || fields["order"] != "3" {
+		t.Errorf("fields = %v", fields)
+	}
+	if body != "Body\n" {
+		t.Errorf("body = %q", body)
+	}
+
+	fields, body = services.ParseFrontMatter("No front-matter\n---\n")
+	if len(fields) != 0 || body != "No front-matter\n---\n" {
+		t.Errorf("got %v, %q", fields, body)
+	}
+}
+
+func TestReadWikiBundleFolder(t *testing.T) {
+	files := []services.WikiBundleFile{
+		{Path: "docs/guide/README.md", Data: []byte("# The Guide\n\nIntro.")},
+		{Path: "docs/guide/first-steps.md", Data: []byte("Step one.")},
+		{Path: "docs/guide/advanced/tuning.md", Data: []byte("# Tuning")},
+		{Path: "docs/FAQ.md", Data: []byte("Questions.")},
+		{Path: "docs/.hidden.md", Data: []byte("skip me")},
+	}
+
+	pages, err := services.ReadWikiBundle(files)
+	if err != nil {
+		t.Fatalf("ReadWikiBundle: %v", err)
+	}
+
+	want := map[string]struct{ title, parent string }{
+		"guide":       {"The Guide", ""},
+		"first-steps": {"first-steps", "guide"},
+		"tuning":      {"Tuning", "guide"},
+		"FAQ":         {"FAQ", ""},
+	}
+	if len(pages) != len(want) {
+		t.Fatalf("read %d pages, want %d: %+v", len(pages), len(want), pages)
+	}
+	for _, p := range pages {
+		w, ok := want[p.Slug]
+		if !ok {
+			t.Errorf("unexpected page %q", p.Slug)
+			continue
+		}
+		if p.Title != w.title || p.ParentSlug != w.parent {
+			t.Errorf("%s: title %q parent %q, want %q %q", p.Slug, p.Title, p.ParentSlug, w.title, w.parent)
+		}
+	}
+}
+
+func TestReadWikiBundleDuplicateSlug(t *testing.T) {
+	files := []services.WikiBundleFile{
+		{Path: "a/notes.md", Data: []byte("one")},
+		{Path: "b/notes.md", Data: []byte("two")},
+	}
+	if _, err := services.ReadWikiBundle(files); !errors.Is(err, services.ErrDuplicateWikiSlug) {
+		t.Fatalf("err = %v, want ErrDuplicateWikiSlug", err)
+	}
+}
+
+func TestReadWikiBundleRevisions(t *testing.T) {
+	author := uuid.New()
+	files := []services.WikiBundleFile{
+		{Path: "wiki/setup.md", Data: []byte("---\ntitle: \"Setup\"\n---\n\nNow.\n")},
+		{Path: "wiki/setup/install.md", Data: []byte("Install.")},
+		{Path: "wiki/.revisions/setup/2.md", Data: []byte("---\ntitle: \"Setup v2\"\nrevision: 2\nauthor: \"" + author.String() +
+			"\"\nsummary: \"Second\"\ncreated_at: \"2024-01-02T03:04:05Z\"\n---\n\nThen.\n")},
+		{Path: "wiki/.revisions/setup/1.md", Data: []byte("---\ntitle: \"Setup v1\"\nrevision: 1\n---\n\nFirst.\n")},
+		{Path: "wiki/.revisions/setup/install/1.md", Data: []byte("Old install.")},
+		{Path: "wiki/.revisions/notes.txt", Data: []byte("not a revision")},
+	}
+
+	pages, err := services.ReadWikiBundle(files)
+	if err != nil {
+		t.Fatalf("ReadWikiBundle: %v", err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("read %d pages, want 2: %+v", len(pages), pages)
+	}
+
+	bySlug := make(map[string]services.WikiBundlePage)
+	for _, p := range pages {
+		bySlug[p.Slug] = p
+	}
+	history := bySlug["setup"].Revisions
+	if len(history) != 2 {
+		t.Fatalf("setup has %d revisions, want 2: %+v", len(history), history)
+	}
+	if history[0].Number != 1 || history[0].Title != "Setup v1" || history[0].Content != "First.\n" {
+		t.Errorf("first revision = %+v", history[0])
+	}
+	second := history[1]
+	if second.Number != 2 || second.Summary != "Second" || second.AuthorID != author ||
+		!second.CreatedAt.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("second revision = %+v", second)
+	}
+	if got := bySlug["install"].Revisions; len(got) != 1 || got[0].Content != "Old install." {
+		t.Errorf("install revisions = %+v", got)
+	}
+}
+
+func TestReadWikiBundleTruncatesOnCharacters(t *testing.T) {
+	long := strings.Repeat("ж", 300)
+	files := []services.WikiBundleFile{
+		{Path: "page.md", Data: []byte("---\ntitle: \"" + long + "\"\nslug: \"" + long + "\"\n---\n\nBody")},
+	}
+
+	pages, err := services.ReadWikiBundle(files)
+	if err != nil {
+		t.Fatalf("ReadWikiBundle: %v", err)
+	}
+	page := pages[0]
+	if page.Title != strings.Repeat("ж", 255) || !utf8.ValidString(page.Title) {
+		t.Errorf("title has %d characters, want 255", utf8.RuneCountInString(page.Title))
+	}
+	if page.Slug != strings.Repeat("ж", 255) || !utf8.ValidString(page.Slug) {
+		t.Errorf("slug has %d characters, want 255", utf8.RuneCountInString(page.Slug))
+	}
+}
+
+func TestReadWikiBundleAvoidsReservedSlugs(t *testing.T) {
+	files := []services.WikiBundleFile{
+		{Path: "search.md", Data: []byte("# Search\n\nHow to search")},
+		{Path: "other.md", Data: []byte("---\nslug: watch\n---\n\nBody")},
+	}
+
+	pages, err := services.ReadWikiBundle(files)
+	if err != nil {
+		t.Fatalf("ReadWikiBundle: %v", err)
+	}
+	slugs := map[string]bool{}
+	for _, page := range pages {
+		slugs[page.Slug] = true
+	}
+	if !slugs["search-page"] || !slugs["watch-page"] || len(slugs) != 2 {
+		t.Errorf("got slugs %v, want search-page and watch-page", slugs)
+	}
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/messenger/backend/internal/models"
+)
+
+func TestWikiPermissionsGrant(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		want        models.WikiPermissions
+	}{
+		{"none", nil, models.WikiPermissions{}},
+		{"unrelated", []string{"post", "moderate"}, models.WikiPermissions{}},
+		{"view", []string{models.WikiPermissionView}, models.WikiPermissions{View: true}},
+		{"edit implies view", []string{models.WikiPermissionEdit}, models.WikiPermissions{View: true, Edit: true}},
+		{
+			"combined",
+			[]string{models.WikiPermissionCreate, models.WikiPermissionPublish},
+			models.WikiPermissions{View: true, Create: true, Publish: true},
+		},
+		{"admin", []string{"admin"}, models.FullWikiPermissions()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got models.WikiPermissions
+			got.Grant(tt.permissions)
+			if got != tt.want {
+				t.Errorf("Grant(%v) = %+v, want %+v", tt.permissions, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWikiPagePermissions(t *testing.T) {
+	published := &models.WikiPage{IsPublished: true}
+	draft := &models.WikiPage{}
+	protected := &models.WikiPage{IsPublished: true, IsProtected: true}
+
+	reader := models.WikiPermissions{View: true}
+	editor := models.WikiPermissions{View: true, Edit: true, Delete: true}
+	admin := models.FullWikiPermissions()
+
+	if !reader.CanViewPage(published) || reader.CanViewPage(draft) {
+		t.Error("readers should see published pages but not drafts")
+	}
+	if !editor.CanViewPage(draft) {
+		t.Error("editors should see drafts")
+	}
+	if reader.CanEditPage(published) {
+		t.Error("readers should not edit pages")
+	}
+	if !editor.CanEditPage(published) || editor.CanEditPage(protected) {
+		t.Error("editors should edit unprotected pages only")
+	}
+	if editor.CanDeletePage(protected) {
+		t.Error("editors should not delete protected pages")
+	}
+	if !admin.CanEditPage(protected) || !admin.CanDeletePage(protected) {
+		t.Error("admins should change protected pages")
+	}
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/pkg/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestWikiSearchTerms(t *testing.T) {
+	got := services.WikiSearchTerms(`Deploy "staging server" -prod or Deploy`)
+	want := []string{"deploy", "staging", "server"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WikiSearchTerms = %v, want %v", got, want)
+	}
+}
+
+func TestHighlightWikiText(t *testing.T) {
+	got := services.HighlightWikiText("Deploying <the> deploy script", []string{"deploy"})
+	want := "Deploying &lt;the&gt; <mark>deploy</mark> script"
+	if got != want {
+		t.Errorf("HighlightWikiText = %q, want %q", got, want)
+	}
+}
+
+func TestWikiSnippet(t *testing.T) {
+	filler := strings.Repeat("lorem ipsum dolor ", 20)
+	content := filler + "Run the deploy\n\nscript on the staging host. " + filler
+
+	got := services.WikiSnippet(content, []string{"deploy", "staging"})
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("snippet should be cut on both ends: %q", got)
+	}
+	if !strings.Contains(got, "<mark>deploy</mark> script on the <mark>staging</mark> host") {
+		t.Errorf("snippet misses the matches: %q", got)
+	}
+	if n := len([]rune(got)); n > 200 {
+		t.Errorf("snippet is %d characters long", n)
+	}
+
+	if got := services.WikiSnippet("short page", []string{"missing"}); got != "short page" {
+		t.Errorf("snippet without matches = %q", got)
+	}
+}
+
+func TestRankWikiPage(t *testing.T) {
+	terms := []string{"deploy"}
+	inTitle := services.RankWikiPage("Deploy", "How we ship.", terms)
+	inContent := services.RankWikiPage("Shipping", "How we deploy.", terms)
+	if inTitle <= inContent {
+		t.Errorf("title match ranks %v, content match %v", inTitle, inContent)
+	}
+}
+
+func TestWikiSearchSQLite(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:wikisearch?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	// AutoMigrate cannot create the Postgres uuid defaults on SQLite.
+	if err := db.Exec(`CREATE TABLE wiki_pages (
+		id TEXT PRIMARY KEY, channel_id TEXT NOT NULL, slug TEXT NOT NULL, title TEXT NOT NULL,
+		content TEXT NOT NULL, created_by_id TEXT NOT NULL, parent_id TEXT,
+		is_published NUMERIC DEFAULT true, is_protected NUMERIC DEFAULT false, "order" INTEGER DEFAULT 0, created_at DATETIME, updated_at DATETIME)`).Error; err != nil {
+		t.Fatalf("failed to create wiki_pages: %v", err)
+	}
+	if err := database.EnsureWikiSearchIndex(db); err != nil {
+		t.Fatalf("EnsureWikiSearchIndex: %v", err)
+	}
+
+	channelID, otherChannel := uuid.New(), uuid.New()
+	pages := []models.WikiPage{
+		{ID: uuid.New(), ChannelID: channelID, Slug: "deploy", Title: "Deploy", Content: "Run the deploy script.", IsPublished: true},
+		{ID: uuid.New(), ChannelID: channelID, Slug: "notes", Title: "Notes", Content: "Remember to deploy on Fridays.", IsPublished: true},
+		{ID: uuid.New(), ChannelID: channelID, Slug: "draft", Title: "Deploy draft", Content: "Unfinished."},
+		{ID: uuid.New(), ChannelID: otherChannel, Slug: "deploy", Title: "Deploy", Content: "Elsewhere.", IsPublished: true},
+	}
+	for i := range pages {
+		if err := db.Create(&pages[i]).Error; err != nil {
+			t.Fatalf("failed to create page: %v", err)
+		}
+	}
+	if err := db.Model(&pages[2]).Update("is_published", false).Error; err != nil {
+		t.Fatalf("failed to unpublish draft: %v", err)
+	}
+
+	search := services.NewWikiSearchService(db)
+	slugs := func(opts services.WikiSearchOptions) []string {
+		t.Helper()
+		hits, total, err := search.Search(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		if int(total) != len(hits) {
+			t.Errorf("total %d, got %d hits", total, len(hits))
+		}
+		out := make([]string, len(hits))
+		for i := range hits {
+			out[i] = hits[i].Page.Slug
+		}
+		return out
+	}
+
+	got := slugs(services.WikiSearchOptions{Query: "deploy", ChannelIDs: []uuid.UUID{channelID}, Limit: 20})
+	if want := []string{"deploy", "notes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reader results = %v, want %v", got, want)
+	}
+
+	drafts := false
+	got = slugs(services.WikiSearchOptions{Query: "deploy", ChannelIDs: []uuid.UUID{channelID}, DraftChannelIDs: []uuid.UUID{channelID}, Published: &drafts, Limit: 20})
+	if want := []string{"draft"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("editor draft results = %v, want %v", got, want)
+	}
+
+	if err := db.Model(&pages[1]).Update("content", "Nothing relevant.").Error; err != nil {
+		t.Fatalf("failed to update page: %v", err)
+	}
+	got = slugs(services.WikiSearchOptions{Query: "deploy fridays", ChannelIDs: []uuid.UUID{channelID}, Limit: 20})
+	if len(got) != 0 {
+		t.Errorf("stale index: results = %v", got)
+	}
+}
@@ -0,0 +1,32 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestExpandWikiTemplate(t *testing.T) {
+	now := time.Date(2024, 3, 5, 14, 7, 0, 0, time.UTC)
+	vars := services.WikiTemplateVars(now, "alice", "Weekly sync")
+
+	got := services.ExpandWikiTemplate("# {{title}}\n_{{ author }} on {{date}} at {{time}}_\n{{unknown}}", vars)
+	want := "# Weekly sync\n_alice on 2024-03-05 at 14:07_\n{{unknown}}"
+	if got != want {
+		t.Errorf("ExpandWikiTemplate = %q, want %q", got, want)
+	}
+}
+
+func TestWikiAttachmentMarkdown(t *testing.T) {
+	image := models.WikiAttachment{FileName: "flow chart.png", MimeType: "image/png"}
+	if got, want := image.ToResponse().Markdown, "![flow chart.png](attachment:flow%20chart.png)"; got != want {
+		t.Errorf("image markdown = %q, want %q", got, want)
+	}
+
+	doc := models.WikiAttachment{FileName: "spec [v2].pdf", MimeType: "application/pdf"}
+	if got, want := doc.ToResponse().Markdown, `[spec \[v2\].pdf](attachment:spec%20%5Bv2%5D.pdf)`; got != want {
+		t.Errorf("document markdown = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,277 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+// newWikiTestDB returns a database with a channel and the user who owns it.
+func newWikiTestDB(t *testing.T) (*gorm.DB, models.Channel, models.User) {
+	t.Helper()
+	db := newSQLiteDB(t, &models.User{}, &models.Channel{}, &models.WikiPage{},
+		&models.WikiRevision{}, &models.WikiRedirect{}, &models.WikiLink{})
+
+	owner := models.User{Phone: "+10000000001", PasswordHash: "x"}
+	mustCreate(t, db, &owner)
+	channel := models.Channel{Name: "Docs", OwnerID: owner.ID}
+	mustCreate(t, db, &channel)
+	return db, channel, owner
+}
+
+func newWikiPage(t *testing.T, db *gorm.DB, channel models.Channel, owner models.User, slug string, parent *models.WikiPage) models.WikiPage {
+	t.Helper()
+	page := models.WikiPage{ChannelID: channel.ID, Slug: slug, Title: slug, Content: slug, CreatedByID: owner.ID}
+	if parent != nil {
+		page.ParentID = &parent.ID
+	}
+	mustCreate(t, db, &page)
+	return page
+}
+
+func TestCheckWikiParent(t *testing.T) {
+	db, channel, owner := newWikiTestDB(t)
+
+	root := newWikiPage(t, db, channel, owner, "root", nil)
+	child := newWikiPage(t, db, channel, owner, "child", &root)
+	grandchild := newWikiPage(t, db, channel, owner, "grandchild", &child)
+	other := newWikiPage(t, db, channel, owner, "other", nil)
+
+	otherChannel := models.Channel{Name: "Elsewhere", OwnerID: owner.ID}
+	mustCreate(t, db, &otherChannel)
+	foreign := newWikiPage(t, db, otherChannel, owner, "foreign", nil)
+
+	tests := []struct {
+		name    string
+		page    models.WikiPage
+		parent  uuid.UUID
+		wantErr error
+	}{
+		{"self", root, root.ID, services.ErrWikiParentCycle},
+		{"own child", root, child.ID, services.ErrWikiParentCycle},
+		{"own descendant", root, grandchild.ID, services.ErrWikiParentCycle},
+		{"unrelated page", root, other.ID, nil},
+		{"under an ancestor", grandchild, root.ID, nil},
+		{"page of another channel", other, foreign.ID, services.ErrWikiParentNotFound},
+		{"missing page", other, uuid.New(), services.ErrWikiParentNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent, err := services.CheckWikiParent(db, &tt.page, tt.parent)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parent.ID != tt.parent {
+				t.Errorf("got parent %s, want %s", parent.ID, tt.parent)
+			}
+		})
+	}
+}
+
+func TestWikiRedirectChains(t *testing.T) {
+	db, channel, owner := newWikiTestDB(t)
+	svc := services.NewChannelService(db, nil)
+	ctx := context.Background()
+
+	page := newWikiPage(t, db, channel, owner, "intro", nil)
+	for _, slug := range []string{"start", "begin"} {
+		if err := services.RenameWikiPage(db, &page, slug); err != nil {
+			t.Fatalf("rename to %s: %v", slug, err)
+		}
+	}
+
+	for _, slug := range []string{"intro", "start"} {
+		target, err := svc.WikiRedirectTarget(ctx, channel.ID, slug)
+		if err != nil {
+			t.Fatalf("%s: %v", slug, err)
+		}
+		if target == nil || target.ID != page.ID || target.Slug != "begin" {
+			t.Errorf("%s redirects to %+v, want the page at begin", slug, target)
+		}
+	}
+
+	// Taking an old slug back drops its redirect and redirects the slug
+	// given up instead.
+	if err := services.RenameWikiPage(db, &page, "intro"); err != nil {
+		t.Fatal(err)
+	}
+	var redirect models.WikiRedirect
+	if err := db.Where("channel_id = ? AND slug = ?", channel.ID, "intro").First(&redirect).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("redirect from intro should be gone, got %+v (%v)", redirect, err)
+	}
+	for _, slug := range []string{"start", "begin"} {
+		target, err := svc.WikiRedirectTarget(ctx, channel.ID, slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target == nil || target.Slug != "intro" {
+			t.Errorf("%s redirects to %+v, want the page at intro", slug, target)
+		}
+	}
+
+	otherChannel := models.Channel{Name: "Elsewhere", OwnerID: owner.ID}
+	mustCreate(t, db, &otherChannel)
+	if target, err := svc.WikiRedirectTarget(ctx, otherChannel.ID, "start"); err != nil || target != nil {
+		t.Errorf("redirect leaked into another channel: %+v (%v)", target, err)
+	}
+	if target, err := svc.WikiRedirectTarget(ctx, channel.ID, "never"); err != nil || target != nil {
+		t.Errorf("unknown slug redirects to %+v (%v)", target, err)
+	}
+}
+
+func TestReorderWikiPages(t *testing.T) {
+	db, channel, owner := newWikiTestDB(t)
+	svc := services.NewChannelService(db, nil)
+	ctx := context.Background()
+
+	parent := newWikiPage(t, db, channel, owner, "parent", nil)
+	a := newWikiPage(t, db, channel, owner, "a", &parent)
+	b := newWikiPage(t, db, channel, owner, "b", &parent)
+	c := newWikiPage(t, db, channel, owner, "c", &parent)
+	elsewhere := newWikiPage(t, db, channel, owner, "elsewhere", nil)
+
+	otherChannel := models.Channel{Name: "Elsewhere", OwnerID: owner.ID}
+	mustCreate(t, db, &otherChannel)
+	foreign := newWikiPage(t, db, otherChannel, owner, "foreign", nil)
+	db.Model(&foreign).Update("parent_id", parent.ID)
+
+	ordered, err := svc.ReorderWikiPages(ctx, channel.ID, &parent.ID, []uuid.UUID{c.ID, a.ID})
+	if err != nil {
+		t.Fatalf("ReorderWikiPages: %v", err)
+	}
+	wantOrder := []uuid.UUID{c.ID, a.ID, b.ID}
+	if len(ordered) != len(wantOrder) {
+		t.Fatalf("got %d pages, want %d", len(ordered), len(wantOrder))
+	}
+	for i, id := range wantOrder {
+		var stored models.WikiPage
+		if err := db.First(&stored, "id = ?", id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if ordered[i].ID != id || stored.Order != i {
+			t.Errorf("position %d: got %s with stored order %d, want %s", i, ordered[i].Slug, stored.Order, id)
+		}
+	}
+
+	for name, ids := range map[string][]uuid.UUID{
+		"page of another channel": {foreign.ID, a.ID},
+		"page of another parent":  {elsewhere.ID},
+		"missing page":            {a.ID, uuid.New()},
+		"repeated page":           {a.ID, a.ID},
+	} {
+		if _, err := svc.ReorderWikiPages(ctx, channel.ID, &parent.ID, ids); !errors.Is(err, services.ErrWikiNotSibling) {
+			t.Errorf("%s: got %v, want ErrWikiNotSibling", name, err)
+		}
+	}
+
+	var stored models.WikiPage
+	if err := db.First(&stored, "id = ?", a.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Order != 1 {
+		t.Errorf("a failed reorder changed the order of a to %d", stored.Order)
+	}
+
+	if ordered, err := svc.ReorderWikiPages(ctx, channel.ID, nil, []uuid.UUID{elsewhere.ID}); err != nil || ordered[0].ID != elsewhere.ID {
+		t.Errorf("reordering the top level: %v", err)
+	}
+}
+
+func TestRewriteWikiLinksSkipsProtectedPages(t *testing.T) {
+	db, channel, owner := newWikiTestDB(t)
+
+	target := newWikiPage(t, db, channel, owner, "old", nil)
+	open := models.WikiPage{ChannelID: channel.ID, Slug: "open", Title: "Open", Content: "See [[old]].", CreatedByID: owner.ID}
+	locked := models.WikiPage{ChannelID: channel.ID, Slug: "locked", Title: "Locked", Content: "See [[old]].", CreatedByID: owner.ID, IsProtected: true}
+	for _, page := range []*models.WikiPage{&open, &locked} {
+		mustCreate(t, db, page)
+		if err := services.SyncWikiLinks(db, page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	editor := models.WikiPermissions{View: true, Edit: true}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := services.RenameWikiPage(tx, &target, "new"); err != nil {
+			return err
+		}
+		rewritten, skipped, err := services.RewriteWikiLinks(tx, &target, "old", owner.ID, editor)
+		if err != nil {
+			return err
+		}
+		if len(rewritten) != 1 || rewritten[0].ID != open.ID {
+			t.Errorf("rewritten = %+v, want only the open page", rewritten)
+		}
+		if len(skipped) != 1 || skipped[0].ID != locked.ID {
+			t.Errorf("skipped = %+v, want only the protected page", skipped)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := func(id uuid.UUID) string {
+		var stored models.WikiPage
+		if err := db.First(&stored, "id = ?", id).Error; err != nil {
+			t.Fatal(err)
+		}
+		return stored.Content
+	}
+	if got := content(open.ID); got != "See [[new]]." {
+		t.Errorf("open page content = %q", got)
+	}
+	if got := content(locked.ID); got != "See [[old]]." {
+		t.Errorf("protected page was rewritten to %q", got)
+	}
+	var revisions int64
+	db.Model(&models.WikiRevision{}).Where("page_id = ?", locked.ID).Count(&revisions)
+	if revisions != 0 {
+		t.Errorf("protected page got %d revisions", revisions)
+	}
+
+	// A wiki admin rewrites the links the editor had to leave.
+	err = db.Transaction(func(tx *gorm.DB) error {
+		rewritten, skipped, err := services.RewriteWikiLinks(tx, &target, "old", owner.ID, models.FullWikiPermissions())
+		if err != nil {
+			return err
+		}
+		if len(rewritten) != 1 || rewritten[0].ID != locked.ID || len(skipped) != 0 {
+			t.Errorf("admin rewrite: rewritten %+v, skipped %+v", rewritten, skipped)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := content(locked.ID); got != "See [[new]]." {
+		t.Errorf("protected page content after admin rewrite = %q", got)
+	}
+}
+
+func TestWikiSlugReserved(t *testing.T) {
+	for _, slug := range []string{"search", "permissions", "watch", "templates", "export", "import", "reorder", "tree", "Search"} {
+		if !services.WikiSlugReserved(slug) {
+			t.Errorf("%s is not reserved", slug)
+		}
+		if got := services.UnreservedWikiSlug(slug); got != slug+"-page" {
+			t.Errorf("UnreservedWikiSlug(%q) = %q", slug, got)
+		}
+	}
+	for _, slug := range []string{"searching", "watch-list", "setup"} {
+		if services.WikiSlugReserved(slug) || services.UnreservedWikiSlug(slug) != slug {
+			t.Errorf("%s is reserved", slug)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/messenger/backend/internal/models"
+	"github.com/messenger/backend/internal/services"
+)
+
+func TestWikiDigestContent(t *testing.T) {
+	install, faq := uuid.New(), uuid.New()
+	changes := []models.WikiChange{
+		{PageID: install, Title: "Install", Action: models.WikiChangeCreated, Summary: "Initial creation"},
+		{PageID: faq, Title: "FAQ", Action: models.WikiChangeUpdated, Summary: "Add question"},
+		{PageID: install, Title: "Install", Action: models.WikiChangeUpdated, Summary: "Fix typo"},
+		{PageID: install, Title: "Installation", Action: models.WikiChangeUpdated},
+		{PageID: faq, Title: "FAQ", Action: models.WikiChangeDeleted},
+	}
+
+	got := services.WikiDigestContent(changes)
+	want := "Wiki updates:\n" +
+		"• Installation: created, updated 2 times\n" +
+		"• FAQ: updated, deleted"
+	if got != want {
+		t.Errorf("WikiDigestContent =\n%s\nwant\n%s", got, want)
+	}
+}